@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ==================== 节点间延迟测量 ====================
+//
+// 节点被管理员选入延迟测量后，定期测量到其他测量节点的 TCP 握手延迟并上报面板
+
+// latencyPeers 面板下发的测量任务
+type latencyPeers struct {
+	Interval int `json:"interval"`
+	Timeout  int `json:"timeout"`
+	Count    int `json:"count"`
+	Peers    []struct {
+		NodeID uint   `json:"node_id"`
+		Addr   string `json:"addr"`
+	} `json:"peers"`
+}
+
+// latencyReport 到一个节点的测量结果
+type latencyReport struct {
+	NodeID  uint    `json:"node_id"`
+	Latency int     `json:"latency"`
+	Loss    float64 `json:"loss"`
+}
+
+func (a *Agent) latencyLoop() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	var lastRun time.Time
+	for range ticker.C {
+		if a.stopping.Load() {
+			return
+		}
+
+		// 每次检查都刷新任务，节点加入或退出测量后最多 30 秒生效
+		peers, err := a.fetchLatencyPeers()
+		if err != nil {
+			log.Printf("Fetch latency peers failed: %v", err)
+			continue
+		}
+		if len(peers.Peers) == 0 || time.Since(lastRun) < time.Duration(peers.Interval)*time.Second {
+			continue
+		}
+		lastRun = time.Now()
+
+		reports := make([]latencyReport, len(peers.Peers))
+		var wg sync.WaitGroup
+		for i, peer := range peers.Peers {
+			wg.Add(1)
+			go func(i int, nodeID uint, addr string) {
+				defer wg.Done()
+				spec := &probeSpec{Addr: addr, Timeout: peers.Timeout, Count: peers.Count}
+				result := runProbe(spec)
+				reports[i] = latencyReport{NodeID: nodeID, Latency: result.Latency, Loss: result.Loss}
+			}(i, peer.NodeID, peer.Addr)
+		}
+		wg.Wait()
+
+		if err := a.sendLatencyResults(reports); err != nil {
+			log.Printf("Report latency results failed: %v", err)
+		}
+	}
+}
+
+func (a *Agent) fetchLatencyPeers() (*latencyPeers, error) {
+	resp, err := a.client.Get(a.panelURL + "/agent/peers/" + a.token)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var peers latencyPeers
+	if err := json.NewDecoder(resp.Body).Decode(&peers); err != nil {
+		return nil, err
+	}
+	return &peers, nil
+}
+
+func (a *Agent) sendLatencyResults(reports []latencyReport) error {
+	body, _ := json.Marshal(map[string]interface{}{"results": reports})
+	resp, err := a.client.Post(a.panelURL+"/agent/peers/"+a.token, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -6,7 +6,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"io"
@@ -117,6 +120,12 @@ func (a *Agent) Run() error {
 	// 启动心跳
 	go a.heartbeatLoop()
 
+	// 启动路径探测
+	go a.probeLoop()
+
+	// 启动节点间延迟测量
+	go a.latencyLoop()
+
 	// 启动更新检查
 	if a.autoUpdate {
 		go a.updateCheckLoop()
@@ -152,6 +161,74 @@ func (a *Agent) register() error {
 		return fmt.Errorf("register failed: %s", string(respBody))
 	}
 
+	var result struct {
+		TLS *TLSBundle `json:"tls"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode register response: %w", err)
+	}
+
+	// 写入面板内部 CA 签发的节点证书 (用于节点间 mTLS)，面板未下发节点证书时删除已撤销的旧证书
+	bundle := result.TLS
+	if bundle == nil {
+		bundle = &TLSBundle{}
+	}
+	if err := writeTLSBundle(bundle); err != nil {
+		return fmt.Errorf("write certificates failed: %w", err)
+	}
+
+	return nil
+}
+
+// TLSBundle 面板下发的节点证书
+type TLSBundle struct {
+	CACert string `json:"ca_cert"`
+	Cert   string `json:"cert"`
+	Key    string `json:"key"`
+	Serial string `json:"serial"`
+	// 域名暴露入口终止 TLS 使用的证书 (可选)
+	ExposureCert string `json:"exposure_cert"`
+	ExposureKey  string `json:"exposure_key"`
+}
+
+// 证书存放路径，需与面板生成的 GOST 配置保持一致
+const certDir = "/etc/gost/certs"
+
+// writeTLSBundle 写入证书文件 (用户自有节点没有节点证书，只有域名暴露证书)
+func writeTLSBundle(bundle *TLSBundle) error {
+	if bundle.ExposureCert != "" && bundle.ExposureKey != "" {
+		if err := os.MkdirAll(certDir, 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(certDir, "exposure.crt"), []byte(bundle.ExposureCert), 0644); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(certDir, "exposure.key"), []byte(bundle.ExposureKey), 0600); err != nil {
+			return err
+		}
+	}
+	if bundle.Cert == "" || bundle.Key == "" {
+		// 节点证书已被面板撤销
+		for _, name := range []string{"ca.crt", "node.crt", "node.key"} {
+			if err := os.Remove(filepath.Join(certDir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := os.MkdirAll(certDir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(certDir, "ca.crt"), []byte(bundle.CACert), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(certDir, "node.crt"), []byte(bundle.Cert), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(certDir, "node.key"), []byte(bundle.Key), 0600); err != nil {
+		return err
+	}
+	log.Printf("Node certificate installed (serial: %s)", bundle.Serial)
 	return nil
 }
 
@@ -438,6 +515,7 @@ func (a *Agent) sendHeartbeat() error {
 		"traffic_in":     stats.TrafficIn,
 		"traffic_out":    stats.TrafficOut,
 		"config_hash":    configHash,
+		"cert_serial":    getCertSerial(),
 		"agent_version":  AgentVersion,
 		"service_stats":  serviceStats, // 按服务名分类的统计
 	}
@@ -466,6 +544,14 @@ func (a *Agent) sendHeartbeat() error {
 		return fmt.Errorf("heartbeat failed: status %d", resp.StatusCode)
 	}
 
+	// 证书已轮换: 重新注册获取新证书，再重载配置
+	if renew, ok := result["renew_cert"].(bool); ok && renew {
+		log.Println("Node certificate rotated, fetching new certificate...")
+		if err := a.register(); err != nil {
+			log.Printf("Failed to fetch new certificate: %v", err)
+		}
+	}
+
 	// 检查是否需要重载配置
 	if reload, ok := result["reload_config"].(bool); ok && reload {
 		log.Println("Config update detected, reloading...")
@@ -518,13 +604,31 @@ func (a *Agent) uninstall() {
 	os.Exit(0)
 }
 
-// getConfigHash 获取当前配置文件的修改时间作为版本号
+// getCertSerial 当前安装的节点证书序列号 (与面板记录的格式一致)，面板据此发现手动轮换的证书
+func getCertSerial() string {
+	data, err := os.ReadFile(filepath.Join(certDir, "node.crt"))
+	if err != nil {
+		return ""
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return cert.SerialNumber.Text(16)
+}
+
+// getConfigHash 计算当前配置文件内容的 SHA-256，面板据此判断配置是否已同步
 func (a *Agent) getConfigHash() string {
-	info, err := os.Stat(a.configPath)
+	data, err := os.ReadFile(a.configPath)
 	if err != nil {
 		return ""
 	}
-	return strconv.FormatInt(info.ModTime().Unix(), 10)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 // reloadConfig 重新下载并应用配置
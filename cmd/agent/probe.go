@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== 路径探测 ====================
+//
+// 定期从面板获取本节点的探测任务 (隧道入口经入口端口探测整条路径，出口与代理链跳点直接探测下一跳/目标)，
+// 按各任务的间隔执行后上报结果
+
+// probeSpec 面板下发的探测任务
+type probeSpec struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   uint   `json:"resource_id"`
+	Scope        string `json:"scope"`
+	Type         string `json:"type"`
+	Addr         string `json:"addr"`
+	Network      string `json:"network"`
+	Target       string `json:"target"`
+	Through      bool   `json:"through"`
+	Interval     int    `json:"interval"`
+	Timeout      int    `json:"timeout"`
+	Count        int    `json:"count"`
+}
+
+// key 与面板一致的任务标识
+func (p *probeSpec) key() string {
+	return fmt.Sprintf("%s-%d-%s-%s", p.ResourceType, p.ResourceID, p.Scope, p.Addr)
+}
+
+// probeReport 一轮探测的结果
+type probeReport struct {
+	Key      string  `json:"key"`
+	Attempts int     `json:"attempts"`
+	Loss     float64 `json:"loss"`
+	Latency  int     `json:"latency"`
+	Error    string  `json:"error"`
+}
+
+// probeHold 经转发端口的 TCP 探测在连接后等待的时间: 期间连接被关闭说明路径不通
+const probeHold = 2 * time.Second
+
+func (a *Agent) probeLoop() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	lastRun := make(map[string]time.Time)
+	var specs []probeSpec
+	var fetchedAt time.Time
+	for range ticker.C {
+		if a.stopping.Load() {
+			return
+		}
+		// 任务列表每分钟刷新一次
+		if time.Since(fetchedAt) >= time.Minute {
+			fetched, err := a.fetchProbes()
+			if err != nil {
+				log.Printf("Fetch probes failed: %v", err)
+			} else {
+				specs, fetchedAt = fetched, time.Now()
+			}
+		}
+
+		var due []probeSpec
+		for _, spec := range specs {
+			key := spec.key()
+			if time.Since(lastRun[key]) < time.Duration(spec.Interval)*time.Second {
+				continue
+			}
+			lastRun[key] = time.Now()
+			due = append(due, spec)
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		reports := make([]probeReport, len(due))
+		var wg sync.WaitGroup
+		for i := range due {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				reports[i] = runProbe(&due[i])
+			}(i)
+		}
+		wg.Wait()
+
+		if err := a.sendProbeResults(reports); err != nil {
+			log.Printf("Report probe results failed: %v", err)
+		}
+	}
+}
+
+func (a *Agent) fetchProbes() ([]probeSpec, error) {
+	resp, err := a.client.Get(a.panelURL + "/agent/probes/" + a.token)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Probes []probeSpec `json:"probes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Probes, nil
+}
+
+func (a *Agent) sendProbeResults(reports []probeReport) error {
+	body, _ := json.Marshal(map[string]interface{}{"results": reports})
+	resp, err := a.client.Post(a.panelURL+"/agent/probes/"+a.token, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runProbe 执行一轮探测: 多次尝试，统计失败比例与成功尝试的平均延迟
+func runProbe(spec *probeSpec) probeReport {
+	count := spec.Count
+	if count <= 0 {
+		count = 3
+	}
+	timeout := time.Duration(spec.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	report := probeReport{Key: spec.key(), Attempts: count}
+	failed, measured := 0, 0
+	var total time.Duration
+	for i := 0; i < count; i++ {
+		var latency time.Duration
+		var err error
+		switch spec.Type {
+		case "http":
+			latency, err = probeHTTP(spec, timeout)
+		case "dns":
+			latency, err = probeDNS(spec, timeout)
+		default:
+			latency, err = probeTCP(spec, timeout)
+		}
+		if err != nil {
+			failed++
+			report.Error = err.Error()
+			continue
+		}
+		if latency > 0 {
+			total += latency
+			measured++
+		}
+	}
+
+	report.Loss = float64(failed) / float64(count) * 100
+	if measured > 0 {
+		report.Latency = int((total / time.Duration(measured)).Milliseconds())
+	}
+	return report
+}
+
+// probeTCP TCP 连接探测
+// 直接探测时延迟为建立连接的时间；经转发端口探测时本机连接总会成功，
+// 转发失败表现为连接被关闭，因此连接后等待 probeHold: 收到数据或保持打开视为成功 (收到数据时以首字节时间为延迟)
+func probeTCP(spec *probeSpec, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", spec.Addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if !spec.Through {
+		return time.Since(start), nil
+	}
+
+	conn.SetReadDeadline(time.Now().Add(probeHold))
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return 0, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return 0, errors.New("connection closed by tunnel")
+		}
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// probeHTTP HTTP GET 探测: 连接始终发往 spec.Addr (经转发端口时 URL 中的主机名只用于 Host/SNI)，
+// 5xx 以外的响应视为成功，延迟为收到响应头的时间
+func probeHTTP(spec *probeSpec, timeout time.Duration) (time.Duration, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, spec.Addr)
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Get(spec.Target)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+// probeDNS DNS A 记录查询探测 (UDP 或 TCP)，收到匹配的应答即为成功 (不检查应答码)
+func probeDNS(spec *probeSpec, timeout time.Duration) (time.Duration, error) {
+	id := uint16(rand.Intn(1 << 16))
+	query, err := buildDNSQuery(id, spec.Target)
+	if err != nil {
+		return 0, err
+	}
+	network := spec.Network
+	if network != "udp" {
+		network = "tcp"
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout(network, spec.Addr, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var answer []byte
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return 0, err
+		}
+		buf := make([]byte, 1500)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		answer = buf[:n]
+	} else {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err := conn.Write(msg); err != nil {
+			return 0, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return 0, err
+		}
+		answer = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(answer) < 12 || binary.BigEndian.Uint16(answer) != id || answer[2]&0x80 == 0 {
+		return 0, errors.New("invalid DNS response")
+	}
+	return time.Since(start), nil
+}
+
+// buildDNSQuery 构造递归查询 A 记录的 DNS 请求
+func buildDNSQuery(id uint16, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil, errors.New("empty DNS query name")
+	}
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid DNS name: %s", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 1, 0, 1) // root, QTYPE=A, QCLASS=IN
+	return msg, nil
+}
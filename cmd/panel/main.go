@@ -77,6 +77,18 @@ func main() {
 	// 启动会话清理定时任务
 	go startSessionCleaner(svc)
 
+	// 启动配额重置任务
+	go startQuotaResetter(svc)
+
+	// 启动指标告警评估任务
+	go startAlertEvaluator(svc)
+
+	// 启动流量快照与摘要报告任务
+	go startDigestScheduler(svc)
+
+	// 启动域名暴露证书续期任务
+	go startExposureCertRenewer(svc)
+
 	// 启动 API 服务
 	server := api.NewServer(svc, cfg)
 
@@ -163,3 +175,54 @@ func startSessionCleaner(svc *service.Service) {
 		}
 	}
 }
+
+// startQuotaResetter 启动配额重置定时任务
+func startQuotaResetter(svc *service.Service) {
+	// 每小时检查一次重置日，重置后解除超限处理
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	svc.ResetQuotas()
+
+	for range ticker.C {
+		svc.ResetQuotas()
+	}
+}
+
+// startAlertEvaluator 启动指标告警规则评估、告警升级与用户通知任务
+func startAlertEvaluator(svc *service.Service) {
+	// 每分钟评估一次，与流量历史记录间隔一致
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		svc.GetAlertService().EvaluateMetricRules()
+		svc.GetAlertService().EvaluateEscalations()
+		svc.GetAlertService().EvaluateUserNotifications()
+	}
+}
+
+// startDigestScheduler 启动流量快照记录与摘要报告发送任务
+func startDigestScheduler(svc *service.Service) {
+	// 每分钟检查一次，快照每小时记录一次
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	svc.RecordTrafficSnapshots(time.Now())
+
+	for now := range ticker.C {
+		svc.RecordTrafficSnapshots(now)
+		svc.RunDueDigests(now)
+	}
+}
+
+// startExposureCertRenewer 启动域名暴露证书检查任务 (签发缺失证书、续期即将过期的证书)
+func startExposureCertRenewer(svc *service.Service) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	svc.RenewExposureCertificates()
+	for range ticker.C {
+		svc.RenewExposureCertificates()
+	}
+}
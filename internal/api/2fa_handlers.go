@@ -88,6 +88,7 @@ func (s *Server) login2FA(c *gin.Context) {
 
 	// 记录登录成功
 	s.svc.LogOperation(user.ID, user.Username, "login", "2fa", user.ID, "2FA login success", c.ClientIP(), c.GetHeader("User-Agent"), "success")
+	s.svc.GetAlertService().NotifyUserLogin(&user, c.ClientIP(), c.GetHeader("User-Agent"))
 
 	// 生成正式 JWT（带会话管理）
 	jti := uuid.NewString()
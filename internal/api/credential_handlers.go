@@ -0,0 +1,268 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 用户代理凭据 ====================
+
+// CreateProxyCredentialRequest 创建代理凭据请求
+type CreateProxyCredentialRequest struct {
+	UserID   uint   `json:"user_id"` // 仅管理员可为其他用户创建
+	NodeID   *uint  `json:"node_id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s *Server) listProxyCredentials(c *gin.Context) {
+	userID, isAdmin := getUserInfo(c)
+	creds, err := s.svc.ListProxyCredentials(userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, creds)
+}
+
+func (s *Server) createProxyCredential(c *gin.Context) {
+	var req CreateProxyCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, isAdmin := getUserInfo(c)
+	ownerID := userID
+	if isAdmin && req.UserID > 0 {
+		ownerID = req.UserID
+	}
+
+	// 限定节点时检查节点是否为共享节点
+	if req.NodeID != nil {
+		node, err := s.svc.GetNode(*req.NodeID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "node not found"})
+			return
+		}
+		if !node.SharedAuth || !gost.SharedAuthSupported(node.Protocol) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "该节点未开启共享认证"})
+			return
+		}
+	}
+
+	cred := &model.ProxyCredential{
+		UserID:   ownerID,
+		NodeID:   req.NodeID,
+		Name:     req.Name,
+		Username: req.Username,
+		Password: req.Password,
+		Enabled:  true,
+	}
+	if err := s.svc.CreateProxyCredential(cred); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "proxy_credential", cred.ID, gin.H{"username": cred.Username, "user_id": cred.UserID})
+	c.JSON(http.StatusOK, cred)
+}
+
+func (s *Server) updateProxyCredential(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+
+	if _, err := s.svc.GetProxyCredentialByOwner(uint(id), userID, isAdmin); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+		return
+	}
+
+	var req struct {
+		Name     *string `json:"name"`
+		Password *string `json:"password"`
+		Enabled  *bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Password != nil && *req.Password != "" {
+		updates["password"] = *req.Password
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	if err := s.svc.UpdateProxyCredential(uint(id), updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) deleteProxyCredential(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+
+	if _, err := s.svc.GetProxyCredentialByOwner(uint(id), userID, isAdmin); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+		return
+	}
+
+	if err := s.svc.DeleteProxyCredential(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "proxy_credential", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// resetProxyCredentialPassword 重新生成凭据密码
+func (s *Server) resetProxyCredentialPassword(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+
+	if _, err := s.svc.GetProxyCredentialByOwner(uint(id), userID, isAdmin); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+		return
+	}
+
+	password, err := s.svc.ResetProxyCredentialPassword(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "password": password})
+}
+
+// ==================== GOST 插件回调 (共享节点) ====================
+
+// agentAuthRequest GOST HTTP 认证插件请求
+type agentAuthRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Client   string `json:"client"`
+}
+
+// agentAuth GOST HTTP 认证插件: 校验共享节点上的用户凭据
+func (s *Server) agentAuth(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"ok": false})
+		return
+	}
+
+	var req agentAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{"ok": false})
+		return
+	}
+
+	cred, err := s.svc.AuthenticateProxyCredential(node, req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"ok": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok": true,
+		"id": service.CredentialClientID(cred.ID),
+	})
+}
+
+// agentObserveRequest GOST HTTP 观测插件请求
+type agentObserveRequest struct {
+	Events []struct {
+		Kind    string `json:"kind"`
+		Service string `json:"service"`
+		Client  string `json:"client"`
+		Type    string `json:"type"`
+		Stats   *struct {
+			TotalConns   int64 `json:"totalConns"`
+			CurrentConns int64 `json:"currentConns"`
+			InputBytes   int64 `json:"inputBytes"`
+			OutputBytes  int64 `json:"outputBytes"`
+			TotalErrs    int64 `json:"totalErrs"`
+		} `json:"stats"`
+	} `json:"events"`
+}
+
+// agentObserve GOST HTTP 观测插件: 接收按凭据统计的流量 (增量) 与隧道出口中继的活动情况
+func (s *Server) agentObserve(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"ok": false})
+		return
+	}
+
+	var req agentObserveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ok": false})
+		return
+	}
+
+	for _, event := range req.Events {
+		if event.Type != "stats" || event.Stats == nil {
+			continue
+		}
+		// 隧道出口中继: 按隧道统计出口流量并记录当前承载流量的出口
+		if tunnelID := service.ParseTunnelClientID(event.Client); tunnelID != 0 {
+			s.svc.RecordTunnelExitTraffic(tunnelID, node.ID, event.Stats.InputBytes, event.Stats.OutputBytes, event.Stats.CurrentConns)
+			continue
+		}
+		// 凭据流量只接受共享节点上报，且凭据须可在该节点使用，避免其他节点虚增用户用量
+		credID := service.ParseCredentialClientID(event.Client)
+		if credID == 0 || !node.SharedAuth {
+			continue
+		}
+		cred, err := s.svc.GetProxyCredentialByOwner(credID, 0, true)
+		if err != nil || (cred.NodeID != nil && *cred.NodeID != node.ID) {
+			continue
+		}
+		s.svc.AddCredentialTraffic(credID, event.Stats.InputBytes, event.Stats.OutputBytes)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// agentLimiterRequest GOST HTTP 限速插件请求
+type agentLimiterRequest struct {
+	Scope   string `json:"scope"`
+	Service string `json:"service"`
+	Network string `json:"network"`
+	Addr    string `json:"addr"`
+	Client  string `json:"client"`
+	Src     string `json:"src"`
+}
+
+// agentLimiter GOST HTTP 限速插件: 按凭据所属用户的套餐返回限速值 (bytes/s)
+func (s *Server) agentLimiter(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"in": 0, "out": 0})
+		return
+	}
+
+	var req agentLimiterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"in": 0, "out": 0})
+		return
+	}
+
+	limit := s.svc.GetCredentialSpeedLimit(node, req.Client)
+	c.JSON(http.StatusOK, gin.H{"in": limit, "out": limit})
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 摘要报告 ====================
+
+// DigestScheduleRequest 创建/更新摘要计划请求
+type DigestScheduleRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Frequency string `json:"frequency"` // daily/weekly
+	Weekday   int    `json:"weekday"`   // 0=周日，仅 weekly
+	Hour      int    `json:"hour"`
+	Timezone  string `json:"timezone"`
+	Email     string `json:"email"` // 多个地址逗号分隔
+	ChannelID uint   `json:"channel_id"`
+	TopN      int    `json:"top_n"`
+	Enabled   *bool  `json:"enabled"`
+}
+
+// apply 将请求写入摘要计划
+func (req *DigestScheduleRequest) apply(schedule *model.DigestSchedule) {
+	schedule.Name = strings.TrimSpace(req.Name)
+	schedule.Frequency = req.Frequency
+	if schedule.Frequency == "" {
+		schedule.Frequency = model.DigestDaily
+	}
+	schedule.Weekday = req.Weekday
+	schedule.Hour = req.Hour
+	schedule.Timezone = strings.TrimSpace(req.Timezone)
+	schedule.Email = strings.TrimSpace(req.Email)
+	schedule.ChannelID = req.ChannelID
+	schedule.TopN = req.TopN
+	if schedule.TopN == 0 {
+		schedule.TopN = 5
+	}
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+func (s *Server) listDigestSchedules(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	schedules, err := s.svc.ListDigestSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+func (s *Server) createDigestSchedule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req DigestScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := &model.DigestSchedule{}
+	req.apply(schedule)
+	if err := s.svc.CreateDigestSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "digest_schedule", schedule.ID, map[string]interface{}{"name": schedule.Name, "frequency": schedule.Frequency})
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (s *Server) updateDigestSchedule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	schedule, err := s.svc.GetDigestSchedule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "digest schedule not found"})
+		return
+	}
+
+	var req DigestScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(schedule)
+	if err := s.svc.UpdateDigestSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "digest_schedule", schedule.ID, map[string]interface{}{"name": schedule.Name})
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (s *Server) deleteDigestSchedule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	schedule, err := s.svc.GetDigestSchedule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "digest schedule not found"})
+		return
+	}
+
+	if err := s.svc.DeleteDigestSchedule(schedule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "digest_schedule", schedule.ID, map[string]interface{}{"name": schedule.Name})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// sendDigestNow 立即发送一次摘要报告
+func (s *Server) sendDigestNow(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.SendDigestNow(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "send", "digest_schedule", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// previewDigest 预览截至当前时间的摘要报告
+func (s *Server) previewDigest(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	frequency := c.DefaultQuery("frequency", model.DigestDaily)
+	if frequency != model.DigestDaily && frequency != model.DigestWeekly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "频率只能是 daily 或 weekly"})
+		return
+	}
+	topN, _ := strconv.Atoi(c.DefaultQuery("top_n", "5"))
+	if topN <= 0 || topN > 50 {
+		topN = 5
+	}
+
+	report := s.svc.BuildDigestReport(frequency, time.Now(), topN)
+	body, err := service.RenderDigestHTML(report)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"title": report.Title,
+		"html":  body,
+		"text":  service.RenderDigestText(report),
+	})
+}
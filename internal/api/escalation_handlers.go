@@ -0,0 +1,354 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 告警升级策略 ====================
+
+// EscalationPolicyRequest 创建/更新升级策略请求
+type EscalationPolicyRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Tiers       []model.EscalationTier `json:"tiers"`
+	Enabled     *bool                  `json:"enabled"`
+}
+
+// apply 将请求写入升级策略
+func (req *EscalationPolicyRequest) apply(policy *model.EscalationPolicy) {
+	tiers, _ := json.Marshal(req.Tiers)
+	policy.Name = req.Name
+	policy.Description = req.Description
+	policy.Tiers = string(tiers)
+	policy.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+// formatEscalationPolicy 转换升级策略为前端格式 (tiers 为数组)
+func formatEscalationPolicy(policy *model.EscalationPolicy) gin.H {
+	tiers, _ := notify.ParseEscalationTiers(policy.Tiers)
+	if tiers == nil {
+		tiers = []model.EscalationTier{}
+	}
+	return gin.H{
+		"id":          policy.ID,
+		"name":        policy.Name,
+		"description": policy.Description,
+		"tiers":       tiers,
+		"enabled":     policy.Enabled,
+		"created_at":  policy.CreatedAt,
+		"updated_at":  policy.UpdatedAt,
+	}
+}
+
+// checkEscalationPolicy 校验告警规则引用的升级策略存在
+func (s *Server) checkEscalationPolicy(id uint) error {
+	if id == 0 {
+		return nil
+	}
+	if _, err := s.svc.GetAlertService().GetEscalationPolicy(id); err != nil {
+		return errors.New("升级策略不存在")
+	}
+	return nil
+}
+
+func (s *Server) listEscalationPolicies(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	policies, err := s.svc.GetAlertService().ListEscalationPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]gin.H, len(policies))
+	for i := range policies {
+		result[i] = formatEscalationPolicy(&policies[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) createEscalationPolicy(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req EscalationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := &model.EscalationPolicy{}
+	req.apply(policy)
+	if err := s.svc.GetAlertService().CreateEscalationPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "escalation_policy", policy.ID, map[string]interface{}{"name": policy.Name})
+	c.JSON(http.StatusOK, formatEscalationPolicy(policy))
+}
+
+func (s *Server) updateEscalationPolicy(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	policy, err := s.svc.GetAlertService().GetEscalationPolicy(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "escalation policy not found"})
+		return
+	}
+
+	var req EscalationPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(policy)
+	if err := s.svc.GetAlertService().UpdateEscalationPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "escalation_policy", policy.ID, nil)
+	c.JSON(http.StatusOK, formatEscalationPolicy(policy))
+}
+
+func (s *Server) deleteEscalationPolicy(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.GetAlertService().DeleteEscalationPolicy(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "escalation_policy", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ==================== 值班排班 ====================
+
+// OnCallScheduleRequest 创建/更新值班排班请求
+type OnCallScheduleRequest struct {
+	Name           string              `json:"name" binding:"required"`
+	Timezone       string              `json:"timezone"`
+	Shifts         []model.OnCallShift `json:"shifts"`
+	FallbackUserID uint                `json:"fallback_user_id"`
+	Enabled        *bool               `json:"enabled"`
+}
+
+// apply 将请求写入值班排班
+func (req *OnCallScheduleRequest) apply(schedule *model.OnCallSchedule) {
+	shifts, _ := json.Marshal(req.Shifts)
+	schedule.Name = req.Name
+	schedule.Timezone = req.Timezone
+	schedule.Shifts = string(shifts)
+	schedule.FallbackUserID = req.FallbackUserID
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+// formatOnCallSchedule 转换值班排班为前端格式 (shifts 为数组，附带当前值班人)
+func (s *Server) formatOnCallSchedule(schedule *model.OnCallSchedule) gin.H {
+	shifts, _ := notify.ParseOnCallShifts(schedule.Shifts)
+	if shifts == nil {
+		shifts = []model.OnCallShift{}
+	}
+
+	onCall := notify.OnCallUser(schedule, time.Now())
+	onCallName := ""
+	if onCall != 0 {
+		if user, err := s.svc.GetUser(onCall); err == nil {
+			onCallName = user.Username
+		}
+	}
+
+	return gin.H{
+		"id":               schedule.ID,
+		"name":             schedule.Name,
+		"timezone":         schedule.Timezone,
+		"shifts":           shifts,
+		"fallback_user_id": schedule.FallbackUserID,
+		"enabled":          schedule.Enabled,
+		"on_call_user_id":  onCall,
+		"on_call_username": onCallName,
+		"created_at":       schedule.CreatedAt,
+		"updated_at":       schedule.UpdatedAt,
+	}
+}
+
+func (s *Server) listOnCallSchedules(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	schedules, err := s.svc.GetAlertService().ListOnCallSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]gin.H, len(schedules))
+	for i := range schedules {
+		result[i] = s.formatOnCallSchedule(&schedules[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) createOnCallSchedule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req OnCallScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := &model.OnCallSchedule{}
+	req.apply(schedule)
+	if err := s.svc.GetAlertService().CreateOnCallSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "oncall_schedule", schedule.ID, map[string]interface{}{"name": schedule.Name})
+	c.JSON(http.StatusOK, s.formatOnCallSchedule(schedule))
+}
+
+func (s *Server) updateOnCallSchedule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	schedule, err := s.svc.GetAlertService().GetOnCallSchedule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+
+	var req OnCallScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(schedule)
+	if err := s.svc.GetAlertService().UpdateOnCallSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "oncall_schedule", schedule.ID, nil)
+	c.JSON(http.StatusOK, s.formatOnCallSchedule(schedule))
+}
+
+func (s *Server) deleteOnCallSchedule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.GetAlertService().DeleteOnCallSchedule(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "oncall_schedule", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ==================== 签名链接确认告警 (公开) ====================
+
+// AlertAckLinkRequest 确认链接参数
+type AlertAckLinkRequest struct {
+	IncidentID uint   `json:"incident" form:"incident" binding:"required"`
+	Expires    int64  `json:"expires" form:"expires" binding:"required"`
+	Sig        string `json:"sig" form:"sig" binding:"required"`
+}
+
+// getAlertAckLink 校验确认链接并返回事件摘要 (页面展示，点击确认后才会确认)
+func (s *Server) getAlertAckLink(c *gin.Context) {
+	var req AlertAckLinkRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "确认链接无效"})
+		return
+	}
+
+	alertSvc := s.svc.GetAlertService()
+	if err := alertSvc.VerifyAckLink(req.IncidentID, req.Expires, req.Sig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	incident, err := alertSvc.GetIncident(req.IncidentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":             incident.ID,
+		"rule_name":      incident.RuleName,
+		"target_name":    incident.TargetName,
+		"severity":       incident.Severity,
+		"status":         incident.Status,
+		"message":        incident.Message,
+		"first_fired_at": incident.FirstFiredAt,
+		"acked_by":       incident.AckedBy,
+		"acked_at":       incident.AckedAt,
+	})
+}
+
+// ackAlertByLink 通过签名链接确认告警事件
+func (s *Server) ackAlertByLink(c *gin.Context) {
+	var req AlertAckLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "确认链接无效"})
+		return
+	}
+
+	alertSvc := s.svc.GetAlertService()
+	if err := alertSvc.VerifyAckLink(req.IncidentID, req.Expires, req.Sig); err != nil {
+		s.audit.LogFailed(c, "ack", "alert_incident", req.IncidentID, map[string]interface{}{"via": "link", "error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := alertSvc.AckIncident(req.IncidentID, "确认链接"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "ack", "alert_incident", req.IncidentID, map[string]interface{}{"via": "link"})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
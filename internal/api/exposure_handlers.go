@@ -0,0 +1,161 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 客户端暴露 ====================
+
+// ClientExposureRequest 创建/更新暴露请求
+type ClientExposureRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Protocol   string `json:"protocol"`                      // tcp/udp/http
+	LocalAddr  string `json:"local_addr" binding:"required"` // 局域网目标地址 host:port
+	RemotePort int    `json:"remote_port"`
+	Hostname   string `json:"hostname"` // 仅 http，填写后按域名发布
+	// 以下仅对按域名发布的暴露生效
+	TLSMode  string `json:"tls_mode"`  // 空=HTTP, terminate=节点终止 TLS, passthrough=SNI 透传
+	AuthUser string `json:"auth_user"` // Basic 认证用户名 (空=不认证)
+	AuthPass string `json:"auth_pass"` // Basic 认证密码 (更新时留空=保持不变)
+	AllowIPs string `json:"allow_ips"` // 来源 IP/网段白名单 (逗号分隔，空=不限制)
+	Enabled  *bool  `json:"enabled"`
+}
+
+// apply 将请求写入暴露
+func (req *ClientExposureRequest) apply(exposure *model.ClientExposure) {
+	exposure.Name = req.Name
+	exposure.Protocol = req.Protocol
+	exposure.LocalAddr = req.LocalAddr
+	exposure.RemotePort = req.RemotePort
+	exposure.Hostname = req.Hostname
+	exposure.TLSMode = req.TLSMode
+	if req.AuthPass != "" || req.AuthUser != exposure.AuthUser {
+		exposure.AuthPass = req.AuthPass
+	}
+	exposure.AuthUser = req.AuthUser
+	exposure.AllowIPs = req.AllowIPs
+	exposure.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+// exposureClient 获取当前用户可操作的客户端
+func (s *Server) exposureClient(c *gin.Context) (*model.Client, bool) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+	client, err := s.svc.GetClientByOwner(uint(id), userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+		return nil, false
+	}
+	return client, true
+}
+
+func (s *Server) listClientExposures(c *gin.Context) {
+	client, ok := s.exposureClient(c)
+	if !ok {
+		return
+	}
+
+	exposures, err := s.svc.ListClientExposures(client.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, exposures)
+}
+
+func (s *Server) createClientExposure(c *gin.Context) {
+	client, ok := s.exposureClient(c)
+	if !ok {
+		return
+	}
+
+	var req ClientExposureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exposure := &model.ClientExposure{}
+	req.apply(exposure)
+	if err := s.svc.NormalizeClientExposure(client, exposure); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.svc.CreateClientExposure(client, exposure); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "client_exposure", exposure.ID, map[string]interface{}{"client_id": client.ID, "name": exposure.Name})
+	c.JSON(http.StatusOK, exposure)
+}
+
+func (s *Server) updateClientExposure(c *gin.Context) {
+	client, ok := s.exposureClient(c)
+	if !ok {
+		return
+	}
+
+	eid, _ := strconv.ParseUint(c.Param("eid"), 10, 32)
+	exposure, err := s.svc.GetClientExposure(client.ID, uint(eid))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "exposure not found"})
+		return
+	}
+
+	var req ClientExposureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(exposure)
+	if err := s.svc.NormalizeClientExposure(client, exposure); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.svc.UpdateClientExposure(client, exposure); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "client_exposure", exposure.ID, map[string]interface{}{"client_id": client.ID, "name": exposure.Name})
+	c.JSON(http.StatusOK, exposure)
+}
+
+func (s *Server) deleteClientExposure(c *gin.Context) {
+	client, ok := s.exposureClient(c)
+	if !ok {
+		return
+	}
+
+	eid, _ := strconv.ParseUint(c.Param("eid"), 10, 32)
+	exposure, err := s.svc.GetClientExposure(client.ID, uint(eid))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "exposure not found"})
+		return
+	}
+
+	if err := s.svc.DeleteClientExposure(client, exposure); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "client_exposure", exposure.ID, map[string]interface{}{"client_id": client.ID, "name": exposure.Name})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// acmeChallenge 应答 ACME HTTP-01 验证
+func (s *Server) acmeChallenge(c *gin.Context) {
+	response, ok := service.ACMEChallengeResponse(c.Param("token"))
+	if !ok {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	c.String(http.StatusOK, response)
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +19,7 @@ import (
 	"github.com/goccy/go-yaml"
 	"github.com/AliceNetworks/gost-panel/internal/gost"
 	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/notify"
 	"github.com/AliceNetworks/gost-panel/internal/service"
 )
 
@@ -132,6 +135,8 @@ type CreateNodeRequest struct {
 	ProxyPass     string `json:"proxy_pass"`
 	TrafficQuota  int64  `json:"traffic_quota"`
 	QuotaResetDay int    `json:"quota_reset_day"`
+	QuotaAction   string `json:"quota_action"`   // 超限处理: notify/throttle/block
+	QuotaThrottle int64  `json:"quota_throttle"` // 超限后限速 (bytes/s)
 	// 协议配置
 	Protocol      string `json:"protocol"`       // socks5/http/ss/socks4/http2/ssu/auto/relay
 	Transport     string `json:"transport"`      // tcp/tls/ws/wss/h2/h2c/quic/kcp
@@ -152,6 +157,11 @@ type CreateNodeRequest struct {
 	ConnRateLimit int   `json:"conn_rate_limit"`
 	// DNS
 	DNSServer string `json:"dns_server"`
+	// 允许普通用户使用的端口范围 (仅管理员可设置)
+	PortRange string `json:"port_range"`
+	// 域名暴露 HTTP/HTTPS 入口端口 (0=不启用)
+	ExposeHTTPPort  int `json:"expose_http_port"`
+	ExposeHTTPSPort int `json:"expose_https_port"`
 }
 
 func (s *Server) createNode(c *gin.Context) {
@@ -173,31 +183,35 @@ func (s *Server) createNode(c *gin.Context) {
 	}
 
 	node := &model.Node{
-		Name:           req.Name,
-		Host:           req.Host,
-		Port:           req.Port,
-		APIPort:        req.APIPort,
-		APIUser:        req.APIUser,
-		APIPass:        req.APIPass,
-		ProxyUser:      req.ProxyUser,
-		ProxyPass:      req.ProxyPass,
-		TrafficQuota:   req.TrafficQuota,
-		QuotaResetDay:  req.QuotaResetDay,
-		Protocol:       req.Protocol,
-		Transport:      req.Transport,
-		TransportOpts:  req.TransportOpts,
-		SSMethod:       req.SSMethod,
-		SSPassword:     req.SSPassword,
-		TLSEnabled:     req.TLSEnabled,
-		TLSCertFile:    req.TLSCertFile,
-		TLSKeyFile:     req.TLSKeyFile,
-		TLSSNI:         req.TLSSNI,
-		WSPath:         req.WSPath,
-		WSHost:         req.WSHost,
-		SpeedLimit:     req.SpeedLimit,
-		ConnRateLimit:  req.ConnRateLimit,
-		DNSServer:      req.DNSServer,
-		OwnerID:        &userID,
+		Name:            req.Name,
+		Host:            req.Host,
+		Port:            req.Port,
+		APIPort:         req.APIPort,
+		APIUser:         req.APIUser,
+		APIPass:         req.APIPass,
+		ProxyUser:       req.ProxyUser,
+		ProxyPass:       req.ProxyPass,
+		TrafficQuota:    req.TrafficQuota,
+		QuotaResetDay:   req.QuotaResetDay,
+		QuotaAction:     req.QuotaAction,
+		QuotaThrottle:   req.QuotaThrottle,
+		Protocol:        req.Protocol,
+		Transport:       req.Transport,
+		TransportOpts:   req.TransportOpts,
+		SSMethod:        req.SSMethod,
+		SSPassword:      req.SSPassword,
+		TLSEnabled:      req.TLSEnabled,
+		TLSCertFile:     req.TLSCertFile,
+		TLSKeyFile:      req.TLSKeyFile,
+		TLSSNI:          req.TLSSNI,
+		WSPath:          req.WSPath,
+		WSHost:          req.WSHost,
+		SpeedLimit:      req.SpeedLimit,
+		ConnRateLimit:   req.ConnRateLimit,
+		DNSServer:       req.DNSServer,
+		ExposeHTTPPort:  req.ExposeHTTPPort,
+		ExposeHTTPSPort: req.ExposeHTTPSPort,
+		OwnerID:         &userID,
 	}
 
 	// 默认值
@@ -210,12 +224,26 @@ func (s *Server) createNode(c *gin.Context) {
 	if node.QuotaResetDay == 0 {
 		node.QuotaResetDay = 1
 	}
+	if node.QuotaAction == "" {
+		node.QuotaAction = model.QuotaActionNotify
+	}
 	if node.Protocol == "" {
 		node.Protocol = "socks5"
 	}
 	if node.Transport == "" {
 		node.Transport = "tcp"
 	}
+	if isAdmin && req.PortRange != "" {
+		if _, err := gost.ParsePortRanges(req.PortRange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "端口范围: " + err.Error()})
+			return
+		}
+		node.PortRange = req.PortRange
+	}
+	if node.ExposeHTTPPort < 0 || node.ExposeHTTPPort > 65535 || node.ExposeHTTPSPort < 0 || node.ExposeHTTPSPort > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的域名暴露入口端口"})
+		return
+	}
 
 	if err := s.svc.CreateNode(node); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -246,12 +274,41 @@ func (s *Server) updateNode(c *gin.Context) {
 	delete(updates, "agent_token")
 	delete(updates, "created_at")
 	delete(updates, "owner_id")
+	delete(updates, "cert_pem")
+	delete(updates, "key_pem")
+	delete(updates, "cert_serial")
+	delete(updates, "cert_expire_at")
+	delete(updates, "quota_enforced")
+	// 非管理员不能自行清除超限状态，也不能修改允许使用的端口范围与延迟测量设置
+	if !isAdmin {
+		delete(updates, "quota_used")
+		delete(updates, "quota_exceeded")
+		delete(updates, "port_range")
+		delete(updates, "latency_probe")
+	}
+	if portRange, ok := updates["port_range"].(string); ok && portRange != "" {
+		if _, err := gost.ParsePortRanges(portRange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "端口范围: " + err.Error()})
+			return
+		}
+	}
+	for _, key := range []string{"expose_http_port", "expose_https_port"} {
+		if port, ok := updates[key].(float64); ok && (port < 0 || port > 65535) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的域名暴露入口端口"})
+			return
+		}
+	}
 
 	if err := s.svc.UpdateNode(uint(id), updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 超限处理方式或配额变更后重新评估
+	if node, err := s.svc.GetNode(uint(id)); err == nil {
+		s.svc.EnforceNodeQuota(node)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -641,12 +698,10 @@ func (s *Server) clonePortForward(c *gin.Context) {
 		return
 	}
 
-	// 解析地址以递增端口
+	// 解析地址以递增端口 (端口范围整体顺延)
 	localAddr := forward.LocalAddr
-	if host, port, err := net.SplitHostPort(forward.LocalAddr); err == nil {
-		if p, err := strconv.Atoi(port); err == nil {
-			localAddr = net.JoinHostPort(host, strconv.Itoa(p+1))
-		}
+	if host, ports, err := gost.SplitAddrPorts(forward.LocalAddr); err == nil {
+		localAddr = net.JoinHostPort(host, gost.FormatPortSpec(shiftPorts(ports)))
 	}
 
 	cloned := &model.PortForward{
@@ -659,6 +714,10 @@ func (s *Server) clonePortForward(c *gin.Context) {
 		Enabled:    forward.Enabled,
 		OwnerID:    &userID,
 	}
+	if err := s.svc.NormalizePortForward(cloned); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := s.svc.CreatePortForward(cloned); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -692,13 +751,33 @@ func (s *Server) cloneTunnel(c *gin.Context) {
 		EntryPort:     tunnel.EntryPort + 1,
 		Protocol:      tunnel.Protocol,
 		ExitNodeID:    tunnel.ExitNodeID,
+		ExitNodeIDs:   tunnel.ExitNodeIDs,
+		ExitStrategy:  tunnel.ExitStrategy,
+		ExitGroupID:   tunnel.ExitGroupID,
 		TargetAddr:    tunnel.TargetAddr,
 		Enabled:       tunnel.Enabled,
 		TrafficQuota:  tunnel.TrafficQuota,
 		QuotaResetDay: tunnel.QuotaResetDay,
 		SpeedLimit:    tunnel.SpeedLimit,
+		ProbeType:     tunnel.ProbeType,
+		ProbeTarget:   tunnel.ProbeTarget,
+		ProbeInterval: tunnel.ProbeInterval,
 		OwnerID:       &userID,
 	}
+	if ports := gost.TunnelEntryPorts(tunnel); len(ports) > 1 {
+		cloned.EntryPorts = gost.FormatPortSpec(shiftPorts(ports))
+	}
+	// 中继跳点随入口端口顺延，避免与原隧道端口冲突
+	for _, hop := range tunnel.Hops {
+		if hop.Port > 0 {
+			hop.Port++
+		}
+		cloned.Hops = append(cloned.Hops, hop)
+	}
+	if err := s.svc.NormalizeTunnelPorts(cloned); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := s.svc.CreateTunnel(cloned); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -734,13 +813,16 @@ func (s *Server) cloneProxyChain(c *gin.Context) {
 	}
 
 	cloned := &model.ProxyChain{
-		Name:        chain.Name + " (副本)",
-		Description: chain.Description,
-		ListenAddr:  listenAddr,
-		ListenType:  chain.ListenType,
-		TargetAddr:  chain.TargetAddr,
-		Enabled:     chain.Enabled,
-		OwnerID:     &userID,
+		Name:          chain.Name + " (副本)",
+		Description:   chain.Description,
+		ListenAddr:    listenAddr,
+		ListenType:    chain.ListenType,
+		TargetAddr:    chain.TargetAddr,
+		Enabled:       chain.Enabled,
+		ProbeType:     chain.ProbeType,
+		ProbeTarget:   chain.ProbeTarget,
+		ProbeInterval: chain.ProbeInterval,
+		OwnerID:       &userID,
 	}
 
 	if err := s.svc.CreateProxyChain(cloned); err != nil {
@@ -828,12 +910,7 @@ func (s *Server) syncNodeConfig(c *gin.Context) {
 	}
 
 	// 生成配置并自动保存版本快照
-	generator := gost.NewConfigGenerator()
-	bypasses, _ := s.svc.GetBypassesByNode(node.ID)
-	admissions, _ := s.svc.GetAdmissionsByNode(node.ID)
-	hostMappings, _ := s.svc.GetHostMappingsByNode(node.ID)
-	ingresses, _ := s.svc.GetIngressesByNode(node.ID)
-	config := generator.GenerateNodeConfigWithRules(node, bypasses, admissions, hostMappings, ingresses)
+	config := s.buildNodeConfig(c, node)
 
 	// 将配置序列化为 YAML 字符串并保存版本
 	configYAML, err := yaml.Marshal(config)
@@ -857,6 +934,51 @@ func (s *Server) syncNodeConfig(c *gin.Context) {
 	})
 }
 
+// rotateNodeCertificate 重新签发节点 mTLS 证书
+func (s *Server) rotateNodeCertificate(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+
+	node, err := s.svc.GetNodeByOwner(uint(id), userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+
+	if err := s.svc.RotateNodeCertificate(node); err != nil {
+		if err == service.ErrNodeCertNotEligible {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"cert_serial":    node.CertSerial,
+		"cert_expire_at": node.CertExpireAt,
+		"message":        "证书已重新签发，Agent 将在下次心跳时自动更新",
+	})
+}
+
+// getInternalCA 获取内部 CA 证书 (仅管理员)
+func (s *Server) getInternalCA(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	ca, err := s.svc.GetInternalCA()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ca)
+}
+
 func (s *Server) getNodeGostConfig(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	userID, isAdmin := getUserInfo(c)
@@ -867,15 +989,45 @@ func (s *Server) getNodeGostConfig(c *gin.Context) {
 		return
 	}
 
-	// 使用新的配置生成器
-	generator := gost.NewConfigGenerator()
+	config := s.buildNodeConfig(c, node)
+
+	c.YAML(http.StatusOK, config)
+}
+
+// buildNodeConfig 生成节点完整 GOST 配置 (含分流/准入/主机映射/反向代理规则与经过该节点的隧道)
+func (s *Server) buildNodeConfig(c *gin.Context, node *model.Node) map[string]interface{} {
+	generator := gost.NewConfigGeneratorWithPanel(s.getPanelURL(c)).
+		WithPlan(s.svc.GetActivePlan(node.OwnerID)).
+		WithOwnerQuota(s.svc.GetOwnerQuotaEnforcement(node.OwnerID))
 	bypasses, _ := s.svc.GetBypassesByNode(node.ID)
 	admissions, _ := s.svc.GetAdmissionsByNode(node.ID)
 	hostMappings, _ := s.svc.GetHostMappingsByNode(node.ID)
 	ingresses, _ := s.svc.GetIngressesByNode(node.ID)
 	config := generator.GenerateNodeConfigWithRules(node, bypasses, admissions, hostMappings, ingresses)
 
-	c.YAML(http.StatusOK, config)
+	entries, exits := s.svc.NodeTunnelConfigs(node)
+	generator.AddTunnels(config, node, entries, exits)
+
+	exposureClients, _ := s.svc.NodeHostExposureClients(node.ID)
+	exposureCert, _ := s.svc.GetExposureCertificate(node.ID)
+	generator.AddExposureIngress(config, node, exposureClients, exposureCert)
+	return config
+}
+
+// renderNodeConfig 序列化节点配置，Agent 下载的配置文件与配置哈希使用同一份内容
+func (s *Server) renderNodeConfig(c *gin.Context, node *model.Node) ([]byte, error) {
+	return yaml.Marshal(s.buildNodeConfig(c, node))
+}
+
+// renderClientConfig 生成下发给客户端 Agent 的配置内容 (心跳按同一内容计算哈希)
+func (s *Server) renderClientConfig(client *model.Client) ([]byte, error) {
+	return yaml.Marshal(s.generateClientConfig(client))
+}
+
+// configHash 配置内容的哈希值 (与 Agent 对本地配置文件计算的哈希一致)
+func configHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 func (s *Server) getNodeInstallScript(c *gin.Context) {
@@ -1023,6 +1175,10 @@ func (s *Server) createClient(c *gin.Context) {
 	if client.QuotaResetDay == 0 {
 		client.QuotaResetDay = 1
 	}
+	if err := s.svc.NormalizeClientPorts(client); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := s.svc.CreateClient(client); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -1037,7 +1193,8 @@ func (s *Server) updateClient(c *gin.Context) {
 	userID, isAdmin := getUserInfo(c)
 
 	// 权限检查
-	if _, err := s.svc.GetClientByOwner(uint(id), userID, isAdmin); err != nil {
+	existing, err := s.svc.GetClientByOwner(uint(id), userID, isAdmin)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此客户端"})
 		return
 	}
@@ -1053,8 +1210,23 @@ func (s *Server) updateClient(c *gin.Context) {
 	delete(updates, "created_at")
 	delete(updates, "owner_id")
 
+	// 节点或远程端口变更时重新校验端口
+	client := *existing
+	if nodeID, ok := updates["node_id"].(float64); ok {
+		client.NodeID = uint(nodeID)
+	}
+	if port, ok := updates["remote_port"].(float64); ok {
+		client.RemotePort = int(port)
+	}
+	if client.NodeID != existing.NodeID || client.RemotePort != existing.RemotePort {
+		if err := s.svc.NormalizeClientPorts(&client); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	if err := s.svc.UpdateClient(uint(id), updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -1281,6 +1453,9 @@ func (s *Server) generateClientConfig(client *model.Client) map[string]interface
 		},
 	}
 
+	// 暴露的局域网服务
+	gost.AddClientExposures(config, client, node, chainName)
+
 	return config
 }
 
@@ -1303,11 +1478,17 @@ func (s *Server) agentRegister(c *gin.Context) {
 	node, err := s.svc.GetNodeByToken(req.Token)
 	if err == nil {
 		s.svc.UpdateNodeStatus(node.ID, "online", 0, 0, 0)
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"type":    "node",
 			"id":      node.ID,
 			"message": "registered",
-		})
+		}
+		// 下发内部 CA 签发的节点证书 (缺失或即将过期时自动签发，用户自有节点只下发域名暴露证书)
+		s.svc.EnsureNodeCertificate(node)
+		if bundle, err := s.svc.GetNodeTLSBundle(node); err == nil {
+			resp["tls"] = bundle
+		}
+		c.JSON(http.StatusOK, resp)
 		return
 	}
 
@@ -1332,6 +1513,7 @@ type AgentHeartbeatRequest struct {
 	TrafficIn    int64                        `json:"traffic_in"`
 	TrafficOut   int64                        `json:"traffic_out"`
 	ConfigHash   string                       `json:"config_hash"`   // 当前配置的哈希值
+	CertSerial   string                       `json:"cert_serial"`   // Agent 当前安装的节点证书序列号
 	AgentVersion string                       `json:"agent_version"` // Agent 版本
 	ServiceStats map[string]map[string]int64  `json:"service_stats"` // 按服务名分类的统计
 }
@@ -1355,15 +1537,34 @@ func (s *Server) agentHeartbeat(c *gin.Context) {
 			s.processServiceStats(node.ID, req.ServiceStats)
 		}
 
-		// 检查配置是否需要更新
+		// 检查配置是否需要更新: 比较 Agent 本地配置文件与当前生成配置的哈希，并记录同步状态
 		reloadConfig := false
 		if req.ConfigHash != "" {
-			// 计算当前节点配置的哈希值
-			currentHash := s.svc.GetNodeConfigHash(node.ID)
-			if currentHash != req.ConfigHash {
+			if data, err := s.renderNodeConfig(c, node); err == nil {
+				reloadConfig = configHash(data) != req.ConfigHash
+				s.svc.SetNodeConfigSynced(node.ID, !reloadConfig)
+			}
+		}
+
+		// 节点证书即将过期时轮换，Agent 重新注册获取新证书后重载配置
+		renewCert := false
+		if s.svc.NodeCertNeedsRenewal(node) {
+			if rotated, err := s.svc.EnsureNodeCertificate(node); err == nil && rotated {
+				renewCert = true
 				reloadConfig = true
 			}
 		}
+		// 证书已在面板上手动轮换或撤销，Agent 仍在使用旧证书
+		if !renewCert && req.CertSerial != "" && req.CertSerial != node.CertSerial {
+			renewCert = true
+			reloadConfig = true
+		}
+		// 域名暴露证书随配置一同变更 (证书序列号写在配置中)，重载前重新注册获取证书
+		if reloadConfig && !renewCert {
+			if cert, err := s.svc.GetExposureCertificate(node.ID); err == nil && cert.CertPEM != "" {
+				renewCert = true
+			}
+		}
 
 		// 检查 Agent 是否需要更新
 		needsUpdate, forceUpdate := s.checkAgentNeedsUpdate(req.AgentVersion)
@@ -1371,6 +1572,7 @@ func (s *Server) agentHeartbeat(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"status":        "ok",
 			"reload_config": reloadConfig,
+			"renew_cert":    renewCert,
 			"needs_update":  needsUpdate,
 			"force_update":  forceUpdate,
 		})
@@ -1387,12 +1589,16 @@ func (s *Server) agentHeartbeat(c *gin.Context) {
 			"traffic_out": client.TrafficOut + req.TrafficOut,
 		})
 
-		// 检查配置是否需要更新（包括关联节点的密码变更）
+		// 处理服务级别统计 (暴露流量)
+		if req.ServiceStats != nil {
+			s.processClientServiceStats(client.ID, req.ServiceStats)
+		}
+
+		// 检查配置是否需要更新: 与节点相同，比较 Agent 本地配置文件与当前生成配置的哈希 (包括关联节点的密码变更)
 		reloadConfig := false
 		if req.ConfigHash != "" {
-			currentHash := s.svc.GetClientConfigHash(client.ID)
-			if currentHash != req.ConfigHash {
-				reloadConfig = true
+			if data, err := s.renderClientConfig(client); err == nil {
+				reloadConfig = configHash(data) != req.ConfigHash
 			}
 		}
 
@@ -1448,17 +1654,28 @@ func (s *Server) processServiceStats(nodeID uint, stats map[string]map[string]in
 		// 解析服务名，匹配隧道或客户端
 		// 隧道服务名格式: tunnel-{id}-tcp, tunnel-{id}-udp, tunnel-{id}
 		// 客户端服务名格式: rtcp-tunnel, rudp-tunnel, client-{id}
+		// 只接受隧道入口 / 客户端绑定节点的上报，其他节点不能把流量记到别人的隧道或客户端上
 		if tunnelID := parseTunnelID(serviceName); tunnelID > 0 {
-			s.svc.UpdateTunnelTraffic(uint(tunnelID), trafficIn, trafficOut)
+			s.svc.RecordTunnelEntryTraffic(uint(tunnelID), nodeID, trafficIn, trafficOut)
 		} else if clientID := parseClientID(serviceName); clientID > 0 {
-			s.svc.UpdateClientTraffic(uint(clientID), trafficIn, trafficOut)
+			s.svc.RecordNodeClientTraffic(uint(clientID), nodeID, trafficIn, trafficOut)
+		}
+	}
+}
+
+// processClientServiceStats 处理客户端按服务分类的流量统计
+// 暴露服务名格式: exposure-{id}-tcp, exposure-{id}-udp, exposure-{id}-http
+func (s *Server) processClientServiceStats(clientID uint, stats map[string]map[string]int64) {
+	for serviceName, serviceStats := range stats {
+		if exposureID := gost.ParseExposureServiceName(serviceName); exposureID > 0 {
+			s.svc.UpdateExposureTraffic(clientID, exposureID, serviceStats["traffic_in"], serviceStats["traffic_out"])
 		}
 	}
 }
 
 // parseTunnelID 从服务名解析隧道ID
 func parseTunnelID(serviceName string) int {
-	// 匹配 tunnel-{id}, tunnel-{id}-tcp, tunnel-{id}-udp
+	// 匹配 tunnel-{id}, tunnel-{id}-tcp, tunnel-{id}-udp (多端口隧道为 tunnel-{id}-tcp-{port})
 	var id int
 	if n, _ := fmt.Sscanf(serviceName, "tunnel-%d-tcp", &id); n == 1 {
 		return id
@@ -1487,22 +1704,25 @@ func (s *Server) agentGetConfig(c *gin.Context) {
 	// 尝试查找节点
 	node, err := s.svc.GetNodeByToken(token)
 	if err == nil {
-		// 使用 ConfigGenerator 生成完整配置（包含规则）
-		generator := gost.NewConfigGenerator()
-		bypasses, _ := s.svc.GetBypassesByNode(node.ID)
-		admissions, _ := s.svc.GetAdmissionsByNode(node.ID)
-		hostMappings, _ := s.svc.GetHostMappingsByNode(node.ID)
-		ingresses, _ := s.svc.GetIngressesByNode(node.ID)
-		config := generator.GenerateNodeConfigWithRules(node, bypasses, admissions, hostMappings, ingresses)
-		c.YAML(http.StatusOK, config)
+		// 生成完整配置（包含规则与隧道）
+		data, err := s.renderNodeConfig(c, node)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
 		return
 	}
 
 	// 尝试查找客户端
 	client, err := s.svc.GetClientByToken(token)
 	if err == nil {
-		config := s.generateClientConfig(client)
-		c.YAML(http.StatusOK, config)
+		data, err := s.renderClientConfig(client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
 		return
 	}
 
@@ -1954,10 +2174,12 @@ func (s *Server) getNotifyChannel(c *gin.Context) {
 }
 
 type CreateNotifyChannelRequest struct {
-	Name    string                 `json:"name" binding:"required"`
-	Type    string                 `json:"type" binding:"required"` // telegram/webhook/smtp
-	Config  map[string]interface{} `json:"config" binding:"required"`
-	Enabled bool                   `json:"enabled"`
+	Name      string                 `json:"name" binding:"required"`
+	Type      string                 `json:"type" binding:"required"` // telegram/webhook/smtp/slack/discord/feishu/dingtalk/wecom/ntfy/gotify
+	Config    map[string]interface{} `json:"config" binding:"required"`
+	RateLimit int                    `json:"rate_limit"` // 每分钟最多发送条数，0=不限制
+	UserID    uint                   `json:"user_id"`    // 个人渠道所属用户，0=公共渠道
+	Enabled   bool                   `json:"enabled"`
 }
 
 func (s *Server) createNotifyChannel(c *gin.Context) {
@@ -1981,10 +2203,16 @@ func (s *Server) createNotifyChannel(c *gin.Context) {
 	}
 
 	channel := &model.NotifyChannel{
-		Name:    req.Name,
-		Type:    req.Type,
-		Config:  string(configJSON),
-		Enabled: req.Enabled,
+		Name:      req.Name,
+		Type:      req.Type,
+		Config:    string(configJSON),
+		RateLimit: req.RateLimit,
+		UserID:    req.UserID,
+		Enabled:   req.Enabled,
+	}
+	if err := validateNotifyChannel(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if err := s.svc.GetAlertService().CreateChannel(channel); err != nil {
@@ -1995,6 +2223,14 @@ func (s *Server) createNotifyChannel(c *gin.Context) {
 	c.JSON(http.StatusOK, channel)
 }
 
+// validateNotifyChannel 校验渠道配置，个人渠道可省略发信配置 (使用系统邮件渠道 / 机器人)
+func validateNotifyChannel(channel *model.NotifyChannel) error {
+	if channel.UserID != 0 {
+		return notify.ValidatePersonalChannel(channel)
+	}
+	return notify.ValidateChannel(channel)
+}
+
 func (s *Server) updateNotifyChannel(c *gin.Context) {
 	_, isAdmin := getUserInfo(c)
 	if !isAdmin {
@@ -2025,6 +2261,26 @@ func (s *Server) updateNotifyChannel(c *gin.Context) {
 		}
 	}
 
+	// 校验更新后的渠道配置
+	channel, err := s.svc.GetAlertService().GetChannel(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+	if v, ok := updates["type"].(string); ok {
+		channel.Type = v
+	}
+	if v, ok := updates["config"].(string); ok {
+		channel.Config = v
+	}
+	if v, ok := updates["user_id"].(float64); ok {
+		channel.UserID = uint(v)
+	}
+	if err := validateNotifyChannel(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := s.svc.GetAlertService().UpdateChannel(uint(id), updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -2067,6 +2323,37 @@ func (s *Server) testNotifyChannel(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "测试通知已发送"})
 }
 
+// testNotifyChannelConfig 使用未保存的渠道配置发送测试通知
+func (s *Server) testNotifyChannelConfig(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req struct {
+		Type   string                 `json:"type" binding:"required"`
+		Config map[string]interface{} `json:"config" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	configJSON, err := json.Marshal(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid config format"})
+		return
+	}
+
+	if err := notify.SendTest(&model.NotifyChannel{Type: req.Type, Config: string(configJSON)}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "测试通知已发送"})
+}
+
 // ==================== 告警规则管理 ====================
 
 func (s *Server) listAlertRules(c *gin.Context) {
@@ -2135,30 +2422,34 @@ func (s *Server) formatAlertRule(rule *model.AlertRule) gin.H {
 	}
 
 	return gin.H{
-		"id":               rule.ID,
-		"name":             rule.Name,
-		"type":             rule.Type,
-		"alert_type":       rule.Type, // 前端兼容字段
-		"condition":        condition,
-		"channel_ids":      channelIDs,
-		"enabled":          rule.Enabled,
-		"cooldown_min":     rule.CooldownMin,
-		"silence_duration": rule.CooldownMin * 60000, // 转为毫秒给前端
-		"last_alert_at":    rule.LastAlertAt,
-		"created_at":       rule.CreatedAt,
-		"updated_at":       rule.UpdatedAt,
+		"id":                   rule.ID,
+		"name":                 rule.Name,
+		"type":                 rule.Type,
+		"alert_type":           rule.Type, // 前端兼容字段
+		"condition":            condition,
+		"channel_ids":          channelIDs,
+		"enabled":              rule.Enabled,
+		"cooldown_min":         rule.CooldownMin,
+		"silence_duration":     rule.CooldownMin * 60000, // 转为毫秒给前端
+		"severity":             rule.Severity,
+		"escalation_policy_id": rule.EscalationPolicyID,
+		"last_alert_at":        rule.LastAlertAt,
+		"created_at":           rule.CreatedAt,
+		"updated_at":           rule.UpdatedAt,
 	}
 }
 
 type CreateAlertRuleRequest struct {
-	Name            string                 `json:"name" binding:"required"`
-	Type            string                 `json:"type"`             // 后端字段名
-	AlertType       string                 `json:"alert_type"`       // 前端字段名 (兼容)
-	Condition       interface{}            `json:"condition"`        // 接受对象或字符串
-	ChannelIDs      interface{}            `json:"channel_ids"`      // 接受数组或字符串
-	Enabled         bool                   `json:"enabled"`
-	CooldownMin     int                    `json:"cooldown_min"`     // 后端字段名 (分钟)
-	SilenceDuration int                    `json:"silence_duration"` // 前端字段名 (毫秒，兼容)
+	Name               string      `json:"name" binding:"required"`
+	Type               string      `json:"type"`                 // 后端字段名
+	AlertType          string      `json:"alert_type"`           // 前端字段名 (兼容)
+	Condition          interface{} `json:"condition"`            // 接受对象或字符串
+	ChannelIDs         interface{} `json:"channel_ids"`          // 接受数组或字符串
+	EscalationPolicyID uint        `json:"escalation_policy_id"` // 升级策略，0=直接通知渠道
+	Enabled            bool        `json:"enabled"`
+	CooldownMin        int         `json:"cooldown_min"`     // 后端字段名 (分钟)
+	SilenceDuration    int         `json:"silence_duration"` // 前端字段名 (毫秒，兼容)
+	Severity           string      `json:"severity"`         // info/warning/critical
 }
 
 func (s *Server) createAlertRule(c *gin.Context) {
@@ -2219,17 +2510,30 @@ func (s *Server) createAlertRule(c *gin.Context) {
 	}
 
 	rule := &model.AlertRule{
-		Name:        req.Name,
-		Type:        ruleType,
-		Condition:   conditionStr,
-		ChannelIDs:  channelIDsStr,
-		Enabled:     req.Enabled,
-		CooldownMin: cooldownMin,
+		Name:               req.Name,
+		Type:               ruleType,
+		Condition:          conditionStr,
+		ChannelIDs:         channelIDsStr,
+		Enabled:            req.Enabled,
+		CooldownMin:        cooldownMin,
+		Severity:           req.Severity,
+		EscalationPolicyID: req.EscalationPolicyID,
 	}
 
 	if rule.CooldownMin == 0 {
 		rule.CooldownMin = 30
 	}
+	if rule.Severity == "" {
+		rule.Severity = notify.SeverityWarning
+	}
+	if err := notify.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkEscalationPolicy(rule.EscalationPolicyID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := s.svc.GetAlertService().CreateRule(rule); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -2292,6 +2596,32 @@ func (s *Server) updateAlertRule(c *gin.Context) {
 		}
 	}
 
+	// 校验更新后的规则
+	rule, err := s.svc.GetAlertService().GetRule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+		return
+	}
+	if v, ok := updates["type"].(string); ok {
+		rule.Type = v
+	}
+	if v, ok := updates["condition"].(string); ok {
+		rule.Condition = v
+	}
+	if v, ok := updates["severity"].(string); ok {
+		rule.Severity = v
+	}
+	if err := notify.ValidateRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if v, ok := updates["escalation_policy_id"].(float64); ok {
+		if err := s.checkEscalationPolicy(uint(v)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	if err := s.svc.GetAlertService().UpdateRule(uint(id), updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -2336,7 +2666,7 @@ func (s *Server) getAlertLogs(c *gin.Context) {
 		limit = 50
 	}
 
-	logs, total, err := s.svc.GetAlertService().GetAlertLogs(limit, offset)
+	logs, total, err := s.svc.GetAlertService().GetAlertLogs(c.Query("status"), limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -2346,18 +2676,26 @@ func (s *Server) getAlertLogs(c *gin.Context) {
 	formattedLogs := make([]gin.H, len(logs))
 	for i, log := range logs {
 		formattedLogs[i] = gin.H{
-			"id":          log.ID,
-			"rule_id":     log.RuleID,
-			"rule_name":   log.RuleName,
-			"type":        log.Type,
-			"alert_type":  log.Type, // 前端兼容字段
-			"message":     log.Message,
-			"target_type": log.TargetType,
-			"target_id":   log.TargetID,
-			"target_name": log.TargetName,
-			"status":      log.Status,
-			"sent":        log.Status == "sent", // 前端期望的布尔值
-			"created_at":  log.CreatedAt,
+			"id":              log.ID,
+			"rule_id":         log.RuleID,
+			"rule_name":       log.RuleName,
+			"type":            log.Type,
+			"alert_type":      log.Type, // 前端兼容字段
+			"message":         log.Message,
+			"target_type":     log.TargetType,
+			"target_id":       log.TargetID,
+			"target_name":     log.TargetName,
+			"status":          log.Status,
+			"sent":            log.Status == "sent", // 前端期望的布尔值
+			"incident_id":     log.IncidentID,
+			"silence_id":      log.SilenceID,
+			"channel_id":      log.ChannelID,
+			"channel_name":    log.ChannelName,
+			"attempts":        log.Attempts,
+			"last_error":      log.LastError,
+			"next_attempt_at": log.NextAttemptAt,
+			"sent_at":         log.SentAt,
+			"created_at":      log.CreatedAt,
 		}
 	}
 
@@ -2367,27 +2705,49 @@ func (s *Server) getAlertLogs(c *gin.Context) {
 	})
 }
 
+// resendAlertLog 重新投递一条通知 (死信重发)
+func (s *Server) resendAlertLog(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.GetAlertService().ResendAlertLog(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "resend", "alert_log", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
 // ==================== 端口转发 ====================
 
 // portForwardToResponse 转换 PortForward 为前端兼容的响应格式
 func portForwardToResponse(pf model.PortForward, nodeName string) gin.H {
 	listenHost := "0.0.0.0"
 	listenPort := 0
+	listenPorts := ""
 	if pf.LocalAddr != "" {
 		if h, p, err := net.SplitHostPort(pf.LocalAddr); err == nil {
 			if h != "" {
 				listenHost = h
 			}
 			listenPort, _ = strconv.Atoi(p)
+			listenPorts = p
 		}
 	}
 
 	targetHost := ""
 	targetPort := 0
+	targetPorts := ""
 	if pf.RemoteAddr != "" {
 		if h, p, err := net.SplitHostPort(pf.RemoteAddr); err == nil {
 			targetHost = h
 			targetPort, _ = strconv.Atoi(p)
+			targetPorts = p
 		}
 	}
 
@@ -2401,8 +2761,10 @@ func portForwardToResponse(pf model.PortForward, nodeName string) gin.H {
 		"remote_addr": pf.RemoteAddr,
 		"listen_host": listenHost,
 		"listen_port": listenPort,
+		"listen_ports": listenPorts, // 端口列表/范围 (多端口时 listen_port 为 0)
 		"target_host": targetHost,
 		"target_port": targetPort,
+		"target_ports": targetPorts,
 		"chain_id":    pf.ChainID,
 		"enabled":     pf.Enabled,
 		"owner_id":    pf.OwnerID,
@@ -2468,11 +2830,13 @@ type CreatePortForwardRequest struct {
 	Protocol   string `json:"protocol"`   // 前端字段 (兼容)
 	LocalAddr  string `json:"local_addr"` // 后端字段
 	RemoteAddr string `json:"remote_addr"` // 后端字段
-	// 前端兼容字段
+	// 前端兼容字段 (端口列表/范围优先于单个端口)
 	ListenHost  string `json:"listen_host"`
 	ListenPort  int    `json:"listen_port"`
+	ListenPorts string `json:"listen_ports"`
 	TargetHost  string `json:"target_host"`
 	TargetPort  int    `json:"target_port"`
+	TargetPorts string `json:"target_ports"`
 	Description string `json:"description"` // 前端发送但后端忽略
 	ChainID     *uint  `json:"chain_id"`
 	Enabled     bool   `json:"enabled"`
@@ -2494,18 +2858,34 @@ func (s *Server) createPortForward(c *gin.Context) {
 		forwardType = "tcp"
 	}
 
+	userID, isAdmin := getUserInfo(c)
+
+	remoteAddr := req.RemoteAddr
+	if remoteAddr == "" {
+		remoteAddr = joinForwardAddr(req.TargetHost, req.TargetPorts, req.TargetPort)
+	}
+
 	localAddr := req.LocalAddr
-	if localAddr == "" && req.ListenPort > 0 {
+	if localAddr == "" {
 		host := req.ListenHost
 		if host == "" {
 			host = "0.0.0.0"
 		}
-		localAddr = fmt.Sprintf("%s:%d", host, req.ListenPort)
-	}
+		localAddr = joinForwardAddr(host, req.ListenPorts, req.ListenPort)
 
-	remoteAddr := req.RemoteAddr
-	if remoteAddr == "" && req.TargetPort > 0 {
-		remoteAddr = fmt.Sprintf("%s:%d", req.TargetHost, req.TargetPort)
+		// 未指定监听端口时在节点上自动分配空闲端口 (数量与目标端口一致)
+		if localAddr == "" && req.NodeID > 0 && remoteAddr != "" {
+			count := 1
+			if _, targets, err := gost.SplitAddrPorts(remoteAddr); err == nil {
+				count = len(targets)
+			}
+			ports, err := s.svc.FindFreePorts(req.NodeID, &userID, count)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			localAddr = net.JoinHostPort(host, gost.FormatPortSpec(ports))
+		}
 	}
 
 	if localAddr == "" || remoteAddr == "" {
@@ -2513,8 +2893,6 @@ func (s *Server) createPortForward(c *gin.Context) {
 		return
 	}
 
-	userID, isAdmin := getUserInfo(c)
-
 	// 检查套餐资源限制
 	if !isAdmin {
 		allowed, msg := s.svc.CheckPlanResourceLimit(userID, "port_forward")
@@ -2543,6 +2921,10 @@ func (s *Server) createPortForward(c *gin.Context) {
 		Enabled:    req.Enabled,
 		OwnerID:    &userID,
 	}
+	if err := s.svc.NormalizePortForward(forward); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := s.svc.CreatePortForward(forward); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -2557,7 +2939,8 @@ func (s *Server) updatePortForward(c *gin.Context) {
 	userID, isAdmin := getUserInfo(c)
 
 	// 权限检查
-	if _, err := s.svc.GetPortForwardByOwner(uint(id), userID, isAdmin); err != nil {
+	existing, err := s.svc.GetPortForwardByOwner(uint(id), userID, isAdmin)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此转发规则"})
 		return
 	}
@@ -2581,28 +2964,33 @@ func (s *Server) updatePortForward(c *gin.Context) {
 		delete(updates, "protocol")
 	}
 
-	// 兼容前端字段: listen_host + listen_port -> local_addr
-	if _, hasListenPort := updates["listen_port"]; hasListenPort {
-		host := "0.0.0.0"
-		if h, ok := updates["listen_host"].(string); ok && h != "" {
-			host = h
-		}
-		port := int(updates["listen_port"].(float64))
-		updates["local_addr"] = fmt.Sprintf("%s:%d", host, port)
-		delete(updates, "listen_host")
-		delete(updates, "listen_port")
+	// 兼容前端字段: listen_host + listen_ports/listen_port -> local_addr
+	if addr, ok := takeForwardAddr(updates, "listen_host", "listen_ports", "listen_port", "0.0.0.0"); ok {
+		updates["local_addr"] = addr
 	}
 
-	// 兼容前端字段: target_host + target_port -> remote_addr
-	if _, hasTargetPort := updates["target_port"]; hasTargetPort {
-		host := ""
-		if h, ok := updates["target_host"].(string); ok {
-			host = h
+	// 兼容前端字段: target_host + target_ports/target_port -> remote_addr
+	if addr, ok := takeForwardAddr(updates, "target_host", "target_ports", "target_port", ""); ok {
+		updates["remote_addr"] = addr
+	}
+
+	// 监听地址、目标地址或节点变更时重新校验端口
+	forward := *existing
+	if addr, ok := updates["local_addr"].(string); ok {
+		forward.LocalAddr = addr
+	}
+	if addr, ok := updates["remote_addr"].(string); ok {
+		forward.RemoteAddr = addr
+	}
+	if nodeID, ok := updates["node_id"].(float64); ok {
+		forward.NodeID = uint(nodeID)
+	}
+	if forward.LocalAddr != existing.LocalAddr || forward.RemoteAddr != existing.RemoteAddr || forward.NodeID != existing.NodeID {
+		if err := s.svc.NormalizePortForward(&forward); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		port := int(updates["target_port"].(float64))
-		updates["remote_addr"] = fmt.Sprintf("%s:%d", host, port)
-		delete(updates, "target_host")
-		delete(updates, "target_port")
+		updates["local_addr"] = forward.LocalAddr
 	}
 
 	if err := s.svc.UpdatePortForward(uint(id), updates); err != nil {
@@ -2613,6 +3001,40 @@ func (s *Server) updatePortForward(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// joinForwardAddr 由主机与端口 (端口列表/范围优先) 组成转发地址，没有端口时返回空
+func joinForwardAddr(host, ports string, port int) string {
+	ports = strings.TrimSpace(ports)
+	if ports == "" && port > 0 {
+		ports = strconv.Itoa(port)
+	}
+	if ports == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, ports)
+}
+
+// takeForwardAddr 从更新中取出前端的主机与端口字段并组成转发地址
+func takeForwardAddr(updates map[string]interface{}, hostKey, portsKey, portKey, defaultHost string) (string, bool) {
+	_, hasPorts := updates[portsKey]
+	_, hasPort := updates[portKey]
+	if !hasPorts && !hasPort {
+		return "", false
+	}
+
+	host, _ := updates[hostKey].(string)
+	if host == "" {
+		host = defaultHost
+	}
+	ports, _ := updates[portsKey].(string)
+	port, _ := updates[portKey].(float64)
+	delete(updates, hostKey)
+	delete(updates, portsKey)
+	delete(updates, portKey)
+
+	addr := joinForwardAddr(host, ports, int(port))
+	return addr, addr != ""
+}
+
 func (s *Server) deletePortForward(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	userID, isAdmin := getUserInfo(c)
@@ -2791,7 +3213,7 @@ func (s *Server) deleteNodeGroup(c *gin.Context) {
 	}
 
 	if err := s.svc.DeleteNodeGroup(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -2872,6 +3294,24 @@ func (s *Server) removeNodeGroupMember(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// listNodeGroupHealthEvents 节点组成员的健康调整记录
+func (s *Server) listNodeGroupHealthEvents(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+	if _, err := s.svc.GetNodeGroupByOwner(uint(id), userID, isAdmin); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此节点组"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	events, err := s.svc.ListNodeGroupHealthEvents(uint(id), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
 func (s *Server) getNodeGroupConfig(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
@@ -2949,7 +3389,7 @@ func (s *Server) pingNode(c *gin.Context) {
 	}
 
 	// 测试 TCP 连接延迟到节点的代理端口
-	addr := fmt.Sprintf("%s:%d", node.Host, node.Port)
+	addr := net.JoinHostPort(node.Host, strconv.Itoa(node.Port))
 	start := time.Now()
 
 	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
@@ -2990,7 +3430,7 @@ func (s *Server) pingAllNodes(c *gin.Context) {
 			semaphore <- struct{}{}        // 获取信号量
 			defer func() { <-semaphore }() // 释放信号量
 
-			addr := fmt.Sprintf("%s:%d", n.Host, n.Port)
+			addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
 			start := time.Now()
 
 			conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
@@ -3494,6 +3934,7 @@ func (s *Server) getProxyChain(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "proxy chain not found"})
 		return
 	}
+	s.svc.FillProxyChainProbes(chain)
 	c.JSON(http.StatusOK, chain)
 }
 
@@ -3518,6 +3959,11 @@ func (s *Server) createProxyChain(c *gin.Context) {
 	// 强制设置所有者 (防止用户指定任意 owner_id)
 	chain.OwnerID = &userID
 
+	if err := service.NormalizeProbeSettings(&chain.ProbeType, &chain.ProbeTarget, &chain.ProbeInterval); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := s.svc.CreateProxyChain(&chain); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -3531,7 +3977,8 @@ func (s *Server) updateProxyChain(c *gin.Context) {
 	userID, isAdmin := getUserInfo(c)
 
 	// 权限检查
-	if _, err := s.svc.GetProxyChainByOwner(uint(id), userID, isAdmin); err != nil {
+	existing, err := s.svc.GetProxyChainByOwner(uint(id), userID, isAdmin)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此代理链"})
 		return
 	}
@@ -3547,12 +3994,18 @@ func (s *Server) updateProxyChain(c *gin.Context) {
 	delete(updates, "owner_id")
 	delete(updates, "created_at")
 
+	if err := mergeProbeUpdates(updates, probeSettings{existing.ProbeType, existing.ProbeTarget, existing.ProbeInterval}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := s.svc.UpdateProxyChainMap(uint(id), updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	result, _ := s.svc.GetProxyChain(uint(id))
+	s.svc.FillProxyChainProbes(result)
 	c.JSON(http.StatusOK, result)
 }
 
@@ -3726,18 +4179,34 @@ func (s *Server) createTunnel(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "入口" + msg})
 			return
 		}
-
-		// 检查出口节点访问权限
-		allowed, msg = s.svc.CheckPlanNodeAccess(userID, tunnel.ExitNodeID)
-		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "出口" + msg})
-			return
-		}
 	}
 
 	// 强制设置所有者 (防止用户指定任意 owner_id)
 	tunnel.OwnerID = &userID
 
+	if err := s.svc.NormalizeTunnelPorts(&tunnel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.svc.NormalizeTunnelExits(&tunnel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.svc.NormalizeTunnelHops(&tunnel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.NormalizeProbeSettings(&tunnel.ProbeType, &tunnel.ProbeTarget, &tunnel.ProbeInterval); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isAdmin && !s.checkTunnelPathAccess(c, &tunnel, userID) {
+		return
+	}
+
+	tunnel.ActiveExitID = 0
+	tunnel.ActiveExitAt = nil
+
 	if err := s.svc.CreateTunnel(&tunnel); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -3753,7 +4222,8 @@ func (s *Server) updateTunnel(c *gin.Context) {
 	userID, isAdmin := getUserInfo(c)
 
 	// 权限检查
-	if _, err := s.svc.GetTunnelByOwner(uint(id), userID, isAdmin); err != nil {
+	existing, err := s.svc.GetTunnelByOwner(uint(id), userID, isAdmin)
+	if err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此隧道"})
 		return
 	}
@@ -3768,9 +4238,80 @@ func (s *Server) updateTunnel(c *gin.Context) {
 	delete(updates, "id")
 	delete(updates, "owner_id")
 	delete(updates, "created_at")
+	for _, key := range []string{"entry_node", "exit_node", "exit_group", "exits", "sync_status", "synced_at", "active_exit_id", "active_exit_at"} {
+		delete(updates, key)
+	}
+	if err := mergeProbeUpdates(updates, probeSettings{existing.ProbeType, existing.ProbeTarget, existing.ProbeInterval}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := s.svc.UpdateTunnelMap(uint(id), updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// 端口、出口设置与中继跳点变更: 与现有设置合并后统一校验，中继跳点整体替换
+	portsChanged := hasTunnelUpdates(updates, tunnelPortKeys)
+	exitChanged := hasTunnelUpdates(updates, tunnelExitKeys)
+	rawHops, hopsChanged := updates["hops"]
+	delete(updates, "hops")
+	var hops []model.TunnelHop
+	if portsChanged || exitChanged || hopsChanged {
+		tunnel := *existing
+		if portsChanged {
+			if err := applyTunnelUpdates(&tunnel, updates, tunnelPortKeys, "端口设置"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if !isAdmin && tunnel.EntryNodeID != existing.EntryNodeID {
+				if allowed, msg := s.svc.CheckPlanNodeAccess(userID, tunnel.EntryNodeID); !allowed {
+					c.JSON(http.StatusForbidden, gin.H{"error": "入口" + msg})
+					return
+				}
+			}
+			if err := s.svc.NormalizeTunnelPorts(&tunnel); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updates["entry_port"] = tunnel.EntryPort
+			updates["entry_ports"] = tunnel.EntryPorts
+		}
+		if exitChanged {
+			if err := applyTunnelExitUpdates(&tunnel, updates); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := s.svc.NormalizeTunnelExits(&tunnel); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updates["exit_node_id"] = tunnel.ExitNodeID
+			updates["exit_node_ids"] = tunnel.ExitNodeIDs
+			updates["exit_group_id"] = tunnel.ExitGroupID
+			updates["exit_strategy"] = tunnel.ExitStrategy
+		}
+		// 入口节点或端口变更时重新校验现有跳点 (跳点端口默认与入口端口相同)
+		if hopsChanged || portsChanged {
+			if hopsChanged {
+				if err := decodeTunnelHops(rawHops, &tunnel); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			if err := s.svc.NormalizeTunnelHops(&tunnel); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			hops = tunnel.Hops
+		}
+		if !isAdmin && !s.checkTunnelPathAccess(c, &tunnel, userID) {
+			return
+		}
+	}
+
+	if hopsChanged {
+		err = s.svc.UpdateTunnelWithHops(uint(id), updates, hops)
+	} else {
+		err = s.svc.UpdateTunnelMap(uint(id), updates)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -3795,61 +4336,158 @@ func (s *Server) deleteTunnel(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// syncTunnel 要求入口、中继与出口节点重新加载配置
+// 隧道已编译进节点配置，变更时会自动同步，此接口用于手动触发并查看同步状态
 func (s *Server) syncTunnel(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
 
-	tunnel, err := s.svc.GetTunnel(uint(id))
+	tunnel, err := s.svc.GetTunnelByOwner(uint(id), userID, isAdmin)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "tunnel not found"})
 		return
 	}
 
-	// 检查入口节点是否存在
-	if tunnel.EntryNode == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "entry node not found"})
+	if tunnel.EntryNode == nil || len(tunnel.Exits) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "入口或出口节点不存在"})
 		return
 	}
 
-	// 检查入口节点是否在线
-	if tunnel.EntryNode.Status != "online" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "entry node is offline",
-			"message": "入口节点离线，无法同步配置",
-		})
-		return
+	s.svc.TouchNode(tunnel.EntryNodeID)
+	allOnline := tunnel.EntryNode.Status == "online"
+	pathNodes := tunnel.Exits
+	for _, hop := range tunnel.Hops {
+		pathNodes = append(pathNodes, hop.Nodes...)
 	}
-
-	// 生成隧道配置
-	generator := gost.NewConfigGenerator()
-	config := generator.GenerateTunnelEntryConfig(tunnel)
-	if config == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to generate tunnel config"})
-		return
+	for _, node := range pathNodes {
+		s.svc.TouchNode(node.NodeID)
+		if node.Status != "online" {
+			allOnline = false
+		}
 	}
 
-	// 连接入口节点的 GOST API
-	client := gost.NewClient(
-		tunnel.EntryNode.Host,
-		tunnel.EntryNode.APIPort,
-		tunnel.EntryNode.APIUser,
-		tunnel.EntryNode.APIPass,
-	)
-
-	// 同步配置
-	if err := client.SyncTunnelConfig(config, tunnel.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "sync failed",
-			"message": fmt.Sprintf("同步到入口节点失败: %v", err),
-		})
-		return
+	// 根据节点状态返回不同提示
+	msg := "配置已更新，隧道经过的节点将在下次心跳时自动同步（最多 30 秒）"
+	if !allOnline {
+		msg = "配置已生成，离线节点上线后将自动加载最新配置"
 	}
 
+	result, _ := s.svc.GetTunnel(tunnel.ID)
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("隧道配置已同步到入口节点 %s", tunnel.EntryNode.Name),
+		"success":     true,
+		"message":     msg,
+		"sync_status": result.SyncStatus,
 	})
 }
 
+// tunnelPortKeys 隧道入口与目标端口字段
+var tunnelPortKeys = []string{"entry_node_id", "entry_port", "entry_ports", "target_addr"}
+
+// tunnelExitKeys 隧道出口设置字段
+var tunnelExitKeys = []string{"exit_node_id", "exit_node_ids", "exit_group_id", "exit_strategy"}
+
+func hasTunnelUpdates(updates map[string]interface{}, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := updates[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTunnelExitUpdates 将更新中的出口设置写入隧道副本
+// 只指定 exit_node_id 时视为切换为单一出口
+func applyTunnelExitUpdates(tunnel *model.Tunnel, updates map[string]interface{}) error {
+	_, hasList := updates["exit_node_ids"]
+	_, hasGroup := updates["exit_group_id"]
+	if _, ok := updates["exit_node_id"]; ok && !hasList && !hasGroup {
+		tunnel.ExitNodeIDs = ""
+		tunnel.ExitGroupID = nil
+	}
+	return applyTunnelUpdates(tunnel, updates, tunnelExitKeys, "出口设置")
+}
+
+// applyTunnelUpdates 将更新中的指定字段写入隧道副本
+func applyTunnelUpdates(tunnel *model.Tunnel, updates map[string]interface{}, keys []string, label string) error {
+	fields := make(map[string]interface{})
+	for _, key := range keys {
+		if value, ok := updates[key]; ok {
+			fields[key] = value
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, tunnel); err != nil {
+		return fmt.Errorf("%s格式错误: %v", label, err)
+	}
+	return nil
+}
+
+// shiftPorts 克隆时整个端口段顺延 (单个端口顺延 1)，避免与原规则端口冲突
+func shiftPorts(ports []int) []int {
+	low, high := ports[0], ports[0]
+	for _, port := range ports {
+		if port < low {
+			low = port
+		}
+		if port > high {
+			high = port
+		}
+	}
+	shifted := make([]int, len(ports))
+	for i, port := range ports {
+		shifted[i] = port + high - low + 1
+	}
+	return shifted
+}
+
+// decodeTunnelHops 解析请求中的中继跳点列表
+func decodeTunnelHops(raw interface{}, tunnel *model.Tunnel) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	tunnel.Hops = nil
+	if err := json.Unmarshal(data, &tunnel.Hops); err != nil {
+		return fmt.Errorf("中继跳点格式错误: %v", err)
+	}
+	return nil
+}
+
+// checkTunnelPathAccess 检查普通用户对隧道全部中继、出口节点 (及所用节点组) 的访问权限
+func (s *Server) checkTunnelPathAccess(c *gin.Context, tunnel *model.Tunnel, userID uint) bool {
+	if tunnel.ExitGroupID != nil {
+		if _, err := s.svc.GetNodeGroupByOwner(*tunnel.ExitGroupID, userID, false); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "无权使用此出口节点组"})
+			return false
+		}
+	}
+	for _, nodeID := range s.svc.TunnelExitNodeIDs(tunnel) {
+		if allowed, msg := s.svc.CheckPlanNodeAccess(userID, nodeID); !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "出口" + msg})
+			return false
+		}
+	}
+
+	for _, hop := range tunnel.Hops {
+		if hop.GroupID != nil {
+			if _, err := s.svc.GetNodeGroupByOwner(*hop.GroupID, userID, false); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "无权使用此中继节点组"})
+				return false
+			}
+		}
+		for _, node := range hop.Nodes {
+			if allowed, msg := s.svc.CheckPlanNodeAccess(userID, node.NodeID); !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": "中继" + msg})
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (s *Server) getTunnelEntryConfig(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 
@@ -3859,8 +4497,7 @@ func (s *Server) getTunnelEntryConfig(c *gin.Context) {
 		return
 	}
 
-	generator := gost.NewConfigGenerator()
-	config := generator.GenerateTunnelEntryConfig(tunnel)
+	config := s.svc.TunnelEntryConfig(tunnel)
 
 	c.YAML(http.StatusOK, config)
 }
@@ -3880,6 +4517,28 @@ func (s *Server) getTunnelExitConfig(c *gin.Context) {
 	c.YAML(http.StatusOK, config)
 }
 
+// getTunnelRelayConfig 中继节点承载的隧道跳点配置，按 "第N跳/节点名称" 分别返回
+func (s *Server) getTunnelRelayConfig(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+
+	tunnel, err := s.svc.GetTunnelByOwner(uint(id), userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tunnel not found"})
+		return
+	}
+
+	generator := gost.NewConfigGenerator()
+	configs := map[string]interface{}{}
+	for i, hop := range tunnel.Hops {
+		for _, relay := range hop.Nodes {
+			configs[fmt.Sprintf("hop-%d/%s", i+1, relay.Name)] = generator.GenerateTunnelRelayConfig(tunnel, i, relay.Node)
+		}
+	}
+
+	c.YAML(http.StatusOK, configs)
+}
+
 // ==================== 网站配置 ====================
 
 func (s *Server) getSiteConfigs(c *gin.Context) {
@@ -4289,6 +4948,12 @@ func (s *Server) createPlan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "套餐名称不能为空"})
 		return
 	}
+	if plan.PortRange != "" {
+		if _, err := gost.ParsePortRanges(plan.PortRange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "端口范围: " + err.Error()})
+			return
+		}
+	}
 
 	if err := s.svc.CreatePlan(&plan); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -4311,12 +4976,32 @@ func (s *Server) updatePlan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if portRange, ok := updates["port_range"].(string); ok && portRange != "" {
+		if _, err := gost.ParsePortRanges(portRange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "端口范围: " + err.Error()})
+			return
+		}
+	}
 
 	if err := s.svc.UpdatePlan(uint(id), updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 限制变更时更新所有使用该套餐的用户资源
+	_, speedChanged := updates["speed_limit"]
+	_, rateChanged := updates["conn_rate_limit"]
+	_, connsChanged := updates["max_conns"]
+	_, actionChanged := updates["quota_action"]
+	_, throttleChanged := updates["quota_throttle"]
+	if speedChanged || rateChanged || connsChanged || actionChanged || throttleChanged {
+		userIDs, _ := s.svc.GetPlanUserIDs(uint(id))
+		for _, userID := range userIDs {
+			s.svc.EnforceUserQuota(userID)
+			s.svc.ApplyUserLimits(userID)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -4359,6 +5044,7 @@ func (s *Server) assignUserPlan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	s.svc.ApplyUserLimits(uint(userID))
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -4376,6 +5062,7 @@ func (s *Server) removeUserPlan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	s.svc.ApplyUserLimits(uint(userID))
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -4406,6 +5093,7 @@ func (s *Server) renewUserPlan(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	s.svc.ApplyUserLimits(uint(userID))
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 告警事件 ====================
+
+// listAlertIncidents 获取告警事件列表 (status: open/firing/acknowledged/resolved)
+func (s *Server) listAlertIncidents(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	incidents, total, err := s.svc.GetAlertService().ListIncidents(c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"incidents": incidents,
+		"total":     total,
+	})
+}
+
+// getAlertIncident 获取单个告警事件
+func (s *Server) getAlertIncident(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	incident, err := s.svc.GetAlertService().GetIncident(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+		return
+	}
+	c.JSON(http.StatusOK, incident)
+}
+
+// ackAlertIncident 确认告警事件
+func (s *Server) ackAlertIncident(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	username := c.GetString("username")
+	if err := s.svc.GetAlertService().AckIncident(uint(id), username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "ack", "alert_incident", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// silenceAlertIncident 静默告警事件
+func (s *Server) silenceAlertIncident(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req struct {
+		Minutes int `json:"minutes" binding:"required,min=1,max=43200"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.GetAlertService().SilenceIncident(uint(id), time.Duration(req.Minutes)*time.Minute); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "silence", "alert_incident", uint(id), map[string]interface{}{"minutes": req.Minutes})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// resolveAlertIncident 手动关闭告警事件
+func (s *Server) resolveAlertIncident(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.GetAlertService().ResolveIncident(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "resolve", "alert_incident", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ==================== 指标规则预览 ====================
+
+// previewAlertRule 预览未保存的指标规则当前会触发的目标
+func (s *Server) previewAlertRule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req struct {
+		Type      string      `json:"type"`
+		AlertType string      `json:"alert_type"`
+		Condition interface{} `json:"condition"`
+		Severity  string      `json:"severity"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := &model.AlertRule{Type: req.Type, Severity: req.Severity}
+	if rule.Type == "" {
+		rule.Type = req.AlertType
+	}
+	switch v := req.Condition.(type) {
+	case string:
+		rule.Condition = v
+	case map[string]interface{}:
+		if data, err := json.Marshal(v); err == nil {
+			rule.Condition = string(data)
+		}
+	}
+
+	s.respondRulePreview(c, rule)
+}
+
+// previewSavedAlertRule 预览已保存的指标规则 (包含条件持续状态)
+func (s *Server) previewSavedAlertRule(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	rule, err := s.svc.GetAlertService().GetRule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rule not found"})
+		return
+	}
+
+	s.respondRulePreview(c, rule)
+}
+
+func (s *Server) respondRulePreview(c *gin.Context, rule *model.AlertRule) {
+	targets, err := s.svc.GetAlertService().PreviewRule(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"targets": targets,
+		"total":   len(targets),
+	})
+}
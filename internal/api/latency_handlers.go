@@ -0,0 +1,172 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AliceNetworks/gost-panel/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 节点间延迟矩阵 ====================
+
+// getLatencyMatrix 参与测量的节点及最近的延迟矩阵 (管理员)
+func (s *Server) getLatencyMatrix(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+	c.JSON(http.StatusOK, s.svc.GetLatencyMatrix())
+}
+
+// setLatencyNodesRequest 参与延迟测量的节点
+type setLatencyNodesRequest struct {
+	NodeIDs []uint `json:"node_ids"`
+}
+
+// setLatencyNodes 设置参与延迟测量的节点 (管理员)
+func (s *Server) setLatencyNodes(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req setLatencyNodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.svc.SetLatencyNodes(req.NodeIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "latency_nodes", 0, fmt.Sprintf("%d nodes", len(req.NodeIDs)))
+	c.JSON(http.StatusOK, s.svc.GetLatencyMatrix())
+}
+
+// getLatencyHistory 一对节点的测量历史 (管理员，source/target 为节点 ID)
+func (s *Server) getLatencyHistory(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	source, _ := strconv.ParseUint(c.Query("source"), 10, 32)
+	target, _ := strconv.ParseUint(c.Query("target"), 10, 32)
+	if source == 0 || target == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and target are required"})
+		return
+	}
+
+	records, err := s.svc.ListNodeLatencyHistory(uint(source), uint(target), probeHistoryRange(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// getRelayPath 为入口与出口推荐中继路径 (管理员)
+func (s *Server) getRelayPath(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	entry, _ := strconv.ParseUint(c.Query("entry"), 10, 32)
+	exit, _ := strconv.ParseUint(c.Query("exit"), 10, 32)
+	if entry == 0 || exit == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entry and exit are required"})
+		return
+	}
+	maxRelays, _ := strconv.Atoi(c.Query("max_relays"))
+
+	suggestion, err := s.svc.SuggestRelayPath(uint(entry), uint(exit), maxRelays)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// getNodeGroupWeightHints 节点组成员的权重建议 (from 为流量来源节点，可选)
+func (s *Server) getNodeGroupWeightHints(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+	if _, err := s.svc.GetNodeGroupByOwner(uint(id), userID, isAdmin); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此节点组"})
+		return
+	}
+	from, _ := strconv.ParseUint(c.Query("from"), 10, 32)
+
+	hints, err := s.svc.NodeGroupWeightHints(uint(id), uint(from))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hints)
+}
+
+// applyNodeGroupWeightHints 将权重建议写入节点组成员 (from 与获取建议时相同)
+func (s *Server) applyNodeGroupWeightHints(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+	if _, err := s.svc.GetNodeGroupByOwner(uint(id), userID, isAdmin); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作此节点组"})
+		return
+	}
+
+	from, _ := strconv.ParseUint(c.Query("from"), 10, 32)
+
+	changed, err := s.svc.ApplyNodeGroupWeightHints(uint(id), uint(from))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "node_group", uint(id), fmt.Sprintf("apply latency weight hints: %d members changed", changed))
+	c.JSON(http.StatusOK, gin.H{"changed": changed})
+}
+
+// agentLatencyPeers Agent 获取本节点的延迟测量目标
+func (s *Server) agentLatencyPeers(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+	c.JSON(http.StatusOK, s.svc.NodeLatencyPeers(node))
+}
+
+// agentLatencyResultsRequest Agent 上报的延迟测量结果
+type agentLatencyResultsRequest struct {
+	Results []service.LatencyReport `json:"results"`
+}
+
+// agentLatencyResults 接收 Agent 上报的延迟测量结果
+func (s *Server) agentLatencyResults(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	var req agentLatencyResultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted, err := s.svc.RecordNodeLatencies(node, req.Results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted})
+}
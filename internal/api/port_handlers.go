@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 节点端口分配 ====================
+
+// listNodePorts 节点上的端口分配 (含其他用户的资源，仅管理员)
+func (s *Server) listNodePorts(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	node, err := s.svc.GetNode(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+
+	allocs, err := s.svc.ListPortAllocations(node.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"port_range":  node.PortRange,
+		"allocations": allocs,
+	})
+}
+
+// getNodeFreePorts 在节点上为当前用户挑选 count 个连续的空闲端口
+func (s *Server) getNodeFreePorts(c *gin.Context) {
+	userID, isAdmin := getUserInfo(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if _, err := s.svc.GetNode(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+	if !isAdmin {
+		if allowed, msg := s.svc.CheckPlanNodeAccess(userID, uint(id)); !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+	}
+
+	count, _ := strconv.Atoi(c.DefaultQuery("count", "1"))
+	ports, err := s.svc.FindFreePorts(uint(id), &userID, count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"port":  ports[0],
+		"ports": gost.FormatPortSpec(ports),
+	})
+}
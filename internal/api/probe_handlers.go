@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 路径探测 ====================
+
+// probeKeys 隧道/代理链的探测设置字段
+var probeKeys = []string{"probe_type", "probe_target", "probe_interval"}
+
+// probeSettings 探测设置 (隧道与代理链字段相同)
+type probeSettings struct {
+	ProbeType     string `json:"probe_type"`
+	ProbeTarget   string `json:"probe_target"`
+	ProbeInterval int    `json:"probe_interval"`
+}
+
+// mergeProbeUpdates 将更新中的探测设置与现有设置合并后校验，并写回规范化后的值
+func mergeProbeUpdates(updates map[string]interface{}, current probeSettings) error {
+	delete(updates, "probe")
+	if !hasTunnelUpdates(updates, probeKeys) {
+		return nil
+	}
+	fields := make(map[string]interface{})
+	for _, key := range probeKeys {
+		if value, ok := updates[key]; ok {
+			fields[key] = value
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return fmt.Errorf("探测设置格式错误: %v", err)
+	}
+	if err := service.NormalizeProbeSettings(&current.ProbeType, &current.ProbeTarget, &current.ProbeInterval); err != nil {
+		return err
+	}
+	updates["probe_type"] = current.ProbeType
+	updates["probe_target"] = current.ProbeTarget
+	updates["probe_interval"] = current.ProbeInterval
+	return nil
+}
+
+// probeHistoryRange 探测历史的查询范围 (hours 默认 24，最多 7 天)
+func probeHistoryRange(c *gin.Context) time.Time {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if hours <= 0 || hours > 168 {
+		hours = 24
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour)
+}
+
+// getTunnelProbes 隧道的探测历史
+func (s *Server) getTunnelProbes(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+	tunnel, err := s.svc.GetTunnelByOwner(uint(id), userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tunnel not found"})
+		return
+	}
+
+	results, err := s.svc.ListPathProbeResults(service.ProbeResourceTunnel, tunnel.ID, probeHistoryRange(c), 1000)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": tunnel.Probe, "results": results})
+}
+
+// getProxyChainProbes 代理链的探测历史
+func (s *Server) getProxyChainProbes(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	userID, isAdmin := getUserInfo(c)
+	chain, err := s.svc.GetProxyChainByOwner(uint(id), userID, isAdmin)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proxy chain not found"})
+		return
+	}
+	s.svc.FillProxyChainProbes(chain)
+
+	results, err := s.svc.ListPathProbeResults(service.ProbeResourceChain, chain.ID, probeHistoryRange(c), 1000)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": chain.Probe, "results": results})
+}
+
+// agentProbes Agent 获取本节点需要执行的探测任务
+func (s *Server) agentProbes(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"probes": s.svc.NodeProbeSpecs(node)})
+}
+
+// agentProbeResultsRequest Agent 上报的探测结果
+type agentProbeResultsRequest struct {
+	Results []service.PathProbeReport `json:"results"`
+}
+
+// agentProbeResults 接收 Agent 上报的探测结果
+func (s *Server) agentProbeResults(c *gin.Context) {
+	node, err := s.svc.GetNodeByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	var req agentProbeResultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accepted, err := s.svc.RecordPathProbeResults(node, req.Results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted})
+}
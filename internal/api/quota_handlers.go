@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 超限处理 ====================
+
+// resetNodeQuota 重置节点配额并解除超限处理
+func (s *Server) resetNodeQuota(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.ResetNodeQuota(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "reset_quota", "node", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// listQuotaEnforcementLogs 获取超限处理变更记录
+func (s *Server) listQuotaEnforcementLogs(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	targetID, _ := strconv.ParseUint(c.Query("target_id"), 10, 32)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	logs, err := s.svc.ListQuotaEnforcementLogs(c.Query("target_type"), uint(targetID), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
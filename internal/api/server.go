@@ -3,7 +3,6 @@ package api
 import (
 	"context"
 	"embed"
-	"encoding/json"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -137,6 +136,10 @@ func (s *Server) setupRoutes() {
 		api.POST("/reset-password", s.resetPassword)
 		api.GET("/registration-status", s.getRegistrationStatus)
 
+		// 告警确认链接 (公开，签名认证，带限流)
+		api.GET("/alert-ack", RateLimitMiddleware(s.loginLimiter), s.getAlertAckLink)
+		api.POST("/alert-ack", RateLimitMiddleware(s.loginLimiter), s.ackAlertByLink)
+
 		// 需要认证的接口
 		auth := api.Group("")
 		auth.Use(s.authMiddleware())
@@ -165,11 +168,15 @@ func (s *Server) setupRoutes() {
 			auth.POST("/nodes/:id/clone", APIRateLimitMiddleware(s.writeAPILimiter), s.cloneNode)
 			auth.POST("/nodes/:id/sync", APIRateLimitMiddleware(s.writeAPILimiter), s.syncNodeConfig)
 			auth.GET("/nodes/:id/gost-config", s.getNodeGostConfig)
+			auth.POST("/nodes/:id/rotate-cert", APIRateLimitMiddleware(s.writeAPILimiter), s.rotateNodeCertificate)
+			auth.GET("/internal-ca", s.getInternalCA)
 			auth.GET("/nodes/:id/proxy-uri", s.getNodeProxyURI)
 			auth.GET("/nodes/:id/install-script", s.getNodeInstallScript)
 			auth.GET("/nodes/:id/ping", s.pingNode)
 			auth.GET("/nodes/ping", s.pingAllNodes)
 			auth.GET("/nodes/:id/health-logs", s.getNodeHealthLogs)
+			auth.GET("/nodes/:id/ports", s.listNodePorts)
+			auth.GET("/nodes/:id/free-ports", s.getNodeFreePorts)
 			auth.GET("/health-summary", s.getHealthSummary)
 
 			// 节点配置版本历史
@@ -196,6 +203,10 @@ func (s *Server) setupRoutes() {
 			auth.GET("/clients/:id/gost-config", s.getClientGostConfig)
 			auth.GET("/clients/:id/proxy-uri", s.getClientProxyURI)
 			auth.POST("/clients/:id/clone", s.cloneClient)
+			auth.GET("/clients/:id/exposures", s.listClientExposures)
+			auth.POST("/clients/:id/exposures", s.createClientExposure)
+			auth.PUT("/clients/:id/exposures/:eid", s.updateClientExposure)
+			auth.DELETE("/clients/:id/exposures/:eid", s.deleteClientExposure)
 
 			// 客户端批量操作
 			auth.POST("/clients/batch-enable", s.batchEnableClients)
@@ -220,12 +231,40 @@ func (s *Server) setupRoutes() {
 			auth.POST("/profile/2fa/verify", s.verify2FA)
 			auth.POST("/profile/2fa/disable", s.disable2FA)
 
+			// Telegram 机器人绑定
+			auth.GET("/profile/telegram", s.getTelegramLink)
+			auth.POST("/profile/telegram/link", s.createTelegramLinkCode)
+			auth.DELETE("/profile/telegram", s.unlinkTelegram)
+
+			// 个人通知渠道与订阅
+			auth.GET("/profile/notify-channels", s.listPersonalChannels)
+			auth.POST("/profile/notify-channels", s.createPersonalChannel)
+			auth.PUT("/profile/notify-channels/:id", s.updatePersonalChannel)
+			auth.DELETE("/profile/notify-channels/:id", s.deletePersonalChannel)
+			auth.POST("/profile/notify-channels/:id/test", s.testPersonalChannel)
+			auth.GET("/profile/notify-preferences", s.listUserNotifyPreferences)
+			auth.PUT("/profile/notify-preferences/:event", s.updateUserNotifyPreference)
+			auth.GET("/profile/notifications", s.listUserNotifications)
+
 			// 流量历史
 			auth.GET("/traffic-history", s.getTrafficHistory)
 
+			// 订阅链接
+			auth.GET("/subscription", s.getSubscription)
+			auth.POST("/subscription/reset", s.resetSubscription)
+			auth.DELETE("/subscription", s.revokeSubscription)
+
+			// 代理凭据 (共享节点按用户认证)
+			auth.GET("/proxy-credentials", s.listProxyCredentials)
+			auth.POST("/proxy-credentials", s.createProxyCredential)
+			auth.PUT("/proxy-credentials/:id", s.updateProxyCredential)
+			auth.DELETE("/proxy-credentials/:id", s.deleteProxyCredential)
+			auth.POST("/proxy-credentials/:id/reset-password", s.resetProxyCredentialPassword)
+
 			// 通知渠道管理
 			auth.GET("/notify-channels", s.listNotifyChannels)
 			auth.POST("/notify-channels", s.createNotifyChannel)
+			auth.POST("/notify-channels/test", s.testNotifyChannelConfig)
 			auth.GET("/notify-channels/:id", s.getNotifyChannel)
 			auth.PUT("/notify-channels/:id", s.updateNotifyChannel)
 			auth.DELETE("/notify-channels/:id", s.deleteNotifyChannel)
@@ -234,12 +273,44 @@ func (s *Server) setupRoutes() {
 			// 告警规则管理
 			auth.GET("/alert-rules", s.listAlertRules)
 			auth.POST("/alert-rules", s.createAlertRule)
+			auth.POST("/alert-rules/preview", s.previewAlertRule)
 			auth.GET("/alert-rules/:id", s.getAlertRule)
 			auth.PUT("/alert-rules/:id", s.updateAlertRule)
 			auth.DELETE("/alert-rules/:id", s.deleteAlertRule)
+			auth.GET("/alert-rules/:id/preview", s.previewSavedAlertRule)
 
 			// 告警日志
 			auth.GET("/alert-logs", s.getAlertLogs)
+			auth.POST("/alert-logs/:id/resend", s.resendAlertLog)
+
+			// 告警事件
+			auth.GET("/alert-incidents", s.listAlertIncidents)
+			auth.GET("/alert-incidents/:id", s.getAlertIncident)
+			auth.POST("/alert-incidents/:id/ack", s.ackAlertIncident)
+			auth.POST("/alert-incidents/:id/silence", s.silenceAlertIncident)
+			auth.POST("/alert-incidents/:id/resolve", s.resolveAlertIncident)
+
+			// 告警静默 / 维护窗口
+			auth.GET("/alert-silences", s.listAlertSilences)
+			auth.POST("/alert-silences", s.createAlertSilence)
+			auth.PUT("/alert-silences/:id", s.updateAlertSilence)
+			auth.DELETE("/alert-silences/:id", s.deleteAlertSilence)
+
+			// 告警升级策略 / 值班排班
+			auth.GET("/escalation-policies", s.listEscalationPolicies)
+			auth.POST("/escalation-policies", s.createEscalationPolicy)
+			auth.PUT("/escalation-policies/:id", s.updateEscalationPolicy)
+			auth.DELETE("/escalation-policies/:id", s.deleteEscalationPolicy)
+			auth.GET("/oncall-schedules", s.listOnCallSchedules)
+			auth.POST("/oncall-schedules", s.createOnCallSchedule)
+			auth.PUT("/oncall-schedules/:id", s.updateOnCallSchedule)
+			auth.DELETE("/oncall-schedules/:id", s.deleteOnCallSchedule)
+			auth.GET("/digests", s.listDigestSchedules)
+			auth.POST("/digests", s.createDigestSchedule)
+			auth.GET("/digests/preview", s.previewDigest)
+			auth.PUT("/digests/:id", s.updateDigestSchedule)
+			auth.DELETE("/digests/:id", s.deleteDigestSchedule)
+			auth.POST("/digests/:id/send", s.sendDigestNow)
 
 			// 操作日志
 			auth.GET("/operation-logs", s.getOperationLogs)
@@ -269,8 +340,11 @@ func (s *Server) setupRoutes() {
 			auth.GET("/node-groups/:id/members", s.listNodeGroupMembers)
 			auth.POST("/node-groups/:id/members", s.addNodeGroupMember)
 			auth.DELETE("/node-groups/:id/members/:memberId", s.removeNodeGroupMember)
+			auth.GET("/node-groups/:id/health-events", s.listNodeGroupHealthEvents)
 			auth.GET("/node-groups/:id/config", s.getNodeGroupConfig)
 			auth.POST("/node-groups/:id/clone", s.cloneNodeGroup)
+			auth.GET("/node-groups/:id/weight-hints", s.getNodeGroupWeightHints)
+			auth.POST("/node-groups/:id/weight-hints/apply", s.applyNodeGroupWeightHints)
 
 			// 代理链/隧道转发
 			auth.GET("/proxy-chains", s.listProxyChains)
@@ -283,6 +357,7 @@ func (s *Server) setupRoutes() {
 			auth.PUT("/proxy-chains/:id/hops/:hopId", s.updateProxyChainHop)
 			auth.DELETE("/proxy-chains/:id/hops/:hopId", s.removeProxyChainHop)
 			auth.GET("/proxy-chains/:id/config", s.getProxyChainConfig)
+			auth.GET("/proxy-chains/:id/probes", s.getProxyChainProbes)
 			auth.POST("/proxy-chains/:id/clone", s.cloneProxyChain)
 
 			// 隧道转发 (入口-出口模式)
@@ -292,8 +367,10 @@ func (s *Server) setupRoutes() {
 			auth.PUT("/tunnels/:id", s.updateTunnel)
 			auth.DELETE("/tunnels/:id", s.deleteTunnel)
 			auth.POST("/tunnels/:id/sync", s.syncTunnel)
+			auth.GET("/tunnels/:id/probes", s.getTunnelProbes)
 			auth.GET("/tunnels/:id/entry-config", s.getTunnelEntryConfig)
 			auth.GET("/tunnels/:id/exit-config", s.getTunnelExitConfig)
+			auth.GET("/tunnels/:id/relay-config", s.getTunnelRelayConfig)
 			auth.POST("/tunnels/:id/clone", s.cloneTunnel)
 
 			// 预配置模板
@@ -328,6 +405,8 @@ func (s *Server) setupRoutes() {
 			auth.POST("/users/:id/verify-email", s.adminVerifyUserEmail)
 			auth.POST("/users/:id/resend-verification", s.resendVerificationEmail)
 			auth.POST("/users/:id/reset-quota", s.resetUserQuota)
+			auth.POST("/nodes/:id/reset-quota", s.resetNodeQuota)
+			auth.GET("/quota-enforcements", s.listQuotaEnforcementLogs)
 			auth.POST("/users/:id/assign-plan", s.assignUserPlan)
 			auth.POST("/users/:id/remove-plan", s.removeUserPlan)
 			auth.POST("/users/:id/renew-plan", s.renewUserPlan)
@@ -341,6 +420,12 @@ func (s *Server) setupRoutes() {
 			auth.GET("/plans/:id/resources", s.getPlanResources)
 			auth.PUT("/plans/:id/resources", s.setPlanResources)
 
+			// 节点间延迟矩阵
+			auth.GET("/latency/matrix", s.getLatencyMatrix)
+			auth.PUT("/latency/nodes", s.setLatencyNodes)
+			auth.GET("/latency/history", s.getLatencyHistory)
+			auth.GET("/latency/path", s.getRelayPath)
+
 			// Bypass 分流规则
 			auth.GET("/bypasses", s.listBypasses)
 			auth.GET("/bypasses/:id", s.getBypass)
@@ -410,11 +495,26 @@ func (s *Server) setupRoutes() {
 		agent.GET("/download/:os/:arch", s.agentDownload)
 		// 客户端心跳 (通过 token 认证)
 		agent.POST("/client-heartbeat/:token", s.clientHeartbeat)
+		// GOST 插件回调 (共享节点认证/凭据流量)
+		agent.POST("/auth/:token", s.agentAuth)
+		agent.POST("/observe/:token", s.agentObserve)
+		agent.POST("/limiter/:token", s.agentLimiter)
+		// 路径探测任务与结果上报
+		agent.GET("/probes/:token", s.agentProbes)
+		agent.POST("/probes/:token", s.agentProbeResults)
+		agent.GET("/peers/:token", s.agentLatencyPeers)
+		agent.POST("/peers/:token", s.agentLatencyResults)
 	}
 
 	// WebSocket 接口
 	s.router.GET("/ws", s.handleWebSocket)
 
+	// 订阅接口 (使用订阅令牌认证)
+	s.router.GET("/sub/:token", s.subscribe)
+
+	// ACME HTTP-01 验证 (公开，由节点的域名暴露入口转发)
+	s.router.GET("/.well-known/acme-challenge/:token", s.acmeChallenge)
+
 	// 安装脚本接口 (公开)
 	scripts := s.router.Group("/scripts")
 	{
@@ -568,13 +668,21 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 func (s *Server) viewerWriteBlockMiddleware() gin.HandlerFunc {
 	// 个人账户管理路由 (viewer 也可以操作)
 	personalPaths := map[string]bool{
-		"/api/change-password":     true,
-		"/api/profile":             true,
-		"/api/profile/2fa/enable":  true,
-		"/api/profile/2fa/verify":  true,
-		"/api/profile/2fa/disable": true,
-		"/api/sessions/:id":        true,
-		"/api/sessions/others":     true,
+		"/api/change-password":                   true,
+		"/api/profile":                           true,
+		"/api/profile/2fa/enable":                true,
+		"/api/profile/2fa/verify":                true,
+		"/api/profile/2fa/disable":               true,
+		"/api/profile/telegram":                  true,
+		"/api/profile/telegram/link":             true,
+		"/api/profile/notify-channels":           true,
+		"/api/profile/notify-channels/:id":       true,
+		"/api/profile/notify-channels/:id/test":  true,
+		"/api/profile/notify-preferences/:event": true,
+		"/api/sessions/:id":                      true,
+		"/api/sessions/others":                   true,
+		"/api/subscription":                      true,
+		"/api/subscription/reset":                true,
 	}
 
 	return func(c *gin.Context) {
@@ -672,6 +780,7 @@ func (s *Server) login(c *gin.Context) {
 
 	// 记录登录成功
 	s.svc.LogOperation(user.ID, user.Username, "login", "user", user.ID, "login success", c.ClientIP(), c.GetHeader("User-Agent"), "success")
+	s.svc.GetAlertService().NotifyUserLogin(user, c.ClientIP(), c.GetHeader("User-Agent"))
 
 	// 生成 JWT with JTI
 	jti := uuid.New().String()
@@ -923,27 +1032,7 @@ func (s *Server) sendWelcomeEmail(user *model.User) {
 
 // getEmailSender 获取邮件发送器
 func (s *Server) getEmailSender() *notify.EmailSender {
-	// 查找 SMTP 类型的通知渠道
-	channels, err := s.svc.ListNotifyChannels()
-	if err != nil {
-		return nil
-	}
-
-	for _, ch := range channels {
-		if (ch.Type == "smtp" || ch.Type == "email") && ch.Enabled {
-			var smtpConfig model.SMTPConfig
-			if err := json.Unmarshal([]byte(ch.Config), &smtpConfig); err != nil {
-				continue
-			}
-			siteName := s.svc.GetSiteConfig(model.ConfigSiteName)
-			siteURL := s.svc.GetSiteConfig(model.ConfigSiteURL)
-			if siteName == "" {
-				siteName = "GOST Panel"
-			}
-			return notify.NewEmailSender(&smtpConfig, siteName, siteURL)
-		}
-	}
-	return nil
+	return s.svc.GetEmailSender()
 }
 
 func (s *Server) getStats(c *gin.Context) {
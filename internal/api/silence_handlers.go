@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 告警静默 / 维护窗口 ====================
+
+// AlertSilenceRequest 创建/更新静默请求
+type AlertSilenceRequest struct {
+	Name            string     `json:"name" binding:"required"`
+	Reason          string     `json:"reason"`
+	MatchType       string     `json:"match_type" binding:"required"` // all/node/tag/group
+	MatchID         uint       `json:"match_id"`
+	AlertTypes      []string   `json:"alert_types"` // 空=全部告警类型
+	StartsAt        *time.Time `json:"starts_at"`
+	EndsAt          *time.Time `json:"ends_at"`
+	Schedule        string     `json:"schedule"`     // cron 表达式，设置后为周期性维护窗口
+	DurationMin     int        `json:"duration_min"` // 维护窗口时长（分钟）
+	SkipHealthCheck bool       `json:"skip_health_check"`
+	Enabled         *bool      `json:"enabled"`
+}
+
+// apply 将请求写入静默
+func (req *AlertSilenceRequest) apply(silence *model.AlertSilence) {
+	silence.Name = req.Name
+	silence.Reason = req.Reason
+	silence.MatchType = req.MatchType
+	silence.MatchID = req.MatchID
+	silence.AlertTypes = strings.Join(req.AlertTypes, ",")
+	silence.StartsAt = req.StartsAt
+	silence.EndsAt = req.EndsAt
+	silence.Schedule = strings.TrimSpace(req.Schedule)
+	silence.DurationMin = req.DurationMin
+	silence.SkipHealthCheck = req.SkipHealthCheck
+	silence.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+// listAlertSilences 获取静默列表 (?active=true 仅返回当前生效的)
+func (s *Server) listAlertSilences(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	silences, err := s.svc.GetAlertService().ListSilences(c.Query("active") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, silences)
+}
+
+// createAlertSilence 创建静默
+func (s *Server) createAlertSilence(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	var req AlertSilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	silence := &model.AlertSilence{CreatedBy: c.GetString("username")}
+	req.apply(silence)
+	if err := s.svc.GetAlertService().CreateSilence(silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "alert_silence", silence.ID, map[string]interface{}{"name": silence.Name, "reason": silence.Reason})
+	c.JSON(http.StatusOK, silence)
+}
+
+// updateAlertSilence 更新静默
+func (s *Server) updateAlertSilence(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	silence, err := s.svc.GetAlertService().GetSilence(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "silence not found"})
+		return
+	}
+
+	var req AlertSilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.apply(silence)
+	if err := s.svc.GetAlertService().UpdateSilence(silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "alert_silence", silence.ID, nil)
+	c.JSON(http.StatusOK, silence)
+}
+
+// deleteAlertSilence 删除静默
+func (s *Server) deleteAlertSilence(c *gin.Context) {
+	_, isAdmin := getUserInfo(c)
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin only"})
+		return
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err := s.svc.GetAlertService().DeleteSilence(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "alert_silence", uint(id), nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 订阅链接 ====================
+
+// subscriptionFormats 支持的订阅格式
+var subscriptionFormats = []string{"base64", "clash", "singbox", "sip008"}
+
+// getSubscription 获取当前用户的订阅链接
+func (s *Server) getSubscription(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+
+	token, err := s.svc.GetSubscriptionToken(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.subscriptionLinks(c, token))
+}
+
+// resetSubscription 重新生成订阅令牌 (旧链接失效)
+func (s *Server) resetSubscription(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+
+	token, err := s.svc.ResetSubscriptionToken(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "reset", "subscription", userID, nil)
+	c.JSON(http.StatusOK, s.subscriptionLinks(c, token))
+}
+
+// revokeSubscription 撤销订阅链接
+func (s *Server) revokeSubscription(c *gin.Context) {
+	userID, isAdmin := getUserInfo(c)
+
+	// 管理员可撤销指定用户的订阅
+	targetID := userID
+	if isAdmin && c.Query("user_id") != "" {
+		var id uint
+		if _, err := fmt.Sscanf(c.Query("user_id"), "%d", &id); err == nil && id > 0 {
+			targetID = id
+		}
+	}
+
+	if err := s.svc.RevokeSubscriptionToken(targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "revoke", "subscription", targetID, nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) subscriptionLinks(c *gin.Context, token string) gin.H {
+	base := fmt.Sprintf("%s/sub/%s", s.getPanelURL(c), url.PathEscape(token))
+	links := gin.H{}
+	for _, format := range subscriptionFormats {
+		links[format] = base + "?format=" + format
+	}
+	return gin.H{
+		"token": token,
+		"url":   base,
+		"links": links,
+	}
+}
+
+// subscribe 订阅内容 (公开接口，使用订阅令牌认证)
+func (s *Server) subscribe(c *gin.Context) {
+	user, err := s.svc.GetUserBySubscriptionToken(c.Param("token"))
+	if err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+
+	nodes, groups, err := s.svc.GetSubscriptionNodes(user)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to load nodes")
+		return
+	}
+	nodes = gost.FilterSubscriptionNodes(nodes)
+
+	// 流量/到期信息 (Clash 等客户端识别此响应头)
+	var expire int64
+	if user.PlanExpireAt != nil {
+		expire = user.PlanExpireAt.Unix()
+	}
+	summary, _ := s.svc.GetUserTrafficSummary(user.ID)
+	var upload, download int64
+	if summary != nil {
+		upload, download = summary.TotalTrafficOut, summary.TotalTrafficIn
+	}
+	c.Header("Subscription-Userinfo", fmt.Sprintf("upload=%d; download=%d; total=%d; expire=%d",
+		upload, download, user.TrafficQuota, expire))
+	c.Header("Profile-Update-Interval", "12")
+
+	switch detectSubscriptionFormat(c) {
+	case "clash":
+		c.Header("Content-Disposition", "attachment; filename=clash.yaml")
+		c.YAML(http.StatusOK, gost.GenerateClashConfig(nodes, groups))
+	case "singbox":
+		c.JSON(http.StatusOK, gost.GenerateSingBoxConfig(nodes, groups))
+	case "sip008":
+		c.JSON(http.StatusOK, gost.GenerateSIP008(nodes))
+	default:
+		c.String(http.StatusOK, gost.GenerateSubscriptionBase64(nodes))
+	}
+}
+
+// detectSubscriptionFormat 优先使用 format 参数，否则根据 User-Agent 识别客户端
+func detectSubscriptionFormat(c *gin.Context) string {
+	if format := strings.ToLower(c.Query("format")); format != "" {
+		switch format {
+		case "clash", "mihomo", "clash-meta":
+			return "clash"
+		case "singbox", "sing-box":
+			return "singbox"
+		case "sip008":
+			return "sip008"
+		default:
+			return "base64"
+		}
+	}
+
+	ua := strings.ToLower(c.GetHeader("User-Agent"))
+	switch {
+	case strings.Contains(ua, "clash"), strings.Contains(ua, "mihomo"), strings.Contains(ua, "stash"):
+		return "clash"
+	case strings.Contains(ua, "sing-box"):
+		return "singbox"
+	default:
+		return "base64"
+	}
+}
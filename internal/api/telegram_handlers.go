@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== Telegram 机器人账户绑定 ====================
+
+// getTelegramLink 获取当前用户的 Telegram 绑定状态
+func (s *Server) getTelegramLink(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	user, err := s.svc.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":           s.svc.GetSiteConfig(model.ConfigTelegramBotToken) != "",
+		"linked":            user.TelegramID != 0,
+		"telegram_username": user.TelegramUsername,
+	})
+}
+
+// createTelegramLinkCode 生成一次性绑定码，用户将其发送给机器人完成绑定
+func (s *Server) createTelegramLinkCode(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	if s.svc.GetSiteConfig(model.ConfigTelegramBotToken) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "管理员未配置 Telegram 机器人"})
+		return
+	}
+
+	bot := s.svc.GetTelegramBot()
+	code := bot.CreateLinkCode(userID)
+	result := gin.H{
+		"code":       code,
+		"command":    "/link " + code,
+		"expires_in": 600,
+	}
+	if username := bot.BotUsername(); username != "" {
+		result["bot_username"] = username
+		result["deep_link"] = fmt.Sprintf("https://t.me/%s?start=%s", username, code)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// unlinkTelegram 解除 Telegram 绑定
+func (s *Server) unlinkTelegram(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	if err := s.svc.UnlinkTelegram(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "unlink", "telegram", userID, nil)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
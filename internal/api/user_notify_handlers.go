@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== 用户个人通知渠道 ====================
+
+// PersonalChannelRequest 创建/更新个人通知渠道请求
+// 邮件渠道只需 to，Telegram 渠道只需 chat_id (均可留空，默认使用账户邮箱 / 已绑定的 Telegram)
+type PersonalChannelRequest struct {
+	Name    string                 `json:"name" binding:"required"`
+	Type    string                 `json:"type" binding:"required"` // email/telegram/webhook
+	Config  map[string]interface{} `json:"config"`
+	Enabled *bool                  `json:"enabled"`
+}
+
+// apply 将请求写入个人渠道，缺省的收件人使用账户信息补全
+func (req *PersonalChannelRequest) apply(channel *model.NotifyChannel, user *model.User) {
+	config := req.Config
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	switch req.Type {
+	case "email":
+		if to, _ := config["to"].(string); to == "" && user.Email != nil {
+			config["to"] = *user.Email
+		}
+	case "telegram":
+		if chatID, _ := config["chat_id"].(string); chatID == "" && user.TelegramID != 0 {
+			config["chat_id"] = strconv.FormatInt(user.TelegramID, 10)
+		}
+	}
+	configJSON, _ := json.Marshal(config)
+
+	channel.Name = req.Name
+	channel.Type = req.Type
+	channel.Config = string(configJSON)
+	channel.UserID = user.ID
+	channel.Enabled = req.Enabled == nil || *req.Enabled
+}
+
+// bindPersonalChannel 解析请求并校验个人渠道
+func (s *Server) bindPersonalChannel(c *gin.Context, channel *model.NotifyChannel) bool {
+	userID, _ := getUserInfo(c)
+	user, err := s.svc.GetUser(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return false
+	}
+
+	var req PersonalChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if !notify.PersonalChannelTypes[req.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "个人渠道仅支持 email/telegram/webhook"})
+		return false
+	}
+
+	req.apply(channel, user)
+	if err := notify.ValidatePersonalChannel(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if channel.Type == "webhook" {
+		if err := notify.ValidatePublicWebhook(channel); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) listPersonalChannels(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	channels, err := s.svc.GetAlertService().ListUserChannels(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, channels)
+}
+
+func (s *Server) createPersonalChannel(c *gin.Context) {
+	channel := &model.NotifyChannel{}
+	if !s.bindPersonalChannel(c, channel) {
+		return
+	}
+
+	if err := s.svc.GetAlertService().CreateChannel(channel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "create", "personal_channel", channel.ID, map[string]interface{}{"name": channel.Name, "type": channel.Type})
+	c.JSON(http.StatusOK, channel)
+}
+
+func (s *Server) updatePersonalChannel(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	channel, err := s.svc.GetAlertService().GetUserChannel(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	if !s.bindPersonalChannel(c, channel) {
+		return
+	}
+
+	err = s.svc.GetAlertService().UpdateChannel(channel.ID, map[string]interface{}{
+		"name":    channel.Name,
+		"type":    channel.Type,
+		"config":  channel.Config,
+		"enabled": channel.Enabled,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "personal_channel", channel.ID, map[string]interface{}{"name": channel.Name})
+	c.JSON(http.StatusOK, channel)
+}
+
+func (s *Server) deletePersonalChannel(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	channel, err := s.svc.GetAlertService().GetUserChannel(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	if err := s.svc.GetAlertService().DeleteChannel(channel.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "delete", "personal_channel", channel.ID, map[string]interface{}{"name": channel.Name})
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (s *Server) testPersonalChannel(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	channel, err := s.svc.GetAlertService().GetUserChannel(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
+		return
+	}
+
+	if err := s.svc.GetAlertService().TestPersonalChannel(channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ==================== 用户通知订阅 ====================
+
+// UserNotifyPreferenceRequest 更新单个事件订阅请求
+type UserNotifyPreferenceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	ChannelIDs []uint `json:"channel_ids"`
+	Thresholds string `json:"thresholds"` // quota: 用量百分比；plan_expiry: 提前天数，逗号分隔
+}
+
+// formatUserNotifyPreference 转换订阅设置为前端格式 (channel_ids 为数组)
+func formatUserNotifyPreference(pref *model.UserNotifyPreference) gin.H {
+	channelIDs := make([]int, 0)
+	if pref.ChannelIDs != "" {
+		for _, idStr := range strings.Split(pref.ChannelIDs, ",") {
+			if id, err := strconv.Atoi(strings.TrimSpace(idStr)); err == nil {
+				channelIDs = append(channelIDs, id)
+			}
+		}
+	}
+	return gin.H{
+		"event":       pref.Event,
+		"enabled":     pref.Enabled,
+		"channel_ids": channelIDs,
+		"thresholds":  pref.Thresholds,
+		"updated_at":  pref.UpdatedAt,
+	}
+}
+
+func (s *Server) listUserNotifyPreferences(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+	prefs, err := s.svc.GetAlertService().ListUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := make([]gin.H, len(prefs))
+	for i := range prefs {
+		result[i] = formatUserNotifyPreference(&prefs[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) updateUserNotifyPreference(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+
+	var req UserNotifyPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref, err := s.svc.GetAlertService().SaveUserPreference(userID, c.Param("event"), req.Enabled, req.ChannelIDs, req.Thresholds)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.audit.LogSuccess(c, "update", "notify_preference", pref.ID, map[string]interface{}{"event": pref.Event, "enabled": pref.Enabled})
+	c.JSON(http.StatusOK, formatUserNotifyPreference(pref))
+}
+
+func (s *Server) listUserNotifications(c *gin.Context) {
+	userID, _ := getUserInfo(c)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	logs, total, err := s.svc.GetAlertService().ListUserNotifyLogs(userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logs": logs, "total": total})
+}
@@ -257,57 +257,6 @@ func (c *Client) DeleteChain(name string) error {
 	return c.delete("/config/chains/" + name)
 }
 
-// SyncTunnelConfig 同步隧道配置 (增量添加，不影响现有服务)
-func (c *Client) SyncTunnelConfig(config map[string]interface{}, tunnelID uint) error {
-	// 删除该隧道的旧服务和链
-	services, _ := c.GetServices()
-	for _, svc := range services {
-		if name, ok := svc["name"].(string); ok {
-			// 匹配 tunnel-{id}-tcp 或 tunnel-{id}-udp 格式
-			if matchTunnelService(name, tunnelID) {
-				c.delete("/config/services/" + name)
-			}
-		}
-	}
-
-	chains, _ := c.GetChains()
-	for _, chain := range chains {
-		if name, ok := chain["name"].(string); ok {
-			// 匹配 tunnel-chain-{id} 格式
-			if name == fmt.Sprintf("tunnel-chain-%d", tunnelID) {
-				c.delete("/config/chains/" + name)
-			}
-		}
-	}
-
-	// 创建新的转发链
-	if newChains, ok := config["chains"].([]map[string]interface{}); ok {
-		for _, chain := range newChains {
-			if err := c.post("/config/chains", chain); err != nil {
-				return fmt.Errorf("create chain failed: %w", err)
-			}
-		}
-	}
-
-	// 创建新服务
-	if newServices, ok := config["services"].([]map[string]interface{}); ok {
-		for _, svc := range newServices {
-			if err := c.post("/config/services", svc); err != nil {
-				return fmt.Errorf("create service failed: %w", err)
-			}
-		}
-	}
-
-	return nil
-}
-
-// matchTunnelService 检查服务名是否属于指定隧道
-func matchTunnelService(name string, tunnelID uint) bool {
-	// 匹配 tunnel-{id}-tcp, tunnel-{id}-udp, tunnel-{id}
-	prefix := fmt.Sprintf("tunnel-%d", tunnelID)
-	return name == prefix || name == prefix+"-tcp" || name == prefix+"-udp"
-}
-
 // UpdateService 更新服务配置
 func (c *Client) UpdateService(name string, config map[string]interface{}) error {
 	return c.put("/config/services/"+name, config)
@@ -0,0 +1,158 @@
+package gost
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 客户端暴露的局域网服务:
+// 客户端配置为每个暴露生成 rtcp/rudp 反向隧道服务，经节点主服务 (SOCKS5 BIND) 在节点上监听远程端口；
+// 按域名发布的 http 暴露经节点的隧道服务 (tunnel handler) 注册，节点的 HTTP/HTTPS 入口按域名路由到对应的隧道 (见 exposure_ingress.go)
+
+// ClientAPIPort 客户端 GOST API 端口 (与 Agent 默认的 GOST API 地址一致)
+const ClientAPIPort = 18080
+
+// ExposureTunnelPort 节点接入客户端域名暴露的隧道服务端口 (主端口+3000)
+func ExposureTunnelPort(node *model.Node) int {
+	return node.Port + 3000
+}
+
+// ExposureIngressEnabled 节点是否启用了域名暴露入口 (HTTP 或 HTTPS)
+func ExposureIngressEnabled(node *model.Node) bool {
+	return node.ExposeHTTPPort > 0 || node.ExposeHTTPSPort > 0
+}
+
+// ExposureServiceName 暴露在客户端配置中的服务名 (Agent 按服务名上报流量)
+func ExposureServiceName(exposure *model.ClientExposure, proto string) string {
+	return fmt.Sprintf("exposure-%d-%s", exposure.ID, proto)
+}
+
+// ParseExposureServiceName 从服务名解析暴露 ID
+func ParseExposureServiceName(serviceName string) uint {
+	var id uint
+	if n, _ := fmt.Sscanf(serviceName, "exposure-%d-", &id); n == 1 {
+		return id
+	}
+	return 0
+}
+
+// ExposureTunnelID 域名暴露的隧道 ID (UUID 格式)
+// 由客户端令牌派生，只有面板、客户端与节点知道
+func ExposureTunnelID(client *model.Client, exposure *model.ClientExposure) string {
+	mac := hmac.New(sha256.New, []byte(client.Token))
+	fmt.Fprintf(mac, "exposure-%d", exposure.ID)
+	b := mac.Sum(nil)[:16]
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ExposureHostRouted 暴露是否按域名发布
+func ExposureHostRouted(exposure *model.ClientExposure) bool {
+	return exposure.Protocol == "http" && exposure.Hostname != ""
+}
+
+// reverseForwarder 反向隧道转发到局域网目标
+func reverseForwarder(exposure *model.ClientExposure) map[string]interface{} {
+	return map[string]interface{}{
+		"nodes": []map[string]interface{}{
+			{"name": "target", "addr": exposure.LocalAddr},
+		},
+	}
+}
+
+// AddClientExposures 将暴露编译进客户端配置
+// chainName 为连接节点主服务的转发链，域名暴露使用独立的隧道链 (每条链携带自己的隧道 ID)
+func AddClientExposures(config map[string]interface{}, client *model.Client, node *model.Node, chainName string) {
+	added := false
+	for i := range client.Exposures {
+		exposure := &client.Exposures[i]
+		if !exposure.Enabled {
+			continue
+		}
+
+		if ExposureHostRouted(exposure) {
+			if !ExposureIngressEnabled(node) {
+				continue
+			}
+			tunnelChain := fmt.Sprintf("exposure-chain-%d", exposure.ID)
+			appendConfigItem(config, "chains", map[string]interface{}{
+				"name": tunnelChain,
+				"hops": []map[string]interface{}{
+					{
+						"name": "hop-0",
+						"nodes": []map[string]interface{}{
+							{
+								"name": "node-0",
+								"addr": fmt.Sprintf("%s:%d", node.Host, ExposureTunnelPort(node)),
+								"connector": map[string]interface{}{
+									"type": "tunnel",
+									"metadata": map[string]interface{}{
+										"tunnel.id": ExposureTunnelID(client, exposure),
+									},
+								},
+								"dialer": map[string]interface{}{
+									"type": "tcp",
+								},
+							},
+						},
+					},
+				},
+			})
+			appendConfigItem(config, "services", map[string]interface{}{
+				"name":     ExposureServiceName(exposure, "http"),
+				"addr":     ":0",
+				"observer": "stats-observer",
+				"handler": map[string]interface{}{
+					"type": "rtcp",
+				},
+				"listener": map[string]interface{}{
+					"type":  "rtcp",
+					"chain": tunnelChain,
+				},
+				"forwarder": reverseForwarder(exposure),
+			})
+			added = true
+			continue
+		}
+
+		proto := "tcp"
+		if exposure.Protocol == "udp" {
+			proto = "udp"
+		}
+		appendConfigItem(config, "services", map[string]interface{}{
+			"name":     ExposureServiceName(exposure, proto),
+			"addr":     fmt.Sprintf(":%d", exposure.RemotePort),
+			"observer": "stats-observer",
+			"handler": map[string]interface{}{
+				"type": "r" + proto,
+			},
+			"listener": map[string]interface{}{
+				"type":  "r" + proto,
+				"chain": chainName,
+				"metadata": map[string]interface{}{
+					"keepalive": true,
+				},
+			},
+			"forwarder": reverseForwarder(exposure),
+		})
+		added = true
+	}
+
+	// 暴露服务的流量由 Agent 通过 GOST API 读取观察器统计并上报
+	if added {
+		config["api"] = map[string]interface{}{
+			"addr": fmt.Sprintf("127.0.0.1:%d", ClientAPIPort),
+		}
+		appendConfigItem(config, "observers", map[string]interface{}{
+			"name": "stats-observer",
+			"plugin": map[string]interface{}{
+				"type": "http",
+				"addr": fmt.Sprintf("http://127.0.0.1:%d/observers/stats-observer", ClientAPIPort),
+			},
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package gost
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 节点的域名暴露入口:
+//   - exposure-tunnel: 隧道服务，客户端经隧道连接器注册，入口 (entrypoint) 只监听本机，按 ingress 将域名映射到隧道
+//   - exposure-http: HTTP 入口，嗅探 Host 后按域名、来源 IP 与 Basic 认证转发到隧道入口
+//   - exposure-https: HTTPS 入口，嗅探 SNI 后按域名与来源 IP 转发: 透传的域名直接转发到隧道入口 (由客户端局域网服务终止 TLS)，
+//     由节点终止 TLS 的域名转发到本机的 exposure-tls 服务
+//   - exposure-tls: 使用面板签发的证书终止 TLS，再按 Host 与 Basic 认证转发到隧道入口
+//
+// 来源 IP 白名单只在公网入口 (exposure-http/exposure-https) 上判断，本机服务看到的来源均为 127.0.0.1
+
+// 域名暴露证书在节点上的存放路径 (由 Agent 在注册时写入)
+const (
+	ExposureCertFile = InternalCertDir + "/exposure.crt"
+	ExposureKeyFile  = InternalCertDir + "/exposure.key"
+)
+
+// TLS 模式
+const (
+	ExposureTLSNone        = ""
+	ExposureTLSTerminate   = "terminate"
+	ExposureTLSPassthrough = "passthrough"
+)
+
+// acmeChallengePrefix ACME HTTP-01 验证路径，节点的 HTTP 入口将其转发到面板
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// exposureEntryPort 隧道服务入口端口 (主端口+3001，仅监听本机)
+func exposureEntryPort(node *model.Node) int {
+	return node.Port + 3001
+}
+
+// exposureTLSPort 终止 TLS 的本机服务端口 (主端口+3002)
+func exposureTLSPort(node *model.Node) int {
+	return node.Port + 3002
+}
+
+// ExposureIngressPorts 域名暴露入口在节点上占用的端口
+func ExposureIngressPorts(node *model.Node) []int {
+	if !ExposureIngressEnabled(node) {
+		return nil
+	}
+	ports := []int{ExposureTunnelPort(node), exposureEntryPort(node)}
+	if node.ExposeHTTPPort > 0 {
+		ports = append(ports, node.ExposeHTTPPort)
+	}
+	if node.ExposeHTTPSPort > 0 {
+		ports = append(ports, node.ExposeHTTPSPort, exposureTLSPort(node))
+	}
+	return ports
+}
+
+// ParseAllowIPs 解析逗号/空白分隔的 IP 或 CIDR 列表，返回规范化后的列表
+func ParseAllowIPs(raw string) ([]string, error) {
+	var entries []string
+	for _, item := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\t' || r == '\r'
+	}) {
+		if _, ipNet, err := net.ParseCIDR(item); err == nil {
+			entries = append(entries, ipNet.String())
+			continue
+		}
+		if ip := net.ParseIP(item); ip != nil {
+			entries = append(entries, ip.String())
+			continue
+		}
+		return nil, fmt.Errorf("无效的 IP 或网段: %s", item)
+	}
+	return entries, nil
+}
+
+// exposureMatcher 转发节点的匹配规则: 域名 (通配符域名使用正则)，checkClient 时附加来源 IP 白名单
+func exposureMatcher(exposure *model.ClientExposure, checkClient bool) map[string]interface{} {
+	rule := fmt.Sprintf("Host(`%s`)", exposure.Hostname)
+	if strings.HasPrefix(exposure.Hostname, "*.") {
+		rule = fmt.Sprintf("HostRegexp(`^[^.]+%s$`)", regexp.QuoteMeta(exposure.Hostname[1:]))
+	}
+	if checkClient {
+		if ips, _ := ParseAllowIPs(exposure.AllowIPs); len(ips) > 0 {
+			terms := make([]string, len(ips))
+			for i, ip := range ips {
+				terms[i] = fmt.Sprintf("ClientIP(`%s`)", ip)
+			}
+			rule = fmt.Sprintf("%s && (%s)", rule, strings.Join(terms, " || "))
+		}
+	}
+	return map[string]interface{}{"rule": rule}
+}
+
+// exposureForwardNode 入口服务转发到下一跳的节点，withAuth 时附加 Basic 认证 (需能看到明文 HTTP)
+func exposureForwardNode(exposure *model.ClientExposure, addr string, checkClient, withAuth bool) map[string]interface{} {
+	node := map[string]interface{}{
+		"name":    fmt.Sprintf("exposure-%d", exposure.ID),
+		"addr":    addr,
+		"matcher": exposureMatcher(exposure, checkClient),
+	}
+	if withAuth && exposure.AuthUser != "" {
+		node["http"] = map[string]interface{}{
+			"auth": map[string]string{
+				"username": exposure.AuthUser,
+				"password": exposure.AuthPass,
+			},
+		}
+	}
+	return node
+}
+
+// acmeChallengeNode 将 ACME HTTP-01 验证请求转发到面板 (证书由面板向 ACME 服务器申请)
+func (g *ConfigGenerator) acmeChallengeNode(hostnames []string) map[string]interface{} {
+	panel, err := url.Parse(g.panelURL)
+	if err != nil || panel.Host == "" || len(hostnames) == 0 {
+		return nil
+	}
+	addr := panel.Host
+	if panel.Port() == "" {
+		port := "80"
+		if panel.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(panel.Hostname(), port)
+	}
+
+	hosts := make([]string, len(hostnames))
+	for i, hostname := range hostnames {
+		hosts[i] = fmt.Sprintf("Host(`%s`)", hostname)
+	}
+	node := map[string]interface{}{
+		"name": "acme-challenge",
+		"addr": addr,
+		"matcher": map[string]interface{}{
+			"rule":     fmt.Sprintf("(%s) && PathPrefix(`%s`)", strings.Join(hosts, " || "), acmeChallengePrefix),
+			"priority": 100,
+		},
+		"http": map[string]interface{}{
+			"host": panel.Host,
+		},
+	}
+	if panel.Scheme == "https" {
+		node["tls"] = map[string]interface{}{
+			"serverName": panel.Hostname(),
+		}
+	}
+	return node
+}
+
+// sniffingService 嗅探 Host/SNI 后按转发节点的匹配规则转发的入口服务
+func sniffingService(name, addr string, nodes []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     name,
+		"addr":     addr,
+		"observer": "stats-observer",
+		"handler": map[string]interface{}{
+			"type": "tcp",
+			"metadata": map[string]interface{}{
+				"sniffing":           true,
+				"sniffing.websocket": true,
+			},
+		},
+		"listener": map[string]interface{}{
+			"type": "tcp",
+		},
+		"forwarder": map[string]interface{}{
+			"nodes": nodes,
+		},
+	}
+}
+
+// AddExposureIngress 为按域名发布到本节点的客户端暴露生成隧道服务、入口服务与域名路由
+// clients 为绑定本节点且有域名暴露的客户端 (已加载暴露)，cert 为节点终止 TLS 使用的证书 (可为 nil)；
+// 节点超限阻断时不下发
+func (g *ConfigGenerator) AddExposureIngress(config map[string]interface{}, node *model.Node, clients []model.Client, cert *model.ExposureCertificate) {
+	if !ExposureIngressEnabled(node) {
+		return
+	}
+	if blocked, _ := g.quotaEnforcement(node.QuotaEnforced, node.QuotaThrottle); blocked {
+		return
+	}
+
+	entryAddr := fmt.Sprintf("127.0.0.1:%d", exposureEntryPort(node))
+	tlsAddr := fmt.Sprintf("127.0.0.1:%d", exposureTLSPort(node))
+	terminateReady := node.ExposeHTTPSPort > 0 && cert != nil && cert.CertPEM != ""
+
+	var rules, httpNodes, httpsNodes, tlsNodes []map[string]interface{}
+	var terminateHosts []string
+	for i := range clients {
+		client := &clients[i]
+		for j := range client.Exposures {
+			exposure := &client.Exposures[j]
+			if !exposure.Enabled || !ExposureHostRouted(exposure) {
+				continue
+			}
+			rules = append(rules, map[string]interface{}{
+				"hostname": exposure.Hostname,
+				"endpoint": ExposureTunnelID(client, exposure),
+			})
+
+			switch exposure.TLSMode {
+			case ExposureTLSPassthrough:
+				httpsNodes = append(httpsNodes, exposureForwardNode(exposure, entryAddr, true, false))
+			case ExposureTLSTerminate:
+				httpNodes = append(httpNodes, exposureForwardNode(exposure, entryAddr, true, true))
+				if terminateReady {
+					httpsNodes = append(httpsNodes, exposureForwardNode(exposure, tlsAddr, true, false))
+					tlsNodes = append(tlsNodes, exposureForwardNode(exposure, entryAddr, false, true))
+				}
+				terminateHosts = append(terminateHosts, exposure.Hostname)
+			default:
+				httpNodes = append(httpNodes, exposureForwardNode(exposure, entryAddr, true, true))
+			}
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	ingressName := fmt.Sprintf("exposure-ingress-%d", node.ID)
+	appendConfigItem(config, "ingresses", map[string]interface{}{
+		"name":  ingressName,
+		"rules": rules,
+	})
+	appendConfigItem(config, "services", map[string]interface{}{
+		"name":     "exposure-tunnel",
+		"addr":     fmt.Sprintf(":%d", ExposureTunnelPort(node)),
+		"observer": "stats-observer",
+		"handler": map[string]interface{}{
+			"type":    "tunnel",
+			"ingress": ingressName,
+			"metadata": map[string]interface{}{
+				"entrypoint": entryAddr,
+			},
+		},
+		"listener": map[string]interface{}{
+			"type": "tcp",
+		},
+	})
+
+	if node.ExposeHTTPPort > 0 {
+		if acme := g.acmeChallengeNode(terminateHosts); acme != nil {
+			httpNodes = append(httpNodes, acme)
+		}
+		if len(httpNodes) > 0 {
+			appendConfigItem(config, "services", sniffingService("exposure-http", fmt.Sprintf(":%d", node.ExposeHTTPPort), httpNodes))
+		}
+	}
+
+	if node.ExposeHTTPSPort > 0 && len(httpsNodes) > 0 {
+		appendConfigItem(config, "services", sniffingService("exposure-https", fmt.Sprintf(":%d", node.ExposeHTTPSPort), httpsNodes))
+	}
+
+	if terminateReady && len(tlsNodes) > 0 {
+		service := sniffingService("exposure-tls", tlsAddr, tlsNodes)
+		service["listener"] = map[string]interface{}{
+			"type": "tls",
+			"tls": map[string]interface{}{
+				"certFile": ExposureCertFile,
+				"keyFile":  ExposureKeyFile,
+			},
+		}
+		// 证书轮换后配置随之变化，Agent 重新注册获取新证书后重载
+		service["metadata"] = map[string]interface{}{
+			"cert.serial": cert.Serial,
+		}
+		appendConfigItem(config, "services", service)
+	}
+}
@@ -3,18 +3,31 @@ package gost
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/AliceNetworks/gost-panel/internal/model"
 )
 
 // ConfigGenerator GOST 配置生成器
-type ConfigGenerator struct{}
+type ConfigGenerator struct {
+	panelURL string      // 面板地址 (共享节点的认证/观测插件回调)
+	plan     *model.Plan // 资源所有者当前生效的套餐 (限速/限连)
+
+	ownerQuotaAction   string // 资源所有者当前生效的超限处理
+	ownerQuotaThrottle int64
+}
 
 func NewConfigGenerator() *ConfigGenerator {
 	return &ConfigGenerator{}
 }
 
+// NewConfigGeneratorWithPanel 创建带面板地址的配置生成器
+func NewConfigGeneratorWithPanel(panelURL string) *ConfigGenerator {
+	return &ConfigGenerator{panelURL: strings.TrimSuffix(panelURL, "/")}
+}
+
 // GenerateNodeConfig 生成节点完整配置
 func (g *ConfigGenerator) GenerateNodeConfig(node *model.Node) map[string]interface{} {
 	return g.GenerateNodeConfigWithRules(node, nil, nil, nil)
@@ -29,7 +42,7 @@ func (g *ConfigGenerator) GenerateNodeConfigWithRules(node *model.Node, bypasses
 
 	// Metrics 配置
 	config["metrics"] = map[string]interface{}{
-		"addr": ":9000",
+		"addr": fmt.Sprintf(":%d", MetricsPort),
 	}
 
 	// Observer 配置
@@ -60,18 +73,26 @@ func (g *ConfigGenerator) GenerateNodeConfigWithRules(node *model.Node, bypasses
 		}
 	}
 
+	// 限速/限连配置 (节点设置 + 所有者套餐)
+	setLimiterRefs(mainService, addLimiters(config, "main", g.nodeLimits(node)))
+
 	services := []map[string]interface{}{mainService}
+
+	// 超限阻断: 只保留 API，不对外提供服务
+	if blocked, _ := g.quotaEnforcement(node.QuotaEnforced, node.QuotaThrottle); blocked {
+		services = []map[string]interface{}{}
+	}
 	config["services"] = services
 
 	// 认证器配置
-	if node.ProxyUser != "" {
+	if g.useAuther(node) {
 		config["authers"] = g.generateAuthers(node)
 	}
 
-	// 限速配置
-	if node.SpeedLimit > 0 || node.ConnRateLimit > 0 {
-		config["limiters"] = g.generateLimiters(node)
-		config["rlimiters"] = g.generateRateLimiters(node)
+	// 共享节点: 按凭据上报流量，按用户套餐限速
+	if g.sharedAuth(node) {
+		config["observers"] = append(config["observers"].([]map[string]interface{}), g.generateCredentialObserver(node))
+		appendConfigItem(config, "limiters", g.generateUserLimiter(node))
 	}
 
 	// DNS 配置
@@ -143,14 +164,6 @@ func (g *ConfigGenerator) generateMainService(node *model.Node) map[string]inter
 	// Listener 配置
 	service["listener"] = g.generateListener(node)
 
-	// 限速器
-	if node.SpeedLimit > 0 {
-		service["limiter"] = "speed-limiter"
-	}
-	if node.ConnRateLimit > 0 {
-		service["rlimiter"] = "rate-limiter"
-	}
-
 	// PROXY Protocol
 	if node.ProxyProtocol > 0 {
 		if listener, ok := service["listener"].(map[string]interface{}); ok {
@@ -208,13 +221,13 @@ func (g *ConfigGenerator) generateHandler(node *model.Node) map[string]interface
 	switch node.Protocol {
 	case "http":
 		handler["type"] = "http"
-		if node.ProxyUser != "" {
+		if g.useAuther(node) {
 			handler["auther"] = "main-auth"
 		}
 
 	case "socks5", "":
 		handler["type"] = "socks5"
-		if node.ProxyUser != "" {
+		if g.useAuther(node) {
 			handler["auther"] = "main-auth"
 		}
 		handler["metadata"] = map[string]interface{}{
@@ -280,7 +293,7 @@ func (g *ConfigGenerator) generateHandler(node *model.Node) map[string]interface
 
 	case "sshd":
 		handler["type"] = "sshd"
-		if node.ProxyUser != "" {
+		if g.useAuther(node) {
 			handler["auther"] = "main-auth"
 		}
 
@@ -299,6 +312,19 @@ func (g *ConfigGenerator) generateHandler(node *model.Node) map[string]interface
 		handler["resolver"] = "custom-resolver"
 	}
 
+	// 共享节点: 按认证返回的凭据 ID 统计流量 (增量上报)
+	if g.sharedAuth(node) && handler["auther"] != nil {
+		handler["observer"] = "credential-observer"
+		handler["limiter"] = "user-limiter"
+		metadata, _ := handler["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+		metadata["observer.period"] = "10s"
+		metadata["observer.resetTraffic"] = true
+		handler["metadata"] = metadata
+	}
+
 	return handler
 }
 
@@ -533,55 +559,54 @@ func (g *ConfigGenerator) generateTLSConfig(node *model.Node) map[string]interfa
 	return tls
 }
 
-// generateAuthers 生成认证器配置
-func (g *ConfigGenerator) generateAuthers(node *model.Node) []map[string]interface{} {
-	return []map[string]interface{}{
-		{
-			"name": "main-auth",
-			"auths": []map[string]string{
-				{"username": node.ProxyUser, "password": node.ProxyPass},
-			},
-		},
-	}
+// SharedAuthSupported 协议能否按用户凭据认证
+// ss/ssu 只有加密方式 + 单一密码，无法区分用户，不能作为共享节点
+func SharedAuthSupported(protocol string) bool {
+	return protocol != "ss" && protocol != "ssu"
 }
 
-// generateLimiters 生成限速器配置
-func (g *ConfigGenerator) generateLimiters(node *model.Node) []map[string]interface{} {
-	if node.SpeedLimit <= 0 {
-		return nil
-	}
+// sharedAuth 节点是否为共享节点 (由面板按用户凭据认证)
+func (g *ConfigGenerator) sharedAuth(node *model.Node) bool {
+	return node.SharedAuth && SharedAuthSupported(node.Protocol) && g.panelURL != "" && node.AgentToken != ""
+}
 
-	// 转换为合适的单位
-	limit := fmt.Sprintf("%dB", node.SpeedLimit)
-	if node.SpeedLimit >= 1024*1024*1024 {
-		limit = fmt.Sprintf("%.2fGB", float64(node.SpeedLimit)/(1024*1024*1024))
-	} else if node.SpeedLimit >= 1024*1024 {
-		limit = fmt.Sprintf("%.2fMB", float64(node.SpeedLimit)/(1024*1024))
-	} else if node.SpeedLimit >= 1024 {
-		limit = fmt.Sprintf("%.2fKB", float64(node.SpeedLimit)/1024)
-	}
+// useAuther 节点是否需要认证器
+func (g *ConfigGenerator) useAuther(node *model.Node) bool {
+	return node.ProxyUser != "" || g.sharedAuth(node)
+}
 
-	return []map[string]interface{}{
-		{
-			"name": "speed-limiter",
-			"limits": []string{
-				"$ " + limit,
-			},
+// generateCredentialObserver 生成凭据流量观测器 (回调面板)
+func (g *ConfigGenerator) generateCredentialObserver(node *model.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"name": "credential-observer",
+		"plugin": map[string]interface{}{
+			"type": "http",
+			"addr": fmt.Sprintf("%s/agent/observe/%s", g.panelURL, node.AgentToken),
 		},
 	}
 }
 
-// generateRateLimiters 生成连接速率限制器配置
-func (g *ConfigGenerator) generateRateLimiters(node *model.Node) []map[string]interface{} {
-	if node.ConnRateLimit <= 0 {
-		return nil
+// generateAuthers 生成认证器配置
+func (g *ConfigGenerator) generateAuthers(node *model.Node) []map[string]interface{} {
+	// 共享节点: 通过 HTTP 认证插件由面板校验用户凭据
+	if g.sharedAuth(node) {
+		return []map[string]interface{}{
+			{
+				"name": "main-auth",
+				"plugin": map[string]interface{}{
+					"type":    "http",
+					"addr":    fmt.Sprintf("%s/agent/auth/%s", g.panelURL, node.AgentToken),
+					"timeout": "5s",
+				},
+			},
+		}
 	}
 
 	return []map[string]interface{}{
 		{
-			"name": "rate-limiter",
-			"limits": []string{
-				fmt.Sprintf("$ %d/s", node.ConnRateLimit),
+			"name": "main-auth",
+			"auths": []map[string]string{
+				{"username": node.ProxyUser, "password": node.ProxyPass},
 			},
 		},
 	}
@@ -607,33 +632,48 @@ func (g *ConfigGenerator) generateResolvers(node *model.Node) []map[string]inter
 }
 
 // GeneratePortForwardConfig 生成端口转发配置
-func (g *ConfigGenerator) GeneratePortForwardConfig(pf *model.PortForward) map[string]interface{} {
-	handler := map[string]interface{}{
-		"type": pf.Type,
+// 监听地址为端口列表/范围时每个端口一个服务，目标端口按位置对应 (或全部转发到同一目标端口)
+func (g *ConfigGenerator) GeneratePortForwardConfig(pf *model.PortForward) []map[string]interface{} {
+	host, ports, err := SplitAddrPorts(pf.LocalAddr)
+	if err != nil {
+		return nil
 	}
+	targets := expandTargets(pf.RemoteAddr, ports)
 
-	listener := map[string]interface{}{
-		"type": pf.Type,
-	}
+	services := make([]map[string]interface{}, 0, len(ports))
+	for i, port := range ports {
+		handler := map[string]interface{}{
+			"type": pf.Type,
+		}
 
-	// RTCP/RUDP 远程转发需要在 listener 上配置 chain
-	if (pf.Type == "rtcp" || pf.Type == "rudp") && pf.ChainID != nil && *pf.ChainID > 0 {
-		listener["chain"] = fmt.Sprintf("chain-pf-%d", *pf.ChainID)
-	}
+		listener := map[string]interface{}{
+			"type": pf.Type,
+		}
 
-	service := map[string]interface{}{
-		"name":     pf.Name,
-		"addr":     pf.LocalAddr,
-		"handler":  handler,
-		"listener": listener,
-		"forwarder": map[string]interface{}{
-			"nodes": []map[string]interface{}{
-				{"name": "target", "addr": pf.RemoteAddr},
+		// RTCP/RUDP 远程转发需要在 listener 上配置 chain
+		if (pf.Type == "rtcp" || pf.Type == "rudp") && pf.ChainID != nil && *pf.ChainID > 0 {
+			listener["chain"] = fmt.Sprintf("chain-pf-%d", *pf.ChainID)
+		}
+
+		name := pf.Name
+		if len(ports) > 1 {
+			name = fmt.Sprintf("%s-%d", pf.Name, port)
+		}
+
+		services = append(services, map[string]interface{}{
+			"name":     name,
+			"addr":     net.JoinHostPort(host, strconv.Itoa(port)),
+			"handler":  handler,
+			"listener": listener,
+			"forwarder": map[string]interface{}{
+				"nodes": []map[string]interface{}{
+					{"name": "target", "addr": targets[i]},
+				},
 			},
-		},
+		})
 	}
 
-	return service
+	return services
 }
 
 // GenerateChainConfig 生成转发链配置 (用于负载均衡)
@@ -641,41 +681,19 @@ func (g *ConfigGenerator) GenerateChainConfig(group *model.NodeGroup, members []
 	nodes := make([]map[string]interface{}, 0, len(members))
 
 	for _, m := range members {
-		if !m.Member.Enabled {
+		if !m.Member.Active() {
 			continue
 		}
 
+		// 配置下载到面板之外的主机运行，该主机没有节点证书，连接成员节点的公开服务
+		// (面板节点经节点组转发时走隧道跳点，由 generateTunnelRelayNode/generateTunnelHopNode 使用 mTLS)
 		node := m.Node
-		nodeConfig := map[string]interface{}{
-			"name": fmt.Sprintf("node-%d", node.ID),
-			"addr": fmt.Sprintf("%s:%d", node.Host, node.Port),
-		}
-
-		// Connector 配置
-		connector := map[string]interface{}{
-			"type": node.Protocol,
-		}
-		if node.ProxyUser != "" {
-			connector["auth"] = map[string]string{
-				"username": node.ProxyUser,
-				"password": node.ProxyPass,
-			}
-		}
-		nodeConfig["connector"] = connector
+		nodeConfig := g.generateHopNode(fmt.Sprintf("node-%d", node.ID), node)
 
-		// Dialer 配置
-		dialer := map[string]interface{}{
-			"type": normalizeTransport(node.Transport),
-		}
-		if node.TLSEnabled {
-			dialer["tls"] = g.generateTLSConfig(node)
-		}
-		nodeConfig["dialer"] = dialer
-
-		// 权重
-		if m.Member.Weight > 0 {
+		// 权重 (健康检查调整后的有效权重)
+		if weight := m.Member.CurrentWeight(); weight > 0 {
 			nodeConfig["metadata"] = map[string]interface{}{
-				"weight": m.Member.Weight,
+				"weight": weight,
 			}
 		}
 
@@ -716,32 +734,10 @@ func (g *ConfigGenerator) GenerateProxyChainConfig(chain *model.ProxyChain, hops
 			continue
 		}
 
+		// 转发链中每一跳的 TLS 都由运行配置的主机经前面的跳点端到端建立，
+		// 该主机在面板之外，没有节点证书，因此连接各跳节点的公开服务
 		node := hop.Node
-		nodeConfig := map[string]interface{}{
-			"name": fmt.Sprintf("node-%d", node.ID),
-			"addr": fmt.Sprintf("%s:%d", node.Host, node.Port),
-		}
-
-		// Connector 配置
-		connector := map[string]interface{}{
-			"type": node.Protocol,
-		}
-		if node.ProxyUser != "" {
-			connector["auth"] = map[string]string{
-				"username": node.ProxyUser,
-				"password": node.ProxyPass,
-			}
-		}
-		nodeConfig["connector"] = connector
-
-		// Dialer 配置
-		dialer := map[string]interface{}{
-			"type": node.Transport,
-		}
-		if node.TLSEnabled {
-			dialer["tls"] = g.generateTLSConfig(node)
-		}
-		nodeConfig["dialer"] = dialer
+		nodeConfig := g.generateHopNode(fmt.Sprintf("node-%d", node.ID), node)
 
 		hopConfig := map[string]interface{}{
 			"name":  fmt.Sprintf("hop-%d", i),
@@ -804,80 +800,63 @@ func (g *ConfigGenerator) GenerateProxyChainFullConfig(chain *model.ProxyChain,
 // GenerateTunnelEntryConfig 生成隧道入口端配置 (部署在入口节点)
 // 支持端口复用：tcp+udp 模式下同一端口同时监听 TCP 和 UDP
 func (g *ConfigGenerator) GenerateTunnelEntryConfig(tunnel *model.Tunnel) map[string]interface{} {
-	exitNode := tunnel.ExitNode
-	if exitNode == nil {
+	hop := g.generateTunnelNextHop(tunnel, 0)
+	if hop == nil {
 		return nil
 	}
 
 	chainName := fmt.Sprintf("tunnel-chain-%d", tunnel.ID)
 
-	// 转发链配置 - 连接到出口节点
-	connector := map[string]interface{}{
-		"type": exitNode.Protocol,
-	}
-	if exitNode.ProxyUser != "" {
-		connector["auth"] = map[string]string{
-			"username": exitNode.ProxyUser,
-			"password": exitNode.ProxyPass,
-		}
-	}
-
-	dialer := map[string]interface{}{
-		"type": normalizeTransport(exitNode.Transport),
-	}
-	if exitNode.TLSEnabled {
-		dialer["tls"] = g.generateTLSConfig(exitNode)
-	}
-
+	// 转发链配置 - 连接到第一个中继跳点或出口节点的隧道中继 (启用 mTLS 中继时双向证书认证)
 	chain := map[string]interface{}{
 		"name": chainName,
-		"hops": []map[string]interface{}{
-			{
-				"name": "hop-0",
-				"nodes": []map[string]interface{}{
-					{
-						"name":      fmt.Sprintf("exit-%d", exitNode.ID),
-						"addr":      fmt.Sprintf("%s:%d", exitNode.Host, exitNode.Port),
-						"connector": connector,
-						"dialer":    dialer,
-					},
-				},
-			},
-		},
+		"hops": []map[string]interface{}{hop},
 	}
 
-	// 生成服务列表 - 支持端口复用 (tcp+udp)
+	// 生成服务列表 - 支持端口复用 (tcp+udp)，多端口隧道每个端口一个服务
 	services := []map[string]interface{}{}
 	protocols := g.parseProtocols(tunnel.Protocol)
+	ports := TunnelEntryPorts(tunnel)
+	var targets []string
+	if tunnel.TargetAddr != "" {
+		targets = expandTargets(tunnel.TargetAddr, ports)
+	}
 
-	for _, proto := range protocols {
-		service := map[string]interface{}{
-			"name": fmt.Sprintf("tunnel-%d-%s", tunnel.ID, proto),
-			"addr": fmt.Sprintf(":%d", tunnel.EntryPort),
-			"handler": map[string]interface{}{
-				"type":  proto,
-				"chain": chainName,
-			},
-			"listener": map[string]interface{}{
-				"type": proto,
-			},
-		}
-
-		// 如果有目标地址，添加 forwarder
-		if tunnel.TargetAddr != "" {
-			service["forwarder"] = map[string]interface{}{
-				"nodes": []map[string]interface{}{
-					{"name": "target", "addr": tunnel.TargetAddr},
+	for i, port := range ports {
+		for _, proto := range protocols {
+			name := fmt.Sprintf("tunnel-%d-%s", tunnel.ID, proto)
+			if len(ports) > 1 {
+				name = fmt.Sprintf("tunnel-%d-%s-%d", tunnel.ID, proto, port)
+			}
+			service := map[string]interface{}{
+				"name":     name,
+				"addr":     fmt.Sprintf(":%d", port),
+				"observer": "stats-observer",
+				"handler": map[string]interface{}{
+					"type":  proto,
+					"chain": chainName,
+				},
+				"listener": map[string]interface{}{
+					"type": proto,
 				},
 			}
-		}
 
-		// 限速配置
-		if tunnel.SpeedLimit > 0 {
-			service["limiter"] = "tunnel-limiter"
+			// 如果有目标地址，添加 forwarder (目标端口与入口端口按位置对应)
+			if targets != nil {
+				service["forwarder"] = map[string]interface{}{
+					"nodes": []map[string]interface{}{
+						{"name": "target", "addr": targets[i]},
+					},
+				}
+			}
+
+			services = append(services, service)
 		}
+	}
 
-		services = append(services, service)
+	// 所有者超限阻断: 不下发隧道服务
+	if blocked, _ := g.quotaEnforcement("", 0); blocked {
+		services = []map[string]interface{}{}
 	}
 
 	config := map[string]interface{}{
@@ -885,22 +864,10 @@ func (g *ConfigGenerator) GenerateTunnelEntryConfig(tunnel *model.Tunnel) map[st
 		"chains":   []map[string]interface{}{chain},
 	}
 
-	// 添加限速器
-	if tunnel.SpeedLimit > 0 {
-		limit := fmt.Sprintf("%dB", tunnel.SpeedLimit)
-		if tunnel.SpeedLimit >= 1024*1024*1024 {
-			limit = fmt.Sprintf("%.2fGB", float64(tunnel.SpeedLimit)/(1024*1024*1024))
-		} else if tunnel.SpeedLimit >= 1024*1024 {
-			limit = fmt.Sprintf("%.2fMB", float64(tunnel.SpeedLimit)/(1024*1024))
-		} else if tunnel.SpeedLimit >= 1024 {
-			limit = fmt.Sprintf("%.2fKB", float64(tunnel.SpeedLimit)/1024)
-		}
-		config["limiters"] = []map[string]interface{}{
-			{
-				"name":   "tunnel-limiter",
-				"limits": []string{"$ " + limit},
-			},
-		}
+	// 限速/限连配置 (隧道设置 + 所有者套餐)，同一隧道所有端口的 tcp/udp 服务共享限制器
+	refs := addLimiters(config, fmt.Sprintf("tunnel-%d", tunnel.ID), g.tunnelLimits(tunnel))
+	for _, service := range services {
+		setLimiterRefs(service, refs)
 	}
 
 	return config
@@ -933,12 +900,26 @@ func normalizeTransport(transport string) string {
 }
 
 // GenerateTunnelExitConfig 生成隧道出口端配置 (部署在出口节点)
-// 出口节点使用标准节点配置即可
+// 接入该隧道的中继服务 (出口启用 mTLS 中继时为带隧道认证的 mTLS 中继)
+// 多出口隧道按出口节点名称分别返回各节点的配置
 func (g *ConfigGenerator) GenerateTunnelExitConfig(tunnel *model.Tunnel) map[string]interface{} {
-	if tunnel.ExitNode == nil {
+	exits := tunnelExitNodes(tunnel)
+	if len(exits) == 0 {
 		return nil
 	}
-	return g.GenerateNodeConfig(tunnel.ExitNode)
+	if len(exits) == 1 {
+		config := map[string]interface{}{}
+		g.addTunnelExits(config, exits[0], []model.Tunnel{*tunnel})
+		return config
+	}
+
+	configs := map[string]interface{}{}
+	for _, exit := range exits {
+		config := map[string]interface{}{}
+		g.addTunnelExits(config, exit, []model.Tunnel{*tunnel})
+		configs[exit.Name] = config
+	}
+	return configs
 }
 
 // ==================== Bypass/Admission/Hosts 配置生成 ====================
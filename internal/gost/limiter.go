@@ -0,0 +1,180 @@
+package gost
+
+import (
+	"fmt"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// limitSpec 单个服务的限速/限连参数 (0 表示不限制)
+// $ 为服务级限制，$$ 为客户端级限制
+type limitSpec struct {
+	speed          int64 // 服务总带宽 ($)
+	connRate       int   // 服务每秒新建连接数 ($)
+	clientConnRate int   // 每客户端每秒新建连接数 ($$)
+	clientMaxConns int   // 每客户端最大并发连接数 ($$)
+}
+
+func (l limitSpec) empty() bool {
+	return l.speed <= 0 && l.connRate <= 0 && l.clientConnRate <= 0 && l.clientMaxConns <= 0
+}
+
+// WithPlan 设置资源所有者当前生效的套餐，生成配置时叠加套餐限制
+func (g *ConfigGenerator) WithPlan(plan *model.Plan) *ConfigGenerator {
+	g.plan = plan
+	return g
+}
+
+// WithOwnerQuota 设置资源所有者当前生效的超限处理 (action 为空表示未超限)
+func (g *ConfigGenerator) WithOwnerQuota(action string, throttle int64) *ConfigGenerator {
+	g.ownerQuotaAction = action
+	g.ownerQuotaThrottle = throttle
+	return g
+}
+
+// quotaEnforcement 合并资源自身与所有者的超限处理，返回是否阻断以及超限限速值 (0=不限速)
+func (g *ConfigGenerator) quotaEnforcement(action string, throttle int64) (bool, int64) {
+	if action == model.QuotaActionBlock || g.ownerQuotaAction == model.QuotaActionBlock {
+		return true, 0
+	}
+	var limit int64
+	if action == model.QuotaActionThrottle {
+		limit = minLimit(limit, quotaThrottle(throttle))
+	}
+	if g.ownerQuotaAction == model.QuotaActionThrottle {
+		limit = minLimit(limit, quotaThrottle(g.ownerQuotaThrottle))
+	}
+	return false, limit
+}
+
+func quotaThrottle(throttle int64) int64 {
+	if throttle <= 0 {
+		return model.DefaultQuotaThrottle
+	}
+	return throttle
+}
+
+// nodeLimits 节点主服务的限制: 节点设置与所有者套餐取较严格者
+// 共享节点的套餐限速按用户单独下发 (见 generateUserLimiter)，此处只保留节点自身设置
+func (g *ConfigGenerator) nodeLimits(node *model.Node) limitSpec {
+	spec := limitSpec{
+		speed:    node.SpeedLimit,
+		connRate: node.ConnRateLimit,
+	}
+	if g.plan != nil && !g.sharedAuth(node) {
+		spec.speed = minLimit(spec.speed, g.plan.SpeedLimit)
+		spec.clientConnRate = g.plan.ConnRateLimit
+		spec.clientMaxConns = g.plan.MaxConns
+	}
+	if _, throttle := g.quotaEnforcement(node.QuotaEnforced, node.QuotaThrottle); throttle > 0 {
+		spec.speed = minLimit(spec.speed, throttle)
+	}
+	return spec
+}
+
+// tunnelLimits 隧道服务的限制: 隧道设置与所有者套餐取较严格者
+func (g *ConfigGenerator) tunnelLimits(tunnel *model.Tunnel) limitSpec {
+	spec := limitSpec{speed: tunnel.SpeedLimit}
+	if g.plan != nil {
+		spec.speed = minLimit(spec.speed, g.plan.SpeedLimit)
+		spec.clientConnRate = g.plan.ConnRateLimit
+		spec.clientMaxConns = g.plan.MaxConns
+	}
+	if _, throttle := g.quotaEnforcement("", 0); throttle > 0 {
+		spec.speed = minLimit(spec.speed, throttle)
+	}
+	return spec
+}
+
+// addLimiters 生成名为 {name}-limiter/-rlimiter/-climiter 的限制器并追加到配置中
+// 返回服务需要引用的字段 (limiter/rlimiter/climiter)
+func addLimiters(config map[string]interface{}, name string, spec limitSpec) map[string]string {
+	refs := map[string]string{}
+	if spec.empty() {
+		return refs
+	}
+
+	if spec.speed > 0 {
+		limiterName := name + "-limiter"
+		appendConfigItem(config, "limiters", map[string]interface{}{
+			"name":   limiterName,
+			"limits": []string{"$ " + formatRate(spec.speed)},
+		})
+		refs["limiter"] = limiterName
+	}
+
+	rateLimits := []string{}
+	if spec.connRate > 0 {
+		rateLimits = append(rateLimits, fmt.Sprintf("$ %d/s", spec.connRate))
+	}
+	if spec.clientConnRate > 0 {
+		rateLimits = append(rateLimits, fmt.Sprintf("$$ %d/s", spec.clientConnRate))
+	}
+	if len(rateLimits) > 0 {
+		limiterName := name + "-rlimiter"
+		appendConfigItem(config, "rlimiters", map[string]interface{}{
+			"name":   limiterName,
+			"limits": rateLimits,
+		})
+		refs["rlimiter"] = limiterName
+	}
+
+	if spec.clientMaxConns > 0 {
+		limiterName := name + "-climiter"
+		appendConfigItem(config, "climiters", map[string]interface{}{
+			"name":   limiterName,
+			"limits": []string{fmt.Sprintf("$$ %d", spec.clientMaxConns)},
+		})
+		refs["climiter"] = limiterName
+	}
+
+	return refs
+}
+
+// setLimiterRefs 在服务上引用限制器
+func setLimiterRefs(service map[string]interface{}, refs map[string]string) {
+	for key, name := range refs {
+		service[key] = name
+	}
+}
+
+// generateUserLimiter 共享节点按用户限速: 由面板根据凭据所属用户的套餐返回限速值
+func (g *ConfigGenerator) generateUserLimiter(node *model.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"name": "user-limiter",
+		"plugin": map[string]interface{}{
+			"type": "http",
+			"addr": fmt.Sprintf("%s/agent/limiter/%s", g.panelURL, node.AgentToken),
+		},
+	}
+}
+
+func appendConfigItem(config map[string]interface{}, key string, item map[string]interface{}) {
+	items, _ := config[key].([]map[string]interface{})
+	config[key] = append(items, item)
+}
+
+// formatRate 将 bytes/s 转换为 GOST 限速单位
+func formatRate(bytes int64) string {
+	switch {
+	case bytes >= 1024*1024*1024:
+		return fmt.Sprintf("%.2fGB", float64(bytes)/(1024*1024*1024))
+	case bytes >= 1024*1024:
+		return fmt.Sprintf("%.2fMB", float64(bytes)/(1024*1024))
+	case bytes >= 1024:
+		return fmt.Sprintf("%.2fKB", float64(bytes)/1024)
+	default:
+		return fmt.Sprintf("%dB", bytes)
+	}
+}
+
+// minLimit 返回两个限制中较小的非零值 (0 表示不限制)
+func minLimit(a, b int64) int64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,108 @@
+package gost
+
+import (
+	"fmt"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 面板内部 CA 签发的证书在节点上的存放路径 (由 Agent 在注册时写入)
+const (
+	InternalCertDir  = "/etc/gost/certs"
+	InternalCAFile   = InternalCertDir + "/ca.crt"
+	InternalCertFile = InternalCertDir + "/node.crt"
+	InternalKeyFile  = InternalCertDir + "/node.key"
+)
+
+// InternalServerName 节点证书中的内部域名，用于 mTLS 校验 (与节点公网地址无关)
+func InternalServerName(nodeID uint) string {
+	return fmt.Sprintf("node-%d.gost-panel.internal", nodeID)
+}
+
+// MTLSRelayPort 节点 mTLS 中继服务端口
+func MTLSRelayPort(node *model.Node) int {
+	if node.MTLSPort > 0 {
+		return node.MTLSPort
+	}
+	return node.Port + 2000
+}
+
+// mtlsReady 节点是否启用 mTLS 中继且已签发证书
+func mtlsReady(node *model.Node) bool {
+	return node.MTLSEnabled && node.CertPEM != ""
+}
+
+// generateMTLSRelayService 生成 mTLS 中继服务 (校验客户端证书，仅允许本面板节点接入)
+// 只在节点是隧道出口时生成，handler 由 addTunnelExits 设置为只接受隧道凭据；
+// 不带认证的中继会让任何持有面板证书的节点把它当作开放代理。节点的公开服务照常对用户开放，不受 mTLS 影响
+func (g *ConfigGenerator) generateMTLSRelayService(node *model.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     "mtls-relay",
+		"addr":     fmt.Sprintf(":%d", MTLSRelayPort(node)),
+		"observer": "stats-observer",
+		"listener": map[string]interface{}{
+			"type": "mtls",
+			// 配置 caFile 后 GOST 要求并校验客户端证书
+			"tls": map[string]interface{}{
+				"certFile": InternalCertFile,
+				"keyFile":  InternalKeyFile,
+				"caFile":   InternalCAFile,
+			},
+		},
+	}
+}
+
+// generateMTLSHopNode 使用本节点证书通过 mtls 连接目标节点的中继端口
+// 只用于隧道跳点，调用方已按 tunnelHopMTLS 确认拨号的节点都持有面板签发的证书
+func (g *ConfigGenerator) generateMTLSHopNode(name string, node *model.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"name": name,
+		"addr": fmt.Sprintf("%s:%d", node.Host, MTLSRelayPort(node)),
+		"connector": map[string]interface{}{
+			"type": "relay",
+		},
+		"dialer": map[string]interface{}{
+			"type": "mtls",
+			"tls":  internalClientTLS(node),
+		},
+	}
+}
+
+// internalClientTLS 使用本节点证书连接目标节点时的 TLS 配置 (双向校验，按内部域名校验目标证书)
+func internalClientTLS(target *model.Node) map[string]interface{} {
+	return map[string]interface{}{
+		"certFile":   InternalCertFile,
+		"keyFile":    InternalKeyFile,
+		"caFile":     InternalCAFile,
+		"serverName": InternalServerName(target.ID),
+	}
+}
+
+// generateHopNode 生成下载的转发链 (节点组/代理链) 中连接目标节点公开服务的 node 配置
+// 这些配置运行在面板之外的主机上，没有节点证书，不能经 mTLS 中继接入 (启用 mTLS 中继不会关闭公开服务)；
+// 面板节点之间的转发链即隧道跳点，由 generateTunnelHopNode/generateTunnelRelayNode 使用 mTLS 与隧道凭据
+func (g *ConfigGenerator) generateHopNode(name string, node *model.Node) map[string]interface{} {
+	connector := map[string]interface{}{
+		"type": node.Protocol,
+	}
+	if node.ProxyUser != "" {
+		connector["auth"] = map[string]string{
+			"username": node.ProxyUser,
+			"password": node.ProxyPass,
+		}
+	}
+
+	dialer := map[string]interface{}{
+		"type": normalizeTransport(node.Transport),
+	}
+	if node.TLSEnabled {
+		dialer["tls"] = g.generateTLSConfig(node)
+	}
+
+	return map[string]interface{}{
+		"name":      name,
+		"addr":      fmt.Sprintf("%s:%d", node.Host, node.Port),
+		"connector": connector,
+		"dialer":    dialer,
+	}
+}
@@ -0,0 +1,185 @@
+package gost
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// MaxPortsPerRule 单条隧道/转发规则最多展开的端口数
+const MaxPortsPerRule = 1000
+
+// MetricsPort 节点 Prometheus 指标端口
+const MetricsPort = 9000
+
+// PortRange 端口范围 (含两端)
+type PortRange struct {
+	From int
+	To   int
+}
+
+// ParsePortRanges 解析端口列表与范围，如 "10000-20000,30000"，不限制端口数量
+func ParsePortRanges(spec string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			start, end = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		from, err1 := strconv.Atoi(start)
+		to, err2 := strconv.Atoi(end)
+		if err1 != nil || err2 != nil || from < 1 || to > 65535 || from > to {
+			return nil, fmt.Errorf("无效的端口: %s", part)
+		}
+		ranges = append(ranges, PortRange{From: from, To: to})
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("端口不能为空")
+	}
+	return ranges, nil
+}
+
+// PortsInRanges 检查端口是否都在允许的范围内，返回第一个超出范围的端口
+func PortsInRanges(ports []int, ranges []PortRange) (int, bool) {
+	for _, port := range ports {
+		allowed := false
+		for _, r := range ranges {
+			if port >= r.From && port <= r.To {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return port, false
+		}
+	}
+	return 0, true
+}
+
+// ParsePortSpec 解析端口列表与范围，如 "8080"、"10000-10100"、"80,443,8000-8010"
+// 保持书写顺序 (用于与目标端口按位置对应)，不允许重复端口
+func ParsePortSpec(spec string) ([]int, error) {
+	ranges, err := ParsePortRanges(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []int
+	seen := make(map[int]bool)
+	for _, r := range ranges {
+		if len(ports)+r.To-r.From+1 > MaxPortsPerRule {
+			return nil, fmt.Errorf("端口数量不能超过 %d 个", MaxPortsPerRule)
+		}
+		for port := r.From; port <= r.To; port++ {
+			if seen[port] {
+				return nil, fmt.Errorf("端口 %d 重复", port)
+			}
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// FormatPortSpec 将端口列表格式化为紧凑形式，连续端口合并为范围
+func FormatPortSpec(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// SplitAddrPorts 解析 host:端口列表 形式的地址，如 "0.0.0.0:10000-10100"
+func SplitAddrPorts(addr string) (string, []int, error) {
+	host, spec, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("无效的地址: %s", addr)
+	}
+	ports, err := ParsePortSpec(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	return host, ports, nil
+}
+
+// MapTargetPorts 按位置将监听端口映射到目标端口
+// 目标只有一个端口时所有监听端口都转发到该端口，否则两边的端口数量必须一致
+func MapTargetPorts(listen, target []int) ([]int, error) {
+	if len(target) == 1 {
+		mapped := make([]int, len(listen))
+		for i := range mapped {
+			mapped[i] = target[0]
+		}
+		return mapped, nil
+	}
+	if len(target) != len(listen) {
+		return nil, fmt.Errorf("监听端口 (%d 个) 与目标端口 (%d 个) 数量不一致", len(listen), len(target))
+	}
+	return target, nil
+}
+
+// expandTargets 将目标地址按监听端口展开为逐个端口的目标地址
+// 目标地址无法解析时原样用于所有端口 (由创建时的校验保证格式)
+func expandTargets(targetAddr string, listen []int) []string {
+	targets := make([]string, len(listen))
+	host, ports, err := SplitAddrPorts(targetAddr)
+	if err == nil {
+		ports, err = MapTargetPorts(listen, ports)
+	}
+	for i := range targets {
+		if err != nil {
+			targets[i] = targetAddr
+		} else {
+			targets[i] = net.JoinHostPort(host, strconv.Itoa(ports[i]))
+		}
+	}
+	return targets
+}
+
+// TunnelEntryPorts 隧道入口监听的全部端口 (EntryPorts 为空时为 EntryPort)
+func TunnelEntryPorts(tunnel *model.Tunnel) []int {
+	if tunnel.EntryPorts != "" {
+		if ports, err := ParsePortSpec(tunnel.EntryPorts); err == nil {
+			return ports
+		}
+	}
+	return []int{tunnel.EntryPort}
+}
+
+// NodeReservedPorts 节点自身占用的端口: 主端口、API、指标、隧道中继、mTLS 中继与域名暴露入口
+func NodeReservedPorts(node *model.Node) []int {
+	ports := []int{node.Port, node.APIPort, MetricsPort, TunnelRelayPort(node)}
+	if node.MTLSEnabled {
+		ports = append(ports, MTLSRelayPort(node))
+	}
+	ports = append(ports, ExposureIngressPorts(node)...)
+
+	var reserved []int
+	seen := make(map[int]bool)
+	for _, port := range ports {
+		if port > 0 && port <= 65535 && !seen[port] {
+			seen[port] = true
+			reserved = append(reserved, port)
+		}
+	}
+	return reserved
+}
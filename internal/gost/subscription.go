@@ -0,0 +1,331 @@
+package gost
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// SubscriptionNode 订阅中的单个节点 (认证信息已替换为用户凭据)
+type SubscriptionNode struct {
+	Node     *model.Node
+	Username string
+	Password string
+}
+
+// SubscriptionGroup 订阅中的节点组 (对应 NodeGroup)
+type SubscriptionGroup struct {
+	Name     string
+	Strategy string
+	NodeIDs  []uint
+}
+
+// subscriptionSupported 节点是否可以被常见客户端直接使用
+// GOST 私有传输 (ws/kcp/quic 等) 和非标准协议无法被 Clash/sing-box 识别
+func subscriptionSupported(node *model.Node) bool {
+	switch node.Protocol {
+	case "socks5", "", "http", "ss":
+	default:
+		return false
+	}
+	switch node.Transport {
+	case "tcp", "", "tcp+udp":
+		return true
+	case "tls":
+		// Shadowsocks 客户端不支持外层 TLS
+		return node.Protocol != "ss"
+	}
+	return false
+}
+
+func subscriptionTLS(node *model.Node) bool {
+	return node.Transport == "tls" || node.TLSEnabled
+}
+
+// withCredential 返回替换认证信息后的节点副本
+func (n SubscriptionNode) withCredential() *model.Node {
+	node := *n.Node
+	node.ProxyUser = n.Username
+	node.ProxyPass = n.Password
+	return &node
+}
+
+// nameSet 订阅中已使用的代理/出站名称
+type nameSet map[string]bool
+
+// unique 返回不重复的名称，重名时追加数字后缀 (name-2、name-3 ...)
+// Clash 不允许重复的代理名称，sing-box 的出站 tag 也必须唯一
+func (s nameSet) unique(name string) string {
+	candidate := name
+	for i := 2; s[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	s[candidate] = true
+	return candidate
+}
+
+// FilterSubscriptionNodes 过滤出客户端可用的节点
+func FilterSubscriptionNodes(nodes []SubscriptionNode) []SubscriptionNode {
+	result := make([]SubscriptionNode, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Node != nil && subscriptionSupported(n.Node) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// GenerateSubscriptionBase64 生成 base64 编码的 URI 列表 (通用订阅格式)
+func GenerateSubscriptionBase64(nodes []SubscriptionNode) string {
+	lines := make([]string, 0, len(nodes))
+	used := nameSet{}
+	for _, n := range nodes {
+		node := n.withCredential()
+		node.Name = used.unique(node.Name)
+		uri := GenerateProxyURI(node)
+		if !strings.Contains(uri, "#") {
+			uri += "#" + url.PathEscape(node.Name)
+		}
+		lines = append(lines, uri)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(lines, "\n")))
+}
+
+// GenerateClashConfig 生成 Clash/Mihomo 配置
+func GenerateClashConfig(nodes []SubscriptionNode, groups []SubscriptionGroup) map[string]interface{} {
+	proxies := make([]map[string]interface{}, 0, len(nodes))
+	names := make(map[uint]string, len(nodes))
+	allNames := make([]string, 0, len(nodes))
+	// 代理与代理组共用名称空间，并避开内置的 PROXY/DIRECT/REJECT
+	used := nameSet{"PROXY": true, "DIRECT": true, "REJECT": true}
+
+	for _, n := range nodes {
+		node := n.Node
+		name := used.unique(node.Name)
+		proxy := map[string]interface{}{
+			"name":   name,
+			"server": node.Host,
+			"port":   node.Port,
+		}
+		switch node.Protocol {
+		case "ss":
+			proxy["type"] = "ss"
+			proxy["cipher"] = ssMethod(node)
+			proxy["password"] = node.SSPassword
+			proxy["udp"] = true
+		case "http":
+			proxy["type"] = "http"
+			if subscriptionTLS(node) {
+				proxy["tls"] = true
+			}
+		default:
+			proxy["type"] = "socks5"
+			proxy["udp"] = true
+			if subscriptionTLS(node) {
+				proxy["tls"] = true
+			}
+		}
+		if node.Protocol != "ss" && n.Username != "" {
+			proxy["username"] = n.Username
+			proxy["password"] = n.Password
+		}
+		if subscriptionTLS(node) && node.TLSSNI != "" {
+			proxy["sni"] = node.TLSSNI
+		}
+		proxies = append(proxies, proxy)
+		names[node.ID] = name
+		allNames = append(allNames, name)
+	}
+
+	proxyGroups := []map[string]interface{}{}
+	groupNames := []string{}
+	for _, g := range groups {
+		members := []string{}
+		for _, id := range g.NodeIDs {
+			if name, ok := names[id]; ok {
+				members = append(members, name)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		name := used.unique(g.Name)
+		proxyGroups = append(proxyGroups, map[string]interface{}{
+			"name":     name,
+			"type":     clashGroupType(g.Strategy),
+			"proxies":  members,
+			"url":      "http://www.gstatic.com/generate_204",
+			"interval": 300,
+		})
+		groupNames = append(groupNames, name)
+	}
+
+	// 默认选择组: 节点组 + 全部节点
+	selectProxies := append(append([]string{}, groupNames...), allNames...)
+	if len(selectProxies) == 0 {
+		selectProxies = []string{"DIRECT"}
+	}
+	proxyGroups = append([]map[string]interface{}{
+		{
+			"name":    "PROXY",
+			"type":    "select",
+			"proxies": selectProxies,
+		},
+	}, proxyGroups...)
+
+	return map[string]interface{}{
+		"mixed-port":   7890,
+		"allow-lan":    false,
+		"mode":         "rule",
+		"log-level":    "info",
+		"proxies":      proxies,
+		"proxy-groups": proxyGroups,
+		"rules": []string{
+			"GEOIP,LAN,DIRECT",
+			"MATCH,PROXY",
+		},
+	}
+}
+
+// clashGroupType 将节点组策略映射为 Clash 代理组类型
+func clashGroupType(strategy string) string {
+	switch strategy {
+	case "fifo":
+		return "fallback"
+	case "round", "random", "hash":
+		return "load-balance"
+	default:
+		return "url-test"
+	}
+}
+
+// GenerateSingBoxConfig 生成 sing-box 配置
+func GenerateSingBoxConfig(nodes []SubscriptionNode, groups []SubscriptionGroup) map[string]interface{} {
+	outbounds := []map[string]interface{}{}
+	tags := make(map[uint]string, len(nodes))
+	allTags := []string{}
+	used := nameSet{"proxy": true, "direct": true}
+
+	for _, n := range nodes {
+		node := n.Node
+		// sing-box 的 socks 出站不支持 TLS
+		if node.Protocol != "http" && node.Protocol != "ss" && subscriptionTLS(node) {
+			continue
+		}
+		tag := used.unique(node.Name)
+		outbound := map[string]interface{}{
+			"tag":         tag,
+			"server":      node.Host,
+			"server_port": node.Port,
+		}
+		switch node.Protocol {
+		case "ss":
+			outbound["type"] = "shadowsocks"
+			outbound["method"] = ssMethod(node)
+			outbound["password"] = node.SSPassword
+		case "http":
+			outbound["type"] = "http"
+		default:
+			outbound["type"] = "socks"
+			outbound["version"] = "5"
+		}
+		if node.Protocol != "ss" && n.Username != "" {
+			outbound["username"] = n.Username
+			outbound["password"] = n.Password
+		}
+		if node.Protocol == "http" && subscriptionTLS(node) {
+			tls := map[string]interface{}{"enabled": true}
+			if node.TLSSNI != "" {
+				tls["server_name"] = node.TLSSNI
+			}
+			outbound["tls"] = tls
+		}
+		outbounds = append(outbounds, outbound)
+		tags[node.ID] = tag
+		allTags = append(allTags, tag)
+	}
+
+	groupTags := []string{}
+	groupOutbounds := []map[string]interface{}{}
+	for _, g := range groups {
+		members := []string{}
+		for _, id := range g.NodeIDs {
+			if tag, ok := tags[id]; ok {
+				members = append(members, tag)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		tag := used.unique(g.Name)
+		groupOutbounds = append(groupOutbounds, map[string]interface{}{
+			"type":      "urltest",
+			"tag":       tag,
+			"outbounds": members,
+		})
+		groupTags = append(groupTags, tag)
+	}
+
+	selectTags := append(append([]string{}, groupTags...), allTags...)
+	selectTags = append(selectTags, "direct")
+
+	all := []map[string]interface{}{
+		{
+			"type":      "selector",
+			"tag":       "proxy",
+			"outbounds": selectTags,
+		},
+	}
+	all = append(all, groupOutbounds...)
+	all = append(all, outbounds...)
+	all = append(all, map[string]interface{}{"type": "direct", "tag": "direct"})
+
+	return map[string]interface{}{
+		"log": map[string]interface{}{"level": "info"},
+		"inbounds": []map[string]interface{}{
+			{
+				"type":        "mixed",
+				"tag":         "mixed-in",
+				"listen":      "127.0.0.1",
+				"listen_port": 2080,
+			},
+		},
+		"outbounds": all,
+		"route": map[string]interface{}{
+			"final": "proxy",
+		},
+	}
+}
+
+// GenerateSIP008 生成 SIP008 订阅 (仅包含 Shadowsocks 节点)
+func GenerateSIP008(nodes []SubscriptionNode) map[string]interface{} {
+	servers := []map[string]interface{}{}
+	for _, n := range nodes {
+		node := n.Node
+		if node.Protocol != "ss" {
+			continue
+		}
+		servers = append(servers, map[string]interface{}{
+			"id":          fmt.Sprintf("node-%d", node.ID),
+			"remarks":     node.Name,
+			"server":      node.Host,
+			"server_port": node.Port,
+			"password":    node.SSPassword,
+			"method":      ssMethod(node),
+		})
+	}
+	return map[string]interface{}{
+		"version": 1,
+		"servers": servers,
+	}
+}
+
+func ssMethod(node *model.Node) string {
+	if node.SSMethod == "" {
+		return "aes-256-gcm"
+	}
+	return node.SSMethod
+}
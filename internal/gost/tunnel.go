@@ -0,0 +1,236 @@
+package gost
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 隧道作为节点配置的一部分下发:
+// 入口节点配置包含每条隧道的监听服务、转发链与限制器，中继节点配置包含隧道的跳点服务 (见 tunnel_hop.go)，
+// 出口节点配置包含接入隧道的中继服务 (出口启用 mTLS 中继时直接复用 mTLS 中继)
+
+// TunnelRelayPort 出口节点隧道中继服务端口 (主端口+1000)
+func TunnelRelayPort(node *model.Node) int {
+	return node.Port + 1000
+}
+
+// tunnelRelayAuth 入口节点接入出口中继的凭据
+// 密码由出口节点的 Agent 令牌派生，只有面板和两端节点知道
+func tunnelRelayAuth(tunnel *model.Tunnel, exit *model.Node) (string, string) {
+	username := fmt.Sprintf("tunnel-%d", tunnel.ID)
+	mac := hmac.New(sha256.New, []byte(exit.AgentToken))
+	mac.Write([]byte(username))
+	return username, hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// generateTunnelHopNode 隧道最后一跳 (入口或最后一个中继) 连接出口节点的 hop
+// 出口启用 mTLS 中继且上一跳节点都持有证书时经 mTLS 中继接入，否则连接出口节点的隧道中继服务
+// (出口与上一跳都持有面板签发的证书时，隧道中继同样出示并校验节点证书)
+func (g *ConfigGenerator) generateTunnelHopNode(tunnel *model.Tunnel, exit *model.Node) map[string]interface{} {
+	name := fmt.Sprintf("exit-%d", exit.ID)
+	username, password := tunnelRelayAuth(tunnel, exit)
+	connector := map[string]interface{}{
+		"type": "relay",
+		"auth": map[string]string{
+			"username": username,
+			"password": password,
+		},
+	}
+	// mTLS 中继同样使用隧道凭据，出口据此按隧道上报流量
+	if tunnelHopMTLS(tunnel, len(tunnel.Hops), exit) {
+		nodeConfig := g.generateMTLSHopNode(name, exit)
+		nodeConfig["connector"] = connector
+		return nodeConfig
+	}
+
+	dialer := map[string]interface{}{
+		"type": "tls",
+	}
+	if exit.CertPEM != "" && tunnelHopCertified(tunnel, len(tunnel.Hops)) {
+		dialer["tls"] = internalClientTLS(exit)
+	}
+
+	return map[string]interface{}{
+		"name":      name,
+		"addr":      fmt.Sprintf("%s:%d", exit.Host, TunnelRelayPort(exit)),
+		"connector": connector,
+		"dialer":    dialer,
+	}
+}
+
+// tunnelExitNodes 隧道的出口节点 (已解析的出口列表，未解析时为单一出口节点)
+func tunnelExitNodes(tunnel *model.Tunnel) []*model.Node {
+	if len(tunnel.Exits) > 0 {
+		nodes := make([]*model.Node, 0, len(tunnel.Exits))
+		for _, exit := range tunnel.Exits {
+			if exit.Node != nil {
+				nodes = append(nodes, exit.Node)
+			}
+		}
+		return nodes
+	}
+	if tunnel.ExitNode != nil {
+		return []*model.Node{tunnel.ExitNode}
+	}
+	return nil
+}
+
+// generateTunnelExitHop 入口节点连接出口节点的 hop
+// 多出口时由选择器按策略负载均衡，节点失败 maxFails 次后在 failTimeout 内被剔除 (fifo 即按优先级故障转移)
+func (g *ConfigGenerator) generateTunnelExitHop(tunnel *model.Tunnel) map[string]interface{} {
+	var nodes []map[string]interface{}
+	for _, exit := range tunnel.Exits {
+		// 路径探测无法到达目标的出口不参与选择 (全部出口不可达时不会被标记)
+		if exit.Node == nil || exit.Status == model.TunnelNodeUnreachable {
+			continue
+		}
+		nodeConfig := g.generateTunnelHopNode(tunnel, exit.Node)
+		if exit.Weight > 1 {
+			nodeConfig["metadata"] = map[string]interface{}{
+				"weight": exit.Weight,
+			}
+		}
+		nodes = append(nodes, nodeConfig)
+	}
+	if len(nodes) == 0 && tunnel.ExitNode != nil {
+		nodes = append(nodes, g.generateTunnelHopNode(tunnel, tunnel.ExitNode))
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	hop := map[string]interface{}{
+		"name":  "hop-0",
+		"nodes": nodes,
+	}
+	if len(nodes) > 1 {
+		strategy, maxFails, failTimeout := tunnel.ExitStrategy, 3, 30
+		if tunnel.ExitGroup != nil {
+			strategy, maxFails, failTimeout = tunnel.ExitGroup.Strategy, tunnel.ExitGroup.MaxFails, tunnel.ExitGroup.FailTimeout
+		}
+		if strategy == "" {
+			strategy = "fifo"
+		}
+		hop["selector"] = map[string]interface{}{
+			"strategy":    strategy,
+			"maxFails":    maxFails,
+			"failTimeout": fmt.Sprintf("%ds", failTimeout),
+		}
+	}
+	return hop
+}
+
+// addTunnelExits 为以本节点为出口的隧道生成中继服务，每条隧道使用独立凭据
+// 出口启用 mTLS 中继时，上一跳节点都持有证书的隧道经 mTLS 中继接入 (校验客户端证书)；
+// 其余隧道接入隧道中继服务，接入该服务的上一跳都持有证书时同样校验客户端证书。
+// 两个中继服务都只接受隧道凭据，节点的公开服务不受影响，仍对所有用户开放
+func (g *ConfigGenerator) addTunnelExits(config map[string]interface{}, node *model.Node, tunnels []model.Tunnel) {
+	if len(tunnels) == 0 {
+		return
+	}
+
+	var mtlsAuths, relayAuths []map[string]string
+	verify := node.CertPEM != ""
+	for i := range tunnels {
+		tunnel := &tunnels[i]
+		username, password := tunnelRelayAuth(tunnel, node)
+		auth := map[string]string{
+			"username": username,
+			"password": password,
+		}
+		if tunnelHopMTLS(tunnel, len(tunnel.Hops), node) {
+			mtlsAuths = append(mtlsAuths, auth)
+			continue
+		}
+		relayAuths = append(relayAuths, auth)
+		if !tunnelHopCertified(tunnel, len(tunnel.Hops)) {
+			verify = false
+		}
+	}
+
+	// 按隧道凭据上报流量，面板据此判断多出口隧道当前承载流量的出口
+	observer := ""
+	if g.panelURL != "" && node.AgentToken != "" {
+		appendConfigItem(config, "observers", map[string]interface{}{
+			"name": "tunnel-observer",
+			"plugin": map[string]interface{}{
+				"type": "http",
+				"addr": fmt.Sprintf("%s/agent/observe/%s", g.panelURL, node.AgentToken),
+			},
+		})
+		observer = "tunnel-observer"
+	}
+
+	if len(mtlsAuths) > 0 {
+		service := g.generateMTLSRelayService(node)
+		service["handler"] = tunnelExitHandler(config, "mtls-relay-auth", mtlsAuths, observer)
+		appendConfigItem(config, "services", service)
+	}
+	if len(relayAuths) == 0 {
+		return
+	}
+
+	listener := map[string]interface{}{
+		"type": "tls",
+	}
+	if verify {
+		// 配置 caFile 后 GOST 要求并校验客户端证书
+		listener["tls"] = map[string]interface{}{
+			"certFile": InternalCertFile,
+			"keyFile":  InternalKeyFile,
+			"caFile":   InternalCAFile,
+		}
+	}
+
+	appendConfigItem(config, "services", map[string]interface{}{
+		"name":     "tunnel-relay",
+		"addr":     fmt.Sprintf(":%d", TunnelRelayPort(node)),
+		"observer": "stats-observer",
+		"handler":  tunnelExitHandler(config, "tunnel-relay-auth", relayAuths, observer),
+		"listener": listener,
+	})
+}
+
+// tunnelExitHandler 出口中继服务的 relay handler，只接受给定的隧道凭据
+func tunnelExitHandler(config map[string]interface{}, auther string, auths []map[string]string, observer string) map[string]interface{} {
+	appendConfigItem(config, "authers", map[string]interface{}{
+		"name":  auther,
+		"auths": auths,
+	})
+
+	handler := map[string]interface{}{
+		"type":   "relay",
+		"auther": auther,
+	}
+	if observer != "" {
+		handler["observer"] = observer
+		handler["metadata"] = map[string]interface{}{
+			"observer.period":       "10s",
+			"observer.resetTraffic": true,
+		}
+	}
+	return handler
+}
+
+// AddTunnels 将隧道编译进节点配置
+// entries 为以本节点为入口或中继的隧道配置片段 (GenerateTunnelEntryConfig 按隧道所有者的套餐生成，
+// GenerateTunnelRelayConfig 生成中继跳点)，exits 为以本节点为出口的隧道；节点超限阻断时不下发任何隧道
+func (g *ConfigGenerator) AddTunnels(config map[string]interface{}, node *model.Node, entries []map[string]interface{}, exits []model.Tunnel) {
+	if blocked, _ := g.quotaEnforcement(node.QuotaEnforced, node.QuotaThrottle); blocked {
+		return
+	}
+
+	for _, entry := range entries {
+		for _, key := range []string{"authers", "limiters", "rlimiters", "climiters", "chains", "services"} {
+			items, _ := entry[key].([]map[string]interface{})
+			for _, item := range items {
+				appendConfigItem(config, key, item)
+			}
+		}
+	}
+	g.addTunnelExits(config, node, exits)
+}
@@ -0,0 +1,214 @@
+package gost
+
+import (
+	"fmt"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 多跳隧道: 入口 → 中继1 → ... → 中继N → 出口
+// 每个中继节点为隧道单独监听一个服务 (使用节点自身的协议与传输层)，服务的转发链指向下一跳，
+// 上一跳使用隧道凭据连接该服务；中继服务不计入隧道流量，隧道流量只在入口与出口统计
+
+// wsTransports 需要 path/host 元数据的 WebSocket 传输层
+var wsTransports = map[string]bool{"ws": true, "wss": true, "mws": true, "mwss": true}
+
+// tunnelHopProtocols 可承载隧道中继的节点协议，其他协议 (如 ss、tun) 的中继节点使用 relay
+var tunnelHopProtocols = map[string]bool{"socks5": true, "http": true, "http2": true, "relay": true}
+
+func tunnelHopProtocol(node *model.Node) string {
+	if tunnelHopProtocols[node.Protocol] {
+		return node.Protocol
+	}
+	return "relay"
+}
+
+// TunnelHopPort 中继节点为隧道监听的端口 (未设置时与入口端口相同)
+func TunnelHopPort(tunnel *model.Tunnel, hop *model.TunnelHop) int {
+	if hop.Port > 0 {
+		return hop.Port
+	}
+	return tunnel.EntryPort
+}
+
+// tunnelHopDialers 连接隧道第 index 跳的节点: 第一跳为入口节点，其后为上一个中继跳点的节点
+// (index 等于中继跳点数时即出口)
+func tunnelHopDialers(tunnel *model.Tunnel, index int) []*model.Node {
+	if index == 0 {
+		if tunnel.EntryNode == nil {
+			return nil
+		}
+		return []*model.Node{tunnel.EntryNode}
+	}
+	if index > len(tunnel.Hops) {
+		return nil
+	}
+	var nodes []*model.Node
+	for _, relay := range tunnel.Hops[index-1].Nodes {
+		if relay.Node != nil {
+			nodes = append(nodes, relay.Node)
+		}
+	}
+	return nodes
+}
+
+// tunnelHopCertified 连接隧道第 index 跳的节点是否都持有面板签发的证书
+func tunnelHopCertified(tunnel *model.Tunnel, index int) bool {
+	dialers := tunnelHopDialers(tunnel, index)
+	if len(dialers) == 0 {
+		return false
+	}
+	for _, node := range dialers {
+		if node.CertPEM == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// tunnelHopMTLS 隧道第 index 跳是否经目标节点的 mTLS 中继接入
+// 目标启用 mTLS 中继且上一跳节点都持有证书时才使用；拨号与监听两端按同一规则判断，保证两端一致
+func tunnelHopMTLS(tunnel *model.Tunnel, index int, target *model.Node) bool {
+	return mtlsReady(target) && tunnelHopCertified(tunnel, index)
+}
+
+// generateTunnelNextHop 隧道第 index 跳之后的下一跳: 下一个中继跳点，没有更多中继时为出口
+// 下一跳没有可用节点时返回 nil
+func (g *ConfigGenerator) generateTunnelNextHop(tunnel *model.Tunnel, index int) map[string]interface{} {
+	if index >= len(tunnel.Hops) {
+		return g.generateTunnelExitHop(tunnel)
+	}
+
+	hop := &tunnel.Hops[index]
+	var nodes []map[string]interface{}
+	for _, relay := range hop.Nodes {
+		if relay.Node == nil {
+			continue
+		}
+		nodeConfig := g.generateTunnelRelayNode(tunnel, index, relay.Node)
+		if relay.Weight > 1 {
+			nodeConfig["metadata"] = map[string]interface{}{
+				"weight": relay.Weight,
+			}
+		}
+		nodes = append(nodes, nodeConfig)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	hopConfig := map[string]interface{}{
+		"name":  "hop-0",
+		"nodes": nodes,
+	}
+	if hop.Group != nil && len(nodes) > 1 {
+		hopConfig["selector"] = map[string]interface{}{
+			"strategy":    hop.Group.Strategy,
+			"maxFails":    hop.Group.MaxFails,
+			"failTimeout": fmt.Sprintf("%ds", hop.Group.FailTimeout),
+		}
+	}
+	return hopConfig
+}
+
+// generateTunnelRelayNode 上一跳连接中继节点隧道服务的 node 配置
+// 经 mTLS 中继接入时使用证书认证，否则使用节点自身的协议与传输层；两种方式都出示隧道凭据
+func (g *ConfigGenerator) generateTunnelRelayNode(tunnel *model.Tunnel, index int, relay *model.Node) map[string]interface{} {
+	name := fmt.Sprintf("relay-%d", relay.ID)
+	addr := fmt.Sprintf("%s:%d", relay.Host, TunnelHopPort(tunnel, &tunnel.Hops[index]))
+	username, password := tunnelRelayAuth(tunnel, relay)
+	if tunnelHopMTLS(tunnel, index, relay) {
+		nodeConfig := g.generateMTLSHopNode(name, relay)
+		nodeConfig["addr"] = addr
+		nodeConfig["connector"] = map[string]interface{}{
+			"type": "relay",
+			"auth": map[string]string{
+				"username": username,
+				"password": password,
+			},
+		}
+		return nodeConfig
+	}
+
+	dialer := map[string]interface{}{
+		"type": normalizeTransport(relay.Transport),
+	}
+	if relay.TLSEnabled {
+		dialer["tls"] = g.generateTLSConfig(relay)
+	}
+	if wsTransports[relay.Transport] && (relay.WSPath != "" || relay.WSHost != "") {
+		metadata := map[string]interface{}{}
+		if relay.WSPath != "" {
+			metadata["path"] = relay.WSPath
+		}
+		if relay.WSHost != "" {
+			metadata["host"] = relay.WSHost
+		}
+		dialer["metadata"] = metadata
+	}
+
+	return map[string]interface{}{
+		"name": name,
+		"addr": addr,
+		"connector": map[string]interface{}{
+			"type": tunnelHopProtocol(relay),
+			"auth": map[string]string{
+				"username": username,
+				"password": password,
+			},
+		},
+		"dialer": dialer,
+	}
+}
+
+// GenerateTunnelRelayConfig 生成中继节点承载隧道第 index 跳的配置 (部署在中继节点)
+func (g *ConfigGenerator) GenerateTunnelRelayConfig(tunnel *model.Tunnel, index int, relay *model.Node) map[string]interface{} {
+	if index < 0 || index >= len(tunnel.Hops) {
+		return nil
+	}
+	next := g.generateTunnelNextHop(tunnel, index+1)
+	if next == nil {
+		return nil
+	}
+
+	hop := &tunnel.Hops[index]
+	name := fmt.Sprintf("tunnel-hop-%d-%d", tunnel.ID, index)
+	config := map[string]interface{}{
+		"chains": []map[string]interface{}{
+			{"name": name, "hops": []map[string]interface{}{next}},
+		},
+	}
+
+	// 上一跳必须出示隧道凭据 (经 mTLS 接入时还要出示面板签发的证书)，否则任何人都能借该服务经隧道连到出口
+	username, password := tunnelRelayAuth(tunnel, relay)
+	config["authers"] = []map[string]interface{}{
+		{
+			"name":  name,
+			"auths": []map[string]string{{"username": username, "password": password}},
+		},
+	}
+	service := map[string]interface{}{
+		"name": name,
+		"addr": fmt.Sprintf(":%d", TunnelHopPort(tunnel, hop)),
+	}
+	if tunnelHopMTLS(tunnel, index, relay) {
+		mtls := g.generateMTLSRelayService(relay)
+		service["handler"] = map[string]interface{}{
+			"type":   "relay",
+			"auther": name,
+			"chain":  name,
+		}
+		service["listener"] = mtls["listener"]
+	} else {
+		listenerNode := *relay
+		listenerNode.Protocol = tunnelHopProtocol(relay)
+		service["handler"] = map[string]interface{}{
+			"type":   listenerNode.Protocol,
+			"auther": name,
+			"chain":  name,
+		}
+		service["listener"] = g.generateListener(&listenerNode)
+	}
+	config["services"] = []map[string]interface{}{service}
+	return config
+}
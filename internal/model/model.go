@@ -23,6 +23,7 @@ type Node struct {
 	APIPass     string    `gorm:"size:100" json:"-"`                    // API 认证密码 (隐藏)
 	ProxyUser   string    `gorm:"size:100" json:"proxy_user"`           // 代理认证用户
 	ProxyPass   string    `gorm:"size:100" json:"-"`                    // 代理认证密码 (隐藏)
+	SharedAuth  bool      `gorm:"default:false" json:"shared_auth"`     // 共享节点: 按用户凭据认证并计费
 	AgentToken  string    `gorm:"size:100;uniqueIndex" json:"-"`        // Agent 认证令牌
 	Status      string    `gorm:"size:20;default:offline" json:"status"` // online/offline
 	TrafficIn   int64     `gorm:"default:0" json:"traffic_in"`          // 入站流量 (bytes)
@@ -41,6 +42,13 @@ type Node struct {
 	TLSKeyFile  string `gorm:"size:255" json:"tls_key_file"`
 	TLSSNI      string `gorm:"size:255" json:"tls_sni"`
 	TLSALPN     string `gorm:"size:255" json:"tls_alpn"`                     // TLS ALPN 协议列表 (逗号分隔)
+	// 内部 mTLS 中继 (仅允许持有面板 CA 签发证书的节点接入)
+	MTLSEnabled  bool       `gorm:"default:false" json:"mtls_enabled"`
+	MTLSPort     int        `gorm:"default:0" json:"mtls_port"`           // mTLS 中继端口 (0=主端口+2000)
+	CertPEM      string     `gorm:"type:text" json:"-"`                    // 面板签发的节点证书 (隐藏)
+	KeyPEM       string     `gorm:"type:text" json:"-"`                    // 节点证书私钥 (隐藏)
+	CertSerial   string     `gorm:"size:64" json:"cert_serial"`            // 证书序列号
+	CertExpireAt *time.Time `json:"cert_expire_at"`                        // 证书过期时间
 	// WebSocket 配置
 	WSPath string `gorm:"size:255" json:"ws_path"`
 	WSHost string `gorm:"size:255" json:"ws_host"`
@@ -60,6 +68,19 @@ type Node struct {
 	QuotaUsed      int64  `gorm:"default:0" json:"quota_used"`          // 本周期已用流量
 	QuotaResetAt   time.Time `json:"quota_reset_at"`                    // 上次重置时间
 	QuotaExceeded  bool   `gorm:"default:false" json:"quota_exceeded"`  // 是否超限
+	QuotaAction    string `gorm:"size:20;default:notify" json:"quota_action"` // 超限处理: notify/throttle/block
+	QuotaThrottle  int64  `gorm:"default:0" json:"quota_throttle"`      // 超限后限速 (bytes/s)
+	QuotaEnforced  string `gorm:"size:20" json:"quota_enforced"`        // 当前生效的超限处理 (空=未生效)
+	// 端口分配
+	PortRange string `gorm:"size:255" json:"port_range"` // 允许普通用户使用的端口范围 (如 10000-20000，空=不限制)
+	// 域名暴露入口 (客户端的 HTTP 服务经反向隧道按域名发布)
+	ExposeHTTPPort  int `gorm:"default:0" json:"expose_http_port"`  // HTTP 入口端口 (0=不启用)
+	ExposeHTTPSPort int `gorm:"default:0" json:"expose_https_port"` // HTTPS 入口端口 (0=不启用)
+	// 节点间延迟测量 (由管理员选择参与测量的节点，两两测量 TCP 握手延迟)
+	LatencyProbe bool `gorm:"default:false" json:"latency_probe"`
+	// 配置同步 (Agent 上报的配置哈希与面板生成的配置一致即为已同步)
+	ConfigStatus   string     `gorm:"size:20;default:pending" json:"config_status"` // synced/pending
+	ConfigSyncedAt *time.Time `json:"config_synced_at"`                             // 最近一次确认同步的时间
 	// 所有者 (权限控制)
 	OwnerID     *uint     `gorm:"index" json:"owner_id,omitempty"`      // 所有者用户ID
 	LastSeen    time.Time `json:"last_seen"`
@@ -92,6 +113,29 @@ type Client struct {
 	LastSeen    time.Time `json:"last_seen"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// 暴露的局域网服务
+	Exposures []ClientExposure `gorm:"foreignKey:ClientID" json:"exposures,omitempty"`
+}
+
+// ClientExposure 客户端通过反向隧道在节点上发布的局域网服务
+// tcp/udp 映射到节点的远程端口，http 可映射到远程端口或按域名经节点的 HTTP 入口发布
+type ClientExposure struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ClientID   uint      `gorm:"index" json:"client_id"`
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	Protocol   string    `gorm:"size:10;default:tcp" json:"protocol"` // tcp/udp/http
+	LocalAddr  string    `gorm:"size:255" json:"local_addr"`          // 局域网目标地址 (host:port)
+	RemotePort int       `json:"remote_port"`                         // 节点上的映射端口 (域名发布时为 0)
+	Hostname   string    `gorm:"size:255;index" json:"hostname"`      // 域名 (仅 http)
+	TLSMode    string    `gorm:"size:20" json:"tls_mode"`             // 空=仅 HTTP, terminate=节点终止 TLS, passthrough=按 SNI 透传
+	AuthUser   string    `gorm:"size:100" json:"auth_user"`           // Basic 认证用户名 (空=不启用)
+	AuthPass   string    `gorm:"size:100" json:"-"`                   // Basic 认证密码 (隐藏)
+	AllowIPs   string    `gorm:"type:text" json:"allow_ips"`          // 允许访问的 IP/CIDR (逗号分隔，空=不限制)
+	Enabled    bool      `gorm:"default:true" json:"enabled"`
+	TrafficIn  int64     `gorm:"default:0" json:"traffic_in"`
+	TrafficOut int64     `gorm:"default:0" json:"traffic_out"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // Service GOST 服务配置
@@ -115,8 +159,8 @@ type PortForward struct {
 	NodeID      uint      `gorm:"index" json:"node_id"`
 	Name        string    `gorm:"size:100;not null" json:"name"`
 	Type        string    `gorm:"size:20;not null" json:"type"`           // tcp/udp/rtcp/rudp/relay
-	LocalAddr   string    `gorm:"size:255" json:"local_addr"`             // 本地监听地址
-	RemoteAddr  string    `gorm:"size:255" json:"remote_addr"`            // 远程目标地址
+	LocalAddr   string    `gorm:"size:255" json:"local_addr"`             // 本地监听地址 (端口可为列表/范围，如 0.0.0.0:10000-10100)
+	RemoteAddr  string    `gorm:"size:255" json:"remote_addr"`            // 远程目标地址 (端口数量与监听一致或为单个端口)
 	ChainID     *uint     `gorm:"index" json:"chain_id,omitempty"`        // 使用的转发链
 	Enabled     bool      `gorm:"default:true" json:"enabled"`
 	OwnerID     *uint     `gorm:"index" json:"owner_id,omitempty"`
@@ -124,6 +168,18 @@ type PortForward struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// PortAllocation 节点端口分配 (同一节点的每个端口只能分配给一个资源，由唯一索引保证)
+type PortAllocation struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	NodeID       uint      `gorm:"uniqueIndex:idx_port_allocations_node_port;not null" json:"node_id"`
+	Port         int       `gorm:"uniqueIndex:idx_port_allocations_node_port;not null" json:"port"`
+	ResourceType string    `gorm:"size:50;not null;index:idx_port_allocations_resource" json:"resource_type"` // node/client/tunnel/port_forward
+	ResourceID   uint      `gorm:"index:idx_port_allocations_resource" json:"resource_id"`
+	Name         string    `gorm:"size:100" json:"name"` // 资源名称
+	OwnerID      *uint     `gorm:"index" json:"owner_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
 // NodeGroup 节点组 (用于负载均衡)
 type NodeGroup struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
@@ -132,8 +188,8 @@ type NodeGroup struct {
 	Selector      string    `gorm:"size:255" json:"selector"`              // 选择器配置 JSON
 	FailTimeout   int       `gorm:"default:30" json:"fail_timeout"`        // 故障超时时间(秒)
 	MaxFails      int       `gorm:"default:3" json:"max_fails"`            // 最大失败次数
-	HealthCheck   bool      `gorm:"default:true" json:"health_check"`      // 是否启用健康检查
-	CheckInterval int       `gorm:"default:30" json:"check_interval"`      // 健康检查间隔(秒)
+	HealthCheck   bool      `gorm:"default:true" json:"health_check"`      // 是否启用健康检查 (按成员健康状况自动调整有效权重与启用状态)
+	CheckInterval int       `gorm:"default:30" json:"check_interval"`      // 健康评估间隔(秒)
 	OwnerID       *uint     `gorm:"index" json:"owner_id,omitempty"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
@@ -147,6 +203,50 @@ type NodeGroupMember struct {
 	Weight    int  `gorm:"default:1" json:"weight"`                      // 权重
 	Priority  int  `gorm:"default:0" json:"priority"`                    // 优先级 (故障转移用)
 	Enabled   bool `gorm:"default:true" json:"enabled"`
+	// 健康检查自动调整 (节点组启用健康检查时由面板根据 HealthCheckLog 维护)
+	EffectiveWeight int        `gorm:"default:0" json:"effective_weight"`      // 有效权重 (0=未调整，使用 Weight)
+	AutoDisabled    bool       `gorm:"default:false" json:"auto_disabled"`     // 因健康检查失败被自动停用
+	HealthState     string     `gorm:"size:20" json:"health_state"`            // healthy/degraded/down，空=未评估
+	HealthFailRate  float64    `gorm:"default:0" json:"health_fail_rate"`      // 最近检查的失败率 (%)
+	HealthLatency   int        `gorm:"default:0" json:"health_latency"`        // 最近成功检查的平均延迟 (ms)
+	HealthChangedAt *time.Time `json:"health_changed_at,omitempty"`            // 健康状态最近变更时间
+}
+
+// Active 成员是否参与负载均衡 (手动启用且未被健康检查自动停用)
+func (m *NodeGroupMember) Active() bool {
+	return m.Enabled && !m.AutoDisabled
+}
+
+// CurrentWeight 生效的权重: 健康检查调整后的有效权重，未调整时为配置的权重
+func (m *NodeGroupMember) CurrentWeight() int {
+	if m.EffectiveWeight > 0 {
+		return m.EffectiveWeight
+	}
+	return m.Weight
+}
+
+// 节点组成员健康状态
+const (
+	MemberHealthy  = "healthy"
+	MemberDegraded = "degraded"
+	MemberDown     = "down"
+)
+
+// NodeGroupHealthEvent 节点组成员的健康调整记录 (有效权重或启用状态的每次自动变更)
+type NodeGroupHealthEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	GroupID    uint      `gorm:"index" json:"group_id"`
+	MemberID   uint      `json:"member_id"`
+	NodeID     uint      `json:"node_id"`
+	NodeName   string    `gorm:"size:100" json:"node_name"`
+	FromState  string    `gorm:"size:20" json:"from_state"`
+	ToState    string    `gorm:"size:20" json:"to_state"`
+	FromWeight int       `json:"from_weight"`
+	ToWeight   int       `json:"to_weight"`
+	FailRate   float64   `json:"fail_rate"` // %
+	Latency    int       `json:"latency"`   // ms
+	Reason     string    `gorm:"size:255" json:"reason"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
 }
 
 // Tunnel 隧道转发 (入口端-出口端模式)
@@ -157,27 +257,79 @@ type Tunnel struct {
 	// 入口端配置
 	EntryNodeID uint      `gorm:"index" json:"entry_node_id"`              // 入口节点ID
 	EntryNode   *Node     `gorm:"foreignKey:EntryNodeID" json:"entry_node,omitempty"`
-	EntryPort   int       `gorm:"default:10000" json:"entry_port"`         // 入口监听端口
+	EntryPort   int       `gorm:"default:10000" json:"entry_port"`         // 入口监听端口 (多端口时为第一个端口)
+	EntryPorts  string    `gorm:"size:255" json:"entry_ports"`             // 多端口: 端口列表/范围 (如 10000-10100,10200)
 	Protocol    string    `gorm:"size:20;default:tcp+udp" json:"protocol"` // tcp/udp/tcp+udp (端口复用)
 	// 出口端配置
-	ExitNodeID  uint      `gorm:"index" json:"exit_node_id"`               // 出口节点ID
+	ExitNodeID  uint      `gorm:"index" json:"exit_node_id"`               // 出口节点ID (多出口时为首选出口)
 	ExitNode    *Node     `gorm:"foreignKey:ExitNodeID" json:"exit_node,omitempty"`
-	TargetAddr  string    `gorm:"size:255" json:"target_addr"`             // 目标地址 (如 google.com:443)
+	ExitNodeIDs  string     `gorm:"size:255" json:"exit_node_ids"`                // 多出口: 按优先级排列的出口节点 ID (逗号分隔)
+	ExitStrategy string     `gorm:"size:20;default:fifo" json:"exit_strategy"`    // 多出口选择策略: fifo(按优先级故障转移)/round/random/hash
+	ExitGroupID  *uint      `gorm:"index" json:"exit_group_id,omitempty"`         // 多出口: 出口节点组 (设置后使用组成员与组的选择策略)
+	ExitGroup    *NodeGroup `gorm:"foreignKey:ExitGroupID" json:"exit_group,omitempty"`
+	Exits        []TunnelNode `gorm:"-" json:"exits,omitempty"`                   // 解析后的出口节点 (在线优先，再按优先级排序)
+	Hops         []TunnelHop  `gorm:"-" json:"hops"`                              // 入口与出口之间依次经过的中继跳点
+	ActiveExitID uint       `gorm:"default:0" json:"active_exit_id"`              // 当前承载流量的出口节点 (出口节点上报)
+	ActiveExitAt *time.Time `json:"active_exit_at"`                               // 最近一次上报的时间
+	TargetAddr  string    `gorm:"size:255" json:"target_addr"`             // 目标地址 (如 google.com:443，多端口时可为 host:20000-20100)
 	// 状态
 	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	SyncStatus  string     `gorm:"-" json:"sync_status"`                   // synced/pending/offline/disabled，由入口/出口节点的配置同步状态得出
+	SyncedAt    *time.Time `gorm:"-" json:"synced_at,omitempty"`           // 入口与出口节点都已应用配置的时间
 	TrafficIn   int64     `gorm:"default:0" json:"traffic_in"`
 	TrafficOut  int64     `gorm:"default:0" json:"traffic_out"`
+	ExitTrafficIn  int64  `gorm:"default:0" json:"exit_traffic_in"`        // 出口中继统计的流量 (出口节点上报)
+	ExitTrafficOut int64  `gorm:"default:0" json:"exit_traffic_out"`
 	// 流量配额
 	TrafficQuota  int64   `gorm:"default:0" json:"traffic_quota"`          // 流量配额 (bytes), 0=无限制
 	QuotaResetDay int     `gorm:"default:1" json:"quota_reset_day"`
 	// 限速
 	SpeedLimit    int64   `gorm:"default:0" json:"speed_limit"`            // 限速 (bytes/s), 0=不限
+	// 路径探测 (入口节点经隧道探测目标，出口节点直接探测目标)
+	ProbeType     string  `gorm:"size:10;default:tcp" json:"probe_type"`   // tcp/http/dns/off
+	ProbeTarget   string  `gorm:"size:255" json:"probe_target"`            // http: URL 或路径，dns: 查询域名
+	ProbeInterval int     `gorm:"default:60" json:"probe_interval"`        // 探测间隔 (秒)
+	Probe         *PathProbeSummary `gorm:"-" json:"probe,omitempty"`      // 最近的探测结果
 	// 所有者
 	OwnerID     *uint     `gorm:"index" json:"owner_id,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// TunnelNode 隧道路径上的节点 (由出口节点、出口列表、中继节点或节点组解析得到)
+type TunnelNode struct {
+	NodeID   uint   `json:"node_id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Weight   int    `json:"weight"`
+	Priority int    `json:"priority"` // 数值越小优先级越高
+	Node     *Node  `json:"-"`
+}
+
+// TunnelNodeUnreachable 出口节点在线但路径探测无法到达目标 (入口不再向其转发)
+const TunnelNodeUnreachable = "unreachable"
+
+// TunnelHop 隧道中继跳点 (入口与出口之间按 HopOrder 依次经过)
+// 中继节点使用自身的协议与传输层监听，每个跳点可以是单个节点或节点组
+type TunnelHop struct {
+	ID       uint         `gorm:"primaryKey" json:"id"`
+	TunnelID uint         `gorm:"index" json:"tunnel_id"`
+	HopOrder int          `gorm:"default:0" json:"hop_order"`         // 跳点顺序 (0=入口后的第一跳)
+	NodeID   uint         `gorm:"index" json:"node_id"`               // 中继节点 (未设置节点组时)
+	GroupID  *uint        `gorm:"index" json:"group_id,omitempty"`    // 中继节点组 (组内节点按组的策略负载均衡)
+	Port     int          `gorm:"default:0" json:"port"`              // 中继监听端口，0=与入口端口相同
+	Nodes    []TunnelNode `gorm:"-" json:"nodes,omitempty"`           // 解析后的中继节点
+	Group    *NodeGroup   `gorm:"-" json:"group,omitempty"`
+}
+
+// 节点配置 / 隧道同步状态
+const (
+	ConfigSynced  = "synced"   // 节点已应用面板生成的最新配置
+	ConfigPending = "pending"  // 配置已变更，等待 Agent 重新加载
+	SyncOffline   = "offline"  // 入口或出口节点离线
+	SyncDisabled  = "disabled" // 隧道已停用，不下发到节点
+)
+
 // ProxyChain 代理链 (多跳顺序转发，保留用于高级场景)
 type ProxyChain struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
@@ -187,6 +339,11 @@ type ProxyChain struct {
 	ListenType  string    `gorm:"size:50;default:socks5" json:"listen_type"` // socks5/http/tcp/udp
 	TargetAddr  string    `gorm:"size:255" json:"target_addr"`           // 最终目标地址 (可选，用于端口转发)
 	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	// 路径探测 (每个跳点节点探测下一跳，最后一跳探测目标)
+	ProbeType     string  `gorm:"size:10;default:tcp" json:"probe_type"` // tcp/http/dns/off
+	ProbeTarget   string  `gorm:"size:255" json:"probe_target"`          // http: URL 或路径，dns: 查询域名 (未设置目标地址时的探测目标)
+	ProbeInterval int     `gorm:"default:60" json:"probe_interval"`      // 探测间隔 (秒)
+	Probe         *PathProbeSummary `gorm:"-" json:"probe,omitempty"`    // 最近的探测结果
 	OwnerID     *uint     `gorm:"index" json:"owner_id,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -249,6 +406,9 @@ type User struct {
 	TwoFactorEnabled bool   `gorm:"default:false" json:"two_factor_enabled"`
 	TwoFactorSecret  string `gorm:"size:100" json:"-"`
 	BackupCodes      string `gorm:"type:text" json:"-"` // JSON array of hashed codes
+	// Telegram 机器人绑定
+	TelegramID       int64  `gorm:"index;default:0" json:"telegram_id"` // Telegram 用户 ID，0=未绑定
+	TelegramUsername string `gorm:"size:100" json:"telegram_username"`
 	// 用户套餐
 	PlanID         *uint      `gorm:"index" json:"plan_id,omitempty"`        // 当前套餐ID
 	Plan           *Plan      `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
@@ -261,6 +421,9 @@ type User struct {
 	QuotaResetDay  int       `gorm:"default:1" json:"quota_reset_day"`     // 每月重置日 (1-28)
 	QuotaResetAt   time.Time `json:"quota_reset_at"`                       // 上次重置时间
 	QuotaExceeded  bool      `gorm:"default:false" json:"quota_exceeded"`  // 是否超限
+	QuotaEnforced  string    `gorm:"size:20" json:"quota_enforced"`        // 当前生效的超限处理 (空=未生效)
+	// 订阅链接
+	SubscribeToken string    `gorm:"size:64;index" json:"-"`               // 订阅令牌 (可重置/撤销)
 	CreatedAt         time.Time  `json:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at"`
 }
@@ -277,6 +440,22 @@ type UserSession struct {
 	LastActive time.Time `json:"last_active"`
 }
 
+// ProxyCredential 用户代理凭据 (共享节点上按用户认证和计费)
+type ProxyCredential struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	NodeID     *uint      `gorm:"index" json:"node_id,omitempty"`          // 限定节点 (空=可用于所有有权使用的共享节点)
+	Name       string     `gorm:"size:100" json:"name"`                    // 备注名称
+	Username   string     `gorm:"size:100;uniqueIndex;not null" json:"username"`
+	Password   string     `gorm:"size:100;not null" json:"password"`
+	Enabled    bool       `gorm:"default:true" json:"enabled"`
+	TrafficIn  int64      `gorm:"default:0" json:"traffic_in"`
+	TrafficOut int64      `gorm:"default:0" json:"traffic_out"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
 // Plan 套餐
 type Plan struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
@@ -284,6 +463,10 @@ type Plan struct {
 	Description   string    `gorm:"size:255" json:"description"`             // 套餐描述
 	TrafficQuota  int64     `gorm:"default:0" json:"traffic_quota"`          // 流量配额 (bytes), 0=无限制
 	SpeedLimit    int64     `gorm:"default:0" json:"speed_limit"`            // 速度限制 (bytes/s), 0=不限速
+	ConnRateLimit int       `gorm:"default:0" json:"conn_rate_limit"`        // 每客户端每秒新建连接数, 0=不限制
+	MaxConns      int       `gorm:"default:0" json:"max_conns"`              // 每客户端最大并发连接数, 0=不限制
+	QuotaAction   string    `gorm:"size:20;default:block" json:"quota_action"` // 超限处理: notify/throttle/block
+	QuotaThrottle int64     `gorm:"default:0" json:"quota_throttle"`         // 超限后限速 (bytes/s)
 	Duration      int       `gorm:"default:30" json:"duration"`              // 有效期 (天), 0=永久
 	MaxNodes      int       `gorm:"default:0" json:"max_nodes"`              // 最大节点数, 0=无限制
 	MaxClients    int       `gorm:"default:0" json:"max_clients"`            // 最大客户端数, 0=无限制
@@ -291,6 +474,7 @@ type Plan struct {
 	MaxPortForwards int  `gorm:"default:0" json:"max_port_forwards"`       // 最大端口转发数, 0=无限制
 	MaxProxyChains  int  `gorm:"default:0" json:"max_proxy_chains"`        // 最大代理链数, 0=无限制
 	MaxNodeGroups   int  `gorm:"default:0" json:"max_node_groups"`         // 最大节点组数, 0=无限制
+	PortRange       string `gorm:"size:255" json:"port_range"`             // 允许使用的端口范围 (如 20000-30000), 空=不限制
 	Enabled       bool      `gorm:"default:true" json:"enabled"`             // 是否启用
 	SortOrder     int       `gorm:"default:0" json:"sort_order"`             // 排序顺序
 	CreatedAt     time.Time `json:"created_at"`
@@ -319,8 +503,10 @@ type TrafficHistory struct {
 type NotifyChannel struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Name      string    `gorm:"size:100;not null" json:"name"`          // 渠道名称
-	Type      string    `gorm:"size:20;not null" json:"type"`           // telegram/webhook/smtp
+	Type      string    `gorm:"size:20;not null" json:"type"`           // telegram/webhook/smtp/slack/discord/feishu/dingtalk/wecom/ntfy/gotify
 	Config    string    `gorm:"type:text" json:"config"`                // JSON 配置
+	RateLimit int       `gorm:"default:0" json:"rate_limit"`            // 每分钟最多发送条数，0=不限制
+	UserID    uint      `gorm:"index;default:0" json:"user_id"`         // 个人渠道所属用户 (值班排班呼叫)，0=公共渠道
 	Enabled   bool      `gorm:"default:true" json:"enabled"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -337,6 +523,16 @@ type WebhookConfig struct {
 	URL     string            `json:"url"`
 	Method  string            `json:"method"`  // POST/GET
 	Headers map[string]string `json:"headers"` // 自定义头
+	// 请求体模板 (Go text/template)，为空时发送默认 JSON
+	Template    string `json:"template"`
+	ContentType string `json:"content_type"` // 默认 application/json
+	// HMAC-SHA256 签名: 对 "时间戳.请求体" 签名，接收方可校验时间戳防重放
+	Secret          string `json:"secret"`
+	SignatureHeader string `json:"signature_header"` // 默认 X-Signature
+	TimestampHeader string `json:"timestamp_header"` // 默认 X-Timestamp
+	// 视为成功的状态码范围，默认 200-299
+	SuccessStatusMin int `json:"success_status_min"`
+	SuccessStatusMax int `json:"success_status_max"`
 }
 
 // SMTPConfig SMTP 邮件配置
@@ -350,6 +546,54 @@ type SMTPConfig struct {
 	UseTLS   bool   `json:"use_tls"`
 }
 
+// SlackConfig Slack Incoming Webhook 配置
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Channel    string `json:"channel"` // 可选，覆盖 Webhook 默认频道
+}
+
+// DiscordConfig Discord Webhook 配置
+type DiscordConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Username   string `json:"username"` // 可选，显示的发送者名称
+}
+
+// FeishuConfig 飞书/Lark 自定义机器人配置
+type FeishuConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Secret     string `json:"secret"` // 签名校验密钥 (可选)
+}
+
+// DingTalkConfig 钉钉自定义机器人配置
+type DingTalkConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Secret     string `json:"secret"`     // 加签密钥 (可选)
+	AtMobiles  string `json:"at_mobiles"` // @ 的手机号，逗号分隔
+	AtAll      bool   `json:"at_all"`
+}
+
+// WeComConfig 企业微信群机器人配置
+type WeComConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// NtfyConfig ntfy 推送配置
+type NtfyConfig struct {
+	ServerURL string `json:"server_url"` // 默认 https://ntfy.sh
+	Topic     string `json:"topic"`
+	Token     string `json:"token"` // 访问令牌 (可选)
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Priority  int    `json:"priority"` // 1-5，0=按严重级别
+}
+
+// GotifyConfig Gotify 推送配置
+type GotifyConfig struct {
+	ServerURL string `json:"server_url"`
+	Token     string `json:"token"`    // 应用令牌
+	Priority  int    `json:"priority"` // 0=按严重级别
+}
+
 // AlertRule 告警规则
 type AlertRule struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
@@ -359,6 +603,9 @@ type AlertRule struct {
 	ChannelIDs  string    `gorm:"size:255" json:"channel_ids"`           // 通知渠道 ID，逗号分隔
 	Enabled     bool      `gorm:"default:true" json:"enabled"`
 	CooldownMin int       `gorm:"default:30" json:"cooldown_min"`        // 告警冷却时间（分钟）
+	Severity    string    `gorm:"size:20;default:warning" json:"severity"` // info/warning/critical
+	// 升级策略，设置后按策略分级通知 (ChannelIDs 不再使用)
+	EscalationPolicyID uint `gorm:"default:0" json:"escalation_policy_id"`
 	LastAlertAt time.Time `json:"last_alert_at"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -374,8 +621,144 @@ type AlertLog struct {
 	TargetType string    `gorm:"size:20" json:"target_type"`             // node/client
 	TargetID   uint      `json:"target_id"`
 	TargetName string    `gorm:"size:100" json:"target_name"`
-	Status     string    `gorm:"size:20;default:sent" json:"status"`     // sent/failed
-	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	Status     string    `gorm:"size:20;default:sent;index" json:"status"` // pending/retrying/sent/dead/silenced (failed 为旧版记录)
+	IncidentID uint      `gorm:"index" json:"incident_id"`              // 关联的告警事件
+	SilenceID  uint      `json:"silence_id"`                                // 命中的静默规则 (status=silenced)
+	// 投递队列
+	ChannelID     uint       `gorm:"index" json:"channel_id"`
+	ChannelName   string     `gorm:"size:100" json:"channel_name"`
+	Payload       string     `gorm:"type:text" json:"-"`               // 结构化告警 JSON，重试/重发时使用
+	Attempts      int        `gorm:"default:0" json:"attempts"`       // 已尝试次数
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastError     string     `gorm:"size:500" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+}
+
+// AlertSilence 告警静默 / 维护窗口
+// 一次性静默使用 StartsAt/EndsAt；周期性维护窗口使用 Schedule (cron) + DurationMin
+type AlertSilence struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	Reason          string     `gorm:"size:255" json:"reason"`
+	MatchType       string     `gorm:"size:20;not null" json:"match_type"` // all/node/tag/group
+	MatchID         uint       `json:"match_id"`                           // 节点/标签/节点组 ID
+	AlertTypes      string     `gorm:"size:255" json:"alert_types"`        // 告警类型，逗号分隔，空=全部
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	Schedule        string     `gorm:"size:100" json:"schedule"`               // cron 表达式 (分 时 日 月 周)
+	DurationMin     int        `gorm:"default:0" json:"duration_min"`          // 维护窗口时长（分钟）
+	SkipHealthCheck bool       `gorm:"default:false" json:"skip_health_check"` // 窗口内健康检查不改变节点状态
+	Enabled         bool       `gorm:"default:true" json:"enabled"`
+	CreatedBy       string     `gorm:"size:50" json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AlertIncident 告警事件 (按 规则+目标 维护状态: firing -> acknowledged -> resolved)
+type AlertIncident struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	RuleID          uint       `gorm:"index" json:"rule_id"`
+	RuleName        string     `gorm:"size:100" json:"rule_name"`
+	Type            string     `gorm:"size:50;index" json:"type"`
+	TargetType      string     `gorm:"size:20;index" json:"target_type"`
+	TargetID        uint       `gorm:"index" json:"target_id"`
+	TargetName      string     `gorm:"size:100" json:"target_name"`
+	Status          string     `gorm:"size:20;index" json:"status"` // firing/acknowledged/resolved
+	Severity        string     `gorm:"size:20" json:"severity"`     // 触发时规则的严重级别
+	Message         string     `gorm:"type:text" json:"message"`    // 最近一次触发的消息
+	FireCount       int        `gorm:"default:1" json:"fire_count"` // 触发次数 (去重后累计)
+	FirstFiredAt    time.Time  `json:"first_fired_at"`
+	LastFiredAt     time.Time  `json:"last_fired_at"`
+	LastNotifiedAt  *time.Time `json:"last_notified_at,omitempty"` // 最近一次发送通知时间 (按目标冷却)
+	AckedBy         string     `gorm:"size:100" json:"acked_by"`
+	AckedAt         *time.Time `json:"acked_at,omitempty"`
+	SilencedUntil   *time.Time `json:"silenced_until,omitempty"`          // 静默截止时间
+	EscalationLevel int        `gorm:"default:0" json:"escalation_level"` // 已通知到的升级层级 (1 开始)，0=未通知
+	EscalatedAt     *time.Time `json:"escalated_at,omitempty"`            // 最近一次升级通知时间
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// AlertPendingState 指标告警规则条件开始满足的时间 (按 规则+目标 持久化，面板重启后继续计算持续时间)
+type AlertPendingState struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	RuleID     uint      `gorm:"uniqueIndex:idx_alert_pending_target" json:"rule_id"`
+	TargetType string    `gorm:"size:20;uniqueIndex:idx_alert_pending_target" json:"target_type"`
+	TargetID   uint      `gorm:"uniqueIndex:idx_alert_pending_target" json:"target_id"`
+	Since      time.Time `json:"since"`
+}
+
+// EscalationPolicy 告警升级策略
+// 事件触发后通知第 1 层，超过该层等待时间仍未确认则通知下一层
+type EscalationPolicy struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Tiers       string    `gorm:"type:text" json:"tiers"` // JSON: []EscalationTier
+	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EscalationTier 升级层级
+type EscalationTier struct {
+	DelayMin    int    `json:"delay_min"`    // 上一层通知后多少分钟未确认则通知本层 (第 1 层忽略)
+	ChannelIDs  []uint `json:"channel_ids"`  // 通知渠道
+	ScheduleIDs []uint `json:"schedule_ids"` // 值班排班，呼叫当前值班人的个人渠道
+}
+
+// OnCallSchedule 每周值班排班
+type OnCallSchedule struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Name           string    `gorm:"size:100;not null" json:"name"`
+	Timezone       string    `gorm:"size:50" json:"timezone"` // IANA 时区，空=服务器时区
+	Shifts         string    `gorm:"type:text" json:"shifts"` // JSON: []OnCallShift
+	FallbackUserID uint      `json:"fallback_user_id"`        // 无人值班时呼叫的用户，0=不呼叫
+	Enabled        bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// OnCallShift 值班班次，End 不晚于 Start 时表示跨越午夜
+type OnCallShift struct {
+	Weekday int    `json:"weekday"` // 0=周日 ... 6=周六
+	Start   string `json:"start"`   // HH:MM
+	End     string `json:"end"`     // HH:MM
+	UserID  uint   `json:"user_id"`
+}
+
+// 用户通知事件
+const (
+	UserEventQuota       = "quota"        // 流量配额达到阈值
+	UserEventPlanExpiry  = "plan_expiry"  // 套餐即将到期 / 已到期
+	UserEventExitOffline = "exit_offline" // 隧道出口节点离线 / 恢复
+	UserEventLogin       = "login"        // 账户新登录
+)
+
+// UserNotifyPreference 用户通知订阅 (每个用户每种事件一条，与管理员告警规则相互独立)
+type UserNotifyPreference struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_user_notify_event;not null" json:"user_id"`
+	Event      string    `gorm:"size:30;uniqueIndex:idx_user_notify_event;not null" json:"event"`
+	ChannelIDs string    `gorm:"size:255" json:"channel_ids"` // 用户个人渠道 ID，逗号分隔
+	Thresholds string    `gorm:"size:100" json:"thresholds"`  // quota: 用量百分比；plan_expiry: 提前天数，逗号分隔
+	Enabled    bool      `gorm:"default:false" json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UserNotifyLog 用户通知记录 (同一事件按 DedupKey 只通知一次)
+type UserNotifyLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_notify_dedup;not null" json:"user_id"`
+	Event     string    `gorm:"size:30;uniqueIndex:idx_user_notify_dedup" json:"event"`
+	DedupKey  string    `gorm:"size:100;uniqueIndex:idx_user_notify_dedup" json:"-"`
+	Title     string    `gorm:"size:200" json:"title"`
+	Message   string    `gorm:"type:text" json:"message"`
+	Channels  int       `json:"channels"` // 投递的渠道数量，0=仅记录
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 }
 
 // OperationLog 操作日志
@@ -493,6 +876,137 @@ type HealthCheckLog struct {
 	CheckedAt time.Time `gorm:"index" json:"checked_at"`
 }
 
+// NodeLatency 节点间延迟记录 (源节点 Agent 测量到目标节点的 TCP 握手延迟，每轮多次尝试)
+type NodeLatency struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SourceID   uint      `gorm:"index:idx_node_latency_pair" json:"source_id"`
+	TargetID   uint      `gorm:"index:idx_node_latency_pair" json:"target_id"`
+	Latency    int       `json:"latency"`  // 成功尝试的平均延迟 (ms)
+	Loss       float64   `json:"loss"`     // 失败比例 (%)
+	MeasuredAt time.Time `gorm:"index" json:"measured_at"`
+}
+
+// 路径探测范围
+const (
+	ProbeScopePath   = "path"   // 入口节点经隧道入口端口的端到端探测
+	ProbeScopeTarget = "target" // 出口节点 (或代理链最后一跳) 直接探测目标
+	ProbeScopeHop    = "hop"    // 代理链跳点探测下一跳
+)
+
+// PathProbeResult 路径探测记录 (Agent 对隧道/代理链发起的合成探测，每轮多次尝试)
+type PathProbeResult struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ResourceType string    `gorm:"size:20;index:idx_path_probe_resource" json:"resource_type"` // tunnel/chain
+	ResourceID   uint      `gorm:"index:idx_path_probe_resource" json:"resource_id"`
+	NodeID       uint      `gorm:"index" json:"node_id"`        // 发起探测的节点
+	Scope        string    `gorm:"size:20" json:"scope"`        // path/target/hop
+	Type         string    `gorm:"size:10" json:"type"`         // tcp/http/dns
+	Target       string    `gorm:"size:255" json:"target"`      // 探测的地址
+	Attempts     int       `json:"attempts"`                    // 尝试次数
+	Loss         float64   `json:"loss"`                        // 失败比例 (%)
+	Latency      int       `json:"latency"`                     // 成功尝试的平均延迟 (ms)，0=无法测量
+	ErrorMsg     string    `gorm:"size:500" json:"error_msg"`   // 最近一次失败的原因
+	CheckedAt    time.Time `gorm:"index" json:"checked_at"`
+}
+
+// PathProbeSummary 隧道/代理链最近的探测状态 (由各探测点最近一轮结果汇总)
+type PathProbeSummary struct {
+	Status    string    `json:"status"`              // ok/degraded/failed
+	Latency   int       `json:"latency"`             // 端到端延迟 (ms)
+	Loss      float64   `json:"loss"`                // 最大失败比例 (%)
+	Error     string    `json:"error,omitempty"`     // 失败原因
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// 摘要报告频率
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSchedule 定期摘要报告 (每个接收人一条)
+// 接收人为邮箱 (通过系统邮件发送 HTML 报告) 或通知渠道 (发送文本摘要)，二选一
+type DigestSchedule struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Frequency  string     `gorm:"size:10;default:daily" json:"frequency"` // daily/weekly
+	Weekday    int        `gorm:"default:1" json:"weekday"`               // weekly 发送日: 0=周日 ... 6=周六
+	Hour       int        `gorm:"default:8" json:"hour"`                  // 发送时刻 (0-23)
+	Timezone   string     `gorm:"size:50" json:"timezone"`                // IANA 时区，空=服务器时区
+	Email      string     `gorm:"size:255" json:"email"`                  // 收件邮箱，逗号分隔
+	ChannelID  uint       `gorm:"default:0" json:"channel_id"`            // 通知渠道
+	TopN       int        `gorm:"default:5" json:"top_n"`                 // 各排行榜条数
+	Enabled    bool       `gorm:"default:true" json:"enabled"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	LastError  string     `gorm:"size:500" json:"last_error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TrafficSnapshot 流量累计值的整点快照，用于计算摘要周期内的流量 (流量历史仅保留 24 小时)
+type TrafficSnapshot struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TargetType string    `gorm:"size:20;index:idx_traffic_snapshot_target" json:"target_type"` // node/tunnel/user
+	TargetID   uint      `gorm:"index:idx_traffic_snapshot_target" json:"target_id"`
+	TrafficIn  int64     `json:"traffic_in"`
+	TrafficOut int64     `json:"traffic_out"`
+	RecordedAt time.Time `gorm:"index" json:"recorded_at"`
+}
+
+// QuotaEnforcementLog 超限处理变更记录
+type QuotaEnforcementLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TargetType string    `gorm:"size:20;index" json:"target_type"` // node, user
+	TargetID   uint      `gorm:"index" json:"target_id"`
+	TargetName string    `gorm:"size:100" json:"target_name"`
+	Action     string    `gorm:"size:20" json:"action"` // notify, throttle, block
+	Event      string    `gorm:"size:20" json:"event"`  // apply, lift
+	Reason     string    `gorm:"size:255" json:"reason"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// 超限处理方式
+const (
+	QuotaActionNotify   = "notify"   // 仅通知
+	QuotaActionThrottle = "throttle" // 限速
+	QuotaActionBlock    = "block"    // 阻断
+
+	DefaultQuotaThrottle int64 = 128 * 1024 // 未设置超限限速时的默认值 (128KB/s)
+)
+
+// InternalCA 面板内部 CA (为节点签发 mTLS 证书)
+type InternalCA struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CertPEM   string    `gorm:"type:text" json:"cert_pem"`
+	KeyPEM    string    `gorm:"type:text" json:"-"`
+	ExpireAt  time.Time `json:"expire_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExposureCertificate 节点域名暴露入口的 TLS 证书 (覆盖该节点上全部由节点终止 TLS 的域名)
+type ExposureCertificate struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	NodeID    uint       `gorm:"uniqueIndex" json:"node_id"`
+	Domains   string     `gorm:"type:text" json:"domains"`    // 证书覆盖的域名 (逗号分隔)
+	Issuer    string     `gorm:"size:20" json:"issuer"`       // acme/internal
+	CertPEM   string     `gorm:"type:text" json:"-"`          // 证书链
+	KeyPEM    string     `gorm:"type:text" json:"-"`          // 私钥 (隐藏)
+	Serial    string     `gorm:"size:64" json:"serial"`       // 证书序列号
+	ExpireAt  *time.Time `json:"expire_at"`                   // 过期时间
+	LastError string     `gorm:"size:500" json:"last_error"` // 最近一次 ACME 签发失败的原因
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ACMEAccount ACME 账户 (自动签发域名暴露证书)
+type ACMEAccount struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DirectoryURL string    `gorm:"size:255;uniqueIndex" json:"directory_url"`
+	Email        string    `gorm:"size:255" json:"email"`
+	KeyPEM       string    `gorm:"type:text" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
 // SiteConfig 网站配置
 type SiteConfig struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -521,7 +1035,7 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 	}
 
 	// 自动迁移
-	if err := db.AutoMigrate(&Node{}, &Client{}, &Service{}, &User{}, &UserSession{}, &Plan{}, &PlanResource{}, &TrafficHistory{}, &NotifyChannel{}, &AlertRule{}, &AlertLog{}, &PortForward{}, &NodeGroup{}, &NodeGroupMember{}, &DNSConfig{}, &OperationLog{}, &ProxyChain{}, &ProxyChainHop{}, &Tunnel{}, &SiteConfig{}, &Tag{}, &NodeTag{}, &Bypass{}, &Admission{}, &HostMapping{}, &Ingress{}, &Recorder{}, &Router{}, &SD{}, &ConfigVersion{}, &HealthCheckLog{}); err != nil {
+	if err := db.AutoMigrate(&Node{}, &Client{}, &ClientExposure{}, &Service{}, &User{}, &UserSession{}, &Plan{}, &PlanResource{}, &TrafficHistory{}, &NotifyChannel{}, &AlertRule{}, &AlertLog{}, &PortForward{}, &PortAllocation{}, &NodeGroup{}, &NodeGroupMember{}, &NodeGroupHealthEvent{}, &DNSConfig{}, &OperationLog{}, &ProxyChain{}, &ProxyChainHop{}, &Tunnel{}, &TunnelHop{}, &SiteConfig{}, &Tag{}, &NodeTag{}, &Bypass{}, &Admission{}, &HostMapping{}, &Ingress{}, &Recorder{}, &Router{}, &SD{}, &ConfigVersion{}, &HealthCheckLog{}, &PathProbeResult{}, &NodeLatency{}, &InternalCA{}, &ExposureCertificate{}, &ACMEAccount{}, &ProxyCredential{}, &QuotaEnforcementLog{}, &AlertIncident{}, &AlertPendingState{}, &AlertSilence{}, &EscalationPolicy{}, &OnCallSchedule{}, &UserNotifyPreference{}, &UserNotifyLog{}, &DigestSchedule{}, &TrafficSnapshot{}); err != nil {
 		return nil, err
 	}
 
@@ -536,6 +1050,13 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_port_forwards_node ON port_forwards(node_id, enabled)")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_tunnels_entry_exit ON tunnels(entry_node_id, exit_node_id)")
 
+	// 每个 (规则, 目标) 最多一个未解决的告警事件，防止并发评估 (如心跳与定时检查) 重复创建
+	// 先将已有的重复事件中较早的标记为已解决，再创建部分唯一索引
+	db.Exec(`UPDATE alert_incidents SET status = 'resolved', resolved_at = CURRENT_TIMESTAMP
+		WHERE status IN ('firing', 'acknowledged') AND id NOT IN (
+			SELECT MAX(id) FROM alert_incidents WHERE status IN ('firing', 'acknowledged') GROUP BY rule_id, target_type, target_id)`)
+	db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_incidents_open ON alert_incidents(rule_id, target_type, target_id) WHERE status IN ('firing', 'acknowledged')")
+
 	// 创建默认管理员
 	var count int64
 	db.Model(&User{}).Count(&count)
@@ -683,6 +1204,10 @@ const (
 	ConfigSiteURL                = "site_url"                 // 站点 URL（用于邮件链接）
 	ConfigAgentAutoUpdate        = "agent_auto_update"        // Agent 自动更新开关
 	ConfigAgentForceUpdate       = "agent_force_update"       // 强制所有 Agent 更新
+	ConfigTelegramBotToken       = "telegram_bot_token"       // 交互式 Telegram 机器人 Token (空=不启用)
+	ConfigACMEEmail              = "acme_email"               // ACME 账户邮箱 (域名暴露证书)
+	ConfigACMEDirectoryURL       = "acme_directory_url"       // ACME 目录地址 (空=Let's Encrypt)
+	ConfigLatencyProbeInterval   = "latency_probe_interval"   // 节点间延迟测量间隔 (秒)
 )
 
 // initDefaultSiteConfigs 初始化默认系统配置
@@ -695,6 +1220,10 @@ func initDefaultSiteConfigs(db *gorm.DB) {
 		ConfigSiteURL:                   "",
 		ConfigAgentAutoUpdate:           "true",
 		ConfigAgentForceUpdate:          "false",
+		ConfigTelegramBotToken:          "",
+		ConfigACMEEmail:                 "",
+		ConfigACMEDirectoryURL:          "",
+		ConfigLatencyProbeInterval:      "300",
 	}
 
 	for key, value := range defaultConfigs {
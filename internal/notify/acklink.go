@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 确认链接有效期
+const ackLinkTTL = 7 * 24 * time.Hour
+
+// SetAckLinkSigner 设置告警确认链接的签名密钥与站点地址
+// siteURL 返回空时通知中不附带确认链接
+func (a *AlertService) SetAckLinkSigner(secret string, siteURL func() string) {
+	key := sha256.Sum256([]byte("alert-ack:" + secret))
+	a.ackKey = key[:]
+	a.siteURL = siteURL
+}
+
+func (a *AlertService) ackSignature(incidentID uint, expires int64) string {
+	mac := hmac.New(sha256.New, a.ackKey)
+	fmt.Fprintf(mac, "%d.%d", incidentID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AckLink 生成告警事件的签名确认链接，未配置站点地址时返回空
+func (a *AlertService) AckLink(incidentID uint) string {
+	if a.ackKey == nil || a.siteURL == nil || incidentID == 0 {
+		return ""
+	}
+	site := strings.TrimSuffix(a.siteURL(), "/")
+	if site == "" {
+		return ""
+	}
+	expires := time.Now().Add(ackLinkTTL).Unix()
+	return fmt.Sprintf("%s/alert-ack?incident=%d&expires=%d&sig=%s",
+		site, incidentID, expires, a.ackSignature(incidentID, expires))
+}
+
+// VerifyAckLink 校验确认链接的签名与有效期
+func (a *AlertService) VerifyAckLink(incidentID uint, expires int64, sig string) error {
+	if a.ackKey == nil {
+		return errors.New("确认链接不可用")
+	}
+	if !hmac.Equal([]byte(sig), []byte(a.ackSignature(incidentID, expires))) {
+		return errors.New("确认链接无效")
+	}
+	if time.Now().Unix() > expires {
+		return errors.New("确认链接已过期")
+	}
+	return nil
+}
@@ -3,9 +3,7 @@ package notify
 import (
 	"encoding/json"
 	"fmt"
-	"log"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/AliceNetworks/gost-panel/internal/model"
@@ -15,10 +13,28 @@ import (
 // AlertService 告警服务
 type AlertService struct {
 	db *gorm.DB
+
+	mu        sync.Mutex
+	pending   map[metricStateKey]time.Time // 指标规则条件开始满足的时间 (同时保存在 AlertPendingState)
+	sendTimes map[uint][]time.Time         // 各渠道最近一分钟的发送时间 (限速)
+
+	wake   chan struct{} // 唤醒投递协程
+	stopCh chan struct{}
+
+	ackKey  []byte        // 确认链接签名密钥
+	siteURL func() string // 站点地址 (生成确认链接)
 }
 
 func NewAlertService(db *gorm.DB) *AlertService {
-	return &AlertService{db: db}
+	a := &AlertService{
+		db:        db,
+		pending:   make(map[metricStateKey]time.Time),
+		sendTimes: make(map[uint][]time.Time),
+		wake:      make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+	a.loadPending()
+	return a
 }
 
 // CheckNodeQuota 检查节点流量配额
@@ -68,12 +84,7 @@ func (a *AlertService) checkQuotaWarning(targetID uint, targetType, targetName s
 			continue
 		}
 
-		// 检查冷却时间
-		if time.Since(rule.LastAlertAt) < time.Duration(rule.CooldownMin)*time.Minute {
-			continue
-		}
-
-		// 检查是否已经发送过该阈值的预警 (避免重复告警)
+		// 检查是否已经发送过该阈值的预警 (避免重复告警，冷却由告警事件按目标计算)
 		warningKey := fmt.Sprintf("quota_warning_%s_%d_%d", targetType, targetID, threshold)
 		if a.hasRecentWarning(warningKey, 24*time.Hour) {
 			continue
@@ -87,7 +98,9 @@ func (a *AlertService) checkQuotaWarning(targetID uint, targetType, targetName s
 			formatBytes(used),
 			formatBytes(quota))
 
-		a.TriggerAlertWithKey("quota_warning", targetType, targetID, targetName, message, warningKey)
+		// 只触发当前阈值对应的规则
+		a.triggerRule(&rule, "quota_warning", targetType, targetID, targetName, message+"\n<!-- "+warningKey+" -->",
+			map[string]interface{}{"used": used, "quota": quota, "percent": percent, "threshold": threshold})
 	}
 }
 
@@ -114,6 +127,10 @@ func targetTypeToName(targetType string) string {
 		return "节点"
 	case "client":
 		return "客户端"
+	case "tunnel":
+		return "隧道"
+	case "chain":
+		return "代理链"
 	default:
 		return targetType
 	}
@@ -141,79 +158,21 @@ func (a *AlertService) CheckClientQuota(client *model.Client) {
 	}
 }
 
-// CheckNodeOffline 检查节点离线
+// CheckNodeOffline 检查节点离线 (重新上线时解除离线告警)
 func (a *AlertService) CheckNodeOffline(node *model.Node, previousStatus string) {
+	if previousStatus != "online" && node.Status == "online" {
+		a.ResolveAlert("node_offline", "node", node.ID, node.Name,
+			fmt.Sprintf("节点 %s 已恢复在线", node.Name))
+		if previousStatus == "offline" {
+			a.notifyExitNodeUsers(node, true)
+		}
+	}
 	if previousStatus == "online" && node.Status == "offline" {
 		a.TriggerAlert("node_offline", "node", node.ID, node.Name,
 			fmt.Sprintf("节点 %s 已离线\n最后在线: %s",
 				node.Name,
 				node.LastSeen.Format("2006-01-02 15:04:05")))
-	}
-}
-
-// TriggerAlert 触发告警
-func (a *AlertService) TriggerAlert(alertType, targetType string, targetID uint, targetName, message string) {
-	// 查找匹配的告警规则
-	var rules []model.AlertRule
-	a.db.Where("type = ? AND enabled = ?", alertType, true).Find(&rules)
-
-	for _, rule := range rules {
-		// 检查冷却时间
-		if time.Since(rule.LastAlertAt) < time.Duration(rule.CooldownMin)*time.Minute {
-			continue
-		}
-
-		// 发送通知
-		channelIDs := strings.Split(rule.ChannelIDs, ",")
-		for _, idStr := range channelIDs {
-			idStr = strings.TrimSpace(idStr)
-			if idStr == "" {
-				continue
-			}
-
-			channelID, err := strconv.ParseUint(idStr, 10, 32)
-			if err != nil {
-				continue
-			}
-
-			var channel model.NotifyChannel
-			if err := a.db.First(&channel, channelID).Error; err != nil {
-				continue
-			}
-
-			if !channel.Enabled {
-				continue
-			}
-
-			notifier, err := CreateNotifier(&channel)
-			if err != nil {
-				log.Printf("Create notifier failed: %v", err)
-				continue
-			}
-
-			status := "sent"
-			title := fmt.Sprintf("[%s] %s", alertTypeToTitle(alertType), targetName)
-			if err := notifier.Send(title, message); err != nil {
-				log.Printf("Send notification failed: %v", err)
-				status = "failed"
-			}
-
-			// 记录告警日志
-			a.db.Create(&model.AlertLog{
-				RuleID:     rule.ID,
-				RuleName:   rule.Name,
-				Type:       alertType,
-				Message:    message,
-				TargetType: targetType,
-				TargetID:   targetID,
-				TargetName: targetName,
-				Status:     status,
-				CreatedAt:  time.Now(),
-			})
-		}
-
-		// 更新规则的最后告警时间
-		a.db.Model(&rule).Update("last_alert_at", time.Now())
+		a.notifyExitNodeUsers(node, false)
 	}
 }
 
@@ -232,6 +191,7 @@ func (a *AlertService) ResetQuotas() {
 			"quota_exceeded": false,
 			"quota_reset_at": time.Now(),
 		})
+		a.ResolveQuotaAlerts("node", node.ID, node.Name)
 	}
 
 	// 重置客户端配额
@@ -245,9 +205,17 @@ func (a *AlertService) ResetQuotas() {
 			"quota_exceeded": false,
 			"quota_reset_at": time.Now(),
 		})
+		a.ResolveQuotaAlerts("client", client.ID, client.Name)
 	}
 }
 
+// ResolveQuotaAlerts 配额重置后解除超限/预警告警
+func (a *AlertService) ResolveQuotaAlerts(targetType string, targetID uint, targetName string) {
+	message := fmt.Sprintf("%s %s 流量配额已重置", targetTypeToName(targetType), targetName)
+	a.ResolveAlert("quota_exceeded", targetType, targetID, targetName, message)
+	a.ResolveAlert("quota_warning", targetType, targetID, targetName, message)
+}
+
 // CheckOfflineNodes 检查离线节点（心跳超时）
 func (a *AlertService) CheckOfflineNodes(timeoutMinutes int) {
 	threshold := time.Now().Add(-time.Duration(timeoutMinutes) * time.Minute)
@@ -261,22 +229,27 @@ func (a *AlertService) CheckOfflineNodes(timeoutMinutes int) {
 			fmt.Sprintf("节点 %s 心跳超时，已标记为离线\n最后心跳: %s",
 				node.Name,
 				node.LastSeen.Format("2006-01-02 15:04:05")))
+		a.notifyExitNodeUsers(&node, false)
 	}
 }
 
 // CleanupAlertLogs 清理旧的告警日志
 func (a *AlertService) CleanupAlertLogs(retentionDays int) {
 	threshold := time.Now().AddDate(0, 0, -retentionDays)
-	a.db.Where("created_at < ?", threshold).Delete(&model.AlertLog{})
+	a.db.Where("created_at < ? AND status NOT IN ?", threshold, []string{DeliveryPending, DeliveryRetrying}).Delete(&model.AlertLog{})
 }
 
-// GetAlertLogs 获取告警日志
-func (a *AlertService) GetAlertLogs(limit, offset int) ([]model.AlertLog, int64, error) {
+// GetAlertLogs 获取告警日志，status 非空时按投递状态过滤 (如 dead 查看死信)
+func (a *AlertService) GetAlertLogs(status string, limit, offset int) ([]model.AlertLog, int64, error) {
 	var logs []model.AlertLog
 	var total int64
 
-	a.db.Model(&model.AlertLog{}).Count(&total)
-	err := a.db.Order("created_at desc").Limit(limit).Offset(offset).Find(&logs).Error
+	query := a.db.Model(&model.AlertLog{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Count(&total)
+	err := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&logs).Error
 
 	return logs, total, err
 }
@@ -295,11 +268,18 @@ func alertTypeToTitle(alertType string) string {
 		return "流量异常"
 	case "agent_update":
 		return "Agent 更新"
+	case AlertTypeMetric:
+		return "指标告警"
 	default:
 		return "告警"
 	}
 }
 
+// FormatBytes 格式化字节数
+func FormatBytes(bytes int64) string {
+	return formatBytes(bytes)
+}
+
 func formatBytes(bytes int64) string {
 	const (
 		KB = 1024
@@ -362,13 +342,11 @@ func (a *AlertService) TestChannel(id uint) error {
 	if err != nil {
 		return err
 	}
-
-	notifier, err := CreateNotifier(channel)
-	if err != nil {
-		return err
+	if channel.UserID != 0 {
+		return a.TestPersonalChannel(channel)
 	}
 
-	return notifier.Send("测试通知", "这是一条来自 GOST Panel 的测试通知消息。\n如果您收到此消息，说明通知渠道配置正确。")
+	return SendTest(channel)
 }
 
 // ==================== 告警规则管理 ====================
@@ -458,7 +436,15 @@ func (a *AlertService) CreateDefaultRules() error {
 // AlertRuleCondition 告警条件
 type AlertRuleCondition struct {
 	Threshold int64 `json:"threshold"` // 阈值
-	Duration  int   `json:"duration"`  // 持续时间（分钟）
+	Duration  int   `json:"duration"`  // 持续时间（分钟），条件持续满足该时长后才触发
+
+	// 指标规则 (type=metric)
+	Metric   string `json:"metric,omitempty"`    // 指标名称，见 Metric* 常量
+	Operator string `json:"operator,omitempty"`  // > >= < <=，默认见 defaultMetricOperator
+	Window   int    `json:"window,omitempty"`    // 统计窗口（分钟），用于流量速率/延迟/失败率
+	Target   string `json:"target,omitempty"`    // 目标类型 node/client/user，仅流量使用率可选
+	TagIDs   []uint `json:"tag_ids,omitempty"`   // 限定带有这些标签的节点
+	GroupIDs []uint `json:"group_ids,omitempty"` // 限定这些节点组内的节点
 }
 
 // ParseCondition 解析告警条件
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ValidateChannel 按渠道类型校验通知渠道配置
+func ValidateChannel(channel *model.NotifyChannel) error {
+	notifier, err := CreateNotifier(channel)
+	if err != nil {
+		return err
+	}
+
+	switch n := notifier.(type) {
+	case *TelegramNotifier:
+		if n.BotToken == "" || n.ChatID == "" {
+			return errors.New("telegram 需要 bot_token 和 chat_id")
+		}
+	case *WebhookNotifier:
+		return validateWebhook(channel, n)
+	case *SMTPNotifier:
+		if n.Host == "" || n.Port <= 0 || n.From == "" {
+			return errors.New("smtp 需要 smtp_host、smtp_port 和 from")
+		}
+		if len(n.To) == 0 || n.To[0] == "" {
+			return errors.New("smtp 需要至少一个收件人 (to)")
+		}
+	case *SlackNotifier:
+		return validateHTTPURL("webhook_url", n.WebhookURL)
+	case *DiscordNotifier:
+		return validateHTTPURL("webhook_url", n.WebhookURL)
+	case *FeishuNotifier:
+		return validateHTTPURL("webhook_url", n.WebhookURL)
+	case *DingTalkNotifier:
+		return validateHTTPURL("webhook_url", n.WebhookURL)
+	case *WeComNotifier:
+		return validateHTTPURL("webhook_url", n.WebhookURL)
+	case *NtfyNotifier:
+		if n.Topic == "" || strings.ContainsAny(n.Topic, "/ ") {
+			return errors.New("ntfy 需要有效的 topic")
+		}
+		if n.Priority < 0 || n.Priority > 5 {
+			return errors.New("ntfy priority 取值 1-5")
+		}
+		return validateHTTPURL("server_url", n.ServerURL)
+	case *GotifyNotifier:
+		if n.Token == "" {
+			return errors.New("gotify 需要应用 token")
+		}
+		if n.Priority < 0 || n.Priority > 10 {
+			return errors.New("gotify priority 取值 1-10")
+		}
+		return validateHTTPURL("server_url", n.ServerURL)
+	}
+	return nil
+}
+
+// validateHTTPURL 校验 http/https 地址
+func validateHTTPURL(field, raw string) error {
+	if raw == "" {
+		return fmt.Errorf("%s 不能为空", field)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s 必须是有效的 http/https 地址", field)
+	}
+	return nil
+}
+
+// SendTest 使用渠道配置发送测试通知 (渠道可以尚未保存)
+func SendTest(channel *model.NotifyChannel) error {
+	if err := ValidateChannel(channel); err != nil {
+		return err
+	}
+	notifier, err := CreateNotifier(channel)
+	if err != nil {
+		return err
+	}
+	return sendAlert(notifier, testAlert())
+}
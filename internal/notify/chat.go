@@ -0,0 +1,281 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== Slack ====================
+
+// SlackNotifier Slack Incoming Webhook 通知 (Block Kit)
+type SlackNotifier struct {
+	WebhookURL string
+	Channel    string
+}
+
+func NewSlackNotifier(config *model.SlackConfig) *SlackNotifier {
+	return &SlackNotifier{WebhookURL: config.WebhookURL, Channel: config.Channel}
+}
+
+func (s *SlackNotifier) Send(title, message string) error {
+	return s.SendAlert(plainAlert(title, message))
+}
+
+func (s *SlackNotifier) SendAlert(alert *Alert) error {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": alert.Title},
+		},
+		{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": plainMessage(alert.Message)},
+		},
+		{
+			"type": "context",
+			"elements": []map[string]interface{}{
+				{"type": "mrkdwn", "text": alertSummary(alert)},
+			},
+		},
+	}
+
+	payload := map[string]interface{}{
+		"text": alert.Title, // 通知预览
+		"attachments": []map[string]interface{}{
+			{"color": fmt.Sprintf("#%06x", severityColor(alert)), "blocks": blocks},
+		},
+	}
+	if s.Channel != "" {
+		payload["channel"] = s.Channel
+	}
+
+	_, err := postJSON("slack", s.WebhookURL, payload, nil)
+	return err
+}
+
+// ==================== Discord ====================
+
+// DiscordNotifier Discord Webhook 通知 (Embed)
+type DiscordNotifier struct {
+	WebhookURL string
+	Username   string
+}
+
+func NewDiscordNotifier(config *model.DiscordConfig) *DiscordNotifier {
+	return &DiscordNotifier{WebhookURL: config.WebhookURL, Username: config.Username}
+}
+
+func (d *DiscordNotifier) Send(title, message string) error {
+	return d.SendAlert(plainAlert(title, message))
+}
+
+func (d *DiscordNotifier) SendAlert(alert *Alert) error {
+	fields := []map[string]interface{}{
+		{"name": "级别", "value": severityText(alert), "inline": true},
+	}
+	if alert.TargetName != "" {
+		fields = append(fields, map[string]interface{}{
+			"name": targetTypeToName(alert.TargetType), "value": alert.TargetName, "inline": true,
+		})
+	}
+	if alert.RuleName != "" {
+		fields = append(fields, map[string]interface{}{"name": "规则", "value": alert.RuleName, "inline": true})
+	}
+
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{
+			{
+				"title":       alert.Title,
+				"description": plainMessage(alert.Message),
+				"color":       severityColor(alert),
+				"fields":      fields,
+				"timestamp":   alert.Time.Format(time.RFC3339),
+			},
+		},
+	}
+	if d.Username != "" {
+		payload["username"] = d.Username
+	}
+
+	_, err := postJSON("discord", d.WebhookURL, payload, nil)
+	return err
+}
+
+// ==================== 飞书 / Lark ====================
+
+// FeishuNotifier 飞书/Lark 自定义机器人通知 (消息卡片)
+type FeishuNotifier struct {
+	WebhookURL string
+	Secret     string
+}
+
+func NewFeishuNotifier(config *model.FeishuConfig) *FeishuNotifier {
+	return &FeishuNotifier{WebhookURL: config.WebhookURL, Secret: config.Secret}
+}
+
+func (f *FeishuNotifier) Send(title, message string) error {
+	return f.SendAlert(plainAlert(title, message))
+}
+
+func (f *FeishuNotifier) SendAlert(alert *Alert) error {
+	template := "orange"
+	switch {
+	case alert.Resolved:
+		template = "green"
+	case alert.Severity == SeverityCritical:
+		template = "red"
+	case alert.Severity == SeverityInfo:
+		template = "blue"
+	}
+
+	payload := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title":    map[string]interface{}{"tag": "plain_text", "content": alert.Title},
+				"template": template,
+			},
+			"elements": []map[string]interface{}{
+				{"tag": "div", "text": map[string]interface{}{"tag": "lark_md", "content": plainMessage(alert.Message)}},
+				{"tag": "note", "elements": []map[string]interface{}{
+					{"tag": "plain_text", "content": alertSummary(alert)},
+				}},
+			},
+		},
+	}
+	if f.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = feishuSign(timestamp, f.Secret)
+	}
+
+	body, err := postJSON("feishu", f.WebhookURL, payload, nil)
+	if err != nil {
+		return err
+	}
+	return checkErrCode("feishu", body)
+}
+
+// feishuSign 飞书签名: 以 "timestamp\nsecret" 为密钥对空串做 HmacSHA256
+func feishuSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ==================== 钉钉 ====================
+
+// DingTalkNotifier 钉钉自定义机器人通知 (Markdown)
+type DingTalkNotifier struct {
+	WebhookURL string
+	Secret     string
+	AtMobiles  []string
+	AtAll      bool
+}
+
+func NewDingTalkNotifier(config *model.DingTalkConfig) *DingTalkNotifier {
+	var mobiles []string
+	for _, m := range strings.Split(config.AtMobiles, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			mobiles = append(mobiles, m)
+		}
+	}
+	return &DingTalkNotifier{
+		WebhookURL: config.WebhookURL,
+		Secret:     config.Secret,
+		AtMobiles:  mobiles,
+		AtAll:      config.AtAll,
+	}
+}
+
+func (d *DingTalkNotifier) Send(title, message string) error {
+	return d.SendAlert(plainAlert(title, message))
+}
+
+func (d *DingTalkNotifier) SendAlert(alert *Alert) error {
+	text := fmt.Sprintf("### <font color=\"#%06x\">%s</font>\n\n%s\n\n> %s",
+		severityColor(alert), alert.Title, markdownLines(alert.Message), alertSummary(alert))
+	for _, m := range d.AtMobiles {
+		text += " @" + m // 钉钉要求正文包含 @手机号 才会高亮
+	}
+
+	payload := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]interface{}{"title": alert.Title, "text": text},
+		"at":       map[string]interface{}{"atMobiles": d.AtMobiles, "isAtAll": d.AtAll},
+	}
+
+	webhookURL := d.WebhookURL
+	if d.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		sep := "&"
+		if !strings.Contains(webhookURL, "?") {
+			sep = "?"
+		}
+		webhookURL += sep + "timestamp=" + timestamp + "&sign=" + url.QueryEscape(dingTalkSign(timestamp, d.Secret))
+	}
+
+	body, err := postJSON("dingtalk", webhookURL, payload, nil)
+	if err != nil {
+		return err
+	}
+	return checkErrCode("dingtalk", body)
+}
+
+// dingTalkSign 钉钉加签: 以 secret 为密钥对 "timestamp\nsecret" 做 HmacSHA256
+func dingTalkSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ==================== 企业微信 ====================
+
+// WeComNotifier 企业微信群机器人通知 (Markdown)
+type WeComNotifier struct {
+	WebhookURL string
+}
+
+func NewWeComNotifier(config *model.WeComConfig) *WeComNotifier {
+	return &WeComNotifier{WebhookURL: config.WebhookURL}
+}
+
+func (w *WeComNotifier) Send(title, message string) error {
+	return w.SendAlert(plainAlert(title, message))
+}
+
+func (w *WeComNotifier) SendAlert(alert *Alert) error {
+	// 企业微信 Markdown 仅支持 info(绿)/comment(灰)/warning(橙红) 三种颜色
+	color := "warning"
+	if alert.Resolved {
+		color = "info"
+	} else if alert.Severity == SeverityInfo {
+		color = "comment"
+	}
+
+	content := fmt.Sprintf("**<font color=\"%s\">%s</font>**\n%s\n> <font color=\"comment\">%s</font>",
+		color, alert.Title, markdownLines(alert.Message), alertSummary(alert))
+
+	payload := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]interface{}{"content": content},
+	}
+
+	body, err := postJSON("wecom", w.WebhookURL, payload, nil)
+	if err != nil {
+		return err
+	}
+	return checkErrCode("wecom", body)
+}
+
+// markdownLines 将普通换行转为 Markdown 换行，并去掉去重用的隐藏标识
+func markdownLines(message string) string {
+	return strings.ReplaceAll(plainMessage(message), "\n", "  \n")
+}
@@ -0,0 +1,301 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// capturedRequest 本地替身服务收到的请求
+type capturedRequest struct {
+	Method string
+	Path   string
+	Query  map[string]string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// newStandIn 启动记录请求的本地 HTTP 服务，以 response 作为响应体
+func newStandIn(t *testing.T, response string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	captured := &capturedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		captured.Method = r.Method
+		captured.Path = r.URL.Path
+		captured.Query = map[string]string{}
+		for k := range r.URL.Query() {
+			captured.Query[k] = r.URL.Query().Get(k)
+		}
+		captured.Header = r.Header.Clone()
+		captured.Body = map[string]interface{}{}
+		if err := json.Unmarshal(raw, &captured.Body); err != nil {
+			t.Errorf("request body is not JSON: %s", raw)
+		}
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, captured
+}
+
+func criticalAlert() *Alert {
+	return &Alert{
+		Title:      "节点离线",
+		Message:    "节点 hk-1 已离线\n<!-- dedup:node-1 -->",
+		Severity:   SeverityCritical,
+		TargetType: "node",
+		TargetName: "hk-1",
+		RuleName:   "离线告警",
+		Time:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// path 按 "a.b.0.c" 取出 JSON 中的值
+func path(t *testing.T, v interface{}, keys string) interface{} {
+	t.Helper()
+	for _, key := range strings.Split(keys, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(node) {
+				t.Fatalf("invalid index %q in %s", key, keys)
+			}
+			v = node[i]
+		default:
+			t.Fatalf("cannot resolve %s", keys)
+		}
+	}
+	return v
+}
+
+func assertJSONRequest(t *testing.T, req *capturedRequest) {
+	t.Helper()
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
+
+// assertRecentTimestamp 签名时间戳应为当前时间 (unit 为秒或毫秒)
+func assertRecentTimestamp(t *testing.T, timestamp string, unit time.Duration) {
+	t.Helper()
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp %q is not a number", timestamp)
+	}
+	if d := time.Since(time.Unix(0, ts*int64(unit))); d < 0 || d > time.Minute {
+		t.Errorf("timestamp %s is not current (off by %s)", timestamp, d)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	server, req := newStandIn(t, "ok")
+	n := NewSlackNotifier(&model.SlackConfig{WebhookURL: server.URL + "/services/T/B/X", Channel: "#ops"})
+	if err := n.SendAlert(criticalAlert()); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	if req.Path != "/services/T/B/X" {
+		t.Errorf("path = %s", req.Path)
+	}
+	if got := path(t, req.Body, "text"); got != "节点离线" {
+		t.Errorf("text = %v", got)
+	}
+	if got := path(t, req.Body, "channel"); got != "#ops" {
+		t.Errorf("channel = %v", got)
+	}
+	if got := path(t, req.Body, "attachments.0.color"); got != "#d03050" {
+		t.Errorf("color = %v", got)
+	}
+	if got := path(t, req.Body, "attachments.0.blocks.0.text.text"); got != "节点离线" {
+		t.Errorf("header = %v", got)
+	}
+	if got := path(t, req.Body, "attachments.0.blocks.1.text.text"); got != "节点 hk-1 已离线" {
+		t.Errorf("section = %v", got)
+	}
+	if got := path(t, req.Body, "attachments.0.blocks.2.elements.0.text").(string); !strings.Contains(got, "严重") || !strings.Contains(got, "hk-1") {
+		t.Errorf("context = %v", got)
+	}
+}
+
+func TestSlackNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "no_service")
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier(&model.SlackConfig{WebhookURL: server.URL}).Send("t", "m")
+	if err == nil || !strings.Contains(err.Error(), "no_service") {
+		t.Fatalf("err = %v, want status error with body", err)
+	}
+}
+
+func TestDiscordNotifier(t *testing.T) {
+	server, req := newStandIn(t, "")
+	n := NewDiscordNotifier(&model.DiscordConfig{WebhookURL: server.URL + "/api/webhooks/1/abc", Username: "GOST Panel"})
+	if err := n.SendAlert(criticalAlert()); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	if got := path(t, req.Body, "username"); got != "GOST Panel" {
+		t.Errorf("username = %v", got)
+	}
+	if got := path(t, req.Body, "embeds.0.title"); got != "节点离线" {
+		t.Errorf("title = %v", got)
+	}
+	if got := path(t, req.Body, "embeds.0.description"); got != "节点 hk-1 已离线" {
+		t.Errorf("description = %v", got)
+	}
+	if got := path(t, req.Body, "embeds.0.color"); got != float64(0xd03050) {
+		t.Errorf("color = %v", got)
+	}
+	if got := path(t, req.Body, "embeds.0.timestamp"); got != "2026-01-02T03:04:05Z" {
+		t.Errorf("timestamp = %v", got)
+	}
+	if got := len(path(t, req.Body, "embeds.0.fields").([]interface{})); got != 3 {
+		t.Errorf("fields = %d, want 3", got)
+	}
+	if got := path(t, req.Body, "embeds.0.fields.2.value"); got != "离线告警" {
+		t.Errorf("rule field = %v", got)
+	}
+}
+
+func TestFeishuNotifierSigned(t *testing.T) {
+	server, req := newStandIn(t, `{"code":0,"msg":"success"}`)
+	n := NewFeishuNotifier(&model.FeishuConfig{WebhookURL: server.URL + "/open-apis/bot/v2/hook/x", Secret: "s3cret"})
+	if err := n.SendAlert(criticalAlert()); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	if got := path(t, req.Body, "msg_type"); got != "interactive" {
+		t.Errorf("msg_type = %v", got)
+	}
+	if got := path(t, req.Body, "card.header.title.content"); got != "节点离线" {
+		t.Errorf("card title = %v", got)
+	}
+	if got := path(t, req.Body, "card.header.template"); got != "red" {
+		t.Errorf("template = %v", got)
+	}
+
+	// 飞书签名: 以 "timestamp\nsecret" 为密钥对空串做 HmacSHA256 (时间戳为秒)
+	timestamp, _ := path(t, req.Body, "timestamp").(string)
+	assertRecentTimestamp(t, timestamp, time.Second)
+	mac := hmac.New(sha256.New, []byte(timestamp+"\ns3cret"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); path(t, req.Body, "sign") != want {
+		t.Errorf("sign = %v, want %s", path(t, req.Body, "sign"), want)
+	}
+}
+
+func TestFeishuNotifierUnsignedAndError(t *testing.T) {
+	server, req := newStandIn(t, `{"code":19021,"msg":"sign match fail"}`)
+	err := NewFeishuNotifier(&model.FeishuConfig{WebhookURL: server.URL}).Send("t", "m")
+	if err == nil || !strings.Contains(err.Error(), "19021") {
+		t.Fatalf("err = %v, want business error", err)
+	}
+	if _, ok := req.Body["sign"]; ok {
+		t.Error("unsigned request must not carry sign")
+	}
+	if _, ok := req.Body["timestamp"]; ok {
+		t.Error("unsigned request must not carry timestamp")
+	}
+}
+
+func TestDingTalkNotifierSigned(t *testing.T) {
+	server, req := newStandIn(t, `{"errcode":0,"errmsg":"ok"}`)
+	n := NewDingTalkNotifier(&model.DingTalkConfig{
+		WebhookURL: server.URL + "/robot/send?access_token=tok",
+		Secret:     "SECxyz",
+		AtMobiles:  "13800000000, 13900000000",
+	})
+	if err := n.SendAlert(criticalAlert()); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	if req.Query["access_token"] != "tok" {
+		t.Errorf("access_token = %q, existing query must be kept", req.Query["access_token"])
+	}
+	if got := path(t, req.Body, "msgtype"); got != "markdown" {
+		t.Errorf("msgtype = %v", got)
+	}
+	if got := path(t, req.Body, "markdown.title"); got != "节点离线" {
+		t.Errorf("title = %v", got)
+	}
+	text := path(t, req.Body, "markdown.text").(string)
+	if !strings.Contains(text, "@13800000000") || !strings.Contains(text, "@13900000000") {
+		t.Errorf("text must mention @ mobiles: %s", text)
+	}
+	if strings.Contains(text, "dedup") {
+		t.Errorf("text must not contain hidden markers: %s", text)
+	}
+	if got := path(t, req.Body, "at.atMobiles.1"); got != "13900000000" {
+		t.Errorf("atMobiles = %v", path(t, req.Body, "at.atMobiles"))
+	}
+
+	// 钉钉加签: 以 secret 为密钥对 "timestamp\nsecret" 做 HmacSHA256 (时间戳为毫秒)
+	timestamp := req.Query["timestamp"]
+	assertRecentTimestamp(t, timestamp, time.Millisecond)
+	mac := hmac.New(sha256.New, []byte("SECxyz"))
+	mac.Write([]byte(timestamp + "\nSECxyz"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); req.Query["sign"] != want {
+		t.Errorf("sign = %q, want %q", req.Query["sign"], want)
+	}
+}
+
+func TestDingTalkNotifierError(t *testing.T) {
+	server, req := newStandIn(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	err := NewDingTalkNotifier(&model.DingTalkConfig{WebhookURL: server.URL + "/robot/send"}).Send("t", "m")
+	if err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Fatalf("err = %v, want business error", err)
+	}
+	if _, ok := req.Query["sign"]; ok {
+		t.Error("unsigned request must not carry sign")
+	}
+}
+
+func TestWeComNotifier(t *testing.T) {
+	server, req := newStandIn(t, `{"errcode":0,"errmsg":"ok"}`)
+	alert := criticalAlert()
+	alert.Resolved = true
+	if err := NewWeComNotifier(&model.WeComConfig{WebhookURL: server.URL + "/cgi-bin/webhook/send?key=k"}).SendAlert(alert); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	if req.Query["key"] != "k" {
+		t.Errorf("key = %q", req.Query["key"])
+	}
+	if got := path(t, req.Body, "msgtype"); got != "markdown" {
+		t.Errorf("msgtype = %v", got)
+	}
+	content := path(t, req.Body, "markdown.content").(string)
+	if !strings.HasPrefix(content, `**<font color="info">节点离线</font>**`) {
+		t.Errorf("resolved alert must use info color: %s", content)
+	}
+	if !strings.Contains(content, "已恢复") {
+		t.Errorf("content must contain summary: %s", content)
+	}
+
+	server, _ = newStandIn(t, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	if err := NewWeComNotifier(&model.WeComConfig{WebhookURL: server.URL}).Send("t", "m"); err == nil || !strings.Contains(err.Error(), "93000") {
+		t.Fatalf("err = %v, want business error", err)
+	}
+}
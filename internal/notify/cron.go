@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule 标准 5 字段 cron 表达式 (分 时 日 月 周)
+// 支持 *、数字、范围 a-b、列表 a,b 与步长 */n、a-b/n；周日为 0 或 7
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // 位图
+	domStar, dowStar              bool
+}
+
+// cronFieldBounds 各字段取值范围
+var cronFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// parseCron 解析 cron 表达式
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式需要 5 个字段 (分 时 日 月 周): %q", expr)
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFieldBounds[i][0], cronFieldBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron 字段 %q: %v", field, err)
+		}
+		bits[i] = b
+	}
+
+	// 周日 7 等同于 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长")
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("无效的范围")
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("无效的数值")
+			}
+			lo, hi = n, n
+			if strings.Contains(part, "/") {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("超出范围 %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches 时间 (精确到分钟) 是否匹配表达式
+// 日与周均非 * 时按 cron 惯例取并集
+func (c *cronSchedule) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 ||
+		c.hour&(1<<uint(t.Hour())) == 0 ||
+		c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// ActiveWindow 返回覆盖时间 t 的最近一次窗口开始时间 (窗口时长 duration)
+func (c *cronSchedule) ActiveWindow(t time.Time, duration time.Duration) (time.Time, bool) {
+	start := t.Truncate(time.Minute)
+	for at := start; t.Sub(at) < duration; at = at.Add(-time.Minute) {
+		if c.Matches(at) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
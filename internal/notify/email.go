@@ -2,7 +2,9 @@ package notify
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"html"
 	"net/smtp"
 	"strings"
 
@@ -170,6 +172,57 @@ func (e *EmailSender) SendWelcomeEmail(to, username string) error {
 	return e.sendHTMLEmail(to, subject, htmlBody)
 }
 
+// SendReportEmail 发送报告邮件，bodyHTML 为已渲染的正文片段
+func (e *EmailSender) SendReportEmail(to, title, bodyHTML string) error {
+	subject := fmt.Sprintf("[%s] %s", e.SiteName, title)
+
+	htmlBody := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 720px; margin: 0 auto; padding: 20px; }
+        .header { background: #18a058; color: white; padding: 20px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { background: #f9f9f9; padding: 30px; border-radius: 0 0 8px 8px; }
+        .footer { text-align: center; color: #666; font-size: 12px; margin-top: 20px; }
+        table { width: 100%%; border-collapse: collapse; margin: 8px 0 20px; font-size: 14px; }
+        th, td { text-align: left; padding: 6px 8px; border-bottom: 1px solid #e8e8e8; }
+        th { background: #f0f0f0; }
+        .muted { color: #999; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>%s</h1>
+        </div>
+        <div class="content">
+%s
+        </div>
+        <div class="footer">
+            <p>此邮件由 %s 自动发送，请勿直接回复。</p>
+        </div>
+    </div>
+</body>
+</html>`, html.EscapeString(title), bodyHTML, html.EscapeString(e.SiteName))
+
+	var errs []string
+	for _, addr := range strings.Split(to, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if err := e.sendHTMLEmail(addr, subject, htmlBody); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", addr, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // sendHTMLEmail 发送 HTML 邮件
 func (e *EmailSender) sendHTMLEmail(to, subject, htmlBody string) error {
 	addr := fmt.Sprintf("%s:%d", e.Host, e.Port)
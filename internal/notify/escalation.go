@@ -0,0 +1,393 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+const (
+	maxEscalationTiers    = 10
+	maxEscalationDelayMin = 24 * 60
+)
+
+// ParseEscalationTiers 解析升级策略的层级配置
+func ParseEscalationTiers(raw string) ([]model.EscalationTier, error) {
+	var tiers []model.EscalationTier
+	if strings.TrimSpace(raw) == "" {
+		return tiers, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &tiers); err != nil {
+		return nil, fmt.Errorf("升级层级配置无效: %v", err)
+	}
+	return tiers, nil
+}
+
+// ValidateEscalationPolicy 校验升级策略
+func ValidateEscalationPolicy(policy *model.EscalationPolicy) error {
+	tiers, err := ParseEscalationTiers(policy.Tiers)
+	if err != nil {
+		return err
+	}
+	if len(tiers) == 0 {
+		return errors.New("升级策略至少需要一个层级")
+	}
+	if len(tiers) > maxEscalationTiers {
+		return fmt.Errorf("升级策略最多 %d 个层级", maxEscalationTiers)
+	}
+	for i, tier := range tiers {
+		if len(tier.ChannelIDs) == 0 && len(tier.ScheduleIDs) == 0 {
+			return fmt.Errorf("第 %d 层需要至少一个通知渠道或值班排班", i+1)
+		}
+		if i > 0 && (tier.DelayMin < 1 || tier.DelayMin > maxEscalationDelayMin) {
+			return fmt.Errorf("第 %d 层的等待时间需在 1-%d 分钟之间", i+1, maxEscalationDelayMin)
+		}
+	}
+	return nil
+}
+
+// ParseOnCallShifts 解析值班班次配置
+func ParseOnCallShifts(raw string) ([]model.OnCallShift, error) {
+	var shifts []model.OnCallShift
+	if strings.TrimSpace(raw) == "" {
+		return shifts, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &shifts); err != nil {
+		return nil, fmt.Errorf("值班班次配置无效: %v", err)
+	}
+	return shifts, nil
+}
+
+// parseClock 解析 HH:MM，返回一天中的分钟数
+func parseClock(s string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %s", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	// 24:00 表示当天结束
+	if err1 != nil || err2 != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %s", s)
+	}
+	return h*60 + m, nil
+}
+
+// scheduleLocation 排班使用的时区
+func scheduleLocation(schedule *model.OnCallSchedule) (*time.Location, error) {
+	if schedule.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(schedule.Timezone)
+}
+
+// ValidateOnCallSchedule 校验值班排班
+func ValidateOnCallSchedule(schedule *model.OnCallSchedule) error {
+	if _, err := scheduleLocation(schedule); err != nil {
+		return fmt.Errorf("时区无效: %s", schedule.Timezone)
+	}
+	shifts, err := ParseOnCallShifts(schedule.Shifts)
+	if err != nil {
+		return err
+	}
+	if len(shifts) == 0 && schedule.FallbackUserID == 0 {
+		return errors.New("排班至少需要一个班次或备用值班人")
+	}
+	for i, shift := range shifts {
+		if shift.Weekday < 0 || shift.Weekday > 6 {
+			return fmt.Errorf("第 %d 个班次的星期取值 0-6", i+1)
+		}
+		if shift.UserID == 0 {
+			return fmt.Errorf("第 %d 个班次未指定值班人", i+1)
+		}
+		start, err := parseClock(shift.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(shift.End)
+		if err != nil {
+			return err
+		}
+		if start == end || start >= 24*60 {
+			return fmt.Errorf("第 %d 个班次的时间范围无效", i+1)
+		}
+	}
+	return nil
+}
+
+// OnCallUser 返回排班在时间 t 的值班用户，无人值班时返回备用值班人 (可能为 0)
+func OnCallUser(schedule *model.OnCallSchedule, t time.Time) uint {
+	loc, err := scheduleLocation(schedule)
+	if err != nil {
+		return schedule.FallbackUserID
+	}
+	shifts, err := ParseOnCallShifts(schedule.Shifts)
+	if err != nil {
+		return schedule.FallbackUserID
+	}
+
+	local := t.In(loc)
+	weekday := int(local.Weekday())
+	minute := local.Hour()*60 + local.Minute()
+	for _, shift := range shifts {
+		start, err1 := parseClock(shift.Start)
+		end, err2 := parseClock(shift.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if end > start {
+			if weekday == shift.Weekday && minute >= start && minute < end {
+				return shift.UserID
+			}
+			continue
+		}
+		// 跨越午夜: 当天 start 之后或次日 end 之前
+		if (weekday == shift.Weekday && minute >= start) || (weekday == (shift.Weekday+1)%7 && minute < end) {
+			return shift.UserID
+		}
+	}
+	return schedule.FallbackUserID
+}
+
+// ruleChannels 规则当前应通知的渠道
+// 未配置升级策略时为规则的渠道列表；配置后为已通知到的各层级渠道 (至少第 1 层)
+func (a *AlertService) ruleChannels(rule *model.AlertRule, incident *model.AlertIncident, now time.Time) []model.NotifyChannel {
+	tiers := a.ruleTiers(rule)
+	if tiers == nil {
+		return a.channelsByIDs(parseChannelIDs(rule.ChannelIDs))
+	}
+
+	level := incident.EscalationLevel
+	if level < 1 {
+		level = 1
+	}
+	if level > len(tiers) {
+		level = len(tiers)
+	}
+
+	var channels []model.NotifyChannel
+	seen := make(map[uint]bool)
+	for i := 0; i < level; i++ {
+		for _, channel := range a.tierChannels(&tiers[i], now) {
+			if !seen[channel.ID] {
+				seen[channel.ID] = true
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return channels
+}
+
+// ruleTiers 规则启用的升级策略层级，未配置或策略不可用时返回 nil
+func (a *AlertService) ruleTiers(rule *model.AlertRule) []model.EscalationTier {
+	if rule.EscalationPolicyID == 0 {
+		return nil
+	}
+	var policy model.EscalationPolicy
+	if err := a.db.First(&policy, rule.EscalationPolicyID).Error; err != nil || !policy.Enabled {
+		return nil
+	}
+	tiers, err := ParseEscalationTiers(policy.Tiers)
+	if err != nil || len(tiers) == 0 {
+		return nil
+	}
+	return tiers
+}
+
+// tierChannels 层级的通知渠道: 指定渠道 + 各排班当前值班人的个人渠道
+func (a *AlertService) tierChannels(tier *model.EscalationTier, now time.Time) []model.NotifyChannel {
+	channels := a.channelsByIDs(tier.ChannelIDs)
+
+	for _, scheduleID := range tier.ScheduleIDs {
+		var schedule model.OnCallSchedule
+		if err := a.db.First(&schedule, scheduleID).Error; err != nil || !schedule.Enabled {
+			continue
+		}
+		userID := OnCallUser(&schedule, now)
+		if userID == 0 {
+			log.Printf("[Notify] On-call schedule %s has nobody on call", schedule.Name)
+			continue
+		}
+		var personal []model.NotifyChannel
+		a.db.Where("user_id = ? AND enabled = ?", userID, true).Order("id asc").Find(&personal)
+		if len(personal) == 0 {
+			log.Printf("[Notify] On-call user %d of schedule %s has no personal channel", userID, schedule.Name)
+		}
+		channels = append(channels, personal...)
+	}
+	return channels
+}
+
+// channelsByIDs 按 ID 获取已启用的通知渠道 (保持顺序)
+func (a *AlertService) channelsByIDs(ids []uint) []model.NotifyChannel {
+	var channels []model.NotifyChannel
+	for _, id := range ids {
+		var channel model.NotifyChannel
+		if err := a.db.First(&channel, id).Error; err != nil {
+			continue
+		}
+		if !channel.Enabled {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// parseChannelIDs 解析逗号分隔的渠道 ID
+func parseChannelIDs(raw string) []uint {
+	var ids []uint
+	for _, idStr := range strings.Split(raw, ",") {
+		idStr = strings.TrimSpace(idStr)
+		if idStr == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// EvaluateEscalations 升级超时未确认的告警事件 (由定时任务调用)
+func (a *AlertService) EvaluateEscalations() {
+	var incidents []model.AlertIncident
+	a.db.Where("status = ? AND escalation_level > 0", IncidentFiring).Find(&incidents)
+
+	now := time.Now()
+	for i := range incidents {
+		a.escalateIncident(&incidents[i], now)
+	}
+}
+
+// escalateIncident 当前层级等待超时后通知下一层
+func (a *AlertService) escalateIncident(incident *model.AlertIncident, now time.Time) {
+	if incidentSilenced(incident, now) || incident.EscalatedAt == nil {
+		return
+	}
+
+	var rule model.AlertRule
+	if err := a.db.First(&rule, incident.RuleID).Error; err != nil {
+		return
+	}
+	tiers := a.ruleTiers(&rule)
+	if incident.EscalationLevel >= len(tiers) {
+		return
+	}
+
+	next := tiers[incident.EscalationLevel]
+	if now.Sub(*incident.EscalatedAt) < time.Duration(next.DelayMin)*time.Minute {
+		return
+	}
+	if a.MatchSilence(incident.Type, incident.TargetType, incident.TargetID, now) != nil {
+		return
+	}
+
+	level := incident.EscalationLevel + 1
+	a.db.Model(incident).Updates(map[string]interface{}{
+		"escalation_level": level,
+		"escalated_at":     now,
+		"last_notified_at": now,
+	})
+	incident.EscalationLevel = level
+
+	channels := a.tierChannels(&next, now)
+	if len(channels) == 0 {
+		log.Printf("[Notify] Incident %d escalated to tier %d but no channel is available", incident.ID, level)
+		return
+	}
+
+	title := fmt.Sprintf("[升级 L%d][%s] %s", level, alertRuleTitle(&rule, incident.Type), incident.TargetName)
+	message := fmt.Sprintf("%s\n已持续 %s 未确认", incident.Message, now.Sub(incident.FirstFiredAt).Round(time.Minute))
+	alert := a.buildAlert(&rule, incident, incident.Type, title, message, nil)
+	for i := range channels {
+		a.enqueue(&channels[i], alert)
+	}
+}
+
+// ==================== 升级策略管理 ====================
+
+// ListEscalationPolicies 获取升级策略列表
+func (a *AlertService) ListEscalationPolicies() ([]model.EscalationPolicy, error) {
+	var policies []model.EscalationPolicy
+	err := a.db.Order("id asc").Find(&policies).Error
+	return policies, err
+}
+
+// GetEscalationPolicy 获取单个升级策略
+func (a *AlertService) GetEscalationPolicy(id uint) (*model.EscalationPolicy, error) {
+	var policy model.EscalationPolicy
+	err := a.db.First(&policy, id).Error
+	return &policy, err
+}
+
+// CreateEscalationPolicy 创建升级策略
+func (a *AlertService) CreateEscalationPolicy(policy *model.EscalationPolicy) error {
+	if err := ValidateEscalationPolicy(policy); err != nil {
+		return err
+	}
+	return a.db.Create(policy).Error
+}
+
+// UpdateEscalationPolicy 更新升级策略
+func (a *AlertService) UpdateEscalationPolicy(policy *model.EscalationPolicy) error {
+	if err := ValidateEscalationPolicy(policy); err != nil {
+		return err
+	}
+	return a.db.Save(policy).Error
+}
+
+// DeleteEscalationPolicy 删除升级策略 (引用该策略的规则恢复为直接通知渠道)
+func (a *AlertService) DeleteEscalationPolicy(id uint) error {
+	var count int64
+	a.db.Model(&model.AlertRule{}).Where("escalation_policy_id = ?", id).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("该升级策略被 %d 条告警规则引用，无法删除", count)
+	}
+	return a.db.Delete(&model.EscalationPolicy{}, id).Error
+}
+
+// ==================== 值班排班管理 ====================
+
+// ListOnCallSchedules 获取值班排班列表
+func (a *AlertService) ListOnCallSchedules() ([]model.OnCallSchedule, error) {
+	var schedules []model.OnCallSchedule
+	err := a.db.Order("id asc").Find(&schedules).Error
+	return schedules, err
+}
+
+// GetOnCallSchedule 获取单个值班排班
+func (a *AlertService) GetOnCallSchedule(id uint) (*model.OnCallSchedule, error) {
+	var schedule model.OnCallSchedule
+	err := a.db.First(&schedule, id).Error
+	return &schedule, err
+}
+
+// CreateOnCallSchedule 创建值班排班
+func (a *AlertService) CreateOnCallSchedule(schedule *model.OnCallSchedule) error {
+	if err := ValidateOnCallSchedule(schedule); err != nil {
+		return err
+	}
+	return a.db.Create(schedule).Error
+}
+
+// UpdateOnCallSchedule 更新值班排班
+func (a *AlertService) UpdateOnCallSchedule(schedule *model.OnCallSchedule) error {
+	if err := ValidateOnCallSchedule(schedule); err != nil {
+		return err
+	}
+	return a.db.Save(schedule).Error
+}
+
+// DeleteOnCallSchedule 删除值班排班
+func (a *AlertService) DeleteOnCallSchedule(id uint) error {
+	return a.db.Delete(&model.OnCallSchedule{}, id).Error
+}
@@ -0,0 +1,285 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// 告警事件状态
+const (
+	IncidentFiring       = "firing"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// openIncidentStatuses 未解决的事件状态
+var openIncidentStatuses = []string{IncidentFiring, IncidentAcknowledged}
+
+// TriggerAlert 触发告警
+// 每个 (规则, 目标) 维护一个未解决的事件: 重复触发只累计次数，冷却按目标计算，
+// 已确认或静默中的事件不再发送通知
+func (a *AlertService) TriggerAlert(alertType, targetType string, targetID uint, targetName, message string) {
+	// 查找匹配的告警规则
+	var rules []model.AlertRule
+	a.db.Where("type = ? AND enabled = ?", alertType, true).Find(&rules)
+
+	for i := range rules {
+		a.triggerRule(&rules[i], alertType, targetType, targetID, targetName, message, nil)
+	}
+}
+
+// triggerRule 按单条规则触发告警，values 为告警相关的数值 (供 Webhook 模板使用，可为 nil)
+func (a *AlertService) triggerRule(rule *model.AlertRule, alertType, targetType string, targetID uint, targetName, message string, values map[string]interface{}) {
+	now := time.Now()
+	incident, created := a.fireIncident(rule, alertType, targetType, targetID, targetName, message, now)
+	if incident == nil {
+		return
+	}
+
+	// 静默/维护窗口内: 仅记录日志，不发送通知
+	if silence := a.MatchSilence(alertType, targetType, targetID, now); silence != nil {
+		a.logSilenced(rule, incident, silence, alertType, message)
+		return
+	}
+
+	if !a.shouldNotify(rule, incident, created, now) {
+		return
+	}
+
+	title := fmt.Sprintf("[%s] %s", alertRuleTitle(rule, alertType), targetName)
+	a.notifyRule(rule, incident, alertType, title, message, values)
+
+	updates := map[string]interface{}{"last_notified_at": now}
+	// 升级策略: 首次通知为第 1 层，之后由 EvaluateEscalations 逐层升级
+	if rule.EscalationPolicyID != 0 && incident.EscalationLevel == 0 {
+		updates["escalation_level"] = 1
+		updates["escalated_at"] = now
+	}
+	a.db.Model(incident).Updates(updates)
+	// 更新规则的最后告警时间
+	a.db.Model(rule).Update("last_alert_at", now)
+}
+
+// ResolveAlert 解除目标上指定类型的告警事件，已通知过的事件发送恢复通知
+func (a *AlertService) ResolveAlert(alertType, targetType string, targetID uint, targetName, message string) {
+	var incidents []model.AlertIncident
+	a.db.Where("type = ? AND target_type = ? AND target_id = ? AND status IN ?",
+		alertType, targetType, targetID, openIncidentStatuses).Find(&incidents)
+
+	now := time.Now()
+	for i := range incidents {
+		incidents[i].TargetName = targetName
+		a.resolveIncident(&incidents[i], message, now)
+	}
+}
+
+// resolveIncident 将事件标记为已恢复，已通知过且未静默的事件发送恢复通知
+func (a *AlertService) resolveIncident(incident *model.AlertIncident, message string, now time.Time) {
+	a.db.Model(incident).Updates(map[string]interface{}{
+		"status":      IncidentResolved,
+		"resolved_at": now,
+	})
+
+	// 未通知过或静默中的事件不发送恢复通知
+	if incident.LastNotifiedAt == nil || incidentSilenced(incident, now) {
+		return
+	}
+
+	var rule model.AlertRule
+	if err := a.db.First(&rule, incident.RuleID).Error; err != nil {
+		return
+	}
+	title := fmt.Sprintf("[%s 已恢复] %s", alertRuleTitle(&rule, incident.Type), incident.TargetName)
+	body := fmt.Sprintf("%s\n持续时间: %s", message, now.Sub(incident.FirstFiredAt).Round(time.Second))
+	a.notifyRule(&rule, incident, incident.Type+"_resolved", title, body, nil)
+}
+
+// fireIncident 创建或更新 (规则, 目标) 的未解决事件，返回事件及是否为新建
+func (a *AlertService) fireIncident(rule *model.AlertRule, alertType, targetType string, targetID uint, targetName, message string, now time.Time) (*model.AlertIncident, bool) {
+	if incident, ok := a.refireIncident(rule.ID, targetType, targetID, targetName, message, now); ok {
+		return incident, false
+	}
+
+	incident := model.AlertIncident{
+		RuleID:       rule.ID,
+		RuleName:     rule.Name,
+		Type:         alertType,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetName:   targetName,
+		Status:       IncidentFiring,
+		Severity:     rule.Severity,
+		Message:      message,
+		FireCount:    1,
+		FirstFiredAt: now,
+		LastFiredAt:  now,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := a.db.Create(&incident).Error; err != nil {
+		// 并发评估已创建了该目标的未解决事件 (唯一索引 idx_alert_incidents_open 拒绝重复)，改为更新该事件
+		if existing, ok := a.refireIncident(rule.ID, targetType, targetID, targetName, message, now); ok {
+			return existing, false
+		}
+		log.Printf("Create alert incident failed: %v", err)
+		return nil, false
+	}
+	return &incident, true
+}
+
+// refireIncident 再次触发 (规则, 目标) 的未解决事件，没有未解决事件时返回 false
+func (a *AlertService) refireIncident(ruleID uint, targetType string, targetID uint, targetName, message string, now time.Time) (*model.AlertIncident, bool) {
+	var incident model.AlertIncident
+	err := a.db.Where("rule_id = ? AND target_type = ? AND target_id = ? AND status IN ?",
+		ruleID, targetType, targetID, openIncidentStatuses).First(&incident).Error
+	if err != nil {
+		return nil, false
+	}
+	a.db.Model(&incident).Updates(map[string]interface{}{
+		"fire_count":    gorm.Expr("fire_count + 1"),
+		"last_fired_at": now,
+		"message":       message,
+		"target_name":   targetName,
+	})
+	incident.FireCount++
+	incident.LastFiredAt = now
+	incident.Message, incident.TargetName = message, targetName
+	return &incident, true
+}
+
+// shouldNotify 事件是否需要发送通知 (按目标冷却，确认/静默后不再通知)
+func (a *AlertService) shouldNotify(rule *model.AlertRule, incident *model.AlertIncident, created bool, now time.Time) bool {
+	if incident.Status == IncidentAcknowledged || incidentSilenced(incident, now) {
+		return false
+	}
+
+	cooldown := time.Duration(rule.CooldownMin) * time.Minute
+	if !created {
+		return incident.LastNotifiedAt == nil || now.Sub(*incident.LastNotifiedAt) >= cooldown
+	}
+
+	// 新事件: 同一目标最近已通知过 (抖动) 时同样遵守冷却
+	var last model.AlertIncident
+	err := a.db.Where("rule_id = ? AND target_type = ? AND target_id = ? AND id <> ? AND last_notified_at IS NOT NULL",
+		rule.ID, incident.TargetType, incident.TargetID, incident.ID).
+		Order("last_notified_at desc").First(&last).Error
+	if err == nil && now.Sub(*last.LastNotifiedAt) < cooldown {
+		return false
+	}
+	return true
+}
+
+func incidentSilenced(incident *model.AlertIncident, now time.Time) bool {
+	return incident.SilencedUntil != nil && incident.SilencedUntil.After(now)
+}
+
+// notifyRule 将通知加入规则当前应通知的各渠道的投递队列 (由投递协程异步发送)
+func (a *AlertService) notifyRule(rule *model.AlertRule, incident *model.AlertIncident, alertType, title, message string, values map[string]interface{}) {
+	alert := a.buildAlert(rule, incident, alertType, title, message, values)
+	channels := a.ruleChannels(rule, incident, alert.Time)
+	for i := range channels {
+		a.enqueue(&channels[i], alert)
+	}
+}
+
+// buildAlert 构造事件的结构化告警，触发中的事件附带确认链接
+func (a *AlertService) buildAlert(rule *model.AlertRule, incident *model.AlertIncident, alertType, title, message string, values map[string]interface{}) *Alert {
+	alert := &Alert{
+		Title:      title,
+		Message:    message,
+		Type:       alertType,
+		Severity:   rule.Severity,
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		TargetType: incident.TargetType,
+		TargetID:   incident.TargetID,
+		TargetName: incident.TargetName,
+		IncidentID: incident.ID,
+		NodeHost:   a.targetNodeHost(incident.TargetType, incident.TargetID),
+		Values:     values,
+		Resolved:   strings.HasSuffix(alertType, "_resolved"),
+		FiredAt:    incident.FirstFiredAt,
+		Time:       time.Now(),
+	}
+	if !alert.Resolved {
+		alert.AckURL = a.AckLink(incident.ID)
+	}
+	return alert
+}
+
+// ==================== 告警事件管理 ====================
+
+// ListIncidents 获取告警事件列表，status 为 open 时返回所有未解决事件
+func (a *AlertService) ListIncidents(status string, limit, offset int) ([]model.AlertIncident, int64, error) {
+	var incidents []model.AlertIncident
+	var total int64
+
+	query := a.db.Model(&model.AlertIncident{})
+	switch status {
+	case "":
+	case "open":
+		query = query.Where("status IN ?", openIncidentStatuses)
+	default:
+		query = query.Where("status = ?", status)
+	}
+
+	query.Count(&total)
+	err := query.Order("last_fired_at desc").Limit(limit).Offset(offset).Find(&incidents).Error
+	return incidents, total, err
+}
+
+// GetIncident 获取单个告警事件
+func (a *AlertService) GetIncident(id uint) (*model.AlertIncident, error) {
+	var incident model.AlertIncident
+	err := a.db.First(&incident, id).Error
+	return &incident, err
+}
+
+// AckIncident 确认告警事件 (确认后不再重复通知，恢复时仍发送恢复通知)
+func (a *AlertService) AckIncident(id uint, ackedBy string) error {
+	incident, err := a.GetIncident(id)
+	if err != nil {
+		return err
+	}
+	if incident.Status != IncidentFiring {
+		return errors.New("只能确认触发中的告警事件")
+	}
+	return a.db.Model(incident).Updates(map[string]interface{}{
+		"status":   IncidentAcknowledged,
+		"acked_by": ackedBy,
+		"acked_at": time.Now(),
+	}).Error
+}
+
+// SilenceIncident 静默告警事件一段时间 (静默期间不发送任何通知)
+func (a *AlertService) SilenceIncident(id uint, duration time.Duration) error {
+	incident, err := a.GetIncident(id)
+	if err != nil {
+		return err
+	}
+	if incident.Status == IncidentResolved {
+		return errors.New("告警事件已恢复")
+	}
+	return a.db.Model(incident).Update("silenced_until", time.Now().Add(duration)).Error
+}
+
+// ResolveIncident 手动关闭告警事件 (不发送恢复通知)
+func (a *AlertService) ResolveIncident(id uint) error {
+	incident, err := a.GetIncident(id)
+	if err != nil {
+		return err
+	}
+	if incident.Status == IncidentResolved {
+		return nil
+	}
+	return a.db.Model(incident).Updates(map[string]interface{}{
+		"status":      IncidentResolved,
+		"resolved_at": time.Now(),
+	}).Error
+}
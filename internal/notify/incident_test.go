@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+func TestOneOpenIncidentPerRuleTarget(t *testing.T) {
+	db := newTestDB(t)
+	a := NewAlertService(db)
+	rule := model.AlertRule{Name: "offline", Type: "node_offline", Enabled: true, Severity: SeverityCritical}
+	db.Create(&rule)
+	now := time.Now()
+
+	first, created := a.fireIncident(&rule, "node_offline", "node", 7, "n7", "down", now)
+	if first == nil || !created {
+		t.Fatalf("first fire: incident=%v created=%v", first, created)
+	}
+
+	// 并发评估绕过查找直接插入时，唯一索引拒绝第二个未解决事件
+	dup := model.AlertIncident{RuleID: rule.ID, TargetType: "node", TargetID: 7, Status: IncidentFiring, FirstFiredAt: now, LastFiredAt: now}
+	if err := db.Create(&dup).Error; err == nil {
+		t.Fatal("duplicate open incident was inserted")
+	}
+
+	again, created := a.fireIncident(&rule, "node_offline", "node", 7, "n7", "still down", now.Add(time.Minute))
+	if again == nil || created || again.ID != first.ID {
+		t.Fatalf("second fire: incident=%v created=%v, want existing incident %d", again, created, first.ID)
+	}
+	var stored model.AlertIncident
+	db.First(&stored, first.ID)
+	if stored.FireCount != 2 || stored.Message != "still down" {
+		t.Fatalf("stored incident fire_count=%d message=%q", stored.FireCount, stored.Message)
+	}
+
+	// 解决后可以为同一目标创建新的事件
+	a.resolveIncident(&stored, "recovered", now.Add(2*time.Minute))
+	next, created := a.fireIncident(&rule, "node_offline", "node", 7, "n7", "down again", now.Add(3*time.Minute))
+	if next == nil || !created || next.ID == first.ID {
+		t.Fatalf("fire after resolve: incident=%v created=%v", next, created)
+	}
+}
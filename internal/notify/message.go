@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Alert 结构化的告警消息，供支持富文本格式的通知器使用
+type Alert struct {
+	Title      string                 `json:"title"`
+	Message    string                 `json:"message"`
+	Type       string                 `json:"type"`     // 告警类型，恢复通知带 _resolved 后缀
+	Severity   string                 `json:"severity"` // info/warning/critical
+	RuleID     uint                   `json:"rule_id"`
+	RuleName   string                 `json:"rule_name"`
+	TargetType string                 `json:"target_type"`
+	TargetID   uint                   `json:"target_id"`
+	TargetName string                 `json:"target_name"`
+	IncidentID uint                   `json:"incident_id"`
+	NodeHost   string                 `json:"node_host"` // 目标所在节点地址
+	Values     map[string]interface{} `json:"values"`    // 告警相关数值 (指标值、阈值、用量等)
+	Resolved   bool                   `json:"resolved"`
+	FiredAt    time.Time              `json:"fired_at"` // 事件首次触发时间
+	Time       time.Time              `json:"time"`     // 本次通知时间
+	AckURL     string                 `json:"ack_url"`  // 签名确认链接 (未配置站点地址或恢复通知时为空)
+}
+
+// AlertNotifier 支持结构化告警的通知器
+type AlertNotifier interface {
+	Notifier
+	SendAlert(alert *Alert) error
+}
+
+// sendAlert 优先按结构化告警发送，不支持时退回标题+正文
+func sendAlert(notifier Notifier, alert *Alert) error {
+	if n, ok := notifier.(AlertNotifier); ok {
+		return n.SendAlert(alert)
+	}
+	return notifier.Send(alert.Title, alert.Message)
+}
+
+// withAckLink 在正文末尾附上确认链接
+func withAckLink(alert *Alert) {
+	if alert.AckURL != "" {
+		alert.Message += "\n\n确认告警: " + alert.AckURL
+	}
+}
+
+// plainAlert 仅有标题和正文时构造的告警 (测试通知等)
+func plainAlert(title, message string) *Alert {
+	return &Alert{
+		Title:    title,
+		Message:  message,
+		Severity: SeverityInfo,
+		Time:     time.Now(),
+	}
+}
+
+// testAlert 测试通知
+func testAlert() *Alert {
+	alert := plainAlert("测试通知", "这是一条来自 GOST Panel 的测试通知消息。\n如果您收到此消息，说明通知渠道配置正确。")
+	alert.Type = "test"
+	return alert
+}
+
+// severityText 严重级别的中文名称
+func severityText(alert *Alert) string {
+	if alert.Resolved {
+		return "已恢复"
+	}
+	switch alert.Severity {
+	case SeverityCritical:
+		return "严重"
+	case SeverityInfo:
+		return "提示"
+	default:
+		return "警告"
+	}
+}
+
+// severityColor 严重级别对应的颜色 (RGB)
+func severityColor(alert *Alert) int {
+	if alert.Resolved {
+		return 0x18a058
+	}
+	switch alert.Severity {
+	case SeverityCritical:
+		return 0xd03050
+	case SeverityInfo:
+		return 0x2080f0
+	default:
+		return 0xf0a020
+	}
+}
+
+// alertSummary 告警的目标与时间摘要
+func alertSummary(alert *Alert) string {
+	parts := []string{severityText(alert)}
+	if alert.TargetName != "" {
+		parts = append(parts, fmt.Sprintf("%s: %s", targetTypeToName(alert.TargetType), alert.TargetName))
+	}
+	parts = append(parts, alert.Time.Format("2006-01-02 15:04:05"))
+	return strings.Join(parts, " | ")
+}
+
+// notifyHTTPClient 通知请求使用的 HTTP 客户端
+var notifyHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON 发送 JSON 请求，非 2xx 状态返回错误，返回响应体
+func postJSON(name, url string, payload interface{}, headers map[string]string) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s error (status %d): %s", name, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// checkErrCode 检查钉钉/企业微信/飞书响应中的业务错误码
+func checkErrCode(name string, body []byte) error {
+	var result struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil
+	}
+	if result.ErrCode != nil && *result.ErrCode != 0 {
+		return fmt.Errorf("%s error (%d): %s", name, *result.ErrCode, result.ErrMsg)
+	}
+	if result.Code != nil && *result.Code != 0 {
+		return fmt.Errorf("%s error (%d): %s", name, *result.Code, result.Msg)
+	}
+	return nil
+}
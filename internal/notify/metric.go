@@ -0,0 +1,747 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// AlertTypeMetric 指标告警规则类型
+const AlertTypeMetric = "metric"
+
+// 告警指标
+const (
+	MetricTrafficRate  = "traffic_rate"  // 节点流量速率 (bytes/s)，按统计窗口计算
+	MetricConnections  = "connections"   // 节点当前连接数
+	MetricLatency      = "latency"       // 节点健康检查平均延迟 (ms)
+	MetricFailureRatio = "failure_ratio" // 节点健康检查失败率 (%)
+	MetricQuotaPercent = "quota_percent" // 流量配额使用率 (%)
+	MetricPlanExpiry   = "plan_expiry"   // 用户套餐剩余天数
+	MetricProbeLatency = "probe_latency" // 隧道/代理链路径探测平均延迟 (ms)
+	MetricProbeLoss    = "probe_loss"    // 隧道/代理链路径探测丢失率 (%)
+)
+
+// 告警严重级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// 默认统计窗口（分钟）
+const defaultMetricWindow = 5
+
+// metricTargets 各指标支持的目标类型，第一个为默认值
+var metricTargets = map[string][]string{
+	MetricTrafficRate:  {"node"},
+	MetricConnections:  {"node"},
+	MetricLatency:      {"node"},
+	MetricFailureRatio: {"node"},
+	MetricQuotaPercent: {"node", "client", "user"},
+	MetricPlanExpiry:   {"user"},
+	MetricProbeLatency: {"tunnel", "chain"},
+	MetricProbeLoss:    {"tunnel", "chain"},
+}
+
+// MetricSample 指标采样结果
+type MetricSample struct {
+	TargetType string  `json:"target_type"`
+	TargetID   uint    `json:"target_id"`
+	TargetName string  `json:"target_name"`
+	Value      float64 `json:"value"`
+}
+
+// MetricPreview 规则预览结果 (当前满足条件的目标)
+type MetricPreview struct {
+	MetricSample
+	Display      string     `json:"display"`                 // 格式化后的指标值
+	PendingSince *time.Time `json:"pending_since,omitempty"` // 条件开始满足的时间 (仅已保存的规则)
+	WouldFire    bool       `json:"would_fire"`              // 是否已满足持续时间，下次评估将触发
+}
+
+// metricStateKey 指标规则的 (规则, 目标) 状态键
+type metricStateKey struct {
+	RuleID     uint
+	TargetType string
+	TargetID   uint
+}
+
+// defaultMetricOperator 指标的默认比较方式: 套餐剩余天数越小越危险，其余越大越危险
+func defaultMetricOperator(metric string) string {
+	if metric == MetricPlanExpiry {
+		return "<="
+	}
+	return ">="
+}
+
+// normalizeMetricCondition 补全指标条件的默认值
+func normalizeMetricCondition(cond *AlertRuleCondition) {
+	if cond.Operator == "" {
+		cond.Operator = defaultMetricOperator(cond.Metric)
+	}
+	if cond.Window <= 0 {
+		cond.Window = defaultMetricWindow
+	}
+	if cond.Target == "" {
+		if targets := metricTargets[cond.Metric]; len(targets) > 0 {
+			cond.Target = targets[0]
+		}
+	}
+}
+
+// ValidateRule 校验告警规则的严重级别与指标条件
+func ValidateRule(rule *model.AlertRule) error {
+	switch rule.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("无效的严重级别: %s", rule.Severity)
+	}
+	if rule.Type != AlertTypeMetric {
+		return nil
+	}
+
+	cond, err := ParseCondition(rule.Condition)
+	if err != nil {
+		return fmt.Errorf("条件格式错误: %v", err)
+	}
+	targets, ok := metricTargets[cond.Metric]
+	if !ok {
+		return fmt.Errorf("不支持的指标: %s", cond.Metric)
+	}
+	normalizeMetricCondition(cond)
+
+	switch cond.Operator {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("不支持的比较方式: %s", cond.Operator)
+	}
+	if cond.Duration < 0 {
+		return errors.New("持续时间不能为负数")
+	}
+	for _, t := range targets {
+		if t == cond.Target {
+			return nil
+		}
+	}
+	return fmt.Errorf("指标 %s 不支持目标类型 %s", cond.Metric, cond.Target)
+}
+
+// compareMetric 按比较方式判断指标值是否满足条件
+func compareMetric(value float64, operator string, threshold int64) bool {
+	t := float64(threshold)
+	switch operator {
+	case ">":
+		return value > t
+	case ">=":
+		return value >= t
+	case "<":
+		return value < t
+	case "<=":
+		return value <= t
+	}
+	return false
+}
+
+// ==================== 指标规则评估 ====================
+
+// EvaluateMetricRules 评估所有启用的指标规则 (定时调用)
+// 条件持续满足 duration 分钟后触发告警，条件不再满足或目标移出范围时恢复
+func (a *AlertService) EvaluateMetricRules() {
+	var rules []model.AlertRule
+	a.db.Where("type = ? AND enabled = ?", AlertTypeMetric, true).Find(&rules)
+
+	now := time.Now()
+	active := make(map[uint]bool, len(rules))
+	for i := range rules {
+		active[rules[i].ID] = true
+		if err := a.evaluateMetricRule(&rules[i], now); err != nil {
+			log.Printf("[Alert] Evaluate rule %d (%s) failed: %v", rules[i].ID, rules[i].Name, err)
+		}
+	}
+
+	// 清理已删除或已禁用规则的状态
+	var stale []metricStateKey
+	a.mu.Lock()
+	for key := range a.pending {
+		if !active[key.RuleID] {
+			stale = append(stale, key)
+		}
+	}
+	a.mu.Unlock()
+	a.clearPending(stale)
+}
+
+// evaluateMetricRule 评估单条指标规则
+func (a *AlertService) evaluateMetricRule(rule *model.AlertRule, now time.Time) error {
+	cond, err := ParseCondition(rule.Condition)
+	if err != nil {
+		return err
+	}
+	normalizeMetricCondition(cond)
+
+	samples, err := a.collectMetric(cond, now)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(cond.Duration) * time.Minute
+	matched := make(map[metricStateKey]bool)
+	for _, sample := range samples {
+		key := metricStateKey{RuleID: rule.ID, TargetType: sample.TargetType, TargetID: sample.TargetID}
+		if !compareMetric(sample.Value, cond.Operator, cond.Threshold) {
+			continue
+		}
+		matched[key] = true
+
+		since := a.markPending(key, now)
+		if now.Sub(since) < duration {
+			continue
+		}
+		a.triggerRule(rule, AlertTypeMetric, sample.TargetType, sample.TargetID, sample.TargetName,
+			metricMessage(cond, sample, since, now), map[string]interface{}{
+				"metric":    cond.Metric,
+				"value":     sample.Value,
+				"operator":  cond.Operator,
+				"threshold": cond.Threshold,
+				"since":     since,
+			})
+	}
+
+	// 清理不再满足条件的目标状态，并恢复对应的告警事件
+	var cleared []metricStateKey
+	a.mu.Lock()
+	for key := range a.pending {
+		if key.RuleID == rule.ID && !matched[key] {
+			cleared = append(cleared, key)
+		}
+	}
+	a.mu.Unlock()
+	a.clearPending(cleared)
+
+	var incidents []model.AlertIncident
+	a.db.Where("rule_id = ? AND status IN ?", rule.ID, openIncidentStatuses).Find(&incidents)
+	for i := range incidents {
+		incident := &incidents[i]
+		key := metricStateKey{RuleID: rule.ID, TargetType: incident.TargetType, TargetID: incident.TargetID}
+		if matched[key] {
+			continue
+		}
+		a.resolveIncident(incident, fmt.Sprintf("%s 已恢复正常", metricName(cond.Metric)), now)
+	}
+	return nil
+}
+
+// markPending 记录条件开始满足的时间，返回该时间
+// 开始时间同时写入数据库，面板重启后继续计算持续时间，不会推迟告警
+func (a *AlertService) markPending(key metricStateKey, now time.Time) time.Time {
+	a.mu.Lock()
+	if since, ok := a.pending[key]; ok {
+		a.mu.Unlock()
+		return since
+	}
+	a.pending[key] = now
+	a.mu.Unlock()
+
+	state := model.AlertPendingState{RuleID: key.RuleID, TargetType: key.TargetType, TargetID: key.TargetID, Since: now}
+	if err := a.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error; err != nil {
+		log.Printf("[Alert] Save pending state of rule %d (%s %d) failed: %v", key.RuleID, key.TargetType, key.TargetID, err)
+	}
+	return now
+}
+
+// clearPending 清除条件已不再满足的目标状态
+func (a *AlertService) clearPending(keys []metricStateKey) {
+	if len(keys) == 0 {
+		return
+	}
+	a.mu.Lock()
+	for _, key := range keys {
+		delete(a.pending, key)
+	}
+	a.mu.Unlock()
+
+	for _, key := range keys {
+		a.db.Where("rule_id = ? AND target_type = ? AND target_id = ?", key.RuleID, key.TargetType, key.TargetID).
+			Delete(&model.AlertPendingState{})
+	}
+}
+
+// loadPending 从数据库恢复指标规则条件开始满足的时间 (启动时调用)
+func (a *AlertService) loadPending() {
+	var states []model.AlertPendingState
+	if err := a.db.Find(&states).Error; err != nil {
+		log.Printf("[Alert] Load pending metric states failed: %v", err)
+		return
+	}
+	for _, state := range states {
+		a.pending[metricStateKey{RuleID: state.RuleID, TargetType: state.TargetType, TargetID: state.TargetID}] = state.Since
+	}
+}
+
+// PreviewRule 预览指标规则当前满足条件的目标 (不触发告警)
+func (a *AlertService) PreviewRule(rule *model.AlertRule) ([]MetricPreview, error) {
+	if rule.Type != AlertTypeMetric {
+		return nil, errors.New("仅支持预览指标规则")
+	}
+	if err := ValidateRule(rule); err != nil {
+		return nil, err
+	}
+
+	cond, _ := ParseCondition(rule.Condition)
+	normalizeMetricCondition(cond)
+
+	now := time.Now()
+	samples, err := a.collectMetric(cond, now)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(cond.Duration) * time.Minute
+	result := make([]MetricPreview, 0)
+	for _, sample := range samples {
+		if !compareMetric(sample.Value, cond.Operator, cond.Threshold) {
+			continue
+		}
+		preview := MetricPreview{
+			MetricSample: sample,
+			Display:      formatMetricValue(cond.Metric, sample.Value),
+			WouldFire:    duration == 0,
+		}
+		if rule.ID > 0 {
+			a.mu.Lock()
+			since, ok := a.pending[metricStateKey{RuleID: rule.ID, TargetType: sample.TargetType, TargetID: sample.TargetID}]
+			a.mu.Unlock()
+			if ok {
+				preview.PendingSince = &since
+				preview.WouldFire = now.Sub(since) >= duration
+			}
+		}
+		result = append(result, preview)
+	}
+	return result, nil
+}
+
+// ==================== 指标采集 ====================
+
+// collectMetric 按条件采集所有目标的指标值
+func (a *AlertService) collectMetric(cond *AlertRuleCondition, now time.Time) ([]MetricSample, error) {
+	switch cond.Metric {
+	case MetricPlanExpiry:
+		return a.collectPlanExpiry(now)
+	case MetricProbeLatency, MetricProbeLoss:
+		return a.collectProbe(cond, now)
+	case MetricQuotaPercent:
+		switch cond.Target {
+		case "user":
+			return a.collectUserQuota()
+		case "client":
+			return a.collectClientQuota(cond)
+		}
+	}
+
+	nodes, err := a.scopedNodes(cond)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+
+	switch cond.Metric {
+	case MetricTrafficRate:
+		return a.collectTrafficRate(nodes, cond.Window, now)
+	case MetricLatency, MetricFailureRatio:
+		return a.collectHealth(nodes, cond.Metric, cond.Window, now)
+	}
+
+	samples := make([]MetricSample, 0, len(nodes))
+	for _, node := range nodes {
+		sample := MetricSample{TargetType: "node", TargetID: node.ID, TargetName: node.Name}
+		switch cond.Metric {
+		case MetricConnections:
+			if node.Status != "online" {
+				continue
+			}
+			sample.Value = float64(node.Connections)
+		case MetricQuotaPercent:
+			if node.TrafficQuota <= 0 {
+				continue
+			}
+			sample.Value = float64(node.QuotaUsed) / float64(node.TrafficQuota) * 100
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// scopedNodes 获取规则范围内的节点 (未指定标签和节点组时为全部节点)
+func (a *AlertService) scopedNodes(cond *AlertRuleCondition) ([]model.Node, error) {
+	query := a.db.Model(&model.Node{})
+	if len(cond.TagIDs) > 0 || len(cond.GroupIDs) > 0 {
+		var ids []uint
+		if len(cond.TagIDs) > 0 {
+			var tagged []uint
+			a.db.Model(&model.NodeTag{}).Where("tag_id IN ?", cond.TagIDs).Pluck("node_id", &tagged)
+			ids = append(ids, tagged...)
+		}
+		if len(cond.GroupIDs) > 0 {
+			var members []uint
+			a.db.Model(&model.NodeGroupMember{}).Where("group_id IN ?", cond.GroupIDs).Pluck("node_id", &members)
+			ids = append(ids, members...)
+		}
+		if len(ids) == 0 {
+			return nil, nil
+		}
+		query = query.Where("id IN ?", ids)
+	}
+
+	var nodes []model.Node
+	err := query.Find(&nodes).Error
+	return nodes, err
+}
+
+// collectTrafficRate 按流量历史计算窗口内的平均流量速率
+func (a *AlertService) collectTrafficRate(nodes []model.Node, window int, now time.Time) ([]MetricSample, error) {
+	ids := make([]uint, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+
+	var history []model.TrafficHistory
+	err := a.db.Where("node_id IN ? AND recorded_at >= ?", ids, now.Add(-time.Duration(window)*time.Minute)).
+		Order("recorded_at asc").Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+
+	first := make(map[uint]model.TrafficHistory)
+	last := make(map[uint]model.TrafficHistory)
+	for _, h := range history {
+		if _, ok := first[*h.NodeID]; !ok {
+			first[*h.NodeID] = h
+		}
+		last[*h.NodeID] = h
+	}
+
+	samples := make([]MetricSample, 0, len(nodes))
+	for _, node := range nodes {
+		f, l := first[node.ID], last[node.ID]
+		seconds := l.RecordedAt.Sub(f.RecordedAt).Seconds()
+		if seconds <= 0 {
+			continue // 数据点不足
+		}
+		delta := (l.TrafficIn + l.TrafficOut) - (f.TrafficIn + f.TrafficOut)
+		if delta < 0 {
+			delta = 0 // 计数器被重置
+		}
+		samples = append(samples, MetricSample{
+			TargetType: "node",
+			TargetID:   node.ID,
+			TargetName: node.Name,
+			Value:      float64(delta) / seconds,
+		})
+	}
+	return samples, nil
+}
+
+// collectHealth 按健康检查记录计算窗口内的平均延迟或失败率
+func (a *AlertService) collectHealth(nodes []model.Node, metric string, window int, now time.Time) ([]MetricSample, error) {
+	ids := make([]uint, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+
+	var rows []struct {
+		NodeID     uint
+		Total      int64
+		Failed     int64
+		AvgLatency float64
+	}
+	err := a.db.Model(&model.HealthCheckLog{}).
+		Select("node_id, COUNT(*) as total, "+
+			"SUM(CASE WHEN status = 'unhealthy' THEN 1 ELSE 0 END) as failed, "+
+			"COALESCE(AVG(CASE WHEN status = 'healthy' THEN latency END), 0) as avg_latency").
+		Where("node_id IN ? AND checked_at >= ?", ids, now.Add(-time.Duration(window)*time.Minute)).
+		Group("node_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string, len(nodes))
+	for _, node := range nodes {
+		names[node.ID] = node.Name
+	}
+
+	samples := make([]MetricSample, 0, len(rows))
+	for _, row := range rows {
+		if row.Total == 0 {
+			continue
+		}
+		sample := MetricSample{TargetType: "node", TargetID: row.NodeID, TargetName: names[row.NodeID]}
+		if metric == MetricLatency {
+			if row.Failed == row.Total {
+				continue // 全部失败时没有延迟数据，由失败率规则覆盖
+			}
+			sample.Value = row.AvgLatency
+		} else {
+			sample.Value = float64(row.Failed) / float64(row.Total) * 100
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// collectProbe 按路径探测记录计算窗口内隧道/代理链的平均延迟或丢失率
+// 隧道使用入口节点的端到端探测；代理链逐段 (跳点 -> 下一跳/目标) 计算，丢失率取最差一段，延迟为各段之和
+// 指定标签或节点组时只统计范围内节点发起的探测
+func (a *AlertService) collectProbe(cond *AlertRuleCondition, now time.Time) ([]MetricSample, error) {
+	resourceType := "tunnel"
+	if cond.Target == "chain" {
+		resourceType = "chain"
+	}
+	query := a.db.Model(&model.PathProbeResult{}).
+		Select("resource_id, node_id, scope, target, AVG(loss) as loss, "+
+			"COALESCE(AVG(CASE WHEN loss < 100 THEN latency END), 0) as latency").
+		Where("resource_type = ? AND checked_at >= ?", resourceType, now.Add(-time.Duration(cond.Window)*time.Minute))
+	if resourceType == "tunnel" {
+		query = query.Where("scope = ?", model.ProbeScopePath)
+	}
+	if len(cond.TagIDs) > 0 || len(cond.GroupIDs) > 0 {
+		nodes, err := a.scopedNodes(cond)
+		if err != nil || len(nodes) == 0 {
+			return nil, err
+		}
+		ids := make([]uint, len(nodes))
+		for i, node := range nodes {
+			ids[i] = node.ID
+		}
+		query = query.Where("node_id IN ?", ids)
+	}
+
+	var rows []struct {
+		ResourceID uint
+		NodeID     uint
+		Scope      string
+		Target     string
+		Loss       float64
+		Latency    float64
+	}
+	if err := query.Group("resource_id, node_id, scope, target").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	type aggregate struct {
+		loss, latency float64
+		failed        bool
+	}
+	values := make(map[uint]*aggregate)
+	var ids []uint
+	for _, row := range rows {
+		agg, ok := values[row.ResourceID]
+		if !ok {
+			agg = &aggregate{}
+			values[row.ResourceID] = agg
+			ids = append(ids, row.ResourceID)
+		}
+		if row.Loss > agg.loss {
+			agg.loss = row.Loss
+		}
+		if row.Loss >= 100 {
+			agg.failed = true
+		}
+		if resourceType == "chain" {
+			agg.latency += row.Latency
+		} else if row.Latency > agg.latency {
+			agg.latency = row.Latency
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	names := make(map[uint]string, len(ids))
+	if resourceType == "chain" {
+		var chains []model.ProxyChain
+		a.db.Select("id, name").Where("id IN ?", ids).Find(&chains)
+		for _, chain := range chains {
+			names[chain.ID] = chain.Name
+		}
+	} else {
+		var tunnels []model.Tunnel
+		a.db.Select("id, name").Where("id IN ?", ids).Find(&tunnels)
+		for _, tunnel := range tunnels {
+			names[tunnel.ID] = tunnel.Name
+		}
+	}
+
+	samples := make([]MetricSample, 0, len(ids))
+	for _, id := range ids {
+		name, ok := names[id]
+		if !ok {
+			continue // 已删除
+		}
+		agg := values[id]
+		sample := MetricSample{TargetType: resourceType, TargetID: id, TargetName: name}
+		if cond.Metric == MetricProbeLatency {
+			if agg.failed {
+				continue // 有一段完全不通时没有延迟数据，由丢失率规则覆盖
+			}
+			sample.Value = agg.latency
+		} else {
+			sample.Value = agg.loss
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// collectClientQuota 客户端流量配额使用率 (范围按客户端所在节点)
+func (a *AlertService) collectClientQuota(cond *AlertRuleCondition) ([]MetricSample, error) {
+	query := a.db.Where("traffic_quota > 0")
+	if len(cond.TagIDs) > 0 || len(cond.GroupIDs) > 0 {
+		nodes, err := a.scopedNodes(cond)
+		if err != nil || len(nodes) == 0 {
+			return nil, err
+		}
+		ids := make([]uint, len(nodes))
+		for i, node := range nodes {
+			ids[i] = node.ID
+		}
+		query = query.Where("node_id IN ?", ids)
+	}
+
+	var clients []model.Client
+	if err := query.Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	samples := make([]MetricSample, 0, len(clients))
+	for _, client := range clients {
+		samples = append(samples, MetricSample{
+			TargetType: "client",
+			TargetID:   client.ID,
+			TargetName: client.Name,
+			Value:      float64(client.QuotaUsed) / float64(client.TrafficQuota) * 100,
+		})
+	}
+	return samples, nil
+}
+
+// collectUserQuota 用户流量配额使用率
+func (a *AlertService) collectUserQuota() ([]MetricSample, error) {
+	var users []model.User
+	if err := a.db.Where("traffic_quota > 0").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	samples := make([]MetricSample, 0, len(users))
+	for _, user := range users {
+		samples = append(samples, MetricSample{
+			TargetType: "user",
+			TargetID:   user.ID,
+			TargetName: user.Username,
+			Value:      float64(user.QuotaUsed) / float64(user.TrafficQuota) * 100,
+		})
+	}
+	return samples, nil
+}
+
+// collectPlanExpiry 用户套餐剩余天数 (已过期为负数)
+func (a *AlertService) collectPlanExpiry(now time.Time) ([]MetricSample, error) {
+	var users []model.User
+	if err := a.db.Where("plan_id IS NOT NULL AND plan_expire_at IS NOT NULL").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	samples := make([]MetricSample, 0, len(users))
+	for _, user := range users {
+		samples = append(samples, MetricSample{
+			TargetType: "user",
+			TargetID:   user.ID,
+			TargetName: user.Username,
+			Value:      user.PlanExpireAt.Sub(now).Hours() / 24,
+		})
+	}
+	return samples, nil
+}
+
+// ==================== 格式化 ====================
+
+// metricName 指标的中文名称
+func metricName(metric string) string {
+	switch metric {
+	case MetricTrafficRate:
+		return "流量速率"
+	case MetricConnections:
+		return "连接数"
+	case MetricLatency:
+		return "健康检查延迟"
+	case MetricFailureRatio:
+		return "健康检查失败率"
+	case MetricQuotaPercent:
+		return "流量使用率"
+	case MetricPlanExpiry:
+		return "套餐剩余天数"
+	case MetricProbeLatency:
+		return "路径探测延迟"
+	case MetricProbeLoss:
+		return "路径探测丢失率"
+	default:
+		return metric
+	}
+}
+
+// formatMetricValue 按指标单位格式化数值
+func formatMetricValue(metric string, value float64) string {
+	switch metric {
+	case MetricTrafficRate:
+		return formatBytes(int64(value)) + "/s"
+	case MetricConnections:
+		return fmt.Sprintf("%.0f", value)
+	case MetricLatency, MetricProbeLatency:
+		return fmt.Sprintf("%.0f ms", value)
+	case MetricFailureRatio, MetricQuotaPercent, MetricProbeLoss:
+		return fmt.Sprintf("%.1f%%", value)
+	case MetricPlanExpiry:
+		return fmt.Sprintf("%.1f 天", value)
+	default:
+		return fmt.Sprintf("%.2f", value)
+	}
+}
+
+// metricMessage 指标告警消息
+func metricMessage(cond *AlertRuleCondition, sample MetricSample, since, now time.Time) string {
+	msg := fmt.Sprintf("%s %s %s: %s (条件 %s %s)",
+		targetTypeToName(sample.TargetType),
+		sample.TargetName,
+		metricName(cond.Metric),
+		formatMetricValue(cond.Metric, sample.Value),
+		cond.Operator,
+		formatMetricValue(cond.Metric, float64(cond.Threshold)))
+	if cond.Duration > 0 {
+		msg += fmt.Sprintf("\n已持续 %s", now.Sub(since).Round(time.Minute))
+	}
+	return msg
+}
+
+// severityLabel 严重级别标签 (warning 为默认级别，不显示)
+func severityLabel(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "严重"
+	case SeverityInfo:
+		return "提示"
+	default:
+		return ""
+	}
+}
+
+// alertRuleTitle 通知标题中的告警名称: 指标规则使用规则名称，并附带严重级别
+func alertRuleTitle(rule *model.AlertRule, alertType string) string {
+	name := alertTypeToTitle(alertType)
+	if alertType == AlertTypeMetric && rule.Name != "" {
+		name = rule.Name
+	}
+	if label := severityLabel(rule.Severity); label != "" {
+		return label + " " + name
+	}
+	return name
+}
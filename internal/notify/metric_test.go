@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := model.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return db
+}
+
+func TestMetricPendingSurvivesRestart(t *testing.T) {
+	db := newTestDB(t)
+	node := model.Node{Name: "n1", Host: "127.0.0.1", Port: 1080, AgentToken: "t1", Status: "online", Connections: 50}
+	db.Create(&node)
+	rule := model.AlertRule{Name: "conns", Type: AlertTypeMetric, Enabled: true,
+		Condition: `{"metric":"connections","threshold":10,"duration":10}`}
+	db.Create(&rule)
+	key := metricStateKey{RuleID: rule.ID, TargetType: "node", TargetID: node.ID}
+
+	a := NewAlertService(db)
+	a.EvaluateMetricRules()
+	since, ok := a.pending[key]
+	if !ok {
+		t.Fatal("condition met but no pending state recorded")
+	}
+
+	// 面板重启: 新的服务实例从数据库恢复开始时间，而不是重新计时
+	restarted := NewAlertService(db)
+	if got, ok := restarted.pending[key]; !ok || !got.Equal(since) {
+		t.Fatalf("pending after restart = %v (%v), want %v", got, ok, since)
+	}
+	restarted.EvaluateMetricRules()
+	if got := restarted.pending[key]; !got.Equal(since) {
+		t.Fatalf("pending since reset to %v after restart, want %v", got, since)
+	}
+
+	// 条件不再满足时清除持久化的状态
+	db.Model(&node).Update("connections", 0)
+	restarted.EvaluateMetricRules()
+	var count int64
+	db.Model(&model.AlertPendingState{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("pending rows after recovery = %d, want 0", count)
+	}
+	if len(NewAlertService(db).pending) != 0 {
+		t.Fatal("cleared pending state came back after restart")
+	}
+}
@@ -68,59 +68,6 @@ func escapeMarkdown(text string) string {
 	return text
 }
 
-// WebhookNotifier Webhook 通知
-type WebhookNotifier struct {
-	URL     string
-	Method  string
-	Headers map[string]string
-}
-
-func NewWebhookNotifier(config *model.WebhookConfig) *WebhookNotifier {
-	method := config.Method
-	if method == "" {
-		method = "POST"
-	}
-	return &WebhookNotifier{
-		URL:     config.URL,
-		Method:  method,
-		Headers: config.Headers,
-	}
-}
-
-func (w *WebhookNotifier) Send(title, message string) error {
-	payload := map[string]interface{}{
-		"title":     title,
-		"message":   message,
-		"timestamp": time.Now().Unix(),
-	}
-
-	body, _ := json.Marshal(payload)
-
-	req, err := http.NewRequest(w.Method, w.URL, bytes.NewBuffer(body))
-	if err != nil {
-		return fmt.Errorf("create request failed: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range w.Headers {
-		req.Header.Set(k, v)
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("webhook request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("webhook error (status %d): %s", resp.StatusCode, string(respBody))
-	}
-
-	return nil
-}
-
 // SMTPNotifier SMTP 邮件通知
 type SMTPNotifier struct {
 	Host     string
@@ -218,26 +165,82 @@ func CreateNotifier(channel *model.NotifyChannel) (Notifier, error) {
 	switch channel.Type {
 	case "telegram":
 		var config model.TelegramConfig
-		if err := json.Unmarshal([]byte(channel.Config), &config); err != nil {
-			return nil, fmt.Errorf("parse telegram config failed: %w", err)
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
 		}
 		return NewTelegramNotifier(&config), nil
 
 	case "webhook":
 		var config model.WebhookConfig
-		if err := json.Unmarshal([]byte(channel.Config), &config); err != nil {
-			return nil, fmt.Errorf("parse webhook config failed: %w", err)
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
 		}
 		return NewWebhookNotifier(&config), nil
 
-	case "smtp":
+	case "smtp", "email":
 		var config model.SMTPConfig
-		if err := json.Unmarshal([]byte(channel.Config), &config); err != nil {
-			return nil, fmt.Errorf("parse smtp config failed: %w", err)
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
 		}
 		return NewSMTPNotifier(&config), nil
 
+	case "slack":
+		var config model.SlackConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewSlackNotifier(&config), nil
+
+	case "discord":
+		var config model.DiscordConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewDiscordNotifier(&config), nil
+
+	case "feishu", "lark":
+		var config model.FeishuConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewFeishuNotifier(&config), nil
+
+	case "dingtalk":
+		var config model.DingTalkConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewDingTalkNotifier(&config), nil
+
+	case "wecom":
+		var config model.WeComConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewWeComNotifier(&config), nil
+
+	case "ntfy":
+		var config model.NtfyConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewNtfyNotifier(&config), nil
+
+	case "gotify":
+		var config model.GotifyConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		return NewGotifyNotifier(&config), nil
+
 	default:
 		return nil, fmt.Errorf("unknown channel type: %s", channel.Type)
 	}
 }
+
+func decodeChannelConfig(channel *model.NotifyChannel, config interface{}) error {
+	if err := json.Unmarshal([]byte(channel.Config), config); err != nil {
+		return fmt.Errorf("parse %s config failed: %w", channel.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== ntfy ====================
+
+// DefaultNtfyServer ntfy 公共服务地址
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// NtfyNotifier ntfy 推送通知
+type NtfyNotifier struct {
+	ServerURL string
+	Topic     string
+	Token     string
+	Username  string
+	Password  string
+	Priority  int
+}
+
+func NewNtfyNotifier(config *model.NtfyConfig) *NtfyNotifier {
+	server := strings.TrimRight(config.ServerURL, "/")
+	if server == "" {
+		server = DefaultNtfyServer
+	}
+	return &NtfyNotifier{
+		ServerURL: server,
+		Topic:     config.Topic,
+		Token:     config.Token,
+		Username:  config.Username,
+		Password:  config.Password,
+		Priority:  config.Priority,
+	}
+}
+
+func (n *NtfyNotifier) Send(title, message string) error {
+	return n.SendAlert(plainAlert(title, message))
+}
+
+func (n *NtfyNotifier) SendAlert(alert *Alert) error {
+	priority := n.Priority
+	if priority <= 0 {
+		priority = alertPriority(alert, 5)
+	}
+
+	tags := []string{"warning"}
+	if alert.Resolved {
+		tags = []string{"white_check_mark"}
+	} else if alert.Severity == SeverityCritical {
+		tags = []string{"rotating_light"}
+	} else if alert.Severity == SeverityInfo {
+		tags = []string{"information_source"}
+	}
+
+	// JSON 发布: POST 到服务根路径，topic 放在请求体中
+	payload := map[string]interface{}{
+		"topic":    n.Topic,
+		"title":    alert.Title,
+		"message":  plainMessage(alert.Message) + "\n\n" + alertSummary(alert),
+		"priority": priority,
+		"tags":     tags,
+	}
+
+	headers := map[string]string{}
+	if n.Token != "" {
+		headers["Authorization"] = "Bearer " + n.Token
+	} else if n.Username != "" {
+		headers["Authorization"] = basicAuth(n.Username, n.Password)
+	}
+
+	_, err := postJSON("ntfy", n.ServerURL, payload, headers)
+	return err
+}
+
+// ==================== Gotify ====================
+
+// GotifyNotifier Gotify 推送通知
+type GotifyNotifier struct {
+	ServerURL string
+	Token     string
+	Priority  int
+}
+
+func NewGotifyNotifier(config *model.GotifyConfig) *GotifyNotifier {
+	return &GotifyNotifier{
+		ServerURL: strings.TrimRight(config.ServerURL, "/"),
+		Token:     config.Token,
+		Priority:  config.Priority,
+	}
+}
+
+func (g *GotifyNotifier) Send(title, message string) error {
+	return g.SendAlert(plainAlert(title, message))
+}
+
+func (g *GotifyNotifier) SendAlert(alert *Alert) error {
+	priority := g.Priority
+	if priority <= 0 {
+		priority = alertPriority(alert, 10)
+	}
+
+	payload := map[string]interface{}{
+		"title":    alert.Title,
+		"message":  markdownLines(alert.Message) + "\n\n_" + alertSummary(alert) + "_",
+		"priority": priority,
+		"extras": map[string]interface{}{
+			"client::display": map[string]interface{}{"contentType": "text/markdown"},
+		},
+	}
+
+	_, err := postJSON("gotify", g.ServerURL+"/message", payload, map[string]string{"X-Gotify-Key": g.Token})
+	return err
+}
+
+// alertPriority 按严重级别映射推送优先级 (max 为最高优先级)
+func alertPriority(alert *Alert, max int) int {
+	switch {
+	case alert.Resolved, alert.Severity == SeverityInfo:
+		return max * 2 / 5
+	case alert.Severity == SeverityCritical:
+		return max
+	default:
+		return max * 4 / 5
+	}
+}
+
+// plainMessage 去掉消息中去重用的隐藏标识
+func plainMessage(message string) string {
+	lines := strings.Split(message, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "<!--") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// basicAuth HTTP Basic 认证头
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+func TestNtfyNotifierToken(t *testing.T) {
+	server, req := newStandIn(t, `{"id":"x"}`)
+	n := NewNtfyNotifier(&model.NtfyConfig{ServerURL: server.URL + "/", Topic: "gost-alerts", Token: "tk_abc"})
+	if err := n.SendAlert(criticalAlert()); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	// JSON 发布: POST 到服务根路径，topic 放在请求体中
+	if req.Path != "/" {
+		t.Errorf("path = %s, want /", req.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer tk_abc" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := path(t, req.Body, "topic"); got != "gost-alerts" {
+		t.Errorf("topic = %v", got)
+	}
+	if got := path(t, req.Body, "title"); got != "节点离线" {
+		t.Errorf("title = %v", got)
+	}
+	if got := path(t, req.Body, "priority"); got != float64(5) {
+		t.Errorf("priority = %v, want 5 for critical", got)
+	}
+	if got := path(t, req.Body, "tags.0"); got != "rotating_light" {
+		t.Errorf("tags = %v", got)
+	}
+	if msg := path(t, req.Body, "message").(string); !strings.HasPrefix(msg, "节点 hk-1 已离线\n\n") || strings.Contains(msg, "dedup") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestNtfyNotifierBasicAuth(t *testing.T) {
+	server, req := newStandIn(t, `{}`)
+	n := NewNtfyNotifier(&model.NtfyConfig{ServerURL: server.URL, Topic: "t", Username: "alice", Password: "pw", Priority: 2})
+	if err := n.Send("标题", "内容"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:pw"))
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+	if got := path(t, req.Body, "priority"); got != float64(2) {
+		t.Errorf("priority = %v, configured priority must win", got)
+	}
+}
+
+func TestGotifyNotifier(t *testing.T) {
+	server, req := newStandIn(t, `{"id":1}`)
+	n := NewGotifyNotifier(&model.GotifyConfig{ServerURL: server.URL + "/", Token: "AppTok"})
+	if err := n.SendAlert(criticalAlert()); err != nil {
+		t.Fatalf("SendAlert: %v", err)
+	}
+
+	assertJSONRequest(t, req)
+	if req.Path != "/message" {
+		t.Errorf("path = %s, want /message", req.Path)
+	}
+	if got := req.Header.Get("X-Gotify-Key"); got != "AppTok" {
+		t.Errorf("X-Gotify-Key = %q", got)
+	}
+	if got := path(t, req.Body, "title"); got != "节点离线" {
+		t.Errorf("title = %v", got)
+	}
+	if got := path(t, req.Body, "priority"); got != float64(10) {
+		t.Errorf("priority = %v, want 10 for critical", got)
+	}
+	if got := path(t, req.Body, "extras.client::display.contentType"); got != "text/markdown" {
+		t.Errorf("contentType = %v", got)
+	}
+}
+
+func TestGotifyNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":"Unauthorized"}`)
+	}))
+	defer server.Close()
+
+	err := NewGotifyNotifier(&model.GotifyConfig{ServerURL: server.URL, Token: "bad"}).Send("t", "m")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want status error", err)
+	}
+}
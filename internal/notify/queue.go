@@ -0,0 +1,283 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 通知投递状态
+const (
+	DeliveryPending  = "pending"  // 等待首次发送
+	DeliveryRetrying = "retrying" // 发送失败，等待重试
+	DeliverySent     = "sent"
+	DeliveryDead     = "dead"     // 超过最大重试次数 (死信)
+	DeliverySilenced = "silenced" // 被静默，不发送
+)
+
+const (
+	maxDeliveryAttempts  = 8                // 最大尝试次数，之后进入死信
+	deliveryBaseBackoff  = 30 * time.Second // 首次重试间隔，之后指数增长
+	deliveryMaxBackoff   = time.Hour
+	deliveryPollInterval = 5 * time.Second
+	deliveryBatchSize    = 50
+)
+
+// enqueue 将通知加入渠道的投递队列，并唤醒投递协程
+func (a *AlertService) enqueue(channel *model.NotifyChannel, alert *Alert) {
+	payload, _ := json.Marshal(alert)
+	now := time.Now()
+	entry := &model.AlertLog{
+		RuleID:        alert.RuleID,
+		RuleName:      alert.RuleName,
+		Type:          alert.Type,
+		Message:       alert.Message,
+		TargetType:    alert.TargetType,
+		TargetID:      alert.TargetID,
+		TargetName:    alert.TargetName,
+		Status:        DeliveryPending,
+		IncidentID:    alert.IncidentID,
+		ChannelID:     channel.ID,
+		ChannelName:   channel.Name,
+		Payload:       string(payload),
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+	if err := a.db.Create(entry).Error; err != nil {
+		log.Printf("[Notify] Enqueue notification failed: %v", err)
+		return
+	}
+	a.wakeDispatcher()
+}
+
+// EnqueueMessage 将一条非告警消息 (如摘要报告) 加入渠道的投递队列
+func (a *AlertService) EnqueueMessage(channelID uint, messageType, title, message string) error {
+	channel, err := a.GetChannel(channelID)
+	if err != nil {
+		return errors.New("通知渠道不存在")
+	}
+	if !channel.Enabled {
+		return errors.New("通知渠道已禁用")
+	}
+	alert := plainAlert(title, message)
+	alert.Type = messageType
+	a.enqueue(channel, alert)
+	return nil
+}
+
+func (a *AlertService) wakeDispatcher() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start 启动通知投递协程
+func (a *AlertService) Start() {
+	go a.dispatchLoop()
+}
+
+// Stop 停止通知投递协程 (未发送的通知保留在队列中，重启后继续)
+func (a *AlertService) Stop() {
+	select {
+	case <-a.stopCh:
+	default:
+		close(a.stopCh)
+	}
+}
+
+func (a *AlertService) dispatchLoop() {
+	ticker := time.NewTicker(deliveryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		a.dispatchDue()
+		select {
+		case <-ticker.C:
+		case <-a.wake:
+		case <-a.stopCh:
+			return
+		}
+	}
+}
+
+// dispatchDue 发送所有到期的待投递通知
+func (a *AlertService) dispatchDue() {
+	var entries []model.AlertLog
+	a.db.Where("status IN ? AND next_attempt_at <= ?", []string{DeliveryPending, DeliveryRetrying}, time.Now()).
+		Order("next_attempt_at asc").Limit(deliveryBatchSize).Find(&entries)
+
+	for i := range entries {
+		select {
+		case <-a.stopCh:
+			return
+		default:
+		}
+		a.deliver(&entries[i])
+	}
+}
+
+// deliver 投递单条通知，失败时按指数退避安排重试
+func (a *AlertService) deliver(entry *model.AlertLog) {
+	var channel model.NotifyChannel
+	if err := a.db.First(&channel, entry.ChannelID).Error; err != nil {
+		a.markDead(entry, "通知渠道不存在")
+		return
+	}
+	if !channel.Enabled {
+		a.markDead(entry, "通知渠道已禁用")
+		return
+	}
+
+	// 渠道限速: 超出时推迟到窗口内最早一条发送满一分钟之后
+	now := time.Now()
+	if wait := a.rateLimitWait(&channel, now); wait > 0 {
+		next := now.Add(wait)
+		a.db.Model(entry).Update("next_attempt_at", next)
+		return
+	}
+
+	notifier, err := a.channelNotifier(&channel)
+	if err != nil {
+		a.markDead(entry, err.Error())
+		return
+	}
+
+	var alert Alert
+	if err := json.Unmarshal([]byte(entry.Payload), &alert); err != nil {
+		a.markDead(entry, "通知内容无效")
+		return
+	}
+
+	withAckLink(&alert)
+
+	a.recordSend(channel.ID, now)
+	attempts := entry.Attempts + 1
+	if err := sendAlert(notifier, &alert); err != nil {
+		errMsg := truncate(err.Error(), 500)
+		if attempts >= maxDeliveryAttempts {
+			log.Printf("[Notify] Delivery %d to channel %s dead after %d attempts: %v", entry.ID, channel.Name, attempts, err)
+			a.db.Model(entry).Updates(map[string]interface{}{
+				"status":          DeliveryDead,
+				"attempts":        attempts,
+				"last_error":      errMsg,
+				"next_attempt_at": nil,
+			})
+			return
+		}
+
+		next := time.Now().Add(deliveryBackoff(attempts))
+		log.Printf("[Notify] Delivery %d to channel %s failed (attempt %d), retry at %s: %v",
+			entry.ID, channel.Name, attempts, next.Format(time.RFC3339), err)
+		a.db.Model(entry).Updates(map[string]interface{}{
+			"status":          DeliveryRetrying,
+			"attempts":        attempts,
+			"last_error":      errMsg,
+			"next_attempt_at": next,
+		})
+		return
+	}
+
+	sentAt := time.Now()
+	a.db.Model(entry).Updates(map[string]interface{}{
+		"status":          DeliverySent,
+		"attempts":        attempts,
+		"last_error":      "",
+		"next_attempt_at": nil,
+		"sent_at":         sentAt,
+	})
+}
+
+func (a *AlertService) markDead(entry *model.AlertLog, reason string) {
+	a.db.Model(entry).Updates(map[string]interface{}{
+		"status":          DeliveryDead,
+		"last_error":      reason,
+		"next_attempt_at": nil,
+	})
+}
+
+// deliveryBackoff 第 attempts 次失败后的重试间隔: 30s, 1m, 2m ... 最长 1 小时
+func deliveryBackoff(attempts int) time.Duration {
+	d := deliveryBaseBackoff
+	for i := 1; i < attempts && d < deliveryMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > deliveryMaxBackoff {
+		d = deliveryMaxBackoff
+	}
+	return d
+}
+
+// rateLimitWait 渠道在一分钟窗口内已达发送上限时返回需要等待的时间
+func (a *AlertService) rateLimitWait(channel *model.NotifyChannel, now time.Time) time.Duration {
+	if channel.RateLimit <= 0 {
+		return 0
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	window := now.Add(-time.Minute)
+	recent := a.sendTimes[channel.ID][:0]
+	for _, t := range a.sendTimes[channel.ID] {
+		if t.After(window) {
+			recent = append(recent, t)
+		}
+	}
+	a.sendTimes[channel.ID] = recent
+
+	if len(recent) < channel.RateLimit {
+		return 0
+	}
+	return recent[0].Add(time.Minute).Sub(now)
+}
+
+func (a *AlertService) recordSend(channelID uint, now time.Time) {
+	a.mu.Lock()
+	a.sendTimes[channelID] = append(a.sendTimes[channelID], now)
+	a.mu.Unlock()
+}
+
+// truncate 截断到最多 n 个字节 (与数据库字段长度一致)，在字符边界处截断，不会切开多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ResendAlertLog 重新投递一条通知 (死信或已发送的记录)
+func (a *AlertService) ResendAlertLog(id uint) error {
+	var entry model.AlertLog
+	if err := a.db.First(&entry, id).Error; err != nil {
+		return err
+	}
+	switch entry.Status {
+	case DeliveryPending, DeliveryRetrying:
+		return errors.New("该通知已在投递队列中")
+	case DeliverySilenced:
+		return errors.New("被静默的告警没有发送渠道，无法重发")
+	}
+	if entry.ChannelID == 0 || entry.Payload == "" {
+		return errors.New("该记录缺少渠道信息，无法重发")
+	}
+
+	now := time.Now()
+	err := a.db.Model(&entry).Updates(map[string]interface{}{
+		"status":          DeliveryPending,
+		"attempts":        0,
+		"last_error":      "",
+		"next_attempt_at": now,
+	}).Error
+	if err == nil {
+		a.wakeDispatcher()
+	}
+	return err
+}
@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateKeepsRuneBoundaries(t *testing.T) {
+	msg := strings.Repeat("发送失败", 50) // 每个汉字 3 字节
+	got := truncate(msg, 500)
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncated message is not valid UTF-8: %q", got[len(got)-4:])
+	}
+	if len(got) > 500 || len(got) < 498 {
+		t.Fatalf("len = %d, want the longest whole-rune prefix within 500 bytes", len(got))
+	}
+	if !strings.HasPrefix(msg, got) {
+		t.Fatal("truncated message is not a prefix of the original")
+	}
+	if got := truncate("short", 500); got != "short" {
+		t.Fatalf("truncate(short) = %q", got)
+	}
+}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 静默匹配方式
+const (
+	SilenceMatchAll   = "all"
+	SilenceMatchNode  = "node"
+	SilenceMatchTag   = "tag"
+	SilenceMatchGroup = "group"
+)
+
+// 维护窗口最长时长
+const maxSilenceWindow = 7 * 24 * time.Hour
+
+// ValidateSilence 校验静默配置
+func ValidateSilence(silence *model.AlertSilence) error {
+	switch silence.MatchType {
+	case SilenceMatchAll:
+	case SilenceMatchNode, SilenceMatchTag, SilenceMatchGroup:
+		if silence.MatchID == 0 {
+			return errors.New("请指定匹配的节点/标签/节点组")
+		}
+	default:
+		return errors.New("match_type 必须为 all/node/tag/group")
+	}
+
+	if silence.Schedule != "" {
+		if _, err := parseCron(silence.Schedule); err != nil {
+			return err
+		}
+		window := time.Duration(silence.DurationMin) * time.Minute
+		if window <= 0 || window > maxSilenceWindow {
+			return errors.New("维护窗口时长需在 1 分钟到 7 天之间")
+		}
+		return nil
+	}
+
+	if silence.EndsAt == nil {
+		return errors.New("一次性静默需要指定结束时间")
+	}
+	if silence.StartsAt != nil && !silence.EndsAt.After(*silence.StartsAt) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	return nil
+}
+
+// silenceActive 静默在时间 t 是否生效
+func silenceActive(silence *model.AlertSilence, t time.Time) bool {
+	if !silence.Enabled {
+		return false
+	}
+	if silence.Schedule != "" {
+		schedule, err := parseCron(silence.Schedule)
+		if err != nil {
+			return false
+		}
+		_, ok := schedule.ActiveWindow(t, time.Duration(silence.DurationMin)*time.Minute)
+		return ok
+	}
+	if silence.StartsAt != nil && t.Before(*silence.StartsAt) {
+		return false
+	}
+	return silence.EndsAt != nil && t.Before(*silence.EndsAt)
+}
+
+// silenceMatchesType 静默是否覆盖该告警类型 (未指定类型时覆盖全部)
+func silenceMatchesType(silence *model.AlertSilence, alertType string) bool {
+	if strings.TrimSpace(silence.AlertTypes) == "" {
+		return true
+	}
+	for _, t := range strings.Split(silence.AlertTypes, ",") {
+		if strings.TrimSpace(t) == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+// targetNodeID 告警目标所在节点 (节点为自身，客户端为其所属节点)
+func (a *AlertService) targetNodeID(targetType string, targetID uint) uint {
+	switch targetType {
+	case "node":
+		return targetID
+	case "client":
+		var client model.Client
+		if err := a.db.Select("node_id").First(&client, targetID).Error; err == nil {
+			return client.NodeID
+		}
+	}
+	return 0
+}
+
+// targetNodeHost 告警目标所在节点的地址
+func (a *AlertService) targetNodeHost(targetType string, targetID uint) string {
+	nodeID := a.targetNodeID(targetType, targetID)
+	if nodeID == 0 {
+		return ""
+	}
+	var node model.Node
+	if err := a.db.Select("host").First(&node, nodeID).Error; err != nil {
+		return ""
+	}
+	return node.Host
+}
+
+// silenceMatchesNode 静默的匹配范围是否包含节点
+func (a *AlertService) silenceMatchesNode(silence *model.AlertSilence, nodeID uint) bool {
+	if silence.MatchType == SilenceMatchAll {
+		return true
+	}
+	if nodeID == 0 {
+		return false
+	}
+
+	var count int64
+	switch silence.MatchType {
+	case SilenceMatchNode:
+		return silence.MatchID == nodeID
+	case SilenceMatchTag:
+		a.db.Model(&model.NodeTag{}).Where("node_id = ? AND tag_id = ?", nodeID, silence.MatchID).Count(&count)
+	case SilenceMatchGroup:
+		a.db.Model(&model.NodeGroupMember{}).Where("node_id = ? AND group_id = ?", nodeID, silence.MatchID).Count(&count)
+	}
+	return count > 0
+}
+
+// activeSilences 当前生效的静默
+func (a *AlertService) activeSilences(now time.Time) []model.AlertSilence {
+	var silences []model.AlertSilence
+	a.db.Where("enabled = ?", true).Find(&silences)
+
+	active := silences[:0]
+	for _, silence := range silences {
+		if silenceActive(&silence, now) {
+			active = append(active, silence)
+		}
+	}
+	return active
+}
+
+// MatchSilence 返回命中告警的生效静默 (没有命中返回 nil)
+func (a *AlertService) MatchSilence(alertType, targetType string, targetID uint, now time.Time) *model.AlertSilence {
+	silences := a.activeSilences(now)
+	if len(silences) == 0 {
+		return nil
+	}
+
+	nodeID := a.targetNodeID(targetType, targetID)
+	for i := range silences {
+		silence := &silences[i]
+		if silenceMatchesType(silence, alertType) && a.silenceMatchesNode(silence, nodeID) {
+			return silence
+		}
+	}
+	return nil
+}
+
+// NodeInMaintenance 节点是否处于跳过健康检查的维护窗口内
+func (a *AlertService) NodeInMaintenance(nodeID uint) bool {
+	for _, silence := range a.activeSilences(time.Now()) {
+		if silence.SkipHealthCheck && a.silenceMatchesNode(&silence, nodeID) {
+			return true
+		}
+	}
+	return false
+}
+
+// logSilenced 记录被静默的告警 (不发送通知)
+func (a *AlertService) logSilenced(rule *model.AlertRule, incident *model.AlertIncident, silence *model.AlertSilence, alertType, message string) {
+	a.db.Create(&model.AlertLog{
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		Type:       alertType,
+		Message:    message,
+		TargetType: incident.TargetType,
+		TargetID:   incident.TargetID,
+		TargetName: incident.TargetName,
+		Status:     DeliverySilenced,
+		IncidentID: incident.ID,
+		SilenceID:  silence.ID,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// ==================== 静默管理 ====================
+
+// ListSilences 获取静默列表，active 为 true 时仅返回当前生效的静默
+func (a *AlertService) ListSilences(active bool) ([]model.AlertSilence, error) {
+	if active {
+		return a.activeSilences(time.Now()), nil
+	}
+	var silences []model.AlertSilence
+	err := a.db.Order("id desc").Find(&silences).Error
+	return silences, err
+}
+
+// GetSilence 获取单个静默
+func (a *AlertService) GetSilence(id uint) (*model.AlertSilence, error) {
+	var silence model.AlertSilence
+	err := a.db.First(&silence, id).Error
+	return &silence, err
+}
+
+// CreateSilence 创建静默
+func (a *AlertService) CreateSilence(silence *model.AlertSilence) error {
+	if err := ValidateSilence(silence); err != nil {
+		return err
+	}
+	silence.CreatedAt = time.Now()
+	silence.UpdatedAt = time.Now()
+	return a.db.Create(silence).Error
+}
+
+// UpdateSilence 更新静默
+func (a *AlertService) UpdateSilence(silence *model.AlertSilence) error {
+	if err := ValidateSilence(silence); err != nil {
+		return err
+	}
+	silence.UpdatedAt = time.Now()
+	return a.db.Save(silence).Error
+}
+
+// DeleteSilence 删除静默
+func (a *AlertService) DeleteSilence(id uint) error {
+	return a.db.Delete(&model.AlertSilence{}, id).Error
+}
@@ -0,0 +1,561 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// 用户个人渠道 (NotifyChannel.UserID 为所属用户) 与用户通知订阅
+// 邮件 / Telegram 个人渠道只需填写收件地址或 chat_id，发送时使用系统 SMTP 渠道和交互式机器人
+// 个人 Webhook 渠道的请求只允许连接公网地址
+
+// PersonalChannelTypes 用户可自行创建的个人渠道类型
+var PersonalChannelTypes = map[string]bool{
+	"email":    true,
+	"telegram": true,
+	"webhook":  true,
+}
+
+// UserEvents 用户可订阅的事件 (按界面展示顺序)
+var UserEvents = []string{
+	model.UserEventQuota,
+	model.UserEventPlanExpiry,
+	model.UserEventExitOffline,
+	model.UserEventLogin,
+}
+
+// userEventDefaults 各事件的默认阈值
+var userEventDefaults = map[string]string{
+	model.UserEventQuota:      "80,90,100",
+	model.UserEventPlanExpiry: "7,3,1",
+}
+
+// planExpiredNotifyWindow 套餐到期超过该时长后不再发送到期通知 (避免开启订阅时收到过期很久的提醒)
+const planExpiredNotifyWindow = 7 * 24 * time.Hour
+
+// ValidatePersonalChannel 校验个人渠道，邮件 / Telegram 未填写发信配置时只校验收件人
+func ValidatePersonalChannel(channel *model.NotifyChannel) error {
+	switch channel.Type {
+	case "email", "smtp":
+		var config model.SMTPConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return err
+		}
+		// 个人邮件渠道只能使用系统发信配置，自定义 SMTP 服务器可被用来探测面板所在内网
+		if config.Host != "" {
+			return errors.New("个人邮件渠道不能设置 SMTP 服务器，将使用系统邮件渠道发信")
+		}
+		if config.To == "" {
+			return errors.New("邮件渠道需要收件地址 (to)")
+		}
+		if _, err := mail.ParseAddressList(config.To); err != nil {
+			return fmt.Errorf("收件地址无效: %v", err)
+		}
+		return nil
+	case "telegram":
+		var config model.TelegramConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return err
+		}
+		if config.BotToken == "" {
+			if config.ChatID == "" {
+				return errors.New("telegram 渠道需要 chat_id")
+			}
+			return nil
+		}
+	}
+	return ValidateChannel(channel)
+}
+
+// ValidatePublicWebhook 校验用户 Webhook 地址不指向面板所在的内网 (回环 / 私有 / 链路本地地址)
+func ValidatePublicWebhook(channel *model.NotifyChannel) error {
+	var config model.WebhookConfig
+	if err := decodeChannelConfig(channel, &config); err != nil {
+		return err
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil || u.Hostname() == "" {
+		return errors.New("url 必须是有效的 http/https 地址")
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("无法解析 %s: %v", u.Hostname(), err)
+	}
+	for _, ip := range ips {
+		if internalIP(ip) {
+			return errors.New("webhook 地址不能指向内网")
+		}
+	}
+	return nil
+}
+
+// internalIP 是否为回环 / 私有 / 链路本地 / 未指定地址
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// errInternalAddress 个人渠道请求的目标地址位于内网
+var errInternalAddress = errors.New("personal channel must not connect to internal addresses")
+
+// publicHTTPClient 个人渠道使用的 HTTP 客户端: 在建立连接时检查实际连接的地址，
+// 保存时的校验无法防止 DNS 重绑定 (保存后域名改为解析到内网)，重定向后的连接同样会被检查
+var publicHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		// 不使用环境变量中的代理，否则检查的是代理地址而不是目标地址
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+					return errInternalAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// channelNotifier 创建渠道的通知器，个人渠道缺少发信配置时使用系统配置
+func (a *AlertService) channelNotifier(channel *model.NotifyChannel) (Notifier, error) {
+	if channel.UserID == 0 {
+		return CreateNotifier(channel)
+	}
+
+	switch channel.Type {
+	case "email", "smtp":
+		var config model.SMTPConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		// 个人渠道始终使用系统发信配置 (忽略已保存的 SMTP 服务器)
+		system, err := a.systemSMTPConfig()
+		if err != nil {
+			return nil, err
+		}
+		system.To = config.To
+		return NewSMTPNotifier(system), nil
+	case "webhook":
+		var config model.WebhookConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		notifier := NewWebhookNotifier(&config)
+		notifier.client = publicHTTPClient
+		return notifier, nil
+	case "telegram":
+		var config model.TelegramConfig
+		if err := decodeChannelConfig(channel, &config); err != nil {
+			return nil, err
+		}
+		if config.BotToken == "" {
+			config.BotToken = a.siteConfig(model.ConfigTelegramBotToken)
+			if config.BotToken == "" {
+				return nil, errors.New("管理员未配置 Telegram 机器人")
+			}
+			return NewTelegramNotifier(&config), nil
+		}
+	}
+	return CreateNotifier(channel)
+}
+
+// systemSMTPConfig 系统发信配置: 第一个已启用且配置了服务器的公共邮件渠道
+func (a *AlertService) systemSMTPConfig() (*model.SMTPConfig, error) {
+	var channels []model.NotifyChannel
+	a.db.Where("type IN ? AND user_id = ? AND enabled = ?", []string{"smtp", "email"}, 0, true).Order("id asc").Find(&channels)
+	for i := range channels {
+		var config model.SMTPConfig
+		if err := decodeChannelConfig(&channels[i], &config); err != nil || config.Host == "" {
+			continue
+		}
+		return &config, nil
+	}
+	return nil, errors.New("管理员未配置系统邮件渠道")
+}
+
+func (a *AlertService) siteConfig(key string) string {
+	var config model.SiteConfig
+	if err := a.db.Where("key = ?", key).First(&config).Error; err != nil {
+		return ""
+	}
+	return config.Value
+}
+
+// TestPersonalChannel 向个人渠道发送测试通知
+func (a *AlertService) TestPersonalChannel(channel *model.NotifyChannel) error {
+	if err := ValidatePersonalChannel(channel); err != nil {
+		return err
+	}
+	notifier, err := a.channelNotifier(channel)
+	if err != nil {
+		return err
+	}
+	return sendAlert(notifier, testAlert())
+}
+
+// ==================== 个人渠道管理 ====================
+
+// ListUserChannels 获取用户的个人渠道
+func (a *AlertService) ListUserChannels(userID uint) ([]model.NotifyChannel, error) {
+	var channels []model.NotifyChannel
+	err := a.db.Where("user_id = ?", userID).Order("id asc").Find(&channels).Error
+	return channels, err
+}
+
+// GetUserChannel 获取用户的单个个人渠道
+func (a *AlertService) GetUserChannel(userID, id uint) (*model.NotifyChannel, error) {
+	var channel model.NotifyChannel
+	err := a.db.Where("id = ? AND user_id = ?", id, userID).First(&channel).Error
+	return &channel, err
+}
+
+// userChannels 按 ID 获取用户已启用的个人渠道 (忽略不属于该用户的渠道)
+func (a *AlertService) userChannels(userID uint, ids []uint) []model.NotifyChannel {
+	var channels []model.NotifyChannel
+	for _, channel := range a.channelsByIDs(ids) {
+		if channel.UserID == userID {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// ==================== 用户通知订阅 ====================
+
+// ParseUserEventThresholds 解析事件阈值 (升序去重)，空值使用默认阈值
+// quota 为用量百分比 (1-100)，plan_expiry 为提前天数 (1-90)，其他事件没有阈值
+func ParseUserEventThresholds(event, raw string) ([]int, error) {
+	maxValue := 0
+	switch event {
+	case model.UserEventQuota:
+		maxValue = 100
+	case model.UserEventPlanExpiry:
+		maxValue = 90
+	default:
+		return nil, nil
+	}
+
+	if strings.TrimSpace(raw) == "" {
+		raw = userEventDefaults[event]
+	}
+	seen := make(map[int]bool)
+	var values []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 1 || v > maxValue {
+			return nil, fmt.Errorf("阈值 %q 无效，取值 1-%d", part, maxValue)
+		}
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil, errors.New("至少需要一个阈值")
+	}
+	sort.Ints(values)
+	return values, nil
+}
+
+func formatThresholds(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ListUserPreferences 获取用户各事件的订阅设置，未设置的事件返回默认值 (未启用)
+func (a *AlertService) ListUserPreferences(userID uint) ([]model.UserNotifyPreference, error) {
+	var saved []model.UserNotifyPreference
+	if err := a.db.Where("user_id = ?", userID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byEvent := make(map[string]model.UserNotifyPreference)
+	for _, pref := range saved {
+		byEvent[pref.Event] = pref
+	}
+
+	prefs := make([]model.UserNotifyPreference, 0, len(UserEvents))
+	for _, event := range UserEvents {
+		pref, ok := byEvent[event]
+		if !ok {
+			pref = model.UserNotifyPreference{UserID: userID, Event: event, Thresholds: userEventDefaults[event]}
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, nil
+}
+
+// SaveUserPreference 保存用户单个事件的订阅设置
+func (a *AlertService) SaveUserPreference(userID uint, event string, enabled bool, channelIDs []uint, thresholds string) (*model.UserNotifyPreference, error) {
+	known := false
+	for _, e := range UserEvents {
+		known = known || e == event
+	}
+	if !known {
+		return nil, fmt.Errorf("未知的通知事件: %s", event)
+	}
+
+	values, err := ParseUserEventThresholds(event, thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(channelIDs))
+	for _, id := range channelIDs {
+		var count int64
+		a.db.Model(&model.NotifyChannel{}).Where("id = ? AND user_id = ?", id, userID).Count(&count)
+		if count == 0 {
+			return nil, fmt.Errorf("通知渠道 %d 不存在", id)
+		}
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	if enabled && len(ids) == 0 {
+		return nil, errors.New("启用通知需要至少选择一个渠道")
+	}
+
+	var pref model.UserNotifyPreference
+	a.db.Where("user_id = ? AND event = ?", userID, event).First(&pref)
+	pref.UserID = userID
+	pref.Event = event
+	pref.Enabled = enabled
+	pref.ChannelIDs = strings.Join(ids, ",")
+	pref.Thresholds = formatThresholds(values)
+	if err := a.db.Save(&pref).Error; err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// ListUserNotifyLogs 获取用户的通知记录
+func (a *AlertService) ListUserNotifyLogs(userID uint, limit, offset int) ([]model.UserNotifyLog, int64, error) {
+	var logs []model.UserNotifyLog
+	var total int64
+
+	query := a.db.Model(&model.UserNotifyLog{}).Where("user_id = ?", userID)
+	query.Count(&total)
+	err := query.Order("id desc").Limit(limit).Offset(offset).Find(&logs).Error
+	return logs, total, err
+}
+
+// ==================== 用户通知事件 ====================
+
+// userPreference 用户已启用的事件订阅，未启用时返回 nil
+func (a *AlertService) userPreference(userID uint, event string) *model.UserNotifyPreference {
+	var pref model.UserNotifyPreference
+	if err := a.db.Where("user_id = ? AND event = ? AND enabled = ?", userID, event, true).First(&pref).Error; err != nil {
+		return nil
+	}
+	return &pref
+}
+
+// notifyUser 向用户订阅的个人渠道发送通知，相同 (事件, key) 只发送一次
+func (a *AlertService) notifyUser(user *model.User, pref *model.UserNotifyPreference, key, title, message string, values map[string]interface{}) {
+	var count int64
+	a.db.Model(&model.UserNotifyLog{}).
+		Where("user_id = ? AND event = ? AND dedup_key = ?", user.ID, pref.Event, key).
+		Count(&count)
+	if count > 0 {
+		return
+	}
+
+	channels := a.userChannels(user.ID, parseChannelIDs(pref.ChannelIDs))
+	entry := &model.UserNotifyLog{
+		UserID:   user.ID,
+		Event:    pref.Event,
+		DedupKey: key,
+		Title:    title,
+		Message:  message,
+		Channels: len(channels),
+	}
+	if err := a.db.Create(entry).Error; err != nil {
+		return
+	}
+
+	alert := &Alert{
+		Title:      title,
+		Message:    message,
+		Type:       "user_" + pref.Event,
+		Severity:   SeverityInfo,
+		TargetType: "user",
+		TargetID:   user.ID,
+		TargetName: user.Username,
+		Values:     values,
+		FiredAt:    entry.CreatedAt,
+		Time:       entry.CreatedAt,
+	}
+	for i := range channels {
+		a.enqueue(&channels[i], alert)
+	}
+}
+
+// EvaluateUserNotifications 检查用户订阅的配额阈值与套餐到期 (由定时任务调用)
+func (a *AlertService) EvaluateUserNotifications() {
+	var prefs []model.UserNotifyPreference
+	a.db.Where("enabled = ? AND event IN ?", true, []string{model.UserEventQuota, model.UserEventPlanExpiry}).Find(&prefs)
+
+	now := time.Now()
+	for i := range prefs {
+		var user model.User
+		if err := a.db.Preload("Plan").First(&user, prefs[i].UserID).Error; err != nil || !user.Enabled {
+			continue
+		}
+		switch prefs[i].Event {
+		case model.UserEventQuota:
+			a.checkUserQuotaThresholds(&user, &prefs[i])
+		case model.UserEventPlanExpiry:
+			a.checkPlanExpiry(&user, &prefs[i], now)
+		}
+	}
+}
+
+// checkUserQuotaThresholds 用量越过阈值时通知，每个配额周期每个阈值只通知一次
+func (a *AlertService) checkUserQuotaThresholds(user *model.User, pref *model.UserNotifyPreference) {
+	if user.TrafficQuota <= 0 {
+		return
+	}
+	thresholds, err := ParseUserEventThresholds(pref.Event, pref.Thresholds)
+	if err != nil {
+		return
+	}
+
+	percent := float64(user.QuotaUsed) / float64(user.TrafficQuota) * 100
+	crossed := 0
+	for _, t := range thresholds {
+		if percent >= float64(t) {
+			crossed = t
+		}
+	}
+	if crossed == 0 {
+		return
+	}
+
+	title := fmt.Sprintf("流量配额已使用 %d%%", crossed)
+	if crossed >= 100 {
+		title = "流量配额已用尽"
+	}
+	message := fmt.Sprintf("账户 %s 本周期已用 %s / 配额 %s (%.1f%%)",
+		user.Username, formatBytes(user.QuotaUsed), formatBytes(user.TrafficQuota), percent)
+	key := fmt.Sprintf("%d@%d", crossed, user.QuotaResetAt.Unix())
+	a.notifyUser(user, pref, key, title, message,
+		map[string]interface{}{"used": user.QuotaUsed, "quota": user.TrafficQuota, "percent": percent, "threshold": crossed})
+}
+
+// checkPlanExpiry 套餐剩余天数进入阈值或已到期时通知，每个到期时间每个阈值只通知一次
+func (a *AlertService) checkPlanExpiry(user *model.User, pref *model.UserNotifyPreference, now time.Time) {
+	if user.PlanExpireAt == nil {
+		return
+	}
+	expireAt := *user.PlanExpireAt
+	planName := "当前套餐"
+	if user.Plan != nil {
+		planName = "套餐 " + user.Plan.Name
+	}
+	values := map[string]interface{}{"expire_at": expireAt}
+
+	left := expireAt.Sub(now)
+	if left <= 0 {
+		if -left > planExpiredNotifyWindow {
+			return
+		}
+		a.notifyUser(user, pref, fmt.Sprintf("expired@%d", expireAt.Unix()), "套餐已到期",
+			fmt.Sprintf("账户 %s 的%s已于 %s 到期", user.Username, planName, expireAt.Format("2006-01-02 15:04")), values)
+		return
+	}
+
+	thresholds, err := ParseUserEventThresholds(pref.Event, pref.Thresholds)
+	if err != nil {
+		return
+	}
+	days := int(math.Ceil(left.Hours() / 24))
+	for _, t := range thresholds {
+		if days > t {
+			continue
+		}
+		values["days_left"] = days
+		a.notifyUser(user, pref, fmt.Sprintf("%d@%d", t, expireAt.Unix()),
+			fmt.Sprintf("套餐将在 %d 天内到期", days),
+			fmt.Sprintf("账户 %s 的%s将于 %s 到期，请及时续费", user.Username, planName, expireAt.Format("2006-01-02 15:04")), values)
+		return
+	}
+}
+
+// NotifyUserLogin 通知用户账户有新登录
+func (a *AlertService) NotifyUserLogin(user *model.User, ip, userAgent string) {
+	pref := a.userPreference(user.ID, model.UserEventLogin)
+	if pref == nil {
+		return
+	}
+	now := time.Now()
+	message := fmt.Sprintf("账户 %s 于 %s 登录\nIP: %s\n设备: %s\n如非本人操作，请立即修改密码",
+		user.Username, now.Format("2006-01-02 15:04:05"), ip, truncate(userAgent, 200))
+	a.notifyUser(user, pref, strconv.FormatInt(now.UnixNano(), 10), "新登录提醒", message,
+		map[string]interface{}{"ip": ip, "user_agent": userAgent})
+}
+
+// notifyExitNodeUsers 通知以该节点为出口的隧道所有者 (节点离线 / 恢复)
+// 包括将该节点列为多出口之一或使用其所在节点组作为出口的隧道
+func (a *AlertService) notifyExitNodeUsers(node *model.Node, online bool) {
+	var tunnels []model.Tunnel
+	a.db.Where("owner_id IS NOT NULL").
+		Where("exit_node_id = ? OR (',' || exit_node_ids || ',') LIKE ? OR exit_group_id IN (?)",
+			node.ID, fmt.Sprintf("%%,%d,%%", node.ID),
+			a.db.Model(&model.NodeGroupMember{}).Select("group_id").Where("node_id = ? AND enabled = ?", node.ID, true)).
+		Order("id asc").Find(&tunnels)
+
+	tunnelNames := make(map[uint][]string)
+	var owners []uint
+	for _, tunnel := range tunnels {
+		ownerID := *tunnel.OwnerID
+		if _, ok := tunnelNames[ownerID]; !ok {
+			owners = append(owners, ownerID)
+		}
+		tunnelNames[ownerID] = append(tunnelNames[ownerID], tunnel.Name)
+	}
+
+	for _, ownerID := range owners {
+		pref := a.userPreference(ownerID, model.UserEventExitOffline)
+		if pref == nil {
+			continue
+		}
+		var user model.User
+		if err := a.db.First(&user, ownerID).Error; err != nil || !user.Enabled {
+			continue
+		}
+
+		names := strings.Join(tunnelNames[ownerID], ", ")
+		values := map[string]interface{}{"node": node.Name, "tunnels": tunnelNames[ownerID]}
+		if online {
+			a.notifyUser(&user, pref, fmt.Sprintf("online:%d@%d", node.ID, time.Now().Unix()),
+				fmt.Sprintf("出口节点 %s 已恢复", node.Name),
+				fmt.Sprintf("隧道 %s 的出口节点 %s 已恢复在线", names, node.Name), values)
+			continue
+		}
+		a.notifyUser(&user, pref, fmt.Sprintf("offline:%d@%d", node.ID, node.LastSeen.Unix()),
+			fmt.Sprintf("出口节点 %s 已离线", node.Name),
+			fmt.Sprintf("隧道 %s 的出口节点 %s 已离线，隧道暂时不可用\n最后在线: %s",
+				names, node.Name, node.LastSeen.Format("2006-01-02 15:04:05")), values)
+	}
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+func webhookChannel(t *testing.T, userID uint, url string) *model.NotifyChannel {
+	t.Helper()
+	config, _ := json.Marshal(model.WebhookConfig{URL: url})
+	return &model.NotifyChannel{Type: "webhook", UserID: userID, Config: string(config)}
+}
+
+func TestPersonalWebhookRejectsInternalAddressAtDial(t *testing.T) {
+	server, req := newStandIn(t, "{}")
+	a := &AlertService{}
+
+	// 个人渠道: 连接时检查实际地址 (本地替身监听在 127.0.0.1)
+	notifier, err := a.channelNotifier(webhookChannel(t, 7, server.URL))
+	if err != nil {
+		t.Fatalf("channelNotifier: %v", err)
+	}
+	if err := notifier.Send("t", "m"); !errors.Is(err, errInternalAddress) {
+		t.Fatalf("err = %v, want internal address rejection", err)
+	}
+	if req.Method != "" {
+		t.Fatal("personal webhook must not reach an internal address")
+	}
+
+	// 公共渠道由管理员配置，不受限制
+	notifier, err = a.channelNotifier(webhookChannel(t, 0, server.URL))
+	if err != nil {
+		t.Fatalf("channelNotifier: %v", err)
+	}
+	if err := notifier.Send("t", "m"); err != nil {
+		t.Fatalf("public webhook: %v", err)
+	}
+}
+
+func TestValidatePersonalEmailRejectsSMTPHost(t *testing.T) {
+	config, _ := json.Marshal(model.SMTPConfig{Host: "10.0.0.5", Port: 25, To: "a@example.com"})
+	channel := &model.NotifyChannel{Type: "email", UserID: 7, Config: string(config)}
+	if err := ValidatePersonalChannel(channel); err == nil {
+		t.Fatal("personal email channel with smtp host must be rejected")
+	}
+
+	config, _ = json.Marshal(model.SMTPConfig{To: "a@example.com"})
+	channel.Config = string(config)
+	if err := ValidatePersonalChannel(channel); err != nil {
+		t.Fatalf("recipient-only personal email channel: %v", err)
+	}
+}
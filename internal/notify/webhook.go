@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// Webhook 签名默认请求头
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+)
+
+// WebhookNotifier Webhook 通知
+type WebhookNotifier struct {
+	URL              string
+	Method           string
+	Headers          map[string]string
+	Template         *template.Template // 为空时发送默认 JSON
+	ContentType      string
+	Secret           string
+	SignatureHeader  string
+	TimestampHeader  string
+	SuccessStatusMin int
+	SuccessStatusMax int
+
+	client *http.Client // 为空时使用 notifyHTTPClient
+}
+
+func NewWebhookNotifier(config *model.WebhookConfig) *WebhookNotifier {
+	w := &WebhookNotifier{
+		URL:              config.URL,
+		Method:           strings.ToUpper(config.Method),
+		Headers:          config.Headers,
+		ContentType:      config.ContentType,
+		Secret:           config.Secret,
+		SignatureHeader:  config.SignatureHeader,
+		TimestampHeader:  config.TimestampHeader,
+		SuccessStatusMin: config.SuccessStatusMin,
+		SuccessStatusMax: config.SuccessStatusMax,
+	}
+	if w.Method == "" {
+		w.Method = "POST"
+	}
+	if w.ContentType == "" {
+		w.ContentType = "application/json"
+	}
+	if w.SignatureHeader == "" {
+		w.SignatureHeader = DefaultSignatureHeader
+	}
+	if w.TimestampHeader == "" {
+		w.TimestampHeader = DefaultTimestampHeader
+	}
+	if w.SuccessStatusMin <= 0 {
+		w.SuccessStatusMin = 200
+	}
+	if w.SuccessStatusMax <= 0 {
+		w.SuccessStatusMax = 299
+	}
+	// 模板在 ValidateChannel 中校验，这里解析失败时退回默认 JSON
+	if config.Template != "" {
+		w.Template, _ = parseWebhookTemplate(config.Template)
+	}
+	return w
+}
+
+// webhookFuncs Webhook 模板可用的函数
+var webhookFuncs = template.FuncMap{
+	// json 将值编码为 JSON (字符串会带引号并转义)
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// formatTime 按 Go 时间格式输出
+	"formatTime": func(t time.Time, layout string) string { return t.Format(layout) },
+	"unix":       func(t time.Time) int64 { return t.Unix() },
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	// value 读取告警数值，不存在时返回空
+	"value": func(values map[string]interface{}, key string) interface{} { return values[key] },
+}
+
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(webhookFuncs).Option("missingkey=zero").Parse(text)
+}
+
+func (w *WebhookNotifier) Send(title, message string) error {
+	return w.SendAlert(plainAlert(title, message))
+}
+
+func (w *WebhookNotifier) SendAlert(alert *Alert) error {
+	body, err := w.render(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(w.Method, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+
+	req.Header.Set("Content-Type", w.ContentType)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(w.TimestampHeader, timestamp)
+		req.Header.Set(w.SignatureHeader, "sha256="+WebhookSignature(w.Secret, timestamp, body))
+	}
+
+	client := w.client
+	if client == nil {
+		client = notifyHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < w.SuccessStatusMin || resp.StatusCode > w.SuccessStatusMax {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return fmt.Errorf("webhook error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// render 生成请求体: 有模板时渲染模板，否则为默认 JSON
+func (w *WebhookNotifier) render(alert *Alert) ([]byte, error) {
+	if w.Template == nil {
+		return json.Marshal(map[string]interface{}{
+			"title":     alert.Title,
+			"message":   alert.Message,
+			"timestamp": alert.Time.Unix(),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := w.Template.Execute(&buf, alert); err != nil {
+		return nil, fmt.Errorf("render webhook template failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// WebhookSignature 计算 Webhook 签名: hex(HMAC-SHA256(secret, timestamp + "." + body))
+// 接收方应同时校验时间戳与当前时间的差值以防重放
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateWebhook 校验 Webhook 配置 (模板可解析并能渲染测试告警)
+func validateWebhook(channel *model.NotifyChannel, w *WebhookNotifier) error {
+	if err := validateHTTPURL("url", w.URL); err != nil {
+		return err
+	}
+	if w.SuccessStatusMin < 100 || w.SuccessStatusMax > 599 || w.SuccessStatusMin > w.SuccessStatusMax {
+		return fmt.Errorf("成功状态码范围无效: %d-%d", w.SuccessStatusMin, w.SuccessStatusMax)
+	}
+
+	var config model.WebhookConfig
+	if err := decodeChannelConfig(channel, &config); err != nil {
+		return err
+	}
+	if config.Template == "" {
+		return nil
+	}
+	tmpl, err := parseWebhookTemplate(config.Template)
+	if err != nil {
+		return fmt.Errorf("模板语法错误: %v", err)
+	}
+	if err := tmpl.Execute(io.Discard, testAlert()); err != nil {
+		return fmt.Errorf("模板渲染失败: %v", err)
+	}
+	return nil
+}
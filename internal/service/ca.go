@@ -0,0 +1,307 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+const (
+	caValidity         = 10 * 365 * 24 * time.Hour // CA 有效期 10 年
+	nodeCertValidity   = 90 * 24 * time.Hour       // 节点证书有效期 90 天
+	nodeCertRenewAhead = 30 * 24 * time.Hour       // 过期前 30 天自动轮换
+)
+
+// caMu 防止并发注册时重复创建 CA 或重复签发证书
+var caMu sync.Mutex
+
+// NodeTLSBundle 下发给 Agent 的证书包
+type NodeTLSBundle struct {
+	CACert   string    `json:"ca_cert"`
+	Cert     string    `json:"cert"`
+	Key      string    `json:"key"`
+	Serial   string    `json:"serial"`
+	ExpireAt time.Time `json:"expire_at"`
+	// 域名暴露入口终止 TLS 使用的证书 (可选)
+	ExposureCert string `json:"exposure_cert,omitempty"`
+	ExposureKey  string `json:"exposure_key,omitempty"`
+}
+
+// GetInternalCA 获取内部 CA，不存在则创建
+func (s *Service) GetInternalCA() (*model.InternalCA, error) {
+	caMu.Lock()
+	defer caMu.Unlock()
+	return s.getOrCreateCA()
+}
+
+func (s *Service) getOrCreateCA() (*model.InternalCA, error) {
+	var ca model.InternalCA
+	if err := s.db.Order("id desc").First(&ca).Error; err == nil {
+		return &ca, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "GOST Panel Internal CA", Organization: []string{"GOST Panel"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ca = model.InternalCA{
+		CertPEM:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		KeyPEM:    keyPEM,
+		ExpireAt:  tmpl.NotAfter,
+		CreatedAt: now,
+	}
+	if err := s.db.Create(&ca).Error; err != nil {
+		return nil, err
+	}
+	return &ca, nil
+}
+
+// ErrNodeCertNotEligible 用户自有节点不签发面板证书
+var ErrNodeCertNotEligible = errors.New("node certificates are only issued to panel-managed nodes")
+
+// nodeCertEligible 节点能否持有面板签发的证书
+// 面板证书是节点间 mTLS 的信任凭据，只签发给面板管理的节点 (无所有者或属于管理员)；
+// 普通用户可以自行添加节点并拿到 Agent 令牌，签发给他们的证书会让其节点被当作本面板的节点
+func (s *Service) nodeCertEligible(node *model.Node) bool {
+	if node.OwnerID == nil {
+		return true
+	}
+	var owner model.User
+	if err := s.db.Select("id", "role").First(&owner, *node.OwnerID).Error; err != nil {
+		return false
+	}
+	return owner.Role == "admin"
+}
+
+// EnsureNodeCertificate 确保节点持有有效证书，缺失或即将过期时重新签发
+// 用户自有节点不签发证书，已持有的证书 (如节点转给普通用户前签发的) 被撤销
+// 返回 rotated=true 表示本次证书发生了变化
+func (s *Service) EnsureNodeCertificate(node *model.Node) (rotated bool, err error) {
+	caMu.Lock()
+	defer caMu.Unlock()
+
+	if !s.nodeCertEligible(node) {
+		if node.CertPEM == "" {
+			return false, nil
+		}
+		return true, s.revokeNodeCertificate(node)
+	}
+
+	// 重新读取，避免使用过期的节点数据
+	var current model.Node
+	if err := s.db.First(&current, node.ID).Error; err != nil {
+		return false, err
+	}
+	if current.CertPEM != "" && current.KeyPEM != "" && current.CertExpireAt != nil &&
+		time.Until(*current.CertExpireAt) > nodeCertRenewAhead {
+		node.CertPEM, node.KeyPEM = current.CertPEM, current.KeyPEM
+		node.CertSerial, node.CertExpireAt = current.CertSerial, current.CertExpireAt
+		return false, nil
+	}
+
+	if err := s.issueNodeCertificate(node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NodeCertNeedsRenewal 节点证书是否缺失或即将过期 (用户自有节点持有证书时需要撤销)
+func (s *Service) NodeCertNeedsRenewal(node *model.Node) bool {
+	if !s.nodeCertEligible(node) {
+		return node.CertPEM != ""
+	}
+	return node.CertPEM == "" || node.CertExpireAt == nil || time.Until(*node.CertExpireAt) <= nodeCertRenewAhead
+}
+
+// RotateNodeCertificate 强制为节点重新签发证书
+func (s *Service) RotateNodeCertificate(node *model.Node) error {
+	caMu.Lock()
+	defer caMu.Unlock()
+	if !s.nodeCertEligible(node) {
+		return ErrNodeCertNotEligible
+	}
+	return s.issueNodeCertificate(node)
+}
+
+// revokeNodeCertificate 清除节点证书，节点不再被当作本面板的节点 (不再经 mTLS 中继接入)，调用方需持有 caMu
+func (s *Service) revokeNodeCertificate(node *model.Node) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"cert_pem":       "",
+		"key_pem":        "",
+		"cert_serial":    "",
+		"cert_expire_at": nil,
+		"updated_at":     now,
+	}
+	if err := s.db.Model(&model.Node{}).Where("id = ?", node.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	log.Printf("[CA] Revoked certificate of node %d (owned by a non-admin user)", node.ID)
+
+	node.CertPEM, node.KeyPEM, node.CertSerial = "", "", ""
+	node.CertExpireAt = nil
+	node.UpdatedAt = now
+	return nil
+}
+
+// issueNodeCertificate 签发节点证书 (同时用于服务端和客户端认证)，调用方需持有 caMu
+func (s *Service) issueNodeCertificate(node *model.Node) error {
+	ca, err := s.getOrCreateCA()
+	if err != nil {
+		return err
+	}
+	caCert, caKey, err := parseCA(ca)
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("node-%d", node.ID), Organization: []string{"GOST Panel"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(nodeCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{gost.InternalServerName(node.ID)},
+	}
+	if ip := net.ParseIP(node.Host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else if node.Host != "" {
+		tmpl.DNSNames = append(tmpl.DNSNames, node.Host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return err
+	}
+
+	expireAt := tmpl.NotAfter
+	updates := map[string]interface{}{
+		"cert_pem":       string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		"key_pem":        keyPEM,
+		"cert_serial":    serial.Text(16),
+		"cert_expire_at": expireAt,
+		// 证书变更需要 Agent 重新拉取配置
+		"updated_at": now,
+	}
+	if err := s.db.Model(&model.Node{}).Where("id = ?", node.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	node.CertPEM = updates["cert_pem"].(string)
+	node.KeyPEM = keyPEM
+	node.CertSerial = serial.Text(16)
+	node.CertExpireAt = &expireAt
+	node.UpdatedAt = now
+	return nil
+}
+
+// GetNodeTLSBundle 获取节点证书包 (CA 证书 + 节点证书 + 私钥，以及域名暴露证书)
+// 用户自有节点没有节点证书，只下发域名暴露证书
+func (s *Service) GetNodeTLSBundle(node *model.Node) (*NodeTLSBundle, error) {
+	bundle := &NodeTLSBundle{}
+	if cert, err := s.GetExposureCertificate(node.ID); err == nil && cert.CertPEM != "" {
+		bundle.ExposureCert, bundle.ExposureKey = cert.CertPEM, cert.KeyPEM
+	}
+	if node.CertPEM == "" || node.KeyPEM == "" || node.CertExpireAt == nil {
+		if bundle.ExposureCert == "" {
+			return nil, errors.New("node certificate not issued")
+		}
+		return bundle, nil
+	}
+
+	ca, err := s.GetInternalCA()
+	if err != nil {
+		return nil, err
+	}
+	bundle.CACert = ca.CertPEM
+	bundle.Cert, bundle.Key = node.CertPEM, node.KeyPEM
+	bundle.Serial, bundle.ExpireAt = node.CertSerial, *node.CertExpireAt
+	return bundle, nil
+}
+
+// ==================== 证书辅助函数 ====================
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodeECKey(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+func parseCA(ca *model.InternalCA) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode([]byte(ca.CertPEM))
+	if certBlock == nil {
+		return nil, nil, errors.New("invalid CA certificate")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBlock, _ := pem.Decode([]byte(ca.KeyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("invalid CA key")
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
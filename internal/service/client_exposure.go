@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 客户端暴露 ====================
+
+// exposureProtocols 暴露支持的协议
+var exposureProtocols = map[string]bool{"tcp": true, "udp": true, "http": true}
+
+// hostnamePattern 域名 (允许 *. 通配前缀)
+var hostnamePattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9-]{2,63}$`)
+
+// ListClientExposures 客户端的暴露列表
+func (s *Service) ListClientExposures(clientID uint) ([]model.ClientExposure, error) {
+	var exposures []model.ClientExposure
+	err := s.db.Where("client_id = ?", clientID).Order("id ASC").Find(&exposures).Error
+	return exposures, err
+}
+
+// GetClientExposure 获取客户端的暴露
+func (s *Service) GetClientExposure(clientID, id uint) (*model.ClientExposure, error) {
+	var exposure model.ClientExposure
+	if err := s.db.Where("id = ? AND client_id = ?", id, clientID).First(&exposure).Error; err != nil {
+		return nil, err
+	}
+	return &exposure, nil
+}
+
+// NormalizeClientExposure 校验暴露: 局域网目标地址、远程端口或域名
+// tcp/udp 必须映射远程端口；http 填写域名时经节点的 HTTP/HTTPS 入口发布，否则映射远程端口
+func (s *Service) NormalizeClientExposure(client *model.Client, exposure *model.ClientExposure) error {
+	exposure.Name = strings.TrimSpace(exposure.Name)
+	if exposure.Name == "" {
+		return errors.New("请填写暴露名称")
+	}
+	if exposure.Protocol == "" {
+		exposure.Protocol = "tcp"
+	}
+	if !exposureProtocols[exposure.Protocol] {
+		return fmt.Errorf("不支持的协议: %s", exposure.Protocol)
+	}
+
+	exposure.LocalAddr = strings.TrimSpace(exposure.LocalAddr)
+	host, port, err := net.SplitHostPort(exposure.LocalAddr)
+	if err != nil || host == "" {
+		return errors.New("本地目标地址格式应为 host:port")
+	}
+	if p, err := net.LookupPort("tcp", port); err != nil || p < 1 {
+		return fmt.Errorf("无效的本地目标端口: %s", port)
+	}
+
+	exposure.Hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(exposure.Hostname)), ".")
+	if exposure.Protocol != "http" {
+		exposure.Hostname = ""
+	}
+	if exposure.Hostname != "" {
+		exposure.RemotePort = 0
+		if err := normalizeExposureAccess(exposure); err != nil {
+			return err
+		}
+		return s.checkExposureHostname(client, exposure)
+	}
+	// TLS、认证与来源限制只对按域名发布的暴露生效
+	exposure.TLSMode, exposure.AuthUser, exposure.AuthPass, exposure.AllowIPs = "", "", "", ""
+
+	if exposure.RemotePort < 1 || exposure.RemotePort > 65535 {
+		return fmt.Errorf("无效的远程端口: %d", exposure.RemotePort)
+	}
+	// 客户端自身的远程端口同时承载 TCP 与 UDP，同协议的暴露不能共用远程端口
+	if exposure.RemotePort == client.RemotePort {
+		return fmt.Errorf("远程端口 %d 已被客户端的代理服务使用", exposure.RemotePort)
+	}
+	for _, other := range client.Exposures {
+		if other.ID == exposure.ID || !other.Enabled || other.RemotePort != exposure.RemotePort {
+			continue
+		}
+		if (other.Protocol == "udp") == (exposure.Protocol == "udp") {
+			return fmt.Errorf("远程端口 %d 已被暴露「%s」使用", exposure.RemotePort, other.Name)
+		}
+	}
+	if !exposure.Enabled {
+		return nil
+	}
+	return s.CheckNodePorts(client.NodeID, []int{exposure.RemotePort}, portResourceClient, client.ID, client.OwnerID)
+}
+
+// normalizeExposureAccess 校验域名暴露的 TLS 模式、Basic 认证与来源 IP 白名单
+func normalizeExposureAccess(exposure *model.ClientExposure) error {
+	switch exposure.TLSMode {
+	case gost.ExposureTLSNone, gost.ExposureTLSPassthrough:
+	case gost.ExposureTLSTerminate:
+		// HTTP-01 验证无法签发通配符证书
+		if strings.HasPrefix(exposure.Hostname, "*.") {
+			return errors.New("由节点终止 TLS 的暴露不支持通配符域名")
+		}
+	default:
+		return fmt.Errorf("不支持的 TLS 模式: %s", exposure.TLSMode)
+	}
+
+	exposure.AuthUser = strings.TrimSpace(exposure.AuthUser)
+	if exposure.AuthUser == "" {
+		exposure.AuthPass = ""
+	} else {
+		// SNI 透传时节点看不到 HTTP 请求，无法校验认证
+		if exposure.TLSMode == gost.ExposureTLSPassthrough {
+			return errors.New("TLS 透传的暴露不支持 Basic 认证")
+		}
+		if strings.Contains(exposure.AuthUser, ":") {
+			return errors.New("认证用户名不能包含冒号")
+		}
+		if exposure.AuthPass == "" {
+			return errors.New("请填写认证密码")
+		}
+	}
+
+	ips, err := gost.ParseAllowIPs(exposure.AllowIPs)
+	if err != nil {
+		return err
+	}
+	exposure.AllowIPs = strings.Join(ips, ",")
+	return nil
+}
+
+// checkExposureHostname 域名发布需要节点启用对应的 HTTP/HTTPS 入口，且域名在节点上唯一
+func (s *Service) checkExposureHostname(client *model.Client, exposure *model.ClientExposure) error {
+	if !hostnamePattern.MatchString(exposure.Hostname) {
+		return fmt.Errorf("无效的域名: %s", exposure.Hostname)
+	}
+	node, err := s.GetNode(client.NodeID)
+	if err != nil {
+		return errors.New("node not found")
+	}
+	if exposure.TLSMode == gost.ExposureTLSNone && node.ExposeHTTPPort == 0 {
+		return errors.New("节点未启用域名暴露 HTTP 入口")
+	}
+	if exposure.TLSMode != gost.ExposureTLSNone && node.ExposeHTTPSPort == 0 {
+		return errors.New("节点未启用域名暴露 HTTPS 入口")
+	}
+
+	var count int64
+	s.db.Model(&model.ClientExposure{}).
+		Joins("JOIN clients ON clients.id = client_exposures.client_id").
+		Where("clients.node_id = ? AND client_exposures.hostname = ? AND client_exposures.id <> ?", client.NodeID, exposure.Hostname, exposure.ID).
+		Count(&count)
+	if count > 0 {
+		return fmt.Errorf("域名 %s 已在该节点上发布", exposure.Hostname)
+	}
+	return nil
+}
+
+// CreateClientExposure 创建暴露，与客户端端口分配在同一事务中完成
+func (s *Service) CreateClientExposure(client *model.Client, exposure *model.ClientExposure) error {
+	exposure.ClientID = client.ID
+	enabled := exposure.Enabled
+	return s.saveClientExposure(client, exposure, func(tx *gorm.DB) error {
+		if err := tx.Create(exposure).Error; err != nil {
+			return err
+		}
+		// enabled 有默认值，创建时为 false 会被忽略
+		if !enabled {
+			exposure.Enabled = false
+			return tx.Model(exposure).Update("enabled", false).Error
+		}
+		return nil
+	})
+}
+
+// UpdateClientExposure 更新暴露
+func (s *Service) UpdateClientExposure(client *model.Client, exposure *model.ClientExposure) error {
+	return s.saveClientExposure(client, exposure, func(tx *gorm.DB) error {
+		return tx.Model(exposure).Select("name", "protocol", "local_addr", "remote_port", "hostname", "tls_mode", "auth_user", "auth_pass", "allow_ips", "enabled").Updates(exposure).Error
+	})
+}
+
+// DeleteClientExposure 删除暴露并释放端口
+func (s *Service) DeleteClientExposure(client *model.Client, exposure *model.ClientExposure) error {
+	return s.saveClientExposure(client, exposure, func(tx *gorm.DB) error {
+		return tx.Delete(exposure).Error
+	})
+}
+
+// saveClientExposure 写入暴露后重新分配客户端端口，并更新客户端配置版本
+// 域名暴露变更时标记节点待同步
+func (s *Service) saveClientExposure(client *model.Client, exposure *model.ClientExposure, write func(tx *gorm.DB) error) error {
+	var previous model.ClientExposure
+	if exposure.ID != 0 {
+		s.db.First(&previous, exposure.ID)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		current := *client
+		if err := tx.Where("client_id = ?", client.ID).Find(&current.Exposures).Error; err != nil {
+			return err
+		}
+		if err := allocatePorts(tx, portResourceClient, client.ID, client.Name, client.OwnerID, clientPortClaims(&current)); err != nil {
+			return err
+		}
+		return tx.Model(&model.Client{}).Where("id = ?", client.ID).Update("updated_at", time.Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if previous.Hostname != "" || exposure.Hostname != "" {
+		s.MarkNodesConfigPending(client.NodeID)
+	}
+	if previous.TLSMode == gost.ExposureTLSTerminate || exposure.TLSMode == gost.ExposureTLSTerminate {
+		if err := s.EnsureExposureCertificate(client.NodeID); err != nil {
+			log.Printf("[ExposureCert] Node %d: %v", client.NodeID, err)
+		}
+	}
+	return nil
+}
+
+// NodeHostExposureClients 绑定节点且有启用的域名暴露的客户端 (只加载域名暴露)
+func (s *Service) NodeHostExposureClients(nodeID uint) ([]model.Client, error) {
+	var clients []model.Client
+	err := s.db.Where("node_id = ?", nodeID).
+		Where("id IN (?)", s.db.Model(&model.ClientExposure{}).Select("client_id").Where("enabled = ? AND hostname <> ''", true)).
+		Preload("Exposures", "enabled = ? AND hostname <> ''", true).
+		Find(&clients).Error
+	return clients, err
+}
+
+// markHostExposureNodesPending 客户端有域名暴露时，标记其当前节点与 extra 节点待同步，并确保当前节点的暴露证书
+func (s *Service) markHostExposureNodesPending(clientID uint, extra ...uint) {
+	var count int64
+	s.db.Model(&model.ClientExposure{}).Where("client_id = ? AND hostname <> ''", clientID).Count(&count)
+	if count == 0 {
+		return
+	}
+	var client model.Client
+	if err := s.db.Select("id", "node_id").First(&client, clientID).Error; err != nil {
+		return
+	}
+	s.MarkNodesConfigPending(append(extra, client.NodeID)...)
+	if err := s.EnsureExposureCertificate(client.NodeID); err != nil {
+		log.Printf("[ExposureCert] Node %d: %v", client.NodeID, err)
+	}
+}
+
+// UpdateExposureTraffic 记录客户端 Agent 上报的暴露流量 (增量)
+func (s *Service) UpdateExposureTraffic(clientID, exposureID uint, trafficIn, trafficOut int64) error {
+	if trafficIn <= 0 && trafficOut <= 0 {
+		return nil
+	}
+	return s.db.Model(&model.ClientExposure{}).Where("id = ? AND client_id = ?", exposureID, clientID).UpdateColumns(map[string]interface{}{
+		"traffic_in":  gorm.Expr("traffic_in + ?", trafficIn),
+		"traffic_out": gorm.Expr("traffic_out + ?", trafficOut),
+	}).Error
+}
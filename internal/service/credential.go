@@ -0,0 +1,246 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 用户代理凭据 (共享节点) ====================
+
+// proxyAuthCacheTTL 共享节点认证结果的缓存时间
+// GOST 每个代理连接都会回调认证插件，缓存避免每次连接都查询凭据、用户与套餐；
+// 凭据变更与超限处理变化时清空缓存，其他变化 (如禁用用户、套餐到期) 最迟在 TTL 后生效
+const proxyAuthCacheTTL = 30 * time.Second
+
+// errInvalidProxyCredential 用户名或密码错误 (不缓存，缓存只包含正确的密码，不会因暴力尝试而增长)
+var errInvalidProxyCredential = errors.New("invalid credential")
+
+type proxyAuthResult struct {
+	cred      *model.ProxyCredential
+	err       error
+	expiresAt time.Time
+}
+
+// ListProxyCredentials 获取代理凭据列表
+func (s *Service) ListProxyCredentials(userID uint, isAdmin bool) ([]model.ProxyCredential, error) {
+	var creds []model.ProxyCredential
+	query := s.db.Order("id desc")
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	err := query.Find(&creds).Error
+	return creds, err
+}
+
+// GetProxyCredentialByOwner 获取代理凭据 (带权限检查)
+func (s *Service) GetProxyCredentialByOwner(id uint, userID uint, isAdmin bool) (*model.ProxyCredential, error) {
+	var cred model.ProxyCredential
+	query := s.db.Where("id = ?", id)
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&cred).Error; err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// CreateProxyCredential 创建代理凭据，未指定用户名/密码时自动生成
+func (s *Service) CreateProxyCredential(cred *model.ProxyCredential) error {
+	if cred.Username == "" {
+		cred.Username = "u" + randomHex(6)
+	}
+	if cred.Password == "" {
+		cred.Password = randomHex(12)
+	}
+
+	var count int64
+	s.db.Model(&model.ProxyCredential{}).Where("username = ?", cred.Username).Count(&count)
+	if count > 0 {
+		return errors.New("用户名已存在")
+	}
+
+	cred.CreatedAt = time.Now()
+	cred.UpdatedAt = time.Now()
+	return s.db.Create(cred).Error
+}
+
+// UpdateProxyCredential 更新代理凭据
+func (s *Service) UpdateProxyCredential(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	defer s.proxyAuthCache.Clear()
+	return s.db.Model(&model.ProxyCredential{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteProxyCredential 删除代理凭据
+func (s *Service) DeleteProxyCredential(id uint) error {
+	defer s.proxyAuthCache.Clear()
+	return s.db.Delete(&model.ProxyCredential{}, id).Error
+}
+
+// ResetProxyCredentialPassword 重新生成凭据密码
+func (s *Service) ResetProxyCredentialPassword(id uint) (string, error) {
+	password := randomHex(12)
+	defer s.proxyAuthCache.Clear()
+	err := s.db.Model(&model.ProxyCredential{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":   password,
+		"updated_at": time.Now(),
+	}).Error
+	return password, err
+}
+
+// AuthenticateProxyCredential 校验共享节点上的用户凭据 (结果按节点与凭据缓存 proxyAuthCacheTTL)
+func (s *Service) AuthenticateProxyCredential(node *model.Node, username, password string) (*model.ProxyCredential, error) {
+	if !node.SharedAuth || !gost.SharedAuthSupported(node.Protocol) {
+		return nil, errors.New("node is not shared")
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	key := fmt.Sprintf("%d|%s|%x", node.ID, username, sum)
+	if v, ok := s.proxyAuthCache.Load(key); ok {
+		if result := v.(proxyAuthResult); time.Now().Before(result.expiresAt) {
+			return result.cred, result.err
+		}
+		s.proxyAuthCache.Delete(key)
+	}
+
+	cred, err := s.authenticateProxyCredential(node, username, password)
+	if err != errInvalidProxyCredential {
+		s.proxyAuthCache.Store(key, proxyAuthResult{cred: cred, err: err, expiresAt: time.Now().Add(proxyAuthCacheTTL)})
+	}
+	return cred, err
+}
+
+// authenticateProxyCredential 校验顺序: 凭据有效 -> 节点范围 -> 用户启用 -> 未被超限阻断/套餐未过期未超限 -> 节点访问权限
+func (s *Service) authenticateProxyCredential(node *model.Node, username, password string) (*model.ProxyCredential, error) {
+	var cred model.ProxyCredential
+	if err := s.db.Where("username = ?", username).First(&cred).Error; err != nil {
+		return nil, errInvalidProxyCredential
+	}
+	if subtle.ConstantTimeCompare([]byte(cred.Password), []byte(password)) != 1 {
+		return nil, errInvalidProxyCredential
+	}
+	if !cred.Enabled {
+		return nil, errors.New("credential disabled")
+	}
+	if cred.NodeID != nil && *cred.NodeID != node.ID {
+		return nil, errors.New("credential not valid on this node")
+	}
+
+	var user model.User
+	if err := s.db.First(&user, cred.UserID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.Enabled {
+		return nil, errors.New("user disabled")
+	}
+	// 超限: 仅阻断时拒绝，限速由限速插件处理，仅通知时放行
+	if user.QuotaEnforced == model.QuotaActionBlock {
+		return nil, errors.New("quota exceeded")
+	}
+
+	expired, exceeded, err := s.CheckUserPlanStatus(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, errors.New("plan expired")
+	}
+	if exceeded {
+		return nil, errors.New("plan traffic exceeded")
+	}
+
+	if !s.canUseSharedNode(&user, node) {
+		return nil, errors.New("node not allowed")
+	}
+
+	return &cred, nil
+}
+
+// canUseSharedNode 用户是否可以使用共享节点
+// 管理员和节点所有者始终允许；其他用户需要有套餐，且套餐未绑定节点或绑定了该节点
+func (s *Service) canUseSharedNode(user *model.User, node *model.Node) bool {
+	if user.Role == "admin" {
+		return true
+	}
+	if node.OwnerID != nil && *node.OwnerID == user.ID {
+		return true
+	}
+	if user.PlanID == nil {
+		return false
+	}
+	nodeIDs, err := s.GetPlanResourceIDs(*user.PlanID, "node")
+	if err != nil {
+		return false
+	}
+	if len(nodeIDs) == 0 {
+		return true
+	}
+	for _, id := range nodeIDs {
+		if id == node.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialClientID 认证成功后返回给 GOST 的客户端标识 (用于流量观测)
+func CredentialClientID(credID uint) string {
+	return fmt.Sprintf("cred-%d", credID)
+}
+
+// ParseCredentialClientID 从客户端标识解析凭据 ID
+func ParseCredentialClientID(clientID string) uint {
+	var id uint
+	if n, _ := fmt.Sscanf(clientID, "cred-%d", &id); n == 1 {
+		return id
+	}
+	return 0
+}
+
+// AddCredentialTraffic 累加凭据流量，并计入用户配额/套餐流量
+// 返回凭据所属用户 ID
+func (s *Service) AddCredentialTraffic(credID uint, trafficIn, trafficOut int64) (uint, error) {
+	var cred model.ProxyCredential
+	if err := s.db.First(&cred, credID).Error; err != nil {
+		return 0, err
+	}
+	if trafficIn <= 0 && trafficOut <= 0 {
+		return cred.UserID, nil
+	}
+
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.ProxyCredential{}).Where("id = ?", credID).Updates(map[string]interface{}{
+			"traffic_in":   gorm.Expr("traffic_in + ?", trafficIn),
+			"traffic_out":  gorm.Expr("traffic_out + ?", trafficOut),
+			"last_used_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ? AND plan_id IS NOT NULL", cred.UserID).
+			Update("plan_traffic_used", gorm.Expr("plan_traffic_used + ?", trafficIn+trafficOut)).Error
+	})
+	if err != nil {
+		return cred.UserID, err
+	}
+
+	// 凭据流量计入用户本周期配额用量
+	s.addUserQuotaUsage(cred.UserID, trafficIn+trafficOut)
+	return cred.UserID, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
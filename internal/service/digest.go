@@ -0,0 +1,628 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/notify"
+)
+
+const (
+	trafficSnapshotRetention = 15 * 24 * time.Hour // 覆盖每周摘要所需的快照
+	digestExpiryWindow       = 7 * 24 * time.Hour  // 报告未来 7 天内到期的套餐
+	digestQuotaPercent       = 80                  // 报告用量达到该百分比的配额
+	digestDefaultTopN        = 5
+)
+
+// ==================== 流量快照 ====================
+
+type trafficKey struct {
+	Type string
+	ID   uint
+}
+
+type trafficCounter struct {
+	Name string
+	In   int64
+	Out  int64
+}
+
+// currentTrafficCounters 节点 / 隧道 / 用户的当前流量累计值
+func (s *Service) currentTrafficCounters() map[trafficKey]trafficCounter {
+	counters := make(map[trafficKey]trafficCounter)
+
+	var nodes []model.Node
+	s.db.Select("id, name, traffic_in, traffic_out").Find(&nodes)
+	for _, node := range nodes {
+		counters[trafficKey{"node", node.ID}] = trafficCounter{node.Name, node.TrafficIn, node.TrafficOut}
+	}
+
+	var tunnels []model.Tunnel
+	s.db.Select("id, name, traffic_in, traffic_out").Find(&tunnels)
+	for _, tunnel := range tunnels {
+		counters[trafficKey{"tunnel", tunnel.ID}] = trafficCounter{tunnel.Name, tunnel.TrafficIn, tunnel.TrafficOut}
+	}
+
+	var users []model.User
+	s.db.Select("id, username").Find(&users)
+	for _, user := range users {
+		summary, err := s.GetUserTrafficSummary(user.ID)
+		if err != nil {
+			continue
+		}
+		counters[trafficKey{"user", user.ID}] = trafficCounter{user.Username, summary.TotalTrafficIn, summary.TotalTrafficOut}
+	}
+	return counters
+}
+
+// RecordTrafficSnapshots 每小时记录一次流量累计值快照，并清理过期快照
+func (s *Service) RecordTrafficSnapshots(now time.Time) {
+	var count int64
+	s.db.Model(&model.TrafficSnapshot{}).Where("recorded_at >= ?", now.Truncate(time.Hour)).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	var snapshots []model.TrafficSnapshot
+	for key, counter := range s.currentTrafficCounters() {
+		snapshots = append(snapshots, model.TrafficSnapshot{
+			TargetType: key.Type,
+			TargetID:   key.ID,
+			TrafficIn:  counter.In,
+			TrafficOut: counter.Out,
+			RecordedAt: now,
+		})
+	}
+	if len(snapshots) > 0 {
+		if err := s.db.CreateInBatches(snapshots, 200).Error; err != nil {
+			log.Printf("[Digest] Record traffic snapshots failed: %v", err)
+		}
+	}
+	s.db.Where("recorded_at < ?", now.Add(-trafficSnapshotRetention)).Delete(&model.TrafficSnapshot{})
+}
+
+// trafficBaseline 周期开始时的流量快照: 优先取 from 之前最近一次，快照不足一个周期时取最早一次
+// 返回快照时间，没有任何快照时返回 nil
+func (s *Service) trafficBaseline(from time.Time) (map[trafficKey]model.TrafficSnapshot, *time.Time) {
+	var snapshot model.TrafficSnapshot
+	err := s.db.Where("recorded_at <= ?", from).Order("recorded_at desc").First(&snapshot).Error
+	if err != nil {
+		if err := s.db.Order("recorded_at asc").First(&snapshot).Error; err != nil {
+			return nil, nil
+		}
+	}
+
+	var snapshots []model.TrafficSnapshot
+	s.db.Where("recorded_at = ?", snapshot.RecordedAt).Find(&snapshots)
+	baseline := make(map[trafficKey]model.TrafficSnapshot, len(snapshots))
+	for _, snap := range snapshots {
+		baseline[trafficKey{snap.TargetType, snap.TargetID}] = snap
+	}
+	since := snapshot.RecordedAt
+	return baseline, &since
+}
+
+// ==================== 摘要报告 ====================
+
+// DigestTrafficItem 流量排行条目
+type DigestTrafficItem struct {
+	Name string
+	In   int64
+	Out  int64
+}
+
+// Total 总流量
+func (i DigestTrafficItem) Total() int64 {
+	return i.In + i.Out
+}
+
+// DigestHealthItem 节点可用性条目
+type DigestHealthItem struct {
+	Name         string
+	Checks       int
+	Failures     int
+	Availability float64 // 百分比
+	AvgLatency   int     // ms，仅统计健康的检查
+}
+
+// DigestQuotaItem 配额使用条目
+type DigestQuotaItem struct {
+	Type    string // 节点/用户
+	Name    string
+	Used    int64
+	Quota   int64
+	Percent float64
+}
+
+// DigestExpiryItem 套餐到期条目
+type DigestExpiryItem struct {
+	Username string
+	Plan     string
+	ExpireAt time.Time
+}
+
+// DigestUserItem 新用户条目
+type DigestUserItem struct {
+	Username  string
+	Email     string
+	Role      string
+	CreatedAt time.Time
+}
+
+// DigestReport 摘要报告内容
+type DigestReport struct {
+	Title        string
+	From         time.Time
+	To           time.Time
+	TrafficSince *time.Time // 流量统计起点，快照不足一个周期时晚于 From，nil=暂无快照
+	TotalIn      int64
+	TotalOut     int64
+	TopNodes     []DigestTrafficItem
+	TopTunnels   []DigestTrafficItem
+	TopUsers     []DigestTrafficItem
+	WorstNodes   []DigestHealthItem
+	QuotaAlerts  []DigestQuotaItem
+	PlanExpiries []DigestExpiryItem
+	NewUsers     []DigestUserItem
+}
+
+// digestPeriod 报告周期
+func digestPeriod(frequency string) time.Duration {
+	if frequency == model.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// digestTitle 报告标题
+func digestTitle(frequency string, from, to time.Time) string {
+	if frequency == model.DigestWeekly {
+		return fmt.Sprintf("每周摘要 %s ~ %s", from.Format("01-02"), to.Format("01-02"))
+	}
+	return fmt.Sprintf("每日摘要 %s", to.Format("2006-01-02"))
+}
+
+// BuildDigestReport 生成 [to-周期, to] 的摘要报告
+func (s *Service) BuildDigestReport(frequency string, to time.Time, topN int) *DigestReport {
+	if topN <= 0 {
+		topN = digestDefaultTopN
+	}
+	from := to.Add(-digestPeriod(frequency))
+	report := &DigestReport{
+		Title: digestTitle(frequency, from, to),
+		From:  from,
+		To:    to,
+	}
+
+	s.fillDigestTraffic(report, topN)
+	s.fillDigestHealth(report, topN)
+	s.fillDigestQuotas(report)
+
+	var expiring []model.User
+	s.db.Preload("Plan").
+		Where("plan_expire_at IS NOT NULL AND plan_expire_at > ? AND plan_expire_at <= ?", to, to.Add(digestExpiryWindow)).
+		Order("plan_expire_at asc").Find(&expiring)
+	for _, user := range expiring {
+		item := DigestExpiryItem{Username: user.Username, ExpireAt: *user.PlanExpireAt}
+		if user.Plan != nil {
+			item.Plan = user.Plan.Name
+		}
+		report.PlanExpiries = append(report.PlanExpiries, item)
+	}
+
+	var newUsers []model.User
+	s.db.Where("created_at > ? AND created_at <= ?", from, to).Order("created_at asc").Find(&newUsers)
+	for _, user := range newUsers {
+		item := DigestUserItem{Username: user.Username, Role: user.Role, CreatedAt: user.CreatedAt}
+		if user.Email != nil {
+			item.Email = *user.Email
+		}
+		report.NewUsers = append(report.NewUsers, item)
+	}
+	return report
+}
+
+// fillDigestTraffic 周期流量 = 当前累计值 - 周期开始时的快照 (计数器重置时取当前值)
+func (s *Service) fillDigestTraffic(report *DigestReport, topN int) {
+	baseline, since := s.trafficBaseline(report.From)
+	if since == nil {
+		return
+	}
+	report.TrafficSince = since
+
+	ranked := make(map[string][]DigestTrafficItem)
+	for key, counter := range s.currentTrafficCounters() {
+		item := DigestTrafficItem{Name: counter.Name, In: counter.In, Out: counter.Out}
+		if base, ok := baseline[key]; ok && counter.In >= base.TrafficIn && counter.Out >= base.TrafficOut {
+			item.In -= base.TrafficIn
+			item.Out -= base.TrafficOut
+		}
+		if key.Type == "node" {
+			report.TotalIn += item.In
+			report.TotalOut += item.Out
+		}
+		if item.Total() > 0 {
+			ranked[key.Type] = append(ranked[key.Type], item)
+		}
+	}
+
+	top := func(items []DigestTrafficItem) []DigestTrafficItem {
+		sort.Slice(items, func(i, j int) bool { return items[i].Total() > items[j].Total() })
+		if len(items) > topN {
+			items = items[:topN]
+		}
+		return items
+	}
+	report.TopNodes = top(ranked["node"])
+	report.TopTunnels = top(ranked["tunnel"])
+	report.TopUsers = top(ranked["user"])
+}
+
+// fillDigestHealth 按可用性 (低优先) 与平均延迟 (高优先) 排出表现最差的节点
+func (s *Service) fillDigestHealth(report *DigestReport, topN int) {
+	var rows []struct {
+		NodeID     uint
+		Checks     int
+		Failures   int
+		AvgLatency float64
+	}
+	s.db.Model(&model.HealthCheckLog{}).
+		Select("node_id, COUNT(*) as checks, "+
+			"SUM(CASE WHEN status = 'healthy' THEN 0 ELSE 1 END) as failures, "+
+			"COALESCE(AVG(CASE WHEN status = 'healthy' THEN latency END), 0) as avg_latency").
+		Where("checked_at > ? AND checked_at <= ?", report.From, report.To).
+		Group("node_id").Scan(&rows)
+
+	for _, row := range rows {
+		if row.Checks == 0 {
+			continue
+		}
+		var node model.Node
+		if err := s.db.Select("id, name").First(&node, row.NodeID).Error; err != nil {
+			continue
+		}
+		report.WorstNodes = append(report.WorstNodes, DigestHealthItem{
+			Name:         node.Name,
+			Checks:       row.Checks,
+			Failures:     row.Failures,
+			Availability: float64(row.Checks-row.Failures) / float64(row.Checks) * 100,
+			AvgLatency:   int(row.AvgLatency),
+		})
+	}
+
+	sort.Slice(report.WorstNodes, func(i, j int) bool {
+		a, b := report.WorstNodes[i], report.WorstNodes[j]
+		if a.Availability != b.Availability {
+			return a.Availability < b.Availability
+		}
+		return a.AvgLatency > b.AvgLatency
+	})
+	if len(report.WorstNodes) > topN {
+		report.WorstNodes = report.WorstNodes[:topN]
+	}
+}
+
+// fillDigestQuotas 用量达到阈值的节点与用户配额
+func (s *Service) fillDigestQuotas(report *DigestReport) {
+	var nodes []model.Node
+	s.db.Where("traffic_quota > 0 AND quota_used * 100 >= traffic_quota * ?", digestQuotaPercent).Find(&nodes)
+	for _, node := range nodes {
+		report.QuotaAlerts = append(report.QuotaAlerts, DigestQuotaItem{
+			Type: "节点", Name: node.Name, Used: node.QuotaUsed, Quota: node.TrafficQuota,
+			Percent: float64(node.QuotaUsed) / float64(node.TrafficQuota) * 100,
+		})
+	}
+
+	var users []model.User
+	s.db.Where("traffic_quota > 0 AND quota_used * 100 >= traffic_quota * ?", digestQuotaPercent).Find(&users)
+	for _, user := range users {
+		report.QuotaAlerts = append(report.QuotaAlerts, DigestQuotaItem{
+			Type: "用户", Name: user.Username, Used: user.QuotaUsed, Quota: user.TrafficQuota,
+			Percent: float64(user.QuotaUsed) / float64(user.TrafficQuota) * 100,
+		})
+	}
+
+	sort.Slice(report.QuotaAlerts, func(i, j int) bool {
+		return report.QuotaAlerts[i].Percent > report.QuotaAlerts[j].Percent
+	})
+}
+
+// ==================== 报告渲染 ====================
+
+var digestFuncs = template.FuncMap{
+	"bytes": notify.FormatBytes,
+	"time":  func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+	"pct":   func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+}
+
+var digestHTMLTemplate = template.Must(template.New("digest").Funcs(digestFuncs).Parse(`
+{{define "traffic"}}{{if .}}<table>
+<tr><th>名称</th><th>入站</th><th>出站</th><th>合计</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{bytes .In}}</td><td>{{bytes .Out}}</td><td>{{bytes .Total}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">无流量</p>{{end}}{{end}}
+<p class="muted">统计周期: {{time .From}} ~ {{time .To}}</p>
+
+<h2>流量</h2>
+{{if .TrafficSince}}
+<p>节点总流量: 入站 {{bytes .TotalIn}} / 出站 {{bytes .TotalOut}}{{if .TrafficSince.After .From}} <span class="muted">(自 {{time .TrafficSince}} 起统计)</span>{{end}}</p>
+<h3>节点 Top</h3>
+{{template "traffic" .TopNodes}}
+<h3>隧道 Top</h3>
+{{template "traffic" .TopTunnels}}
+<h3>用户 Top</h3>
+{{template "traffic" .TopUsers}}
+{{else}}<p class="muted">暂无流量快照，下一份报告开始统计</p>{{end}}
+
+<h2>节点可用性 (最差)</h2>
+{{if .WorstNodes}}<table>
+<tr><th>节点</th><th>可用率</th><th>失败 / 检查</th><th>平均延迟</th></tr>
+{{range .WorstNodes}}<tr><td>{{.Name}}</td><td>{{pct .Availability}}</td><td>{{.Failures}} / {{.Checks}}</td><td>{{.AvgLatency}} ms</td></tr>
+{{end}}</table>{{else}}<p class="muted">周期内无健康检查记录</p>{{end}}
+
+<h2>配额预警 (≥ 80%)</h2>
+{{if .QuotaAlerts}}<table>
+<tr><th>类型</th><th>名称</th><th>已用 / 配额</th><th>使用率</th></tr>
+{{range .QuotaAlerts}}<tr><td>{{.Type}}</td><td>{{.Name}}</td><td>{{bytes .Used}} / {{bytes .Quota}}</td><td>{{pct .Percent}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">无</p>{{end}}
+
+<h2>即将到期的套餐 (7 天内)</h2>
+{{if .PlanExpiries}}<table>
+<tr><th>用户</th><th>套餐</th><th>到期时间</th></tr>
+{{range .PlanExpiries}}<tr><td>{{.Username}}</td><td>{{.Plan}}</td><td>{{time .ExpireAt}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">无</p>{{end}}
+
+<h2>新用户</h2>
+{{if .NewUsers}}<table>
+<tr><th>用户名</th><th>邮箱</th><th>角色</th><th>注册时间</th></tr>
+{{range .NewUsers}}<tr><td>{{.Username}}</td><td>{{.Email}}</td><td>{{.Role}}</td><td>{{time .CreatedAt}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">无</p>{{end}}
+`))
+
+// RenderDigestHTML 渲染 HTML 报告正文 (邮件使用)
+func RenderDigestHTML(report *DigestReport) (string, error) {
+	var buf bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderDigestText 渲染文本报告 (通知渠道使用)
+func RenderDigestText(report *DigestReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "统计周期: %s ~ %s\n", report.From.Format("2006-01-02 15:04"), report.To.Format("2006-01-02 15:04"))
+
+	writeTraffic := func(title string, items []DigestTrafficItem) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for i, item := range items {
+			fmt.Fprintf(&b, "%d. %s  %s\n", i+1, item.Name, notify.FormatBytes(item.Total()))
+		}
+	}
+	if report.TrafficSince != nil {
+		fmt.Fprintf(&b, "\n节点总流量: 入站 %s / 出站 %s\n", notify.FormatBytes(report.TotalIn), notify.FormatBytes(report.TotalOut))
+		writeTraffic("节点 Top", report.TopNodes)
+		writeTraffic("隧道 Top", report.TopTunnels)
+		writeTraffic("用户 Top", report.TopUsers)
+	}
+
+	if len(report.WorstNodes) > 0 {
+		b.WriteString("\n节点可用性 (最差):\n")
+		for _, item := range report.WorstNodes {
+			fmt.Fprintf(&b, "- %s  可用率 %.1f%%  平均延迟 %d ms\n", item.Name, item.Availability, item.AvgLatency)
+		}
+	}
+	if len(report.QuotaAlerts) > 0 {
+		b.WriteString("\n配额预警:\n")
+		for _, item := range report.QuotaAlerts {
+			fmt.Fprintf(&b, "- %s %s  %.1f%% (%s / %s)\n", item.Type, item.Name, item.Percent,
+				notify.FormatBytes(item.Used), notify.FormatBytes(item.Quota))
+		}
+	}
+	if len(report.PlanExpiries) > 0 {
+		b.WriteString("\n即将到期的套餐:\n")
+		for _, item := range report.PlanExpiries {
+			fmt.Fprintf(&b, "- %s %s  %s\n", item.Username, item.Plan, item.ExpireAt.Format("2006-01-02 15:04"))
+		}
+	}
+	fmt.Fprintf(&b, "\n新用户: %d", len(report.NewUsers))
+	for _, item := range report.NewUsers {
+		fmt.Fprintf(&b, "\n- %s (%s)", item.Username, item.Role)
+	}
+	return b.String()
+}
+
+// ==================== 发送计划 ====================
+
+func digestLocation(schedule *model.DigestSchedule) (*time.Location, error) {
+	if schedule.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(schedule.Timezone)
+}
+
+// digestSlot 计划在 now 及之前最近一次的发送时间
+func digestSlot(schedule *model.DigestSchedule, now time.Time) time.Time {
+	loc, err := digestLocation(schedule)
+	if err != nil {
+		loc = time.Local
+	}
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), schedule.Hour, 0, 0, 0, loc)
+
+	if schedule.Frequency == model.DigestWeekly {
+		slot = slot.AddDate(0, 0, -((int(local.Weekday()) - schedule.Weekday + 7) % 7))
+		if slot.After(local) {
+			slot = slot.AddDate(0, 0, -7)
+		}
+		return slot
+	}
+	if slot.After(local) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	return slot
+}
+
+// RunDueDigests 发送到期的摘要报告 (由定时任务每分钟调用)
+// 服务停机错过的发送时间在恢复后补发一次；新建的计划从下一个发送时间开始
+func (s *Service) RunDueDigests(now time.Time) {
+	var schedules []model.DigestSchedule
+	s.db.Where("enabled = ?", true).Find(&schedules)
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		last := schedule.CreatedAt
+		if schedule.LastSentAt != nil && schedule.LastSentAt.After(last) {
+			last = *schedule.LastSentAt
+		}
+		slot := digestSlot(schedule, now)
+		if !slot.After(last) {
+			continue
+		}
+		if err := s.deliverDigest(schedule, slot); err != nil {
+			log.Printf("[Digest] Send digest %s failed: %v", schedule.Name, err)
+		}
+	}
+}
+
+// SendDigestNow 立即发送截至当前时间的摘要报告
+func (s *Service) SendDigestNow(id uint) error {
+	schedule, err := s.GetDigestSchedule(id)
+	if err != nil {
+		return errors.New("摘要计划不存在")
+	}
+	return s.deliverDigest(schedule, time.Now())
+}
+
+// deliverDigest 生成并发送报告，记录发送时间与结果 (失败不重试，等待下一周期)
+func (s *Service) deliverDigest(schedule *model.DigestSchedule, to time.Time) error {
+	report := s.BuildDigestReport(schedule.Frequency, to, schedule.TopN)
+
+	var errs []string
+	if schedule.Email != "" {
+		if err := s.sendDigestEmail(schedule.Email, report); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if schedule.ChannelID != 0 {
+		if err := s.alertService.EnqueueMessage(schedule.ChannelID, "digest", report.Title, RenderDigestText(report)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	var sendErr error
+	if len(errs) > 0 {
+		sendErr = errors.New(strings.Join(errs, "; "))
+	}
+	lastError := ""
+	if sendErr != nil {
+		lastError = sendErr.Error()
+		if len(lastError) > 500 {
+			lastError = lastError[:500]
+		}
+	}
+	s.db.Model(schedule).Updates(map[string]interface{}{
+		"last_sent_at": time.Now(),
+		"last_error":   lastError,
+	})
+	return sendErr
+}
+
+func (s *Service) sendDigestEmail(to string, report *DigestReport) error {
+	sender := s.GetEmailSender()
+	if sender == nil {
+		return errors.New("未配置系统邮件渠道")
+	}
+	body, err := RenderDigestHTML(report)
+	if err != nil {
+		return err
+	}
+	return sender.SendReportEmail(to, report.Title, body)
+}
+
+// ==================== 摘要计划管理 ====================
+
+// ValidateDigestSchedule 校验摘要计划，未填写排行条数时设为默认值
+func (s *Service) ValidateDigestSchedule(schedule *model.DigestSchedule) error {
+	if strings.TrimSpace(schedule.Name) == "" {
+		return errors.New("名称不能为空")
+	}
+	if schedule.Frequency != model.DigestDaily && schedule.Frequency != model.DigestWeekly {
+		return errors.New("频率只能是 daily 或 weekly")
+	}
+	if schedule.Hour < 0 || schedule.Hour > 23 {
+		return errors.New("发送时刻取值 0-23")
+	}
+	if schedule.Weekday < 0 || schedule.Weekday > 6 {
+		return errors.New("发送日取值 0-6")
+	}
+	if _, err := digestLocation(schedule); err != nil {
+		return fmt.Errorf("时区无效: %s", schedule.Timezone)
+	}
+	// 未填写 (0) 时使用默认条数
+	if schedule.TopN == 0 {
+		schedule.TopN = digestDefaultTopN
+	}
+	if schedule.TopN < 1 || schedule.TopN > 50 {
+		return fmt.Errorf("排行条数取值 1-50 (留空或 0 使用默认 %d 条)", digestDefaultTopN)
+	}
+	if schedule.Email == "" && schedule.ChannelID == 0 {
+		return errors.New("需要收件邮箱或通知渠道")
+	}
+	if schedule.Email != "" {
+		if _, err := mail.ParseAddressList(schedule.Email); err != nil {
+			return fmt.Errorf("收件邮箱无效: %v", err)
+		}
+	}
+	if schedule.ChannelID != 0 {
+		if _, err := s.GetNotifyChannel(schedule.ChannelID); err != nil {
+			return errors.New("通知渠道不存在")
+		}
+	}
+	return nil
+}
+
+// ListDigestSchedules 获取摘要计划列表
+func (s *Service) ListDigestSchedules() ([]model.DigestSchedule, error) {
+	var schedules []model.DigestSchedule
+	err := s.db.Order("id asc").Find(&schedules).Error
+	return schedules, err
+}
+
+// GetDigestSchedule 获取单个摘要计划
+func (s *Service) GetDigestSchedule(id uint) (*model.DigestSchedule, error) {
+	var schedule model.DigestSchedule
+	err := s.db.First(&schedule, id).Error
+	return &schedule, err
+}
+
+// CreateDigestSchedule 创建摘要计划
+func (s *Service) CreateDigestSchedule(schedule *model.DigestSchedule) error {
+	if err := s.ValidateDigestSchedule(schedule); err != nil {
+		return err
+	}
+	return s.db.Create(schedule).Error
+}
+
+// UpdateDigestSchedule 更新摘要计划
+func (s *Service) UpdateDigestSchedule(schedule *model.DigestSchedule) error {
+	if err := s.ValidateDigestSchedule(schedule); err != nil {
+		return err
+	}
+	return s.db.Save(schedule).Error
+}
+
+// DeleteDigestSchedule 删除摘要计划
+func (s *Service) DeleteDigestSchedule(id uint) error {
+	return s.db.Delete(&model.DigestSchedule{}, id).Error
+}
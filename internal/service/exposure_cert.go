@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"golang.org/x/crypto/acme"
+)
+
+// ==================== 域名暴露证书 ====================
+//
+// 节点终止 TLS 的域名暴露共用一张节点证书:
+// 域名变更或证书缺失时先由内部 CA 同步签发 (立即可用，浏览器不信任)，
+// 节点 HTTP 入口为 80 端口时再异步向 ACME 服务器申请受信任的证书 (HTTP-01 验证经节点入口转发到面板)。
+// 节点上的域名来自不同用户，个别域名未通过验证 (如 DNS 未指向节点) 时去掉这些域名重新申请，
+// 其余域名照常使用受信任的证书，失败的域名在重试间隔后再次尝试
+
+const (
+	exposureCertRenewAhead = 30 * 24 * time.Hour // 过期前 30 天续期
+	acmeRetryInterval      = 6 * time.Hour       // ACME 申请失败后的重试间隔
+	acmeSyncWait           = 5 * time.Minute     // 等待节点加载验证转发规则的最长时间
+	acmeOrderTimeout       = 5 * time.Minute     // 单次 ACME 申请的超时
+)
+
+var (
+	// acmeChallenges HTTP-01 验证令牌 -> 应答内容
+	acmeChallenges sync.Map
+	// acmeInFlight 正在申请 ACME 证书的节点
+	acmeInFlight sync.Map
+)
+
+// ACMEChallengeResponse 获取 HTTP-01 验证应答
+func ACMEChallengeResponse(token string) (string, bool) {
+	value, ok := acmeChallenges.Load(token)
+	if !ok {
+		return "", false
+	}
+	return value.(string), true
+}
+
+// GetExposureCertificate 获取节点的域名暴露证书
+func (s *Service) GetExposureCertificate(nodeID uint) (*model.ExposureCertificate, error) {
+	var cert model.ExposureCertificate
+	if err := s.db.Where("node_id = ?", nodeID).First(&cert).Error; err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// exposureTLSDomains 节点上启用且由节点终止 TLS 的域名 (已排序)
+func (s *Service) exposureTLSDomains(nodeID uint) []string {
+	var domains []string
+	s.db.Model(&model.ClientExposure{}).
+		Joins("JOIN clients ON clients.id = client_exposures.client_id").
+		Where("clients.node_id = ? AND client_exposures.enabled = ? AND client_exposures.tls_mode = ? AND client_exposures.hostname <> ''",
+			nodeID, true, gost.ExposureTLSTerminate).
+		Distinct().Order("client_exposures.hostname ASC").
+		Pluck("client_exposures.hostname", &domains)
+	return domains
+}
+
+// EnsureExposureCertificate 确保节点持有覆盖全部终止 TLS 域名的证书
+// 缺失、域名变化或即将过期时先由内部 CA 签发，再按需异步申请 ACME 证书
+func (s *Service) EnsureExposureCertificate(nodeID uint) error {
+	domains := s.exposureTLSDomains(nodeID)
+	if len(domains) == 0 {
+		return nil
+	}
+	node, err := s.GetNode(nodeID)
+	if err != nil {
+		return err
+	}
+
+	cert, _ := s.GetExposureCertificate(nodeID)
+	if exposureCertStale(cert, domains) {
+		if cert, err = s.issueInternalExposureCertificate(nodeID, domains); err != nil {
+			return err
+		}
+	}
+
+	if s.acmeDue(node, cert) {
+		if _, running := acmeInFlight.LoadOrStore(nodeID, true); !running {
+			go func() {
+				defer acmeInFlight.Delete(nodeID)
+				s.requestACMECertificate(nodeID, domains)
+			}()
+		}
+	}
+	return nil
+}
+
+// exposureCertStale 证书缺失、域名变化或即将过期时需要由内部 CA 重新签发
+// ACME 证书由 ACME 续期，续期一直失败直到最后一天才回退到内部 CA
+func exposureCertStale(cert *model.ExposureCertificate, domains []string) bool {
+	if cert == nil || cert.CertPEM == "" || cert.ExpireAt == nil || cert.Domains != strings.Join(domains, ",") {
+		return true
+	}
+	remaining := time.Until(*cert.ExpireAt)
+	if cert.Issuer == "acme" {
+		return remaining <= 24*time.Hour
+	}
+	return remaining <= exposureCertRenewAhead
+}
+
+// acmeDue 是否需要申请 ACME 证书: HTTP-01 验证要求节点 HTTP 入口为 80 端口，失败后间隔一段时间再重试
+func (s *Service) acmeDue(node *model.Node, cert *model.ExposureCertificate) bool {
+	if node.ExposeHTTPPort != 80 {
+		return false
+	}
+	if cert.LastError != "" && time.Since(cert.UpdatedAt) < acmeRetryInterval {
+		return false
+	}
+	// 部分域名未包含在 ACME 证书中时同样按重试间隔重新申请
+	if cert.Issuer == "acme" && cert.LastError == "" {
+		return time.Until(*cert.ExpireAt) <= exposureCertRenewAhead
+	}
+	return true
+}
+
+// RenewExposureCertificates 检查全部启用 HTTPS 入口的节点的证书 (由后台任务定期调用)
+func (s *Service) RenewExposureCertificates() {
+	var nodeIDs []uint
+	s.db.Model(&model.Node{}).Where("expose_https_port > 0").Pluck("id", &nodeIDs)
+	for _, nodeID := range nodeIDs {
+		if err := s.EnsureExposureCertificate(nodeID); err != nil {
+			log.Printf("[ExposureCert] Node %d: %v", nodeID, err)
+		}
+	}
+}
+
+// issueInternalExposureCertificate 由内部 CA 签发域名暴露证书
+func (s *Service) issueInternalExposureCertificate(nodeID uint, domains []string) (*model.ExposureCertificate, error) {
+	caMu.Lock()
+	defer caMu.Unlock()
+
+	ca, err := s.getOrCreateCA()
+	if err != nil {
+		return nil, err
+	}
+	caCert, caKey, err := parseCA(ca)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: domains[0], Organization: []string{"GOST Panel"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(nodeCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     domains,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 附带 CA 证书，便于客户端导入信任
+	chain := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) + ca.CertPEM
+	return s.saveExposureCertificate(nodeID, domains, "internal", chain, keyPEM, serial.Text(16), tmpl.NotAfter)
+}
+
+// saveExposureCertificate 保存证书并标记节点待同步 (Agent 重新注册获取证书后重载配置)
+func (s *Service) saveExposureCertificate(nodeID uint, domains []string, issuer, certPEM, keyPEM, serial string, expireAt time.Time) (*model.ExposureCertificate, error) {
+	cert, err := s.GetExposureCertificate(nodeID)
+	if err != nil {
+		cert = &model.ExposureCertificate{NodeID: nodeID}
+	}
+	cert.Domains = strings.Join(domains, ",")
+	cert.Issuer = issuer
+	cert.CertPEM = certPEM
+	cert.KeyPEM = keyPEM
+	cert.Serial = serial
+	cert.ExpireAt = &expireAt
+	cert.LastError = ""
+	if err := s.db.Save(cert).Error; err != nil {
+		return nil, err
+	}
+	s.MarkNodesConfigPending(nodeID)
+	return cert, nil
+}
+
+// requestACMECertificate 等待节点加载验证转发规则后申请 ACME 证书，失败时记录原因并保留现有证书
+func (s *Service) requestACMECertificate(nodeID uint, domains []string) {
+	if !s.waitNodeConfigSynced(nodeID, acmeSyncWait) {
+		s.recordACMEError(nodeID, errors.New("节点未在规定时间内同步配置"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), acmeOrderTimeout)
+	defer cancel()
+
+	// 一个域名验证失败会使整个订单失效，去掉失败的域名后用其余域名重新下单
+	remaining := domains
+	failed := acmeDomainErrors{}
+	var certPEM, keyPEM string
+	var leaf *x509.Certificate
+	for {
+		var err error
+		certPEM, keyPEM, leaf, err = s.obtainACMECertificate(ctx, remaining)
+		var domainErrs acmeDomainErrors
+		if errors.As(err, &domainErrs) {
+			for domain, domainErr := range domainErrs {
+				failed[domain] = domainErr
+			}
+			if remaining = withoutDomains(remaining, domainErrs); len(remaining) > 0 {
+				continue
+			}
+			err = failed
+		}
+		if err != nil {
+			log.Printf("[ExposureCert] ACME for node %d (%s) failed: %v", nodeID, strings.Join(domains, ","), err)
+			s.recordACMEError(nodeID, err)
+			return
+		}
+		break
+	}
+
+	// 申请期间域名发生变化时丢弃结果，由下一次检查重新申请
+	if strings.Join(s.exposureTLSDomains(nodeID), ",") != strings.Join(domains, ",") {
+		return
+	}
+	// 证书记录节点的全部域名，避免未覆盖的域名触发内部 CA 重新签发；未覆盖的域名记录在失败原因中
+	if _, err := s.saveExposureCertificate(nodeID, domains, "acme", certPEM, keyPEM, leaf.SerialNumber.Text(16), leaf.NotAfter); err != nil {
+		log.Printf("[ExposureCert] Save ACME certificate for node %d failed: %v", nodeID, err)
+		return
+	}
+	log.Printf("[ExposureCert] Issued ACME certificate for node %d: %s", nodeID, strings.Join(remaining, ","))
+	if len(failed) > 0 {
+		log.Printf("[ExposureCert] Node %d: domains left out of the ACME certificate: %v", nodeID, failed)
+		s.recordACMEError(nodeID, fmt.Errorf("以下域名未通过验证，未包含在证书中: %w", failed))
+	}
+}
+
+// acmeDomainErrors 未通过 ACME 验证的域名及原因
+type acmeDomainErrors map[string]error
+
+func (e acmeDomainErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for domain, err := range e {
+		parts = append(parts, fmt.Sprintf("%s: %v", domain, err))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+// withoutDomains 去掉验证失败的域名 (保持原有顺序)
+func withoutDomains(domains []string, failed acmeDomainErrors) []string {
+	var rest []string
+	for _, domain := range domains {
+		if _, ok := failed[domain]; !ok {
+			rest = append(rest, domain)
+		}
+	}
+	return rest
+}
+
+// waitNodeConfigSynced 等待节点配置同步完成
+func (s *Service) waitNodeConfigSynced(nodeID uint, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		var node model.Node
+		if err := s.db.Select("id", "config_status").First(&node, nodeID).Error; err != nil {
+			return false
+		}
+		if node.ConfigStatus == model.ConfigSynced {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// recordACMEError 记录 ACME 申请失败的原因
+func (s *Service) recordACMEError(nodeID uint, err error) {
+	msg := err.Error()
+	if len(msg) > 500 {
+		// 按字节截断后去掉被截断的多字节字符
+		msg = strings.ToValidUTF8(msg[:500], "")
+	}
+	s.db.Model(&model.ExposureCertificate{}).Where("node_id = ?", nodeID).
+		Updates(map[string]interface{}{"last_error": msg, "updated_at": time.Now()})
+}
+
+// acmeClient 获取目录对应的 ACME 账户 (不存在则创建并注册)
+func (s *Service) acmeClient(ctx context.Context) (*acme.Client, error) {
+	directory := s.GetSiteConfig(model.ConfigACMEDirectoryURL)
+	if directory == "" {
+		directory = acme.LetsEncryptURL
+	}
+	email := s.GetSiteConfig(model.ConfigACMEEmail)
+
+	var account model.ACMEAccount
+	if err := s.db.Where("directory_url = ?", directory).First(&account).Error; err != nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := encodeECKey(key)
+		if err != nil {
+			return nil, err
+		}
+		account = model.ACMEAccount{DirectoryURL: directory, Email: email, KeyPEM: keyPEM}
+		if err := s.db.Create(&account).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode([]byte(account.KeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid ACME account key")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &acme.Client{Key: key, DirectoryURL: directory, UserAgent: "gost-panel"}
+	var contact []string
+	if email != "" {
+		contact = []string{"mailto:" + email}
+	}
+	if _, err := client.Register(ctx, &acme.Account{Contact: contact}, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("注册 ACME 账户失败: %w", err)
+	}
+	return client, nil
+}
+
+// completeHTTP01 完成单个域名的 HTTP-01 验证
+func (s *Service) completeHTTP01(ctx context.Context, client *acme.Client, authz *acme.Authorization) error {
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return errors.New("没有可用的 HTTP-01 验证")
+	}
+	response, err := client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	acmeChallenges.Store(challenge.Token, response)
+	defer acmeChallenges.Delete(challenge.Token)
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
+
+// obtainACMECertificate 通过 HTTP-01 验证申请证书，返回证书链、私钥与叶子证书
+func (s *Service) obtainACMECertificate(ctx context.Context, domains []string) (string, string, *x509.Certificate, error) {
+	client, err := s.acmeClient(ctx)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return "", "", nil, err
+	}
+	// 逐个完成域名验证，验证失败的域名汇总为 acmeDomainErrors 返回，由调用方去掉后重新下单
+	failed := acmeDomainErrors{}
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		if err := s.completeHTTP01(ctx, client, authz); err != nil {
+			failed[authz.Identifier.Value] = err
+		}
+	}
+	if len(failed) > 0 {
+		return "", "", nil, failed
+	}
+
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return "", "", nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return "", "", nil, err
+	}
+	ders, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(ders) == 0 {
+		return "", "", nil, errors.New("ACME 服务器未返回证书")
+	}
+	leaf, err := x509.ParseCertificate(ders[0])
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var chain strings.Builder
+	for _, der := range ders {
+		chain.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+	keyPEM, err := encodeECKey(key)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return chain.String(), keyPEM, leaf, nil
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 节点组健康调整 ====================
+//
+// 节点组启用健康检查时，每轮节点健康检查完成后按组的检查间隔评估成员:
+// 根据成员节点最近的 HealthCheckLog 失败率与延迟计算有效权重，失败率过高时自动停用，
+// 恢复需要失败率回落到更低的阈值并经过最短保持时间 (滞后，避免来回切换)。
+// 有效权重或启用状态变化时记录调整，并重新下发使用该组的隧道配置
+
+const (
+	// groupHealthSamples 评估使用每个节点最近的检查次数
+	groupHealthSamples = 10
+	// groupHealthMinSamples 检查次数不足时不调整
+	groupHealthMinSamples = 3
+	// groupHealthWindow 只使用该时间内的检查结果
+	groupHealthWindow = 10 * time.Minute
+	// groupHealthDownRate 失败率 (%) 达到时自动停用
+	groupHealthDownRate = 50
+	// groupHealthUpRate 失败率 (%) 不超过时视为健康，自动停用的成员也需回落到此才恢复
+	groupHealthUpRate = 10
+	// groupHealthMinHold 自动停用后至少保持的时间 (与 3 个评估间隔取较大值)
+	groupHealthMinHold = 2 * time.Minute
+	// groupHealthMinLatencyFactor 延迟对权重的最大削减 (有效权重不低于配置权重的该比例)
+	groupHealthMinLatencyFactor = 0.2
+	// groupHealthRetention 调整记录保留时间
+	groupHealthRetention = 30 * 24 * time.Hour
+)
+
+// nodeHealthStat 节点最近的健康检查统计
+type nodeHealthStat struct {
+	Samples  int
+	FailRate float64 // %
+	Latency  int     // 成功检查的平均延迟 (ms)
+}
+
+// memberHealthDecision 一个成员的评估结果
+type memberHealthDecision struct {
+	member   *model.NodeGroupMember
+	name     string
+	stat     nodeHealthStat
+	state    string
+	disabled bool
+	weight   int // 有效权重 (0=使用配置的权重)
+	reason   string
+}
+
+// EvaluateNodeGroupHealth 评估所有节点组的成员健康状况 (每轮健康检查完成后调用)
+func (s *Service) EvaluateNodeGroupHealth() {
+	var groups []model.NodeGroup
+	if err := s.db.Find(&groups).Error; err != nil {
+		log.Printf("[NodeGroupHealth] Load node groups failed: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range groups {
+		group := &groups[i]
+		var changed bool
+		if group.HealthCheck {
+			if !s.groupHealthDue(group, now) {
+				continue
+			}
+			changed = s.evaluateGroupHealth(group, now)
+		} else {
+			changed = s.resetGroupHealth(group)
+		}
+		if changed {
+			s.markGroupTunnelsPending(group.ID)
+		}
+	}
+
+	s.db.Where("created_at < ?", now.Add(-groupHealthRetention)).Delete(&model.NodeGroupHealthEvent{})
+}
+
+// groupHealthDue 是否到了节点组的评估时间 (按组的检查间隔)
+func (s *Service) groupHealthDue(group *model.NodeGroup, now time.Time) bool {
+	s.groupHealthMu.Lock()
+	defer s.groupHealthMu.Unlock()
+	if s.groupHealthLast == nil {
+		s.groupHealthLast = make(map[uint]time.Time)
+	}
+	interval := time.Duration(group.CheckInterval) * time.Second
+	// 留出余量，避免与健康检查周期相同时因调度误差跳过一轮
+	if last, ok := s.groupHealthLast[group.ID]; ok && now.Sub(last) < interval-time.Second {
+		return false
+	}
+	s.groupHealthLast[group.ID] = now
+	return true
+}
+
+// groupHealthHold 自动停用后至少保持的时间
+func groupHealthHold(group *model.NodeGroup) time.Duration {
+	hold := 3 * time.Duration(group.CheckInterval) * time.Second
+	if hold < groupHealthMinHold {
+		hold = groupHealthMinHold
+	}
+	return hold
+}
+
+// nodeHealthStats 节点最近的健康检查统计
+func (s *Service) nodeHealthStats(nodeID uint, now time.Time) nodeHealthStat {
+	var logs []model.HealthCheckLog
+	s.db.Where("node_id = ? AND checked_at > ?", nodeID, now.Add(-groupHealthWindow)).
+		Order("checked_at DESC").Limit(groupHealthSamples).Find(&logs)
+
+	stat := nodeHealthStat{Samples: len(logs)}
+	failed, healthy, total := 0, 0, 0
+	for _, l := range logs {
+		if l.Status != "healthy" {
+			failed++
+			continue
+		}
+		healthy++
+		total += l.Latency
+	}
+	if stat.Samples > 0 {
+		stat.FailRate = math.Round(float64(failed)/float64(stat.Samples)*1000) / 10
+	}
+	if healthy > 0 {
+		stat.Latency = total / healthy
+	}
+	return stat
+}
+
+// evaluateGroupHealth 评估节点组成员，返回负载均衡是否发生变化
+func (s *Service) evaluateGroupHealth(group *model.NodeGroup, now time.Time) bool {
+	members, err := s.GetNodeGroupMembersWithNodes(group.ID)
+	if err != nil || len(members) == 0 {
+		return false
+	}
+
+	// 收集统计，计算组内可用成员的最低延迟作为延迟基准
+	var decisions []*memberHealthDecision
+	bestLatency := 0
+	for i := range members {
+		m := &members[i]
+		if !m.Member.Enabled {
+			continue
+		}
+		d := &memberHealthDecision{member: &m.Member, name: m.Node.Name, stat: s.nodeHealthStats(m.Node.ID, now)}
+		decisions = append(decisions, d)
+		if d.stat.Samples >= groupHealthMinSamples && d.stat.FailRate < groupHealthDownRate && d.stat.Latency > 0 &&
+			(bestLatency == 0 || d.stat.Latency < bestLatency) {
+			bestLatency = d.stat.Latency
+		}
+	}
+
+	for _, d := range decisions {
+		decideMemberHealth(group, d, bestLatency, now)
+	}
+	keepLastMember(decisions)
+
+	changed := false
+	for _, d := range decisions {
+		if s.applyMemberHealth(group, d, now) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// decideMemberHealth 计算成员的健康状态与有效权重
+func decideMemberHealth(group *model.NodeGroup, d *memberHealthDecision, bestLatency int, now time.Time) {
+	m := d.member
+	d.state, d.disabled, d.weight = m.HealthState, m.AutoDisabled, m.EffectiveWeight
+	if d.stat.Samples < groupHealthMinSamples {
+		// 检查次数不足，保持现状
+		return
+	}
+
+	if m.AutoDisabled {
+		held := m.HealthChangedAt != nil && now.Sub(*m.HealthChangedAt) < groupHealthHold(group)
+		if d.stat.FailRate > groupHealthUpRate || held {
+			d.state = model.MemberDown
+			return
+		}
+	} else if d.stat.FailRate >= groupHealthDownRate {
+		d.state, d.disabled = model.MemberDown, true
+		d.reason = fmt.Sprintf("最近 %d 次检查失败率 %.0f%%，自动停用", d.stat.Samples, d.stat.FailRate)
+		return
+	}
+
+	// 有效权重: 按成功率与相对组内最低延迟的比例缩放配置的权重
+	factor := 1.0
+	if bestLatency > 0 && d.stat.Latency > bestLatency {
+		factor = math.Max(groupHealthMinLatencyFactor, float64(bestLatency)/float64(d.stat.Latency))
+	}
+	weight := int(math.Round(float64(m.Weight) * (1 - d.stat.FailRate/100) * factor))
+	weight = max(weight, 1)
+
+	state := model.MemberHealthy
+	if d.stat.FailRate > groupHealthUpRate || factor < 0.5 {
+		state = model.MemberDegraded
+	}
+
+	// 滞后: 状态不变时，有效权重变化不足当前值的 1/4 不调整
+	current := m.CurrentWeight()
+	if !m.AutoDisabled && state == m.HealthState && abs(weight-current) < max(1, current/4) {
+		weight = current
+	}
+	if weight == m.Weight {
+		weight = 0
+	}
+
+	if m.AutoDisabled {
+		d.reason = fmt.Sprintf("失败率回落到 %.0f%%，恢复启用", d.stat.FailRate)
+	} else {
+		d.reason = fmt.Sprintf("失败率 %.0f%%，延迟 %d ms (组内最低 %d ms)", d.stat.FailRate, d.stat.Latency, bestLatency)
+	}
+	d.state, d.disabled, d.weight = state, false, weight
+}
+
+// keepLastMember 所有启用的成员都将被自动停用时，保留失败率最低的成员，避免节点组没有可用成员
+func keepLastMember(decisions []*memberHealthDecision) {
+	var keep *memberHealthDecision
+	for _, d := range decisions {
+		if !d.disabled {
+			return
+		}
+		if keep == nil || d.stat.FailRate < keep.stat.FailRate {
+			keep = d
+		}
+	}
+	if keep == nil {
+		return
+	}
+	keep.state, keep.disabled, keep.weight = model.MemberDegraded, false, 1
+	if keep.weight == keep.member.Weight {
+		keep.weight = 0
+	}
+	keep.reason = fmt.Sprintf("组内成员均不可用，保留失败率最低的成员 (%.0f%%)", keep.stat.FailRate)
+}
+
+// applyMemberHealth 保存评估结果，启用状态或有效权重变化时记录调整，返回负载均衡是否变化
+func (s *Service) applyMemberHealth(group *model.NodeGroup, d *memberHealthDecision, now time.Time) bool {
+	m := d.member
+	updates := map[string]interface{}{
+		"health_fail_rate": d.stat.FailRate,
+		"health_latency":   d.stat.Latency,
+	}
+	if d.stat.Samples < groupHealthMinSamples {
+		s.db.Model(&model.NodeGroupMember{}).Where("id = ?", m.ID).Updates(updates)
+		return false
+	}
+
+	changed := d.disabled != m.AutoDisabled || d.weight != m.EffectiveWeight
+	stateChanged := d.state != m.HealthState
+	updates["health_state"] = d.state
+	updates["auto_disabled"] = d.disabled
+	updates["effective_weight"] = d.weight
+	if stateChanged {
+		updates["health_changed_at"] = now
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.NodeGroupMember{}).Where("id = ?", m.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		// 首次评估为健康时不记录
+		if !changed && (!stateChanged || m.HealthState == "") {
+			return nil
+		}
+		return tx.Create(&model.NodeGroupHealthEvent{
+			GroupID:    group.ID,
+			MemberID:   m.ID,
+			NodeID:     m.NodeID,
+			NodeName:   d.name,
+			FromState:  m.HealthState,
+			ToState:    d.state,
+			FromWeight: memberWeight(m.AutoDisabled, m.CurrentWeight()),
+			ToWeight:   memberWeight(d.disabled, effectiveOrBase(d.weight, m.Weight)),
+			FailRate:   d.stat.FailRate,
+			Latency:    d.stat.Latency,
+			Reason:     d.reason,
+			CreatedAt:  now,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("[NodeGroupHealth] Update member %s of group %s failed: %v", d.name, group.Name, err)
+		return false
+	}
+	if changed {
+		log.Printf("[NodeGroupHealth] Group %s member %s: %s -> %s, weight %d -> %d (fail %.0f%%, latency %dms)",
+			group.Name, d.name, m.HealthState, d.state,
+			memberWeight(m.AutoDisabled, m.CurrentWeight()), memberWeight(d.disabled, effectiveOrBase(d.weight, m.Weight)),
+			d.stat.FailRate, d.stat.Latency)
+	}
+	return changed
+}
+
+// resetGroupHealth 节点组关闭健康检查后恢复成员配置的权重与启用状态，返回负载均衡是否变化
+func (s *Service) resetGroupHealth(group *model.NodeGroup) bool {
+	var members []model.NodeGroupMember
+	s.db.Where("group_id = ? AND (auto_disabled = ? OR effective_weight > 0 OR health_state <> '')", group.ID, true).Find(&members)
+	if len(members) == 0 {
+		return false
+	}
+
+	changed := false
+	now := time.Now()
+	for i := range members {
+		m := &members[i]
+		adjusted := m.AutoDisabled || m.EffectiveWeight > 0
+		s.db.Model(&model.NodeGroupMember{}).Where("id = ?", m.ID).Updates(map[string]interface{}{
+			"auto_disabled":     false,
+			"effective_weight":  0,
+			"health_state":      "",
+			"health_fail_rate":  0,
+			"health_latency":    0,
+			"health_changed_at": nil,
+		})
+		if !adjusted {
+			continue
+		}
+		changed = true
+		var node model.Node
+		s.db.Select("name").First(&node, m.NodeID)
+		s.db.Create(&model.NodeGroupHealthEvent{
+			GroupID:    group.ID,
+			MemberID:   m.ID,
+			NodeID:     m.NodeID,
+			NodeName:   node.Name,
+			FromState:  m.HealthState,
+			FromWeight: memberWeight(m.AutoDisabled, m.CurrentWeight()),
+			ToWeight:   m.Weight,
+			Reason:     "节点组关闭健康检查，恢复配置的权重",
+			CreatedAt:  now,
+		})
+	}
+	if changed {
+		log.Printf("[NodeGroupHealth] Group %s health check disabled, member weights restored", group.Name)
+	}
+	return changed
+}
+
+// ListNodeGroupHealthEvents 节点组成员的健康调整记录 (按时间倒序)
+func (s *Service) ListNodeGroupHealthEvents(groupID uint, limit int) ([]model.NodeGroupHealthEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var events []model.NodeGroupHealthEvent
+	err := s.db.Where("group_id = ?", groupID).Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// memberWeight 记录中使用的权重: 停用时为 0
+func memberWeight(disabled bool, weight int) int {
+	if disabled {
+		return 0
+	}
+	return weight
+}
+
+func effectiveOrBase(effective, base int) int {
+	if effective > 0 {
+		return effective
+	}
+	return base
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -15,15 +15,21 @@ type HealthChecker struct {
 	db           *gorm.DB
 	alertService interface {
 		TriggerAlert(alertType, targetType string, targetID uint, targetName, message string)
+		ResolveAlert(alertType, targetType string, targetID uint, targetName, message string)
+		NodeInMaintenance(nodeID uint) bool
 	}
 	interval time.Duration
 	stopCh   chan struct{}
 	wg       sync.WaitGroup
+	// afterCheck 每轮检查的所有节点完成后调用 (节点组健康评估)
+	afterCheck func()
 }
 
 // NewHealthChecker 创建健康检查器
 func NewHealthChecker(db *gorm.DB, alertService interface {
 	TriggerAlert(alertType, targetType string, targetID uint, targetName, message string)
+	ResolveAlert(alertType, targetType string, targetID uint, targetName, message string)
+	NodeInMaintenance(nodeID uint) bool
 }, interval time.Duration) *HealthChecker {
 	return &HealthChecker{
 		db:           db,
@@ -33,6 +39,11 @@ func NewHealthChecker(db *gorm.DB, alertService interface {
 	}
 }
 
+// OnChecked 设置每轮检查完成后的回调，需在 Start 之前调用
+func (h *HealthChecker) OnChecked(fn func()) {
+	h.afterCheck = fn
+}
+
 // Start 启动健康检查
 func (h *HealthChecker) Start() {
 	h.wg.Add(1)
@@ -81,12 +92,24 @@ func (h *HealthChecker) checkAll() {
 
 	log.Printf("Health check: checking %d nodes...", len(nodes))
 
+	var wg sync.WaitGroup
 	for _, node := range nodes {
-		go h.checkNode(node)
+		wg.Add(1)
+		go func(node model.Node) {
+			defer wg.Done()
+			h.checkNode(node)
+		}(node)
 	}
 
 	// 检查客户端超时 (2分钟无心跳则标记离线)
 	h.checkClientTimeout()
+
+	if h.afterCheck != nil {
+		go func() {
+			wg.Wait()
+			h.afterCheck()
+		}()
+	}
 }
 
 func (h *HealthChecker) checkClientTimeout() {
@@ -122,8 +145,15 @@ func (h *HealthChecker) checkNode(node model.Node) {
 		CheckedAt: time.Now(),
 	})
 
+	// 维护窗口内不改变节点状态
+	statusChanged := node.Status != newNodeStatus
+	if statusChanged && h.alertService != nil && h.alertService.NodeInMaintenance(node.ID) {
+		log.Printf("Health check: node %s in maintenance, skip status change %s -> %s", node.Name, node.Status, newNodeStatus)
+		statusChanged = false
+	}
+
 	// 状态变更
-	if node.Status != newNodeStatus {
+	if statusChanged {
 		log.Printf("Health check: node %s status changed: %s -> %s", node.Name, node.Status, newNodeStatus)
 
 		// 更新数据库状态
@@ -132,11 +162,15 @@ func (h *HealthChecker) checkNode(node model.Node) {
 			"last_seen": time.Now(),
 		})
 
-		// 触发告警
-		if newNodeStatus == "offline" && h.alertService != nil {
-			h.alertService.TriggerAlert("node_offline", "node", node.ID, node.Name, "Node is offline")
+		// 触发告警 / 恢复通知
+		if h.alertService != nil {
+			if newNodeStatus == "offline" {
+				h.alertService.TriggerAlert("node_offline", "node", node.ID, node.Name, "Node is offline")
+			} else {
+				h.alertService.ResolveAlert("node_offline", "node", node.ID, node.Name, "Node is back online")
+			}
 		}
-	} else if newNodeStatus == "online" {
+	} else if newNodeStatus == "online" && node.Status == "online" {
 		// 在线时更新 last_seen
 		h.db.Model(&model.Node{}).Where("id = ?", node.ID).Update("last_seen", time.Now())
 	}
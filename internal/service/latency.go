@@ -0,0 +1,471 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 节点间延迟矩阵 ====================
+//
+// 管理员选择参与测量的节点，每个节点的 Agent 定期测量到其余节点的 TCP 握手延迟并上报，
+// 面板据此维护节点间延迟矩阵 (保留历史)，为入口/出口推荐中继路径，并为节点组成员提供权重建议
+
+const (
+	latencyDefaultInterval = 300
+	latencyMinInterval     = 60
+	latencyAttempts        = 3
+	latencyTimeoutMs       = 3000
+	// latencyRetention 延迟记录保留时间
+	latencyRetention = 7 * 24 * time.Hour
+	// latencyMaxRelays 推荐路径最多经过的中继数
+	latencyMaxRelays = 3
+	// latencyMaxWeight 权重建议的最大值 (延迟最低的成员)
+	latencyMaxWeight = 10
+)
+
+// LatencyPeer 下发给 Agent 的测量目标
+type LatencyPeer struct {
+	NodeID uint   `json:"node_id"`
+	Addr   string `json:"addr"` // host:port (节点主服务端口)
+}
+
+// LatencyPeers Agent 的测量任务
+type LatencyPeers struct {
+	Interval int           `json:"interval"` // 测量间隔 (秒)
+	Timeout  int           `json:"timeout"`  // 单次握手超时 (毫秒)
+	Count    int           `json:"count"`    // 每轮尝试次数
+	Peers    []LatencyPeer `json:"peers"`
+}
+
+// LatencyReport Agent 上报的一轮测量结果
+type LatencyReport struct {
+	NodeID  uint    `json:"node_id"`
+	Latency int     `json:"latency"`
+	Loss    float64 `json:"loss"`
+}
+
+// LatencyCell 矩阵中一对节点最近一轮的测量结果 (源节点测量到目标节点)
+type LatencyCell struct {
+	SourceID   uint      `json:"source_id"`
+	TargetID   uint      `json:"target_id"`
+	Latency    int       `json:"latency"`
+	Loss       float64   `json:"loss"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// LatencyMatrixNode 矩阵中的节点
+type LatencyMatrixNode struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// LatencyMatrix 节点间延迟矩阵
+type LatencyMatrix struct {
+	Interval int                 `json:"interval"`
+	Nodes    []LatencyMatrixNode `json:"nodes"`
+	Cells    []LatencyCell       `json:"cells"`
+}
+
+// LatencyInterval 延迟测量间隔 (秒)
+func (s *Service) LatencyInterval() int {
+	interval, err := strconv.Atoi(s.GetSiteConfig(model.ConfigLatencyProbeInterval))
+	if err != nil || interval <= 0 {
+		return latencyDefaultInterval
+	}
+	if interval < latencyMinInterval {
+		return latencyMinInterval
+	}
+	return interval
+}
+
+// latencyWindow 矩阵只使用该时间内的测量结果 (三轮测量，至少 15 分钟)
+func (s *Service) latencyWindow() time.Duration {
+	window := 3 * time.Duration(s.LatencyInterval()) * time.Second
+	if window < 15*time.Minute {
+		window = 15 * time.Minute
+	}
+	return window
+}
+
+// latencyNodes 参与测量的节点
+func (s *Service) latencyNodes() []model.Node {
+	var nodes []model.Node
+	s.db.Where("latency_probe = ?", true).Order("id ASC").Find(&nodes)
+	return nodes
+}
+
+// SetLatencyNodes 设置参与延迟测量的节点 (其余节点退出测量)
+func (s *Service) SetLatencyNodes(ids []uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Node{}).Where("latency_probe = ?", true).Update("latency_probe", false).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&model.Node{}).Where("id IN ?", ids).Update("latency_probe", true).Error
+	})
+}
+
+// NodeLatencyPeers 节点需要测量的目标 (节点未参与测量时为空)
+func (s *Service) NodeLatencyPeers(node *model.Node) LatencyPeers {
+	result := LatencyPeers{
+		Interval: s.LatencyInterval(),
+		Timeout:  latencyTimeoutMs,
+		Count:    latencyAttempts,
+		Peers:    []LatencyPeer{},
+	}
+	if !node.LatencyProbe {
+		return result
+	}
+	for _, peer := range s.latencyNodes() {
+		if peer.ID == node.ID || peer.Host == "" {
+			continue
+		}
+		result.Peers = append(result.Peers, LatencyPeer{
+			NodeID: peer.ID,
+			Addr:   net.JoinHostPort(peer.Host, strconv.Itoa(peer.Port)),
+		})
+	}
+	return result
+}
+
+// RecordNodeLatencies 记录节点上报的测量结果，只接受参与测量的节点之间的结果
+func (s *Service) RecordNodeLatencies(node *model.Node, reports []LatencyReport) (int, error) {
+	if !node.LatencyProbe {
+		return 0, nil
+	}
+	peers := make(map[uint]bool)
+	for _, peer := range s.NodeLatencyPeers(node).Peers {
+		peers[peer.NodeID] = true
+	}
+
+	now := time.Now()
+	var records []model.NodeLatency
+	for _, report := range reports {
+		if !peers[report.NodeID] {
+			continue
+		}
+		records = append(records, model.NodeLatency{
+			SourceID:   node.ID,
+			TargetID:   report.NodeID,
+			Latency:    report.Latency,
+			Loss:       math.Max(0, math.Min(100, report.Loss)),
+			MeasuredAt: now,
+		})
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	if err := s.db.Create(&records).Error; err != nil {
+		return 0, err
+	}
+	s.db.Where("measured_at < ?", now.Add(-latencyRetention)).Delete(&model.NodeLatency{})
+	return len(records), nil
+}
+
+// latestLatencies 每对节点最近一轮的测量结果
+func (s *Service) latestLatencies() map[[2]uint]LatencyCell {
+	var records []model.NodeLatency
+	s.db.Where("measured_at > ?", time.Now().Add(-s.latencyWindow())).Order("measured_at DESC").Find(&records)
+
+	cells := make(map[[2]uint]LatencyCell)
+	for _, r := range records {
+		key := [2]uint{r.SourceID, r.TargetID}
+		if _, ok := cells[key]; ok {
+			continue
+		}
+		cells[key] = LatencyCell{SourceID: r.SourceID, TargetID: r.TargetID, Latency: r.Latency, Loss: r.Loss, MeasuredAt: r.MeasuredAt}
+	}
+	return cells
+}
+
+// GetLatencyMatrix 参与测量的节点及其最近的测量结果
+func (s *Service) GetLatencyMatrix() *LatencyMatrix {
+	matrix := &LatencyMatrix{Interval: s.LatencyInterval(), Nodes: []LatencyMatrixNode{}, Cells: []LatencyCell{}}
+	included := make(map[uint]bool)
+	for _, node := range s.latencyNodes() {
+		included[node.ID] = true
+		matrix.Nodes = append(matrix.Nodes, LatencyMatrixNode{ID: node.ID, Name: node.Name, Status: node.Status})
+	}
+	for _, cell := range s.latestLatencies() {
+		if included[cell.SourceID] && included[cell.TargetID] {
+			matrix.Cells = append(matrix.Cells, cell)
+		}
+	}
+	sort.Slice(matrix.Cells, func(i, j int) bool {
+		if matrix.Cells[i].SourceID != matrix.Cells[j].SourceID {
+			return matrix.Cells[i].SourceID < matrix.Cells[j].SourceID
+		}
+		return matrix.Cells[i].TargetID < matrix.Cells[j].TargetID
+	})
+	return matrix
+}
+
+// ListNodeLatencyHistory 一对节点的测量历史 (包括两个方向，按时间倒序)
+func (s *Service) ListNodeLatencyHistory(a, b uint, since time.Time) ([]model.NodeLatency, error) {
+	var records []model.NodeLatency
+	err := s.db.Where("((source_id = ? AND target_id = ?) OR (source_id = ? AND target_id = ?)) AND measured_at > ?", a, b, b, a, since).
+		Order("measured_at DESC").Limit(2000).Find(&records).Error
+	return records, err
+}
+
+// ==================== 中继路径推荐 ====================
+
+// RelayPathHop 推荐路径中的节点
+type RelayPathHop struct {
+	NodeID  uint   `json:"node_id"`
+	Name    string `json:"name"`
+	Latency int    `json:"latency"` // 从上一节点到此节点的延迟 (ms)，入口为 0
+}
+
+// RelayPath 一条候选路径
+type RelayPath struct {
+	Hops    []RelayPathHop `json:"hops"`    // 入口、中继与出口
+	Latency int            `json:"latency"` // 各段延迟之和 (ms)
+	Loss    float64        `json:"loss"`    // 各段中最大的丢失率 (%)
+}
+
+// RelayPathSuggestion 入口到出口的路径推荐
+type RelayPathSuggestion struct {
+	Direct       *RelayPath  `json:"direct"`       // 直连 (无测量数据时为空)
+	Best         *RelayPath  `json:"best"`         // 延迟最低的路径
+	Alternatives []RelayPath `json:"alternatives"` // 经过单个中继的候选路径 (按延迟排序，最多 5 条)
+}
+
+// latencyEdgeCost 路径计算使用的边权: 延迟按丢失率加权，完全不通或没有延迟数据时不可用
+func latencyEdgeCost(cell LatencyCell) (float64, bool) {
+	if cell.Loss >= 100 || cell.Latency <= 0 {
+		return 0, false
+	}
+	return float64(cell.Latency) * (1 + cell.Loss/100*2), true
+}
+
+// SuggestRelayPath 按最近的延迟矩阵为入口与出口推荐中继路径 (只经过在线的测量节点，最多 maxRelays 个中继)
+func (s *Service) SuggestRelayPath(entryID, exitID uint, maxRelays int) (*RelayPathSuggestion, error) {
+	if entryID == exitID {
+		return nil, errors.New("入口与出口不能是同一节点")
+	}
+	if maxRelays <= 0 || maxRelays > latencyMaxRelays {
+		maxRelays = latencyMaxRelays
+	}
+
+	nodes := make(map[uint]model.Node)
+	for _, node := range s.latencyNodes() {
+		nodes[node.ID] = node
+	}
+	if _, ok := nodes[entryID]; !ok {
+		return nil, errors.New("入口节点未参与延迟测量")
+	}
+	if _, ok := nodes[exitID]; !ok {
+		return nil, errors.New("出口节点未参与延迟测量")
+	}
+
+	cells := s.latestLatencies()
+	edges := make(map[uint][]LatencyCell)
+	for _, cell := range cells {
+		src, dst := nodes[cell.SourceID], nodes[cell.TargetID]
+		if src.ID == 0 || dst.ID == 0 {
+			continue
+		}
+		// 中继必须在线 (入口与出口由调用方选择)
+		if dst.ID != exitID && dst.Status != "online" {
+			continue
+		}
+		if _, ok := latencyEdgeCost(cell); ok {
+			edges[cell.SourceID] = append(edges[cell.SourceID], cell)
+		}
+	}
+
+	buildPath := func(path []LatencyCell) *RelayPath {
+		result := &RelayPath{Hops: []RelayPathHop{{NodeID: entryID, Name: nodes[entryID].Name}}}
+		for _, cell := range path {
+			result.Hops = append(result.Hops, RelayPathHop{NodeID: cell.TargetID, Name: nodes[cell.TargetID].Name, Latency: cell.Latency})
+			result.Latency += cell.Latency
+			result.Loss = math.Max(result.Loss, cell.Loss)
+		}
+		return result
+	}
+
+	suggestion := &RelayPathSuggestion{Alternatives: []RelayPath{}}
+	if cell, ok := cells[[2]uint{entryID, exitID}]; ok {
+		if _, usable := latencyEdgeCost(cell); usable {
+			suggestion.Direct = buildPath([]LatencyCell{cell})
+		}
+	}
+
+	// 限制边数的最短路径 (Bellman-Ford，最多 maxRelays+1 段)，不重复经过节点
+	type state struct {
+		cost float64
+		path []LatencyCell
+	}
+	best := map[uint]state{entryID: {}}
+	for round := 0; round <= maxRelays; round++ {
+		next := make(map[uint]state, len(best))
+		for id, st := range best {
+			next[id] = st
+		}
+		for id, st := range best {
+			if id == exitID {
+				continue
+			}
+			for _, edge := range edges[id] {
+				if edge.TargetID == entryID || pathVisits(st.path, edge.TargetID) {
+					continue
+				}
+				cost, _ := latencyEdgeCost(edge)
+				total := st.cost + cost
+				if cur, ok := next[edge.TargetID]; ok && cur.path != nil && cur.cost <= total {
+					continue
+				}
+				path := append(append([]LatencyCell{}, st.path...), edge)
+				next[edge.TargetID] = state{cost: total, path: path}
+			}
+		}
+		best = next
+	}
+	if st, ok := best[exitID]; ok && len(st.path) > 0 {
+		suggestion.Best = buildPath(st.path)
+	}
+
+	// 经过单个中继的候选路径
+	for _, first := range edges[entryID] {
+		if first.TargetID == exitID {
+			continue
+		}
+		second, ok := cells[[2]uint{first.TargetID, exitID}]
+		if !ok {
+			continue
+		}
+		if _, usable := latencyEdgeCost(second); !usable {
+			continue
+		}
+		suggestion.Alternatives = append(suggestion.Alternatives, *buildPath([]LatencyCell{first, second}))
+	}
+	sort.Slice(suggestion.Alternatives, func(i, j int) bool {
+		return suggestion.Alternatives[i].Latency < suggestion.Alternatives[j].Latency
+	})
+	if len(suggestion.Alternatives) > 5 {
+		suggestion.Alternatives = suggestion.Alternatives[:5]
+	}
+	return suggestion, nil
+}
+
+func pathVisits(path []LatencyCell, nodeID uint) bool {
+	for _, cell := range path {
+		if cell.TargetID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== 节点组权重建议 ====================
+
+// NodeWeightHint 节点组成员的权重建议
+type NodeWeightHint struct {
+	MemberID        uint    `json:"member_id"`
+	NodeID          uint    `json:"node_id"`
+	Name            string  `json:"name"`
+	Weight          int     `json:"weight"`           // 当前权重
+	Latency         int     `json:"latency"`          // 参考延迟 (ms)，0=没有测量数据
+	Loss            float64 `json:"loss"`             // 参考丢失率 (%)
+	Samples         int     `json:"samples"`          // 参与计算的测量节点数
+	SuggestedWeight int     `json:"suggested_weight"` // 建议权重 (没有测量数据时保持当前权重)
+}
+
+// NodeGroupWeightHints 按延迟矩阵为节点组成员计算权重建议
+// fromID 为流量来源节点 (如使用该组的隧道入口)，为 0 时使用所有测量节点到成员的平均延迟；
+// 延迟最低的成员为 latencyMaxWeight，其余按延迟反比缩放 (丢失率同样计入)
+func (s *Service) NodeGroupWeightHints(groupID, fromID uint) ([]NodeWeightHint, error) {
+	members, err := s.GetNodeGroupMembersWithNodes(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	cells := s.latestLatencies()
+	hints := make([]NodeWeightHint, 0, len(members))
+	minCost := math.MaxFloat64
+	costs := make([]float64, len(members))
+	for i, m := range members {
+		hint := NodeWeightHint{
+			MemberID:        m.Member.ID,
+			NodeID:          m.Node.ID,
+			Name:            m.Node.Name,
+			Weight:          m.Member.Weight,
+			SuggestedWeight: m.Member.Weight,
+		}
+		var latency, loss float64
+		for _, cell := range cells {
+			if cell.TargetID != m.Node.ID || (fromID != 0 && cell.SourceID != fromID) {
+				continue
+			}
+			hint.Samples++
+			latency += float64(cell.Latency)
+			loss += cell.Loss
+		}
+		if hint.Samples > 0 {
+			latency /= float64(hint.Samples)
+			loss /= float64(hint.Samples)
+			hint.Latency = int(math.Round(latency))
+			hint.Loss = math.Round(loss*10) / 10
+			if loss < 100 && latency > 0 {
+				costs[i] = latency * (1 + loss/100*2)
+				minCost = math.Min(minCost, costs[i])
+			}
+		}
+		hints = append(hints, hint)
+	}
+
+	for i := range hints {
+		switch {
+		case hints[i].Samples == 0:
+			// 没有测量数据，保持当前权重
+		case costs[i] == 0:
+			hints[i].SuggestedWeight = 1
+		default:
+			weight := int(math.Round(float64(latencyMaxWeight) * minCost / costs[i]))
+			hints[i].SuggestedWeight = max(weight, 1)
+		}
+	}
+	return hints, nil
+}
+
+// ApplyNodeGroupWeightHints 将权重建议写入节点组成员，返回变更的成员数
+func (s *Service) ApplyNodeGroupWeightHints(groupID, fromID uint) (int, error) {
+	hints, err := s.NodeGroupWeightHints(groupID, fromID)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, hint := range hints {
+			if hint.SuggestedWeight == hint.Weight {
+				continue
+			}
+			// 有效权重由健康评估按新权重重新计算
+			if err := tx.Model(&model.NodeGroupMember{}).Where("id = ? AND group_id = ?", hint.MemberID, groupID).
+				Updates(map[string]interface{}{"weight": hint.SuggestedWeight, "effective_weight": 0}).Error; err != nil {
+				return fmt.Errorf("更新成员 %s 权重失败: %v", hint.Name, err)
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if changed > 0 {
+		s.markGroupTunnelsPending(groupID)
+	}
+	return changed, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== 套餐限速/限连 ====================
+
+// GetActivePlan 获取资源所有者当前生效的套餐 (无所有者、无套餐或已过期返回 nil)
+func (s *Service) GetActivePlan(ownerID *uint) *model.Plan {
+	if ownerID == nil {
+		return nil
+	}
+	var user model.User
+	if err := s.db.Preload("Plan").First(&user, *ownerID).Error; err != nil {
+		return nil
+	}
+	if user.PlanID == nil || user.Plan == nil {
+		return nil
+	}
+	if user.PlanExpireAt != nil && user.PlanExpireAt.Before(time.Now()) {
+		return nil
+	}
+	return user.Plan
+}
+
+// GetCredentialSpeedLimit 共享节点上凭据的限速值 (bytes/s): 节点限速与用户套餐限速取较严格者
+func (s *Service) GetCredentialSpeedLimit(node *model.Node, clientID string) int64 {
+	limit := node.SpeedLimit
+	credID := ParseCredentialClientID(clientID)
+	if credID == 0 {
+		return limit
+	}
+
+	var cred model.ProxyCredential
+	if err := s.db.First(&cred, credID).Error; err != nil {
+		return limit
+	}
+	if plan := s.GetActivePlan(&cred.UserID); plan != nil {
+		limit = minSpeedLimit(limit, plan.SpeedLimit)
+	}
+	// 超限限速
+	if action, throttle := s.GetOwnerQuotaEnforcement(&cred.UserID); action == model.QuotaActionThrottle {
+		if throttle <= 0 {
+			throttle = model.DefaultQuotaThrottle
+		}
+		limit = minSpeedLimit(limit, throttle)
+	}
+	return limit
+}
+
+// minSpeedLimit 返回两个限速中较小的非零值 (0 表示不限速)
+func minSpeedLimit(a, b int64) int64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
+
+// TouchUserNodes 标记用户拥有的节点需要重新加载配置 (套餐变更后限制随之更新)
+func (s *Service) TouchUserNodes(userID uint) error {
+	return s.db.Model(&model.Node{}).Where("owner_id = ?", userID).Updates(map[string]interface{}{
+		"updated_at":    time.Now(),
+		"config_status": model.ConfigPending,
+	}).Error
+}
+
+// GetUserOwnedTunnels 获取用户拥有的已启用隧道
+func (s *Service) GetUserOwnedTunnels(userID uint) ([]model.Tunnel, error) {
+	var tunnels []model.Tunnel
+	err := s.db.Preload("EntryNode").Preload("ExitNode").
+		Where("owner_id = ? AND enabled = ?", userID, true).Find(&tunnels).Error
+	return tunnels, err
+}
+
+// TunnelEntryConfig 生成隧道入口配置 (叠加所有者套餐限制与超限处理)
+func (s *Service) TunnelEntryConfig(tunnel *model.Tunnel) map[string]interface{} {
+	generator := gost.NewConfigGenerator().
+		WithPlan(s.GetActivePlan(tunnel.OwnerID)).
+		WithOwnerQuota(s.GetOwnerQuotaEnforcement(tunnel.OwnerID))
+	return generator.GenerateTunnelEntryConfig(tunnel)
+}
+
+// ApplyUserLimits 套餐或超限状态变更后更新用户资源上的限制
+// 用户的节点与隧道所在节点标记为待同步，Agent 在下次心跳时发现配置变化并重新加载
+func (s *Service) ApplyUserLimits(userID uint) {
+	s.TouchUserNodes(userID)
+
+	tunnels, err := s.GetUserOwnedTunnels(userID)
+	if err != nil {
+		return
+	}
+	for i := range tunnels {
+		s.markTunnelNodesPending(&tunnels[i])
+	}
+}
+
+// GetPlanUserIDs 获取使用指定套餐的用户 ID
+func (s *Service) GetPlanUserIDs(planID uint) ([]uint, error) {
+	var ids []uint
+	err := s.db.Model(&model.User{}).Where("plan_id = ?", planID).Pluck("id", &ids).Error
+	return ids, err
+}
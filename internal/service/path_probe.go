@@ -0,0 +1,569 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== 路径探测 ====================
+//
+// Agent 定期对本节点承载的隧道与代理链发起合成探测 (TCP 连接、HTTP GET、DNS 查询):
+//   - 隧道入口节点经本机入口端口探测整条路径 (path)，出口节点直接探测目标 (target)
+//   - 代理链每个跳点节点探测下一跳 (hop)，最后一跳探测目标 (target)
+//
+// 出口节点连续多轮探测目标全部失败时视为不可达，入口节点的出口选择器不再使用该出口 (全部出口不可达时保持原样)
+
+// 探测类型
+const (
+	ProbeTCP  = "tcp"
+	ProbeHTTP = "http"
+	ProbeDNS  = "dns"
+	ProbeOff  = "off"
+)
+
+// 探测的资源类型
+const (
+	ProbeResourceTunnel = "tunnel"
+	ProbeResourceChain  = "chain"
+)
+
+const (
+	probeDefaultInterval = 60
+	probeMinInterval     = 10
+	probeMaxInterval     = 3600
+	probeAttempts        = 3
+	probeTimeoutMs       = 5000
+	// probeFreshWindow 汇总时只使用该时间内的结果
+	probeFreshWindow = 15 * time.Minute
+	// probeDownRounds 出口连续失败该轮数后判定不可达
+	probeDownRounds = 3
+	// probeRetention 探测记录保留时间
+	probeRetention = 7 * 24 * time.Hour
+)
+
+// PathProbeSpec 下发给 Agent 的探测任务
+type PathProbeSpec struct {
+	ResourceType string `json:"resource_type"`
+	ResourceID   uint   `json:"resource_id"`
+	Scope        string `json:"scope"`            // path/target/hop
+	Type         string `json:"type"`             // tcp/http/dns
+	Addr         string `json:"addr"`             // 连接的地址 host:port
+	Network      string `json:"network"`          // tcp/udp (仅 dns)
+	Target       string `json:"target,omitempty"` // http: 请求的 URL，dns: 查询的域名
+	Through      bool   `json:"through"`          // 经本机转发端口探测 (连接本机成功不代表路径可达)
+	Interval     int    `json:"interval"`         // 探测间隔 (秒)
+	Timeout      int    `json:"timeout"`          // 单次尝试超时 (毫秒)
+	Count        int    `json:"count"`            // 每轮尝试次数
+}
+
+// Key 探测任务的唯一标识 (Agent 按此记录上次执行时间，面板按此校验上报结果)
+func (p *PathProbeSpec) Key() string {
+	return fmt.Sprintf("%s-%d-%s-%s", p.ResourceType, p.ResourceID, p.Scope, p.Addr)
+}
+
+// PathProbeReport Agent 上报的一轮探测结果
+type PathProbeReport struct {
+	Key      string  `json:"key"`
+	Attempts int     `json:"attempts"`
+	Loss     float64 `json:"loss"`
+	Latency  int     `json:"latency"`
+	Error    string  `json:"error"`
+}
+
+// NormalizeProbeSettings 校验并规范化探测设置
+func NormalizeProbeSettings(probeType, target *string, interval *int) error {
+	*probeType = strings.ToLower(strings.TrimSpace(*probeType))
+	*target = strings.TrimSpace(*target)
+	if *probeType == "" {
+		*probeType = ProbeTCP
+	}
+	if *interval == 0 {
+		*interval = probeDefaultInterval
+	}
+	if *interval < probeMinInterval || *interval > probeMaxInterval {
+		return fmt.Errorf("探测间隔需在 %d-%d 秒之间", probeMinInterval, probeMaxInterval)
+	}
+
+	switch *probeType {
+	case ProbeOff:
+		return nil
+	case ProbeTCP:
+		if *target != "" {
+			if _, _, err := net.SplitHostPort(*target); err != nil {
+				return errors.New("TCP 探测目标需为 host:port")
+			}
+		}
+	case ProbeHTTP:
+		if *target != "" && !strings.HasPrefix(*target, "/") {
+			u, err := url.Parse(*target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.New("HTTP 探测目标需为 http(s):// 开头的 URL 或以 / 开头的路径")
+			}
+		}
+	case ProbeDNS:
+		if *target == "" {
+			return errors.New("DNS 探测需填写查询的域名")
+		}
+		if !hostnamePattern.MatchString(strings.TrimSuffix(strings.ToLower(*target), ".")) {
+			return errors.New("无效的 DNS 查询域名")
+		}
+	default:
+		return fmt.Errorf("不支持的探测类型: %s", *probeType)
+	}
+	return nil
+}
+
+// firstTargetAddr 目标地址的第一个端口 (多端口目标如 host:20000-20100)
+func firstTargetAddr(addr string) string {
+	host, ports, err := gost.SplitAddrPorts(addr)
+	if err != nil || len(ports) == 0 {
+		return addr
+	}
+	return net.JoinHostPort(host, strconv.Itoa(ports[0]))
+}
+
+// probeURL HTTP 探测的 URL: 完整 URL 原样使用，路径或空值拼接到目标地址上
+func probeURL(target, addr string) string {
+	if target != "" && !strings.HasPrefix(target, "/") {
+		return target
+	}
+	if target == "" {
+		target = "/"
+	}
+	scheme, host := "http", addr
+	if h, port, err := net.SplitHostPort(addr); err == nil {
+		switch port {
+		case "443":
+			scheme, host = "https", h
+		case "80":
+			host = h
+		}
+		if strings.Contains(host, ":") && host == h {
+			host = "[" + h + "]"
+		}
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, host, target)
+}
+
+// newProbeSpec 按探测设置生成探测任务，target 为路径最终要到达的地址 (没有时返回 nil)
+func newProbeSpec(resourceType string, resourceID uint, scope, probeType, probeTarget string, interval int, addr, target, protocol string) *PathProbeSpec {
+	if probeType == ProbeOff || addr == "" {
+		return nil
+	}
+	if probeType == "" {
+		probeType = ProbeTCP
+	}
+	if interval <= 0 {
+		interval = probeDefaultInterval
+	}
+	spec := &PathProbeSpec{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Scope:        scope,
+		Type:         probeType,
+		Addr:         addr,
+		Network:      "tcp",
+		Through:      scope == model.ProbeScopePath,
+		Interval:     interval,
+		Timeout:      probeTimeoutMs,
+		Count:        probeAttempts,
+	}
+	switch probeType {
+	case ProbeHTTP:
+		if target == "" {
+			return nil
+		}
+		spec.Target = probeURL(probeTarget, target)
+	case ProbeDNS:
+		spec.Target = probeTarget
+		if strings.Contains(protocol, "udp") {
+			spec.Network = "udp"
+		}
+	}
+	return spec
+}
+
+// NodeProbeSpecs 节点需要执行的探测任务
+func (s *Service) NodeProbeSpecs(node *model.Node) []PathProbeSpec {
+	var specs []PathProbeSpec
+	add := func(spec *PathProbeSpec) {
+		if spec != nil {
+			specs = append(specs, *spec)
+		}
+	}
+
+	// 隧道入口: 经本机入口端口探测整条路径
+	var entries []model.Tunnel
+	s.db.Where("entry_node_id = ? AND enabled = ?", node.ID, true).Order("id ASC").Find(&entries)
+	for i := range entries {
+		tunnel := &entries[i]
+		ports := gost.TunnelEntryPorts(tunnel)
+		if len(ports) == 0 {
+			continue
+		}
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(ports[0]))
+		target := firstTargetAddr(tunnel.TargetAddr)
+		// HTTP 探测经入口端口请求目标站点: URL 中的主机名用于 Host/SNI，连接始终发往入口端口
+		add(newProbeSpec(ProbeResourceTunnel, tunnel.ID, model.ProbeScopePath, tunnel.ProbeType, tunnel.ProbeTarget, tunnel.ProbeInterval, addr, target, tunnel.Protocol))
+	}
+
+	// 隧道出口: 直接探测目标
+	exits, _ := s.GetTunnelsByExitNode(node.ID)
+	for i := range exits {
+		tunnel := &exits[i]
+		target := firstTargetAddr(tunnel.TargetAddr)
+		add(newProbeSpec(ProbeResourceTunnel, tunnel.ID, model.ProbeScopeTarget, tunnel.ProbeType, tunnel.ProbeTarget, tunnel.ProbeInterval, target, target, tunnel.Protocol))
+	}
+
+	// 代理链: 跳点探测下一跳，最后一跳探测目标
+	var hops []model.ProxyChainHop
+	s.db.Where("node_id = ? AND enabled = ?", node.ID, true).Find(&hops)
+	seen := make(map[uint]bool)
+	for _, hop := range hops {
+		if seen[hop.ChainID] {
+			continue
+		}
+		seen[hop.ChainID] = true
+		chain, err := s.GetProxyChain(hop.ChainID)
+		if err != nil || !chain.Enabled {
+			continue
+		}
+		for _, spec := range s.chainProbeSpecs(chain, node.ID) {
+			add(&spec)
+		}
+	}
+	return specs
+}
+
+// chainProbeSpecs 代理链在指定节点上的探测任务
+func (s *Service) chainProbeSpecs(chain *model.ProxyChain, nodeID uint) []PathProbeSpec {
+	hops, _ := s.GetProxyChainHopsWithNodes(chain.ID)
+	var enabled []model.ProxyChainHop
+	for _, hop := range hops {
+		if hop.Enabled && hop.Node != nil {
+			enabled = append(enabled, hop)
+		}
+	}
+
+	var specs []PathProbeSpec
+	for i, hop := range enabled {
+		if hop.NodeID != nodeID {
+			continue
+		}
+		var spec *PathProbeSpec
+		if i+1 < len(enabled) {
+			next := enabled[i+1].Node
+			addr := net.JoinHostPort(next.Host, strconv.Itoa(next.Port))
+			probeType := chain.ProbeType
+			if probeType != ProbeOff {
+				// 下一跳是代理节点，只检查 TCP 连通性
+				probeType = ProbeTCP
+			}
+			spec = newProbeSpec(ProbeResourceChain, chain.ID, model.ProbeScopeHop, probeType, "", chain.ProbeInterval, addr, addr, "")
+		} else {
+			target := firstTargetAddr(chain.TargetAddr)
+			if target == "" && chain.ProbeType == ProbeTCP {
+				target = chain.ProbeTarget
+			}
+			if target == "" && chain.ProbeType == ProbeHTTP {
+				if u, err := url.Parse(chain.ProbeTarget); err == nil && u.Host != "" {
+					target = u.Host
+					if u.Port() == "" {
+						port := "80"
+						if u.Scheme == "https" {
+							port = "443"
+						}
+						target = net.JoinHostPort(u.Hostname(), port)
+					}
+				}
+			}
+			spec = newProbeSpec(ProbeResourceChain, chain.ID, model.ProbeScopeTarget, chain.ProbeType, chain.ProbeTarget, chain.ProbeInterval, target, target, chain.ListenType)
+		}
+		if spec != nil {
+			specs = append(specs, *spec)
+		}
+	}
+	return specs
+}
+
+// RecordPathProbeResults 记录节点上报的探测结果，只接受该节点当前的探测任务
+// 出口可达性变化时重新下发相关隧道的配置
+func (s *Service) RecordPathProbeResults(node *model.Node, reports []PathProbeReport) (int, error) {
+	specs := make(map[string]PathProbeSpec)
+	for _, spec := range s.NodeProbeSpecs(node) {
+		specs[spec.Key()] = spec
+	}
+
+	now := time.Now()
+	var results []model.PathProbeResult
+	tunnels := make(map[uint]bool)
+	for _, report := range reports {
+		spec, ok := specs[report.Key]
+		if !ok || report.Attempts <= 0 {
+			continue
+		}
+		loss := report.Loss
+		if loss < 0 {
+			loss = 0
+		} else if loss > 100 {
+			loss = 100
+		}
+		errMsg := report.Error
+		if len(errMsg) > 500 {
+			errMsg = errMsg[:500]
+		}
+		target := spec.Addr
+		if spec.Target != "" {
+			target = spec.Target
+		}
+		results = append(results, model.PathProbeResult{
+			ResourceType: spec.ResourceType,
+			ResourceID:   spec.ResourceID,
+			NodeID:       node.ID,
+			Scope:        spec.Scope,
+			Type:         spec.Type,
+			Target:       target,
+			Attempts:     report.Attempts,
+			Loss:         loss,
+			Latency:      report.Latency,
+			ErrorMsg:     errMsg,
+			CheckedAt:    now,
+		})
+		if spec.ResourceType == ProbeResourceTunnel && spec.Scope == model.ProbeScopeTarget {
+			tunnels[spec.ResourceID] = true
+		}
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	before := make(map[uint]map[uint]bool, len(tunnels))
+	for id := range tunnels {
+		before[id] = s.probeDownExits(id)
+	}
+	if err := s.db.Create(&results).Error; err != nil {
+		return 0, err
+	}
+	s.db.Where("checked_at < ?", now.Add(-probeRetention)).Delete(&model.PathProbeResult{})
+
+	for id := range tunnels {
+		after := s.probeDownExits(id)
+		if sameIDSet(before[id], after) {
+			continue
+		}
+		tunnel, err := s.GetTunnel(id)
+		if err != nil {
+			continue
+		}
+		log.Printf("[Probe] Tunnel %s exit reachability changed (node %s), %d exit(s) unreachable", tunnel.Name, node.Name, len(after))
+		s.markTunnelNodesPending(tunnel)
+	}
+	return len(results), nil
+}
+
+func sameIDSet(a, b map[uint]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id := range a {
+		if !b[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// probeDownExits 隧道中无法到达目标的出口节点: 最近 probeDownRounds 轮目标探测全部失败
+func (s *Service) probeDownExits(tunnelID uint) map[uint]bool {
+	var results []model.PathProbeResult
+	s.db.Where("resource_type = ? AND resource_id = ? AND scope = ? AND checked_at > ?",
+		ProbeResourceTunnel, tunnelID, model.ProbeScopeTarget, time.Now().Add(-probeFreshWindow)).
+		Order("checked_at DESC").Find(&results)
+
+	rounds := make(map[uint]int)
+	failed := make(map[uint]int)
+	for _, r := range results {
+		if rounds[r.NodeID] >= probeDownRounds {
+			continue
+		}
+		rounds[r.NodeID]++
+		if r.Loss >= 100 {
+			failed[r.NodeID]++
+		}
+	}
+	down := make(map[uint]bool)
+	for nodeID, n := range rounds {
+		if n >= probeDownRounds && failed[nodeID] == n {
+			down[nodeID] = true
+		}
+	}
+	return down
+}
+
+// applyProbeHealth 将探测判定不可达的出口标记为 unreachable 并排到最后，全部出口不可达时不做调整
+func (s *Service) applyProbeHealth(tunnel *model.Tunnel, exits []model.TunnelNode) []model.TunnelNode {
+	if tunnel.ID == 0 || len(exits) == 0 || tunnel.ProbeType == ProbeOff {
+		return exits
+	}
+	down := s.probeDownExits(tunnel.ID)
+	if len(down) == 0 {
+		return exits
+	}
+	reachable := 0
+	for _, exit := range exits {
+		if !down[exit.NodeID] {
+			reachable++
+		}
+	}
+	if reachable == 0 {
+		return exits
+	}
+	for i := range exits {
+		if down[exits[i].NodeID] {
+			exits[i].Status = model.TunnelNodeUnreachable
+		}
+	}
+	sort.SliceStable(exits, func(i, j int) bool {
+		return exits[i].Status != model.TunnelNodeUnreachable && exits[j].Status == model.TunnelNodeUnreachable
+	})
+	return exits
+}
+
+// latestProbeResults 资源最近的探测结果 (每个探测点每个目标取最新一条)
+func (s *Service) latestProbeResults(resourceType string, ids []uint) map[uint][]model.PathProbeResult {
+	latest := make(map[uint][]model.PathProbeResult)
+	if len(ids) == 0 {
+		return latest
+	}
+	var results []model.PathProbeResult
+	s.db.Where("resource_type = ? AND resource_id IN ? AND checked_at > ?", resourceType, ids, time.Now().Add(-probeFreshWindow)).
+		Order("checked_at DESC").Find(&results)
+
+	seen := make(map[string]bool)
+	for _, r := range results {
+		key := fmt.Sprintf("%d-%d-%s-%s", r.ResourceID, r.NodeID, r.Scope, r.Target)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		latest[r.ResourceID] = append(latest[r.ResourceID], r)
+	}
+	return latest
+}
+
+// summarizeProbes 汇总一组最近的探测结果
+// 隧道以端到端 (path) 结果为准，出口探测失败时为降级；代理链任一跳失败即失败，延迟为各跳之和
+func summarizeProbes(resourceType string, results []model.PathProbeResult) *model.PathProbeSummary {
+	if len(results) == 0 {
+		return nil
+	}
+	summary := &model.PathProbeSummary{Status: "ok"}
+	var pathLoss, otherLoss float64 = -1, 0
+	for _, r := range results {
+		if r.CheckedAt.After(summary.CheckedAt) {
+			summary.CheckedAt = r.CheckedAt
+		}
+		if r.Loss > summary.Loss {
+			summary.Loss = r.Loss
+		}
+		if r.Loss > 0 && r.ErrorMsg != "" && summary.Error == "" {
+			summary.Error = r.ErrorMsg
+		}
+		if resourceType == ProbeResourceTunnel && r.Scope == model.ProbeScopePath {
+			if r.Loss > pathLoss {
+				pathLoss = r.Loss
+			}
+			if r.Latency > summary.Latency {
+				summary.Latency = r.Latency
+			}
+			continue
+		}
+		if r.Loss > otherLoss {
+			otherLoss = r.Loss
+		}
+		if resourceType == ProbeResourceChain {
+			summary.Latency += r.Latency
+		}
+	}
+
+	switch {
+	case resourceType == ProbeResourceTunnel && pathLoss >= 0:
+		if pathLoss >= 100 {
+			summary.Status = "failed"
+		} else if pathLoss > 0 || otherLoss > 0 {
+			summary.Status = "degraded"
+		}
+	case otherLoss >= 100 && resourceType == ProbeResourceChain:
+		summary.Status = "failed"
+	case otherLoss >= 100:
+		// 隧道暂无端到端结果时，全部出口失败才视为失败
+		summary.Status = "degraded"
+		if allFailed(results) {
+			summary.Status = "failed"
+		}
+	case otherLoss > 0:
+		summary.Status = "degraded"
+	}
+	if summary.Status == "ok" {
+		summary.Error = ""
+	}
+	return summary
+}
+
+func allFailed(results []model.PathProbeResult) bool {
+	for _, r := range results {
+		if r.Loss < 100 {
+			return false
+		}
+	}
+	return true
+}
+
+// fillTunnelProbes 填充隧道最近的探测状态
+func (s *Service) fillTunnelProbes(tunnels ...*model.Tunnel) {
+	ids := make([]uint, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		if tunnel.ID != 0 {
+			ids = append(ids, tunnel.ID)
+		}
+	}
+	latest := s.latestProbeResults(ProbeResourceTunnel, ids)
+	for _, tunnel := range tunnels {
+		tunnel.Probe = summarizeProbes(ProbeResourceTunnel, latest[tunnel.ID])
+	}
+}
+
+// FillProxyChainProbes 填充代理链最近的探测状态
+func (s *Service) FillProxyChainProbes(chains ...*model.ProxyChain) {
+	ids := make([]uint, 0, len(chains))
+	for _, chain := range chains {
+		ids = append(ids, chain.ID)
+	}
+	latest := s.latestProbeResults(ProbeResourceChain, ids)
+	for _, chain := range chains {
+		chain.Probe = summarizeProbes(ProbeResourceChain, latest[chain.ID])
+	}
+}
+
+// ListPathProbeResults 资源的探测历史 (按时间倒序)
+func (s *Service) ListPathProbeResults(resourceType string, resourceID uint, since time.Time, limit int) ([]model.PathProbeResult, error) {
+	var results []model.PathProbeResult
+	err := s.db.Where("resource_type = ? AND resource_id = ? AND checked_at > ?", resourceType, resourceID, since).
+		Order("checked_at DESC").Limit(limit).Find(&results).Error
+	return results, err
+}
+
+// DeletePathProbeResults 删除资源的探测记录
+func (s *Service) DeletePathProbeResults(resourceType string, resourceID uint) {
+	s.db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).Delete(&model.PathProbeResult{})
+}
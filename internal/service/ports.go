@@ -0,0 +1,368 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 节点端口分配 ====================
+
+// 端口分配表记录每个节点上被占用的端口: 节点自身的保留端口 (主端口/API/指标/中继)、客户端远程端口与暴露端口、
+// 隧道入口与中继跳点端口、端口转发监听端口。资源写入与端口分配在同一事务中完成，
+// (node_id, port) 唯一索引保证并发创建时同一端口只会分配给一个资源
+
+const (
+	portResourceNode        = "node"
+	portResourceClient      = "client"
+	portResourceTunnel      = "tunnel"
+	portResourcePortForward = "port_forward"
+)
+
+var portResourceLabels = map[string]string{
+	portResourceNode:        "节点",
+	portResourceClient:      "客户端",
+	portResourceTunnel:      "隧道",
+	portResourcePortForward: "端口转发",
+}
+
+// defaultPortRange 未设置允许范围时自动分配端口的范围
+var defaultPortRange = []gost.PortRange{{From: 10000, To: 65535}}
+
+// portClaims 资源在各节点上占用的端口 (同一资源重复声明的端口只登记一次)
+type portClaims map[uint][]int
+
+func (c portClaims) add(nodeID uint, ports ...int) {
+	if nodeID == 0 {
+		return
+	}
+	for _, port := range ports {
+		if port > 0 && !containsPort(c[nodeID], port) {
+			c[nodeID] = append(c[nodeID], port)
+		}
+	}
+}
+
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+func nodePortClaims(node *model.Node) portClaims {
+	claims := portClaims{}
+	claims.add(node.ID, gost.NodeReservedPorts(node)...)
+	return claims
+}
+
+// clientPortClaims 客户端远程端口与启用的暴露端口 (暴露需已加载)
+func clientPortClaims(client *model.Client) portClaims {
+	claims := portClaims{}
+	claims.add(client.NodeID, client.RemotePort)
+	for _, exposure := range client.Exposures {
+		if exposure.Enabled {
+			claims.add(client.NodeID, exposure.RemotePort)
+		}
+	}
+	return claims
+}
+
+// tunnelPortClaims 隧道入口端口与各中继节点上的跳点端口 (跳点需已解析节点)
+func tunnelPortClaims(tunnel *model.Tunnel) portClaims {
+	claims := portClaims{}
+	claims.add(tunnel.EntryNodeID, gost.TunnelEntryPorts(tunnel)...)
+	for i := range tunnel.Hops {
+		hop := &tunnel.Hops[i]
+		for _, node := range hop.Nodes {
+			claims.add(node.NodeID, gost.TunnelHopPort(tunnel, hop))
+		}
+	}
+	return claims
+}
+
+func forwardPortClaims(forward *model.PortForward) portClaims {
+	claims := portClaims{}
+	if _, ports, err := gost.SplitAddrPorts(forward.LocalAddr); err == nil {
+		claims.add(forward.NodeID, ports...)
+	}
+	return claims
+}
+
+// allocatePorts 在事务中替换资源的端口分配: 释放原有端口后写入新端口，端口已被其他资源占用时返回错误
+func allocatePorts(tx *gorm.DB, resourceType string, resourceID uint, name string, ownerID *uint, claims portClaims) error {
+	if err := releasePorts(tx, resourceType, resourceID); err != nil {
+		return err
+	}
+
+	var allocs []model.PortAllocation
+	for nodeID, ports := range claims {
+		var holder model.PortAllocation
+		if err := tx.Where("node_id = ? AND port IN ?", nodeID, ports).Order("port").Limit(1).Find(&holder).Error; err != nil {
+			return err
+		}
+		if holder.ID != 0 {
+			return portConflictError(&holder, ownerID)
+		}
+		for _, port := range ports {
+			allocs = append(allocs, model.PortAllocation{
+				NodeID:       nodeID,
+				Port:         port,
+				ResourceType: resourceType,
+				ResourceID:   resourceID,
+				Name:         name,
+				OwnerID:      ownerID,
+			})
+		}
+	}
+	if len(allocs) == 0 {
+		return nil
+	}
+	// 并发分配同一端口时由唯一索引拒绝
+	if err := tx.CreateInBatches(allocs, 200).Error; err != nil {
+		return errors.New("端口已被占用，请更换端口后重试")
+	}
+	return nil
+}
+
+// releasePorts 释放资源占用的全部端口
+func releasePorts(tx *gorm.DB, resourceType string, resourceID uint) error {
+	return tx.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).Delete(&model.PortAllocation{}).Error
+}
+
+// portConflictError 端口冲突提示，被其他用户占用时不透露对方的资源名称
+func portConflictError(holder *model.PortAllocation, ownerID *uint) error {
+	if holder.ResourceType == portResourceNode {
+		return fmt.Errorf("端口 %d 是节点保留端口 (主端口/API/指标/中继/域名入口)", holder.Port)
+	}
+	if ownerID == nil || holder.OwnerID == nil || *holder.OwnerID != *ownerID {
+		return fmt.Errorf("端口 %d 已被其他用户占用", holder.Port)
+	}
+	return fmt.Errorf("端口 %d 已被%s「%s」占用", holder.Port, portResourceLabels[holder.ResourceType], holder.Name)
+}
+
+// ListPortAllocations 节点上的端口分配
+func (s *Service) ListPortAllocations(nodeID uint) ([]model.PortAllocation, error) {
+	var allocs []model.PortAllocation
+	err := s.db.Where("node_id = ?", nodeID).Order("port ASC").Find(&allocs).Error
+	return allocs, err
+}
+
+// allowedPortRanges 资源所有者在节点上允许使用的端口范围 (节点范围与套餐范围同时生效)
+// 管理员不受限制，返回 nil
+func (s *Service) allowedPortRanges(nodeID uint, ownerID *uint) ([][]gost.PortRange, error) {
+	if ownerID == nil {
+		return nil, nil
+	}
+	var user model.User
+	if err := s.db.Preload("Plan").First(&user, *ownerID).Error; err != nil || user.Role == "admin" {
+		return nil, nil
+	}
+
+	var specs []string
+	if node, err := s.GetNode(nodeID); err == nil && node.PortRange != "" {
+		specs = append(specs, node.PortRange)
+	}
+	if user.Plan != nil && user.Plan.PortRange != "" {
+		specs = append(specs, user.Plan.PortRange)
+	}
+
+	var allowed [][]gost.PortRange
+	for _, spec := range specs {
+		ranges, err := gost.ParsePortRanges(spec)
+		if err != nil {
+			return nil, fmt.Errorf("端口范围配置无效: %v", err)
+		}
+		allowed = append(allowed, ranges)
+	}
+	return allowed, nil
+}
+
+// CheckNodePorts 检查端口是否在允许范围内且未被节点上的其他资源占用 (kind/id 为资源自身，不与自己冲突)
+// 只用于提前给出提示，最终由分配事务保证不冲突
+func (s *Service) CheckNodePorts(nodeID uint, ports []int, kind string, id uint, ownerID *uint) error {
+	if nodeID == 0 || len(ports) == 0 {
+		return nil
+	}
+
+	allowed, err := s.allowedPortRanges(nodeID, ownerID)
+	if err != nil {
+		return err
+	}
+	for _, ranges := range allowed {
+		if port, ok := gost.PortsInRanges(ports, ranges); !ok {
+			return fmt.Errorf("端口 %d 不在允许使用的范围内", port)
+		}
+	}
+
+	var holder model.PortAllocation
+	s.db.Where("node_id = ? AND port IN ?", nodeID, ports).
+		Where("NOT (resource_type = ? AND resource_id = ?)", kind, id).
+		Order("port").Limit(1).Find(&holder)
+	if holder.ID != 0 {
+		return portConflictError(&holder, ownerID)
+	}
+	return nil
+}
+
+// FindFreePorts 在节点上查找 count 个连续的空闲端口 (在所有者允许的范围内)
+func (s *Service) FindFreePorts(nodeID uint, ownerID *uint, count int) ([]int, error) {
+	if count < 1 || count > gost.MaxPortsPerRule {
+		return nil, fmt.Errorf("端口数量必须在 1-%d 之间", gost.MaxPortsPerRule)
+	}
+	allowed, err := s.allowedPortRanges(nodeID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		// 不受限制时优先在节点范围内分配
+		allowed = [][]gost.PortRange{defaultPortRange}
+		if node, err := s.GetNode(nodeID); err == nil && node.PortRange != "" {
+			if ranges, err := gost.ParsePortRanges(node.PortRange); err == nil {
+				allowed[0] = ranges
+			}
+		}
+	}
+
+	var usedPorts []int
+	s.db.Model(&model.PortAllocation{}).Where("node_id = ?", nodeID).Pluck("port", &usedPorts)
+	used := make(map[int]bool, len(usedPorts))
+	for _, port := range usedPorts {
+		used[port] = true
+	}
+
+	// 在第一组范围内查找，候选端口需同时满足其余各组范围
+	for _, r := range allowed[0] {
+		var block []int
+		for port := r.From; port <= r.To; port++ {
+			free := !used[port]
+			for _, ranges := range allowed[1:] {
+				if _, ok := gost.PortsInRanges([]int{port}, ranges); !ok {
+					free = false
+				}
+			}
+			if !free {
+				block = block[:0]
+				continue
+			}
+			if block = append(block, port); len(block) == count {
+				return block, nil
+			}
+		}
+	}
+	return nil, errors.New("没有足够的空闲端口")
+}
+
+// NormalizeTunnelPorts 校验隧道的入口端口与目标端口，EntryPort 保持为第一个入口端口
+// 未指定入口端口时自动分配一个空闲端口
+func (s *Service) NormalizeTunnelPorts(tunnel *model.Tunnel) error {
+	ports := []int{tunnel.EntryPort}
+	switch {
+	case tunnel.EntryPorts != "":
+		var err error
+		if ports, err = gost.ParsePortSpec(tunnel.EntryPorts); err != nil {
+			return fmt.Errorf("入口端口: %v", err)
+		}
+	case tunnel.EntryPort == 0:
+		var err error
+		if ports, err = s.FindFreePorts(tunnel.EntryNodeID, tunnel.OwnerID, 1); err != nil {
+			return err
+		}
+	case tunnel.EntryPort < 1 || tunnel.EntryPort > 65535:
+		return fmt.Errorf("无效的入口端口: %d", tunnel.EntryPort)
+	}
+
+	tunnel.EntryPort = ports[0]
+	tunnel.EntryPorts = ""
+	if len(ports) > 1 {
+		tunnel.EntryPorts = gost.FormatPortSpec(ports)
+	}
+
+	if tunnel.TargetAddr != "" {
+		_, targets, err := gost.SplitAddrPorts(tunnel.TargetAddr)
+		if err != nil {
+			return fmt.Errorf("目标地址: %v", err)
+		}
+		if _, err := gost.MapTargetPorts(ports, targets); err != nil {
+			return err
+		}
+	}
+
+	return s.CheckNodePorts(tunnel.EntryNodeID, ports, portResourceTunnel, tunnel.ID, tunnel.OwnerID)
+}
+
+// NormalizePortForward 校验端口转发的监听与目标端口
+func (s *Service) NormalizePortForward(forward *model.PortForward) error {
+	host, ports, err := gost.SplitAddrPorts(forward.LocalAddr)
+	if err != nil {
+		return fmt.Errorf("监听地址: %v", err)
+	}
+	_, targets, err := gost.SplitAddrPorts(forward.RemoteAddr)
+	if err != nil {
+		return fmt.Errorf("目标地址: %v", err)
+	}
+	if _, err := gost.MapTargetPorts(ports, targets); err != nil {
+		return err
+	}
+
+	forward.LocalAddr = net.JoinHostPort(host, gost.FormatPortSpec(ports))
+	return s.CheckNodePorts(forward.NodeID, ports, portResourcePortForward, forward.ID, forward.OwnerID)
+}
+
+// NormalizeClientPorts 校验客户端在节点上映射的远程端口 (包括已加载的暴露端口)
+func (s *Service) NormalizeClientPorts(client *model.Client) error {
+	if client.RemotePort < 1 || client.RemotePort > 65535 {
+		return fmt.Errorf("无效的远程端口: %d", client.RemotePort)
+	}
+	return s.CheckNodePorts(client.NodeID, clientPortClaims(client)[client.NodeID], portResourceClient, client.ID, client.OwnerID)
+}
+
+// initPortAllocations 端口分配表为空时 (首次升级) 根据现有资源建立分配记录
+// 历史数据中已存在的冲突只记录日志，先登记的资源保留端口
+func (s *Service) initPortAllocations() {
+	var count int64
+	s.db.Model(&model.PortAllocation{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	var nodes []model.Node
+	s.db.Find(&nodes)
+	for i := range nodes {
+		s.backfillPorts(portResourceNode, nodes[i].ID, nodes[i].Name, nodes[i].OwnerID, nodePortClaims(&nodes[i]))
+	}
+	var clients []model.Client
+	s.db.Preload("Exposures").Find(&clients)
+	for i := range clients {
+		s.backfillPorts(portResourceClient, clients[i].ID, clients[i].Name, clients[i].OwnerID, clientPortClaims(&clients[i]))
+	}
+	var tunnels []model.Tunnel
+	s.db.Find(&tunnels)
+	for i := range tunnels {
+		tunnels[i].Hops = s.resolveTunnelHops(tunnels[i].ID)
+		s.backfillPorts(portResourceTunnel, tunnels[i].ID, tunnels[i].Name, tunnels[i].OwnerID, tunnelPortClaims(&tunnels[i]))
+	}
+	var forwards []model.PortForward
+	s.db.Find(&forwards)
+	for i := range forwards {
+		s.backfillPorts(portResourcePortForward, forwards[i].ID, forwards[i].Name, forwards[i].OwnerID, forwardPortClaims(&forwards[i]))
+	}
+}
+
+func (s *Service) backfillPorts(resourceType string, id uint, name string, ownerID *uint, claims portClaims) {
+	for nodeID, ports := range claims {
+		for _, port := range ports {
+			alloc := model.PortAllocation{NodeID: nodeID, Port: port, ResourceType: resourceType, ResourceID: id, Name: name, OwnerID: ownerID}
+			if err := s.db.Create(&alloc).Error; err != nil {
+				log.Printf("[Ports] %s %d: port %d on node %d is already allocated", resourceType, id, port, nodeID)
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== 超限处理 (数据面) ====================
+
+// normalizeQuotaAction 规范化超限处理方式，未知值按 fallback 处理
+func normalizeQuotaAction(action, fallback string) string {
+	switch action {
+	case model.QuotaActionNotify, model.QuotaActionThrottle, model.QuotaActionBlock:
+		return action
+	}
+	return fallback
+}
+
+// quotaAffectsDataPlane 超限处理是否需要重新下发配置 (仅通知不影响数据面)
+func quotaAffectsDataPlane(action string) bool {
+	return action == model.QuotaActionThrottle || action == model.QuotaActionBlock
+}
+
+// userQuotaPolicy 用户的超限处理方式与限速值，取自套餐
+// 没有套餐时阻断 (与共享节点凭据认证的原有行为一致)
+func (s *Service) userQuotaPolicy(user *model.User) (string, int64) {
+	if user.PlanID == nil {
+		return model.QuotaActionBlock, 0
+	}
+	plan, err := s.GetPlan(*user.PlanID)
+	if err != nil {
+		return model.QuotaActionBlock, 0
+	}
+	return normalizeQuotaAction(plan.QuotaAction, model.QuotaActionBlock), plan.QuotaThrottle
+}
+
+// GetOwnerQuotaEnforcement 获取资源所有者当前生效的超限处理 (未超限返回空)
+func (s *Service) GetOwnerQuotaEnforcement(ownerID *uint) (string, int64) {
+	if ownerID == nil {
+		return "", 0
+	}
+	var user model.User
+	if err := s.db.First(&user, *ownerID).Error; err != nil || user.QuotaEnforced == "" {
+		return "", 0
+	}
+	_, throttle := s.userQuotaPolicy(&user)
+	return user.QuotaEnforced, throttle
+}
+
+// EnforceNodeQuota 根据节点超限状态应用或解除超限处理
+// 状态变化且影响数据面时更新 updated_at，Agent 下次心跳重新拉取配置
+func (s *Service) EnforceNodeQuota(node *model.Node) {
+	desired := ""
+	if node.QuotaExceeded && node.TrafficQuota > 0 {
+		desired = normalizeQuotaAction(node.QuotaAction, model.QuotaActionNotify)
+	}
+	if desired == node.QuotaEnforced {
+		return
+	}
+
+	updates := map[string]interface{}{"quota_enforced": desired}
+	if quotaAffectsDataPlane(desired) || quotaAffectsDataPlane(node.QuotaEnforced) {
+		updates["updated_at"] = time.Now()
+	}
+	if err := s.db.Model(&model.Node{}).Where("id = ?", node.ID).Updates(updates).Error; err != nil {
+		log.Printf("[Quota] Failed to update node %d enforcement: %v", node.ID, err)
+		return
+	}
+
+	s.logQuotaTransition("node", node.ID, node.Name, node.QuotaEnforced, desired)
+	node.QuotaEnforced = desired
+}
+
+// EnforceUserQuota 根据用户超限状态应用或解除超限处理
+// 影响用户拥有的节点、隧道，以及用户在共享节点上的凭据
+func (s *Service) EnforceUserQuota(userID uint) {
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return
+	}
+
+	desired := ""
+	if user.QuotaExceeded {
+		desired, _ = s.userQuotaPolicy(&user)
+	}
+	if desired == user.QuotaEnforced {
+		return
+	}
+
+	if err := s.db.Model(&model.User{}).Where("id = ?", userID).Update("quota_enforced", desired).Error; err != nil {
+		log.Printf("[Quota] Failed to update user %d enforcement: %v", userID, err)
+		return
+	}
+
+	s.logQuotaTransition("user", user.ID, user.Username, user.QuotaEnforced, desired)
+	// 共享节点的认证结果随超限阻断变化
+	s.proxyAuthCache.Clear()
+
+	if quotaAffectsDataPlane(desired) || quotaAffectsDataPlane(user.QuotaEnforced) {
+		s.ApplyUserLimits(userID)
+	}
+}
+
+// ReconcileQuotaEnforcement 校正所有节点和用户的超限处理状态 (配额重置后调用)
+func (s *Service) ReconcileQuotaEnforcement() {
+	var nodes []model.Node
+	s.db.Where("quota_exceeded = ? OR quota_enforced <> ?", true, "").Find(&nodes)
+	for i := range nodes {
+		s.EnforceNodeQuota(&nodes[i])
+	}
+
+	var userIDs []uint
+	s.db.Model(&model.User{}).Where("quota_exceeded = ? OR quota_enforced <> ?", true, "").Pluck("id", &userIDs)
+	for _, id := range userIDs {
+		s.EnforceUserQuota(id)
+	}
+}
+
+// ResetQuotas 按重置日重置节点/客户端/用户配额，并解除相应的超限处理
+func (s *Service) ResetQuotas() {
+	s.alertService.ResetQuotas()
+	s.CheckAndResetUserQuotas()
+	s.ReconcileQuotaEnforcement()
+}
+
+// logQuotaTransition 记录超限处理变更 (先解除旧处理，再应用新处理)
+func (s *Service) logQuotaTransition(targetType string, targetID uint, targetName, from, to string) {
+	now := time.Now()
+	if from != "" {
+		reason := "配额已恢复"
+		if to != "" {
+			reason = "超限处理方式变更"
+		}
+		s.db.Create(&model.QuotaEnforcementLog{
+			TargetType: targetType,
+			TargetID:   targetID,
+			TargetName: targetName,
+			Action:     from,
+			Event:      "lift",
+			Reason:     reason,
+			CreatedAt:  now,
+		})
+		log.Printf("[Quota] Lifted %s on %s %d (%s)", from, targetType, targetID, targetName)
+	}
+	if to != "" {
+		s.db.Create(&model.QuotaEnforcementLog{
+			TargetType: targetType,
+			TargetID:   targetID,
+			TargetName: targetName,
+			Action:     to,
+			Event:      "apply",
+			Reason:     "流量超限",
+			CreatedAt:  now,
+		})
+		log.Printf("[Quota] Applied %s on %s %d (%s)", to, targetType, targetID, targetName)
+	}
+}
+
+// ListQuotaEnforcementLogs 获取超限处理变更记录
+func (s *Service) ListQuotaEnforcementLogs(targetType string, targetID uint, limit int) ([]model.QuotaEnforcementLog, error) {
+	var logs []model.QuotaEnforcementLog
+	query := s.db.Order("id desc")
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID > 0 {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	err := query.Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// ResetNodeQuota 手动重置节点配额 (同时解除超限处理)
+func (s *Service) ResetNodeQuota(id uint) error {
+	err := s.db.Model(&model.Node{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"quota_used":     0,
+		"quota_exceeded": false,
+		"quota_reset_at": time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	node, err := s.GetNode(id)
+	if err != nil {
+		return err
+	}
+	s.alertService.ResolveQuotaAlerts("node", node.ID, node.Name)
+	s.EnforceNodeQuota(node)
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/AliceNetworks/gost-panel/internal/config"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/notify"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := model.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return &Service{db: db, cfg: &config.Config{}, alertService: notify.NewAlertService(db)}
+}
+
+func reloadUser(t *testing.T, s *Service, id uint) model.User {
+	t.Helper()
+	var user model.User
+	if err := s.db.First(&user, id).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	return user
+}
+
+func TestUserQuotaResetLiftsEnforcement(t *testing.T) {
+	s := newTestService(t)
+
+	user := model.User{Username: "alice", Password: "x", Role: "user", Enabled: true, TrafficQuota: 1000}
+	if err := s.db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	node := model.Node{Name: "n1", Host: "1.1.1.1", Port: 1000, AgentToken: "tok-1", OwnerID: &user.ID}
+	if err := s.db.Create(&node).Error; err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+
+	// 节点心跳上报的流量超出用户配额 -> 阻断
+	if err := s.UpdateNodeStatus(node.ID, "online", 0, 800, 400); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	if got := reloadUser(t, s, user.ID); !got.QuotaExceeded || got.QuotaEnforced != model.QuotaActionBlock {
+		t.Fatalf("after overuse: exceeded=%v enforced=%q, want blocked", got.QuotaExceeded, got.QuotaEnforced)
+	}
+
+	// 重置配额解除阻断
+	if err := s.ResetUserQuota(user.ID); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if got := reloadUser(t, s, user.ID); got.QuotaExceeded || got.QuotaEnforced != "" || got.QuotaUsed != 0 {
+		t.Fatalf("after reset: exceeded=%v enforced=%q used=%d", got.QuotaExceeded, got.QuotaEnforced, got.QuotaUsed)
+	}
+
+	// 重置后的下一次心跳只计入新流量，不会重新阻断
+	if err := s.UpdateNodeStatus(node.ID, "online", 0, 100, 100); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	got := reloadUser(t, s, user.ID)
+	if got.QuotaExceeded || got.QuotaEnforced != "" {
+		t.Fatalf("heartbeat after reset re-applied enforcement: exceeded=%v enforced=%q", got.QuotaExceeded, got.QuotaEnforced)
+	}
+	if got.QuotaUsed != 200 {
+		t.Fatalf("quota_used = %d, want 200 (traffic since reset)", got.QuotaUsed)
+	}
+
+	// 隧道与共享节点凭据流量同样计入本周期用量
+	tunnel := model.Tunnel{Name: "t1", EntryNodeID: node.ID, EntryPort: 5000, OwnerID: &user.ID}
+	if err := s.db.Create(&tunnel).Error; err != nil {
+		t.Fatalf("create tunnel: %v", err)
+	}
+	s.UpdateTunnelTraffic(tunnel.ID, 300, 200)
+	cred := model.ProxyCredential{UserID: user.ID, Username: "u1", Password: "p", Enabled: true}
+	if err := s.db.Create(&cred).Error; err != nil {
+		t.Fatalf("create credential: %v", err)
+	}
+	if _, err := s.AddCredentialTraffic(cred.ID, 200, 100); err != nil {
+		t.Fatalf("credential traffic: %v", err)
+	}
+	if got := reloadUser(t, s, user.ID); got.QuotaUsed != 1000 || got.QuotaEnforced != model.QuotaActionBlock {
+		t.Fatalf("quota_used = %d enforced=%q, want 1000 and blocked", got.QuotaUsed, got.QuotaEnforced)
+	}
+}
@@ -3,8 +3,10 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/AliceNetworks/gost-panel/internal/config"
@@ -19,23 +21,43 @@ type Service struct {
 	cfg           *config.Config
 	alertService  *notify.AlertService
 	healthChecker *HealthChecker
+	telegramBot   *TelegramBot
+
+	groupHealthMu   sync.Mutex
+	groupHealthLast map[uint]time.Time // 节点组最近一次健康评估时间
+
+	proxyAuthCache sync.Map // 共享节点认证结果缓存 (见 AuthenticateProxyCredential)
 }
 
 func NewService(db *gorm.DB, cfg *config.Config) *Service {
 	alertSvc := notify.NewAlertService(db)
 	// 创建默认告警规则
 	alertSvc.CreateDefaultRules()
+	// 启动通知投递队列
+	alertSvc.Start()
 
 	svc := &Service{
 		db:           db,
 		cfg:          cfg,
 		alertService: alertSvc,
 	}
+	// 告警确认链接使用站点 URL 生成
+	alertSvc.SetAckLinkSigner(cfg.JWTSecret, func() string {
+		return svc.GetSiteConfig(model.ConfigSiteURL)
+	})
+
+	// 首次升级时根据现有资源建立端口分配表
+	svc.initPortAllocations()
 
 	// 启动健康检查 (每30秒检查一次)
 	svc.healthChecker = NewHealthChecker(db, alertSvc, 30*time.Second)
+	svc.healthChecker.OnChecked(svc.EvaluateNodeGroupHealth)
 	svc.healthChecker.Start()
 
+	// 启动交互式 Telegram 机器人 (未配置 Token 时空闲)
+	svc.telegramBot = NewTelegramBot(svc)
+	svc.telegramBot.Start()
+
 	return svc
 }
 
@@ -64,6 +86,10 @@ func (s *Service) Close() {
 	if s.healthChecker != nil {
 		s.healthChecker.Stop()
 	}
+	if s.telegramBot != nil {
+		s.telegramBot.Stop()
+	}
+	s.alertService.Stop()
 }
 
 // Ping 检查数据库连接
@@ -254,17 +280,55 @@ func (s *Service) CreateNode(node *model.Node) error {
 	node.Status = "offline"
 	node.CreatedAt = time.Now()
 	node.UpdatedAt = time.Now()
-	return s.db.Create(node).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(node).Error; err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourceNode, node.ID, node.Name, node.OwnerID, nodePortClaims(node))
+	})
 }
 
+// nodePortKeys 影响节点保留端口的字段
+var nodePortKeys = []string{"port", "api_port", "mtls_enabled", "mtls_port", "expose_http_port", "expose_https_port"}
+
+// UpdateNode 更新节点，端口变更时在同一事务中重新分配节点保留端口
 func (s *Service) UpdateNode(id uint, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
-	return s.db.Model(&model.Node{}).Where("id = ?", id).Updates(updates).Error
+	updates["config_status"] = model.ConfigPending
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Node{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		_, sharedChanged := updates["shared_auth"]
+		_, protocolChanged := updates["protocol"]
+		if sharedChanged || protocolChanged {
+			var node model.Node
+			if err := tx.First(&node, id).Error; err != nil {
+				return err
+			}
+			if node.SharedAuth && !gost.SharedAuthSupported(node.Protocol) {
+				return errors.New("ss/ssu 节点只有单一密码，不支持共享认证")
+			}
+		}
+		for _, key := range nodePortKeys {
+			if _, ok := updates[key]; ok {
+				var node model.Node
+				if err := tx.First(&node, id).Error; err != nil {
+					return err
+				}
+				return allocatePorts(tx, portResourceNode, node.ID, node.Name, node.OwnerID, nodePortClaims(&node))
+			}
+		}
+		return nil
+	})
 }
 
 func (s *Service) DeleteNode(id uint) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		// 删除关联的客户端
+		// 删除关联的客户端及其暴露
+		if err := tx.Where("client_id IN (?)", tx.Model(&model.Client{}).Select("id").Where("node_id = ?", id)).Delete(&model.ClientExposure{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Where("node_id = ?", id).Delete(&model.Client{}).Error; err != nil {
 			return err
 		}
@@ -272,6 +336,13 @@ func (s *Service) DeleteNode(id uint) error {
 		if err := tx.Where("node_id = ?", id).Delete(&model.Service{}).Error; err != nil {
 			return err
 		}
+		// 释放节点上的全部端口分配
+		if err := tx.Where("node_id = ?", id).Delete(&model.PortAllocation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("node_id = ?", id).Delete(&model.ExposureCertificate{}).Error; err != nil {
+			return err
+		}
 		// 删除节点
 		return tx.Delete(&model.Node{}, id).Error
 	})
@@ -310,62 +381,44 @@ func (s *Service) UpdateNodeStatus(id uint, status string, connections int, traf
 	// 重新获取更新后的节点信息
 	node, _ = s.GetNode(id)
 
-	// 检查节点离线
-	if previousStatus == "online" && status == "offline" {
+	// 检查节点离线/恢复
+	if previousStatus != status {
 		s.alertService.CheckNodeOffline(node, previousStatus)
 	}
 
-	// 检查流量配额
+	// 检查流量配额，并按超限处理方式更新数据面
 	s.alertService.CheckNodeQuota(node)
+	if node, err = s.GetNode(id); err != nil {
+		return nil
+	}
+	s.EnforceNodeQuota(node)
+	if node.OwnerID != nil {
+		s.addUserQuotaUsage(*node.OwnerID, trafficIn+trafficOut)
+	}
 
 	return nil
 }
 
 // TouchNode 更新节点的 updated_at 时间戳，用于触发配置同步
 func (s *Service) TouchNode(id uint) error {
-	return s.db.Model(&model.Node{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
-}
-
-// GetNodeConfigHash 获取节点配置的哈希值（基于 updated_at）
-func (s *Service) GetNodeConfigHash(id uint) string {
-	node, err := s.GetNode(id)
-	if err != nil {
-		return ""
-	}
-	// 使用 updated_at 的时间戳作为简单的版本号
-	return fmt.Sprintf("%d", node.UpdatedAt.Unix())
+	return s.db.Model(&model.Node{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"updated_at":    time.Now(),
+		"config_status": model.ConfigPending,
+	}).Error
 }
 
 // ==================== Client 操作 ====================
 
-// GetClientConfigHash 获取客户端配置的哈希值（考虑客户端和关联节点的更新时间）
-func (s *Service) GetClientConfigHash(id uint) string {
-	var client model.Client
-	if err := s.db.Preload("Node").First(&client, id).Error; err != nil {
-		return ""
-	}
-	// 使用客户端和节点的 updated_at 的最大值作为版本号
-	clientTime := client.UpdatedAt.Unix()
-	nodeTime := int64(0)
-	if client.Node.ID > 0 {
-		nodeTime = client.Node.UpdatedAt.Unix()
-	}
-	if nodeTime > clientTime {
-		return fmt.Sprintf("%d", nodeTime)
-	}
-	return fmt.Sprintf("%d", clientTime)
-}
-
 func (s *Service) ListClients() ([]model.Client, error) {
 	var clients []model.Client
-	err := s.db.Preload("Node").Order("id desc").Find(&clients).Error
+	err := s.db.Preload("Node").Preload("Exposures").Order("id desc").Find(&clients).Error
 	return clients, err
 }
 
 // ListClientsByOwner 获取指定用户的客户端列表
 func (s *Service) ListClientsByOwner(userID uint, isAdmin bool) ([]model.Client, error) {
 	var clients []model.Client
-	query := s.db.Preload("Node").Order("id desc")
+	query := s.db.Preload("Node").Preload("Exposures").Order("id desc")
 	if !isAdmin {
 		query = query.Where("owner_id = ? OR owner_id IS NULL", userID)
 	}
@@ -401,7 +454,7 @@ func (s *Service) ListClientsPaginated(userID uint, isAdmin bool, params Paginat
 
 	// 分页查询
 	offset := (params.Page - 1) * params.PageSize
-	if err := query.Preload("Node").Order(orderBy).Offset(offset).Limit(params.PageSize).Find(&clients).Error; err != nil {
+	if err := query.Preload("Node").Preload("Exposures").Order(orderBy).Offset(offset).Limit(params.PageSize).Find(&clients).Error; err != nil {
 		return nil, err
 	}
 
@@ -421,7 +474,7 @@ func (s *Service) ListClientsPaginated(userID uint, isAdmin bool, params Paginat
 
 func (s *Service) GetClient(id uint) (*model.Client, error) {
 	var client model.Client
-	err := s.db.Preload("Node").First(&client, id).Error
+	err := s.db.Preload("Node").Preload("Exposures").First(&client, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -431,7 +484,7 @@ func (s *Service) GetClient(id uint) (*model.Client, error) {
 // GetClientByOwner 获取客户端（检查权限）
 func (s *Service) GetClientByOwner(id uint, userID uint, isAdmin bool) (*model.Client, error) {
 	var client model.Client
-	query := s.db.Preload("Node").Where("id = ?", id)
+	query := s.db.Preload("Node").Preload("Exposures").Where("id = ?", id)
 	if !isAdmin {
 		query = query.Where("owner_id = ? OR owner_id IS NULL", userID)
 	}
@@ -453,22 +506,58 @@ func (s *Service) CreateClient(client *model.Client) error {
 	client.Status = "offline"
 	client.CreatedAt = time.Now()
 	client.UpdatedAt = time.Now()
-	return s.db.Create(client).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(client).Error; err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourceClient, client.ID, client.Name, client.OwnerID, clientPortClaims(client))
+	})
 }
 
+// UpdateClient 更新客户端，节点或远程端口变更时在同一事务中重新分配端口
 func (s *Service) UpdateClient(id uint, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
-	return s.db.Model(&model.Client{}).Where("id = ?", id).Updates(updates).Error
+	_, nodeChanged := updates["node_id"]
+	_, portChanged := updates["remote_port"]
+	var previous model.Client
+	if nodeChanged {
+		s.db.Select("id", "node_id").First(&previous, id)
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Client{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !nodeChanged && !portChanged {
+			return nil
+		}
+		var client model.Client
+		if err := tx.Preload("Exposures").First(&client, id).Error; err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourceClient, client.ID, client.Name, client.OwnerID, clientPortClaims(&client))
+	})
+	if err == nil && nodeChanged {
+		s.markHostExposureNodesPending(id, previous.NodeID)
+	}
+	return err
 }
 
 func (s *Service) DeleteClient(id uint) error {
-	return s.db.Delete(&model.Client{}, id).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := releasePorts(tx, portResourceClient, id); err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", id).Delete(&model.ClientExposure{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Client{}, id).Error
+	})
 }
 
 // GetClientByToken 通过 Token 获取客户端
 func (s *Service) GetClientByToken(token string) (*model.Client, error) {
 	var client model.Client
-	err := s.db.Preload("Node").Where("token = ?", token).First(&client).Error
+	err := s.db.Preload("Node").Preload("Exposures").Where("token = ?", token).First(&client).Error
 	return &client, err
 }
 
@@ -661,6 +750,9 @@ func (s *Service) DeleteUser(id uint) error {
 		return errors.New("cannot delete the last admin user")
 	}
 
+	// 删除用户的代理凭据
+	s.db.Where("user_id = ?", id).Delete(&model.ProxyCredential{})
+
 	return s.db.Delete(&model.User{}, id).Error
 }
 
@@ -944,8 +1036,18 @@ func (s *Service) GetUserTrafficSummary(userID uint) (*UserTrafficSummary, error
 		Select("COALESCE(SUM(traffic_in), 0) as traffic_in, COALESCE(SUM(traffic_out), 0) as traffic_out, COUNT(*) as count").
 		Scan(&tunnelResult)
 
-	summary.TotalTrafficIn = nodeResult.TrafficIn + clientResult.TrafficIn + tunnelResult.TrafficIn
-	summary.TotalTrafficOut = nodeResult.TrafficOut + clientResult.TrafficOut + tunnelResult.TrafficOut
+	// 统计用户在共享节点上的凭据流量
+	var credentialResult struct {
+		TrafficIn  int64
+		TrafficOut int64
+	}
+	s.db.Model(&model.ProxyCredential{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(traffic_in), 0) as traffic_in, COALESCE(SUM(traffic_out), 0) as traffic_out").
+		Scan(&credentialResult)
+
+	summary.TotalTrafficIn = nodeResult.TrafficIn + clientResult.TrafficIn + tunnelResult.TrafficIn + credentialResult.TrafficIn
+	summary.TotalTrafficOut = nodeResult.TrafficOut + clientResult.TrafficOut + tunnelResult.TrafficOut + credentialResult.TrafficOut
 	summary.TotalQuotaUsed = nodeResult.QuotaUsed + clientResult.QuotaUsed
 	summary.NodesCount = nodeResult.Count
 	summary.ClientsCount = clientResult.Count
@@ -981,31 +1083,38 @@ func (s *Service) CheckUserQuota(userID uint) (bool, error) {
 		return false, nil
 	}
 
-	// 获取用户流量汇总
-	summary, err := s.GetUserTrafficSummary(userID)
-	if err != nil {
-		return false, err
+	// quota_used 为本周期用量 (由 addUserQuotaUsage 累加，重置配额时清零)
+	exceeded := user.QuotaUsed >= user.TrafficQuota
+	if exceeded != user.QuotaExceeded {
+		s.db.Model(&model.User{}).Where("id = ?", userID).Update("quota_exceeded", exceeded)
+		s.EnforceUserQuota(userID)
 	}
 
-	totalUsed := summary.TotalTrafficIn + summary.TotalTrafficOut
-	exceeded := totalUsed >= user.TrafficQuota
-
-	// 更新用户的 quota_used 和 quota_exceeded
-	s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"quota_used":     totalUsed,
-		"quota_exceeded": exceeded,
-	})
-
 	return exceeded, nil
 }
 
-// ResetUserQuota 重置用户配额
+// addUserQuotaUsage 将流量增量计入用户本周期用量并检查配额
+// 计入范围与 GetUserTrafficSummary 一致: 拥有的节点/客户端/隧道流量 + 共享节点凭据流量
+func (s *Service) addUserQuotaUsage(userID uint, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	s.db.Model(&model.User{}).Where("id = ?", userID).Update("quota_used", gorm.Expr("quota_used + ?", bytes))
+	s.CheckUserQuota(userID)
+}
+
+// ResetUserQuota 重置用户配额 (同时解除超限处理)
 func (s *Service) ResetUserQuota(userID uint) error {
-	return s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"quota_used":      0,
-		"quota_exceeded":  false,
-		"quota_reset_at":  time.Now(),
+	err := s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"quota_used":     0,
+		"quota_exceeded": false,
+		"quota_reset_at": time.Now(),
 	}).Error
+	if err != nil {
+		return err
+	}
+	s.EnforceUserQuota(userID)
+	return nil
 }
 
 // CheckAndResetUserQuotas 检查并重置所有用户的配额 (按月重置日)
@@ -1238,18 +1347,38 @@ func (s *Service) GetPortForwardByOwner(id uint, userID uint, isAdmin bool) (*mo
 func (s *Service) CreatePortForward(forward *model.PortForward) error {
 	forward.CreatedAt = time.Now()
 	forward.UpdatedAt = time.Now()
-	return s.db.Create(forward).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(forward).Error; err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourcePortForward, forward.ID, forward.Name, forward.OwnerID, forwardPortClaims(forward))
+	})
 }
 
+// UpdatePortForward 更新端口转发并在同一事务中重新分配监听端口
 func (s *Service) UpdatePortForward(id uint, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
 	delete(updates, "id")
 	delete(updates, "created_at")
-	return s.db.Model(&model.PortForward{}).Where("id = ?", id).Updates(updates).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PortForward{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		var forward model.PortForward
+		if err := tx.First(&forward, id).Error; err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourcePortForward, forward.ID, forward.Name, forward.OwnerID, forwardPortClaims(&forward))
+	})
 }
 
 func (s *Service) DeletePortForward(id uint) error {
-	return s.db.Delete(&model.PortForward{}, id).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := releasePorts(tx, portResourcePortForward, id); err != nil {
+			return err
+		}
+		return tx.Delete(&model.PortForward{}, id).Error
+	})
 }
 
 // ==================== 节点组 (负载均衡) ====================
@@ -1297,15 +1426,32 @@ func (s *Service) UpdateNodeGroup(id uint, updates map[string]interface{}) error
 	updates["updated_at"] = time.Now()
 	delete(updates, "id")
 	delete(updates, "created_at")
-	return s.db.Model(&model.NodeGroup{}).Where("id = ?", id).Updates(updates).Error
+	if err := s.db.Model(&model.NodeGroup{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return err
+	}
+	s.markGroupTunnelsPending(id)
+	return nil
 }
 
 func (s *Service) DeleteNodeGroup(id uint) error {
+	var count int64
+	s.db.Model(&model.Tunnel{}).Where("exit_group_id = ?", id).Count(&count)
+	if count > 0 {
+		return errors.New("该节点组正在被隧道用作出口，无法删除")
+	}
+	s.db.Model(&model.TunnelHop{}).Where("group_id = ?", id).Count(&count)
+	if count > 0 {
+		return errors.New("该节点组正在被隧道用作中继，无法删除")
+	}
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		// 删除组成员
 		if err := tx.Where("group_id = ?", id).Delete(&model.NodeGroupMember{}).Error; err != nil {
 			return err
 		}
+		// 删除健康调整记录
+		if err := tx.Where("group_id = ?", id).Delete(&model.NodeGroupHealthEvent{}).Error; err != nil {
+			return err
+		}
 		// 删除组
 		return tx.Delete(&model.NodeGroup{}, id).Error
 	})
@@ -1326,11 +1472,37 @@ func (s *Service) AddNodeGroupMember(member *model.NodeGroupMember) error {
 	if count > 0 {
 		return errors.New("node already in group")
 	}
-	return s.db.Create(member).Error
+	// 该组作为隧道中继跳点时，新成员需要为隧道分配中继端口
+	tunnels := s.groupHopTunnels(member.GroupID, member.NodeID)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return allocateTunnelPorts(tx, tunnels)
+	})
+	if err != nil {
+		return err
+	}
+	s.markGroupTunnelsPending(member.GroupID)
+	return nil
 }
 
+// RemoveNodeGroupMember 移除组成员，移除的节点不再作为隧道出口时也需要重新加载配置
 func (s *Service) RemoveNodeGroupMember(id uint) error {
-	return s.db.Delete(&model.NodeGroupMember{}, id).Error
+	var member model.NodeGroupMember
+	s.db.First(&member, id)
+	if err := s.db.Delete(&model.NodeGroupMember{}, id).Error; err != nil {
+		return err
+	}
+	if member.GroupID != 0 {
+		// 释放移除的节点上为隧道中继分配的端口
+		s.db.Transaction(func(tx *gorm.DB) error {
+			return allocateTunnelPorts(tx, s.groupHopTunnels(member.GroupID, 0))
+		})
+		s.markGroupTunnelsPending(member.GroupID)
+		s.MarkNodesConfigPending(member.NodeID)
+	}
+	return nil
 }
 
 func (s *Service) GetNodeGroupMembersWithNodes(groupID uint) ([]gost.NodeMemberWithNode, error) {
@@ -1430,6 +1602,7 @@ func (s *Service) UpdateProxyChainMap(id uint, updates map[string]interface{}) e
 func (s *Service) DeleteProxyChain(id uint) error {
 	// 先删除跳点
 	s.db.Where("chain_id = ?", id).Delete(&model.ProxyChainHop{})
+	s.DeletePathProbeResults(ProbeResourceChain, id)
 	return s.db.Delete(&model.ProxyChain{}, id).Error
 }
 
@@ -1441,6 +1614,11 @@ func (s *Service) ListProxyChains(ownerID *uint) ([]model.ProxyChain, error) {
 		query = query.Where("owner_id = ? OR owner_id IS NULL", *ownerID)
 	}
 	err := query.Order("id ASC").Find(&chains).Error
+	ptrs := make([]*model.ProxyChain, len(chains))
+	for i := range chains {
+		ptrs[i] = &chains[i]
+	}
+	s.FillProxyChainProbes(ptrs...)
 	return chains, err
 }
 
@@ -1475,84 +1653,237 @@ func (s *Service) GetProxyChainHopsWithNodes(chainID uint) ([]model.ProxyChainHo
 
 // ==================== 隧道转发 (入口-出口模式) ====================
 
-// CreateTunnel 创建隧道
+// CreateTunnel 创建隧道 (连同中继跳点)，入口与中继端口在同一事务中分配
 func (s *Service) CreateTunnel(tunnel *model.Tunnel) error {
-	return s.db.Create(tunnel).Error
+	s.resolveMissingHopNodes(tunnel.Hops)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tunnel).Error; err != nil {
+			return err
+		}
+		if err := replaceTunnelHops(tx, tunnel.ID, tunnel.Hops); err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourceTunnel, tunnel.ID, tunnel.Name, tunnel.OwnerID, tunnelPortClaims(tunnel))
+	})
+	if err != nil {
+		return err
+	}
+	s.markTunnelNodesPending(tunnel)
+	return nil
 }
 
 // GetTunnel 获取隧道
 func (s *Service) GetTunnel(id uint) (*model.Tunnel, error) {
 	var tunnel model.Tunnel
-	err := s.db.Preload("EntryNode").Preload("ExitNode").First(&tunnel, id).Error
+	err := s.db.Preload("EntryNode").Preload("ExitNode").Preload("ExitGroup").First(&tunnel, id).Error
+	s.prepareTunnels(&tunnel)
 	return &tunnel, err
 }
 
 // GetTunnelByOwner 获取隧道（检查权限）
 func (s *Service) GetTunnelByOwner(id uint, userID uint, isAdmin bool) (*model.Tunnel, error) {
 	var tunnel model.Tunnel
-	query := s.db.Preload("EntryNode").Preload("ExitNode").Where("id = ?", id)
+	query := s.db.Preload("EntryNode").Preload("ExitNode").Preload("ExitGroup").Where("id = ?", id)
 	if !isAdmin {
 		query = query.Where("owner_id = ? OR owner_id IS NULL", userID)
 	}
 	err := query.First(&tunnel).Error
+	s.prepareTunnels(&tunnel)
 	return &tunnel, err
 }
 
 // UpdateTunnel 更新隧道
 func (s *Service) UpdateTunnel(tunnel *model.Tunnel) error {
-	return s.db.Save(tunnel).Error
+	var old model.Tunnel
+	s.db.First(&old, tunnel.ID)
+	old.Hops = s.resolveTunnelHops(tunnel.ID)
+	tunnel.Hops = old.Hops
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(tunnel).Error; err != nil {
+			return err
+		}
+		return allocatePorts(tx, portResourceTunnel, tunnel.ID, tunnel.Name, tunnel.OwnerID, tunnelPortClaims(tunnel))
+	})
+	if err != nil {
+		return err
+	}
+	s.markTunnelNodesPending(&old, tunnel)
+	return nil
 }
 
 // UpdateTunnelMap 通过 map 更新隧道 (安全更新，防止字段篡改)
+// 更换入口/出口节点时新旧节点都需要重新加载配置
 func (s *Service) UpdateTunnelMap(id uint, updates map[string]interface{}) error {
-	return s.db.Model(&model.Tunnel{}).Where("id = ?", id).Updates(updates).Error
+	return s.updateTunnel(id, updates, nil, false)
 }
 
-// DeleteTunnel 删除隧道
+// UpdateTunnelWithHops 更新隧道并整体替换中继跳点，入口与新旧中继节点都需要重新加载配置
+func (s *Service) UpdateTunnelWithHops(id uint, updates map[string]interface{}, hops []model.TunnelHop) error {
+	return s.updateTunnel(id, updates, hops, true)
+}
+
+// updateTunnel 在同一事务中更新隧道、替换中继跳点并重新分配入口与中继端口
+func (s *Service) updateTunnel(id uint, updates map[string]interface{}, hops []model.TunnelHop, replaceHops bool) error {
+	var old, updated model.Tunnel
+	if err := s.db.First(&old, id).Error; err != nil {
+		return err
+	}
+	old.Hops = s.resolveTunnelHops(id)
+	if replaceHops {
+		s.resolveMissingHopNodes(hops)
+	} else {
+		hops = old.Hops
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&model.Tunnel{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if replaceHops {
+			if err := replaceTunnelHops(tx, id, hops); err != nil {
+				return err
+			}
+		}
+		if err := tx.First(&updated, id).Error; err != nil {
+			return err
+		}
+		updated.Hops = hops
+		return allocatePorts(tx, portResourceTunnel, id, updated.Name, updated.OwnerID, tunnelPortClaims(&updated))
+	})
+	if err != nil {
+		return err
+	}
+	s.markTunnelNodesPending(&old, &updated)
+	return nil
+}
+
+// DeleteTunnel 删除隧道 (连同中继跳点)
 func (s *Service) DeleteTunnel(id uint) error {
-	return s.db.Delete(&model.Tunnel{}, id).Error
+	var tunnel model.Tunnel
+	s.db.First(&tunnel, id)
+	tunnel.Hops = s.resolveTunnelHops(id)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tunnel_id = ?", id).Delete(&model.TunnelHop{}).Error; err != nil {
+			return err
+		}
+		if err := releasePorts(tx, portResourceTunnel, id); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Tunnel{}, id).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.DeletePathProbeResults(ProbeResourceTunnel, id)
+	s.markTunnelNodesPending(&tunnel)
+	return nil
 }
 
-// UpdateTunnelTraffic 更新隧道流量统计 (增量)
+// UpdateTunnelTraffic 更新隧道流量统计 (增量)，并计入所有者的配额用量
 func (s *Service) UpdateTunnelTraffic(id uint, trafficIn, trafficOut int64) error {
-	return s.db.Model(&model.Tunnel{}).Where("id = ?", id).
+	err := s.db.Model(&model.Tunnel{}).Where("id = ?", id).
 		Updates(map[string]interface{}{
 			"traffic_in":  gorm.Expr("traffic_in + ?", trafficIn),
 			"traffic_out": gorm.Expr("traffic_out + ?", trafficOut),
 		}).Error
+	if err != nil {
+		return err
+	}
+	var tunnel model.Tunnel
+	if s.db.Select("id", "owner_id").First(&tunnel, id).Error == nil && tunnel.OwnerID != nil {
+		s.addUserQuotaUsage(*tunnel.OwnerID, trafficIn+trafficOut)
+	}
+	return nil
 }
 
-// UpdateClientTraffic 更新客户端流量统计 (增量)
+// UpdateClientTraffic 更新客户端流量统计 (增量)，并计入所有者的配额用量
 func (s *Service) UpdateClientTraffic(id uint, trafficIn, trafficOut int64) error {
-	return s.db.Model(&model.Client{}).Where("id = ?", id).
+	err := s.db.Model(&model.Client{}).Where("id = ?", id).
 		Updates(map[string]interface{}{
 			"traffic_in":  gorm.Expr("traffic_in + ?", trafficIn),
 			"traffic_out": gorm.Expr("traffic_out + ?", trafficOut),
 		}).Error
+	if err != nil {
+		return err
+	}
+	var client model.Client
+	if s.db.Select("id", "owner_id").First(&client, id).Error == nil && client.OwnerID != nil {
+		s.addUserQuotaUsage(*client.OwnerID, trafficIn+trafficOut)
+	}
+	return nil
+}
+
+// RecordTunnelEntryTraffic 记录节点上报的隧道流量，只接受隧道入口节点的上报
+// 隧道流量计入所有者的配额，其他节点 (如用户自有节点) 伪造的上报会耗尽他人配额
+func (s *Service) RecordTunnelEntryTraffic(tunnelID, nodeID uint, trafficIn, trafficOut int64) error {
+	var count int64
+	s.db.Model(&model.Tunnel{}).Where("id = ? AND entry_node_id = ?", tunnelID, nodeID).Count(&count)
+	if count == 0 {
+		return errors.New("node is not the entry of this tunnel")
+	}
+	return s.UpdateTunnelTraffic(tunnelID, trafficIn, trafficOut)
+}
+
+// RecordNodeClientTraffic 记录节点上报的客户端流量，只接受客户端绑定节点的上报
+func (s *Service) RecordNodeClientTraffic(clientID, nodeID uint, trafficIn, trafficOut int64) error {
+	var count int64
+	s.db.Model(&model.Client{}).Where("id = ? AND node_id = ?", clientID, nodeID).Count(&count)
+	if count == 0 {
+		return errors.New("node is not bound to this client")
+	}
+	return s.UpdateClientTraffic(clientID, trafficIn, trafficOut)
 }
 
 // ListTunnels 获取隧道列表
 func (s *Service) ListTunnels(ownerID *uint) ([]model.Tunnel, error) {
 	var tunnels []model.Tunnel
-	query := s.db.Preload("EntryNode").Preload("ExitNode")
+	query := s.db.Preload("EntryNode").Preload("ExitNode").Preload("ExitGroup")
 	if ownerID != nil {
 		query = query.Where("owner_id = ? OR owner_id IS NULL", *ownerID)
 	}
 	err := query.Order("id ASC").Find(&tunnels).Error
+	for i := range tunnels {
+		s.prepareTunnels(&tunnels[i])
+	}
 	return tunnels, err
 }
 
-// GetTunnelsByEntryNode 获取指定入口节点的所有隧道
+// GetTunnelsByEntryNode 获取指定入口节点的所有隧道 (含解析后的出口节点与中继跳点)
 func (s *Service) GetTunnelsByEntryNode(nodeID uint) ([]model.Tunnel, error) {
 	var tunnels []model.Tunnel
-	err := s.db.Preload("ExitNode").Where("entry_node_id = ? AND enabled = ?", nodeID, true).Find(&tunnels).Error
+	err := s.db.Preload("EntryNode").Preload("ExitNode").Preload("ExitGroup").Where("entry_node_id = ? AND enabled = ?", nodeID, true).Find(&tunnels).Error
+	for i := range tunnels {
+		tunnels[i].Exits = s.resolveTunnelExits(&tunnels[i])
+		tunnels[i].Hops = s.resolveTunnelHops(tunnels[i].ID)
+	}
 	return tunnels, err
 }
 
-// GetTunnelsByExitNode 获取指定出口节点的所有隧道
+// GetTunnelsByExitNode 获取以指定节点为出口的所有隧道 (包括出口列表与出口节点组)
 func (s *Service) GetTunnelsByExitNode(nodeID uint) ([]model.Tunnel, error) {
+	var candidates []model.Tunnel
+	err := s.db.Preload("EntryNode").Preload("ExitNode").Preload("ExitGroup").
+		Where("enabled = ?", true).
+		Where("exit_node_id = ? OR (',' || exit_node_ids || ',') LIKE ? OR exit_group_id IN (?)",
+			nodeID, fmt.Sprintf("%%,%d,%%", nodeID),
+			s.db.Model(&model.NodeGroupMember{}).Select("group_id").Where("node_id = ?", nodeID)).
+		Order("id ASC").Find(&candidates).Error
+
 	var tunnels []model.Tunnel
-	err := s.db.Preload("EntryNode").Where("exit_node_id = ? AND enabled = ?", nodeID, true).Find(&tunnels).Error
+	for i := range candidates {
+		tunnel := candidates[i]
+		tunnel.Exits = s.resolveTunnelExits(&tunnel)
+		for _, exit := range tunnel.Exits {
+			if exit.NodeID == nodeID {
+				// 出口按最后一跳的节点判断隧道是否经 mTLS 中继接入
+				tunnel.Hops = s.resolveTunnelHops(tunnel.ID)
+				tunnels = append(tunnels, tunnel)
+				break
+			}
+		}
+	}
 	return tunnels, err
 }
 
@@ -1572,6 +1903,25 @@ func (s *Service) GetNotifyChannel(id uint) (*model.NotifyChannel, error) {
 	return &channel, err
 }
 
+// GetEmailSender 获取系统邮件发送器 (第一个已启用的公共 SMTP 渠道)，未配置时返回 nil
+func (s *Service) GetEmailSender() *notify.EmailSender {
+	var channels []model.NotifyChannel
+	s.db.Where("type IN ? AND enabled = ? AND user_id = ?", []string{"smtp", "email"}, true, 0).Order("id asc").Find(&channels)
+
+	for _, ch := range channels {
+		var smtpConfig model.SMTPConfig
+		if err := json.Unmarshal([]byte(ch.Config), &smtpConfig); err != nil || smtpConfig.Host == "" {
+			continue
+		}
+		siteName := s.GetSiteConfig(model.ConfigSiteName)
+		if siteName == "" {
+			siteName = "GOST Panel"
+		}
+		return notify.NewEmailSender(&smtpConfig, siteName, s.GetSiteConfig(model.ConfigSiteURL))
+	}
+	return nil
+}
+
 // CreateNotifyChannel 创建通知渠道
 func (s *Service) CreateNotifyChannel(channel *model.NotifyChannel) error {
 	return s.db.Create(channel).Error
@@ -1856,7 +2206,7 @@ func (s *Service) AssignUserPlan(userID, planID uint) error {
 		expireAt = &expire
 	}
 
-	return s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+	err = s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
 		"plan_id":           planID,
 		"plan_start_at":     now,
 		"plan_expire_at":    expireAt,
@@ -1866,16 +2216,27 @@ func (s *Service) AssignUserPlan(userID, planID uint) error {
 		"quota_used":        0,
 		"quota_exceeded":    false,
 	}).Error
+	if err != nil {
+		return err
+	}
+	s.EnforceUserQuota(userID)
+	return nil
 }
 
 // RemoveUserPlan 移除用户套餐
 func (s *Service) RemoveUserPlan(userID uint) error {
-	return s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+	err := s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
 		"plan_id":           nil,
 		"plan_start_at":     nil,
 		"plan_expire_at":    nil,
 		"plan_traffic_used": 0,
 	}).Error
+	if err != nil {
+		return err
+	}
+	// 超限处理方式随套餐变化
+	s.EnforceUserQuota(userID)
+	return nil
 }
 
 // RenewUserPlan 续期用户套餐
@@ -1899,12 +2260,17 @@ func (s *Service) RenewUserPlan(userID uint, days int) error {
 
 	newExpireAt := baseTime.AddDate(0, 0, days)
 
-	return s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+	err := s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
 		"plan_expire_at":    newExpireAt,
 		"plan_traffic_used": 0,
 		"quota_used":        0,
 		"quota_exceeded":    false,
 	}).Error
+	if err != nil {
+		return err
+	}
+	s.EnforceUserQuota(userID)
+	return nil
 }
 
 // CheckUserPlanStatus 检查用户套餐状态 (是否过期或超限)
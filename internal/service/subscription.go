@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== 订阅链接 ====================
+
+// GetSubscriptionToken 获取用户订阅令牌，不存在时自动生成
+func (s *Service) GetSubscriptionToken(userID uint) (string, error) {
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	if user.SubscribeToken != "" {
+		return user.SubscribeToken, nil
+	}
+	return s.ResetSubscriptionToken(userID)
+}
+
+// ResetSubscriptionToken 重新生成订阅令牌 (旧链接立即失效)
+func (s *Service) ResetSubscriptionToken(userID uint) (string, error) {
+	token := generateToken()
+	err := s.db.Model(&model.User{}).Where("id = ?", userID).Update("subscribe_token", token).Error
+	return token, err
+}
+
+// RevokeSubscriptionToken 撤销订阅令牌
+func (s *Service) RevokeSubscriptionToken(userID uint) error {
+	return s.db.Model(&model.User{}).Where("id = ?", userID).Update("subscribe_token", "").Error
+}
+
+// GetUserBySubscriptionToken 通过订阅令牌获取用户
+func (s *Service) GetUserBySubscriptionToken(token string) (*model.User, error) {
+	if token == "" {
+		return nil, errors.New("invalid token")
+	}
+	var user model.User
+	if err := s.db.Preload("Plan").Where("subscribe_token = ?", token).First(&user).Error; err != nil {
+		return nil, err
+	}
+	if !user.Enabled {
+		return nil, errors.New("user disabled")
+	}
+	return &user, nil
+}
+
+// GetSubscriptionNodes 获取用户订阅中的节点和节点组
+// 节点来源: 用户拥有的节点 + 套餐授权的节点 (套餐未绑定节点时为所有共享节点)，管理员为全部节点
+func (s *Service) GetSubscriptionNodes(user *model.User) ([]gost.SubscriptionNode, []gost.SubscriptionGroup, error) {
+	isAdmin := user.Role == "admin"
+	planActive := user.PlanID != nil && (user.PlanExpireAt == nil || user.PlanExpireAt.After(time.Now()))
+
+	var nodes []model.Node
+	query := s.db.Order("id asc")
+	if !isAdmin {
+		var planNodeIDs []uint
+		if planActive {
+			planNodeIDs, _ = s.GetPlanResourceIDs(*user.PlanID, "node")
+		}
+		switch {
+		case planActive && len(planNodeIDs) == 0:
+			query = query.Where("owner_id = ? OR shared_auth = ?", user.ID, true)
+		case len(planNodeIDs) > 0:
+			query = query.Where("owner_id = ? OR id IN ?", user.ID, planNodeIDs)
+		default:
+			query = query.Where("owner_id = ?", user.ID)
+		}
+	}
+	if err := query.Find(&nodes).Error; err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]gost.SubscriptionNode, 0, len(nodes))
+	included := make(map[uint]bool, len(nodes))
+	for i := range nodes {
+		node := &nodes[i]
+		sn := gost.SubscriptionNode{
+			Node:     node,
+			Username: node.ProxyUser,
+			Password: node.ProxyPass,
+		}
+		if node.SharedAuth && !gost.SharedAuthSupported(node.Protocol) {
+			// ss/ssu 只有节点级密码，不能按用户区分: 只下发给所有者与管理员，也不为其创建凭据
+			if !isAdmin && (node.OwnerID == nil || *node.OwnerID != user.ID) {
+				continue
+			}
+		} else if node.SharedAuth {
+			cred, err := s.getSubscriptionCredential(user.ID, node.ID)
+			if err != nil {
+				continue
+			}
+			sn.Username, sn.Password = cred.Username, cred.Password
+		}
+		result = append(result, sn)
+		included[node.ID] = true
+	}
+
+	// 节点组: 用户拥有的 + 套餐授权的
+	var groups []model.NodeGroup
+	groupQuery := s.db.Order("id asc")
+	if !isAdmin {
+		var planGroupIDs []uint
+		if planActive {
+			planGroupIDs, _ = s.GetPlanResourceIDs(*user.PlanID, "node_group")
+		}
+		if len(planGroupIDs) > 0 {
+			groupQuery = groupQuery.Where("owner_id = ? OR id IN ?", user.ID, planGroupIDs)
+		} else {
+			groupQuery = groupQuery.Where("owner_id = ?", user.ID)
+		}
+	}
+	if err := groupQuery.Find(&groups).Error; err != nil {
+		return nil, nil, err
+	}
+
+	subGroups := make([]gost.SubscriptionGroup, 0, len(groups))
+	for _, g := range groups {
+		members, err := s.ListNodeGroupMembers(g.ID)
+		if err != nil {
+			continue
+		}
+		sg := gost.SubscriptionGroup{Name: g.Name, Strategy: g.Strategy}
+		for _, m := range members {
+			if m.Enabled && included[m.NodeID] {
+				sg.NodeIDs = append(sg.NodeIDs, m.NodeID)
+			}
+		}
+		if len(sg.NodeIDs) > 0 {
+			subGroups = append(subGroups, sg)
+		}
+	}
+
+	return result, subGroups, nil
+}
+
+// getSubscriptionCredential 获取用户在共享节点上使用的凭据，没有通用凭据时自动创建
+func (s *Service) getSubscriptionCredential(userID, nodeID uint) (*model.ProxyCredential, error) {
+	var cred model.ProxyCredential
+	// 优先使用限定该节点的凭据，其次使用通用凭据
+	err := s.db.Where("user_id = ? AND enabled = ? AND (node_id = ? OR node_id IS NULL)", userID, true, nodeID).
+		Order("node_id desc").First(&cred).Error
+	if err == nil {
+		return &cred, nil
+	}
+
+	cred = model.ProxyCredential{
+		UserID:  userID,
+		Name:    "订阅",
+		Enabled: true,
+	}
+	if err := s.CreateProxyCredential(&cred); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
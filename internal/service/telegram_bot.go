@@ -0,0 +1,641 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"github.com/AliceNetworks/gost-panel/internal/notify"
+	"gorm.io/gorm"
+)
+
+const (
+	telegramPollTimeout  = 25 * time.Second // getUpdates 长轮询等待时间
+	telegramIdleInterval = 30 * time.Second // 未配置 Token 时的检查间隔
+	telegramRetryDelay   = 10 * time.Second
+	telegramLinkCodeTTL  = 10 * time.Minute
+	telegramMaxSilence   = 7 * 24 * time.Hour
+	telegramListLimit    = 50
+)
+
+// TelegramBot 交互式 Telegram 机器人
+// 通过 getUpdates 长轮询接收命令，命令按绑定的面板账户鉴权并写入操作日志
+type TelegramBot struct {
+	svc    *Service
+	client *http.Client
+
+	mu          sync.Mutex
+	linkCodes   map[string]telegramLinkCode // 绑定码 -> 面板用户
+	token       string                      // 当前使用的 Bot Token
+	botUsername string
+	offset      int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type telegramLinkCode struct {
+	UserID    uint
+	ExpiresAt time.Time
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Chat struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"` // private/group/supergroup/channel
+	} `json:"chat"`
+	From *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Text string `json:"text"`
+}
+
+// NewTelegramBot 创建 Telegram 机器人 (Token 从系统配置读取，可随时修改)
+func NewTelegramBot(svc *Service) *TelegramBot {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TelegramBot{
+		svc:       svc,
+		client:    &http.Client{Timeout: telegramPollTimeout + 10*time.Second},
+		linkCodes: make(map[string]telegramLinkCode),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start 启动长轮询
+func (b *TelegramBot) Start() {
+	b.wg.Add(1)
+	go b.run()
+}
+
+// Stop 停止长轮询
+func (b *TelegramBot) Stop() {
+	b.cancel()
+	b.wg.Wait()
+}
+
+func (b *TelegramBot) run() {
+	defer b.wg.Done()
+
+	for {
+		token := b.svc.GetSiteConfig(model.ConfigTelegramBotToken)
+		if token == "" {
+			if !b.sleep(telegramIdleInterval) {
+				return
+			}
+			continue
+		}
+
+		b.mu.Lock()
+		if token != b.token {
+			b.token = token
+			b.botUsername = ""
+			b.offset = 0
+		}
+		offset := b.offset
+		b.mu.Unlock()
+
+		updates, err := b.getUpdates(token, offset)
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			log.Printf("[TelegramBot] getUpdates failed: %v", err)
+			if !b.sleep(telegramRetryDelay) {
+				return
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			b.mu.Lock()
+			b.offset = update.UpdateID + 1
+			b.mu.Unlock()
+			if update.Message != nil && update.Message.From != nil && strings.HasPrefix(update.Message.Text, "/") {
+				b.handleMessage(token, update.Message)
+			}
+		}
+	}
+}
+
+// sleep 等待一段时间，机器人停止时返回 false
+func (b *TelegramBot) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
+
+// ==================== Telegram Bot API ====================
+
+func (b *TelegramBot) call(token, method string, params url.Values, result interface{}) error {
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/%s", token, method)
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("invalid response (status %d)", resp.StatusCode)
+	}
+	if !body.OK {
+		return fmt.Errorf("telegram API error: %s", body.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(body.Result, result)
+	}
+	return nil
+}
+
+func (b *TelegramBot) getUpdates(token string, offset int64) ([]telegramUpdate, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.FormatInt(offset, 10))
+	params.Set("timeout", strconv.Itoa(int(telegramPollTimeout.Seconds())))
+	params.Set("allowed_updates", `["message"]`)
+
+	var updates []telegramUpdate
+	err := b.call(token, "getUpdates", params, &updates)
+	return updates, err
+}
+
+func (b *TelegramBot) reply(token string, chatID int64, text string) {
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	params.Set("text", text)
+	params.Set("disable_web_page_preview", "true")
+	if err := b.call(token, "sendMessage", params, nil); err != nil {
+		log.Printf("[TelegramBot] sendMessage failed: %v", err)
+	}
+}
+
+// BotUsername 返回机器人用户名 (用于生成绑定链接)，未配置或获取失败时返回空
+func (b *TelegramBot) BotUsername() string {
+	token := b.svc.GetSiteConfig(model.ConfigTelegramBotToken)
+	if token == "" {
+		return ""
+	}
+
+	b.mu.Lock()
+	if token == b.token && b.botUsername != "" {
+		name := b.botUsername
+		b.mu.Unlock()
+		return name
+	}
+	b.mu.Unlock()
+
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := b.call(token, "getMe", url.Values{}, &me); err != nil {
+		log.Printf("[TelegramBot] getMe failed: %v", err)
+		return ""
+	}
+
+	b.mu.Lock()
+	if token == b.token {
+		b.botUsername = me.Username
+	}
+	b.mu.Unlock()
+	return me.Username
+}
+
+// ==================== 账户绑定 ====================
+
+// CreateLinkCode 为面板用户生成一次性绑定码 (同一用户的旧绑定码失效)
+func (b *TelegramBot) CreateLinkCode(userID uint) string {
+	raw := make([]byte, 4)
+	rand.Read(raw)
+	code := strings.ToUpper(hex.EncodeToString(raw))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for c, link := range b.linkCodes {
+		if link.UserID == userID || now.After(link.ExpiresAt) {
+			delete(b.linkCodes, c)
+		}
+	}
+	b.linkCodes[code] = telegramLinkCode{UserID: userID, ExpiresAt: now.Add(telegramLinkCodeTTL)}
+	return code
+}
+
+// consumeLinkCode 使用绑定码，返回对应的面板用户
+func (b *TelegramBot) consumeLinkCode(code string) (uint, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	link, ok := b.linkCodes[strings.ToUpper(code)]
+	if !ok {
+		return 0, false
+	}
+	delete(b.linkCodes, strings.ToUpper(code))
+	if time.Now().After(link.ExpiresAt) {
+		return 0, false
+	}
+	return link.UserID, true
+}
+
+// LinkTelegram 绑定 Telegram 账户 (一个 Telegram 账户只能绑定一个面板用户)
+func (s *Service) LinkTelegram(userID uint, telegramID int64, telegramUsername string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("telegram_id = ? AND id <> ?", telegramID, userID).
+			Updates(map[string]interface{}{"telegram_id": 0, "telegram_username": ""}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ?", userID).
+			Updates(map[string]interface{}{"telegram_id": telegramID, "telegram_username": telegramUsername}).Error
+	})
+}
+
+// UnlinkTelegram 解除 Telegram 绑定
+func (s *Service) UnlinkTelegram(userID uint) error {
+	return s.db.Model(&model.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"telegram_id": 0, "telegram_username": ""}).Error
+}
+
+// GetTelegramBot 获取 Telegram 机器人
+func (s *Service) GetTelegramBot() *TelegramBot {
+	return s.telegramBot
+}
+
+// ==================== 命令处理 ====================
+
+const telegramHelp = `可用命令:
+/status - 总览
+/nodes - 节点列表
+/node <名称> - 节点详情
+/traffic - 最近 24 小时流量
+/ack <事件ID> - 确认告警事件
+/silence <节点> <时长> - 静默节点告警，例如 /silence hk-1 1h
+/sync <节点> - 同步节点配置
+/link <绑定码> - 绑定面板账户`
+
+func (b *TelegramBot) handleMessage(token string, msg *telegramMessage) {
+	fields := strings.Fields(msg.Text)
+	// 群组中命令可能带 @机器人 后缀
+	cmd := strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	args := fields[1:]
+	chatID := msg.Chat.ID
+
+	switch cmd {
+	case "/start", "/link":
+		if len(args) == 1 {
+			b.reply(token, chatID, b.link(msg, args[0]))
+		} else {
+			b.reply(token, chatID, "请在面板「账户设置 → Telegram」中获取绑定码，然后发送 /link <绑定码>\n\n"+telegramHelp)
+		}
+		return
+	case "/help":
+		b.reply(token, chatID, telegramHelp)
+		return
+	}
+
+	// 查询结果可能包含敏感信息，只在私聊中响应
+	if msg.Chat.Type != "private" {
+		b.reply(token, chatID, "请在与机器人的私聊中使用该命令")
+		return
+	}
+
+	var user model.User
+	if err := b.svc.db.Where("telegram_id = ? AND telegram_id <> 0", msg.From.ID).First(&user).Error; err != nil {
+		b.reply(token, chatID, "未绑定面板账户。请在面板「账户设置 → Telegram」中获取绑定码，然后发送 /link <绑定码>")
+		return
+	}
+	if !user.Enabled {
+		b.reply(token, chatID, "账户已被禁用")
+		return
+	}
+
+	var text string
+	switch cmd {
+	case "/status":
+		text = b.cmdStatus(&user)
+	case "/nodes":
+		text = b.cmdNodes(&user)
+	case "/node":
+		text = b.cmdNode(&user, args)
+	case "/traffic":
+		text = b.cmdTraffic(&user)
+	case "/ack":
+		text = b.cmdAck(&user, args)
+	case "/silence":
+		text = b.cmdSilence(&user, args)
+	case "/sync":
+		text = b.cmdSync(&user, args)
+	default:
+		text = "未知命令\n\n" + telegramHelp
+	}
+	b.reply(token, chatID, text)
+}
+
+func (b *TelegramBot) link(msg *telegramMessage, code string) string {
+	userID, ok := b.consumeLinkCode(code)
+	if !ok {
+		return "绑定码无效或已过期，请在面板中重新获取"
+	}
+	user, err := b.svc.GetUser(userID)
+	if err != nil {
+		return "面板账户不存在"
+	}
+	if err := b.svc.LinkTelegram(userID, msg.From.ID, msg.From.Username); err != nil {
+		return "绑定失败: " + err.Error()
+	}
+	b.logAction(user, "link", "telegram", userID, map[string]interface{}{"telegram_id": msg.From.ID}, "success")
+	return fmt.Sprintf("已绑定面板账户 %s\n\n%s", user.Username, telegramHelp)
+}
+
+// logAction 写入操作日志 (来源标记为 telegram)
+func (b *TelegramBot) logAction(user *model.User, action, resource string, resourceID uint, detail map[string]interface{}, status string) {
+	data, _ := json.Marshal(detail)
+	b.svc.LogOperation(user.ID, user.Username, action, resource, resourceID, string(data), "telegram", "telegram-bot", status)
+}
+
+// denied 记录无权限的操作
+func (b *TelegramBot) denied(user *model.User, action, resource string, resourceID uint) string {
+	b.logAction(user, action, resource, resourceID, map[string]interface{}{"error": "permission denied"}, "failed")
+	return "没有权限执行该操作"
+}
+
+func isTelegramAdmin(user *model.User) bool {
+	return user.Role == "admin"
+}
+
+// findNode 按名称或 ID 查找用户可访问的节点
+func (b *TelegramBot) findNode(user *model.User, key string) (*model.Node, error) {
+	query := b.svc.db.Where("name = ?", key)
+	if id, err := strconv.ParseUint(key, 10, 32); err == nil {
+		query = b.svc.db.Where("name = ? OR id = ?", key, id)
+	}
+	if !isTelegramAdmin(user) {
+		query = query.Where("owner_id = ? OR owner_id IS NULL", user.ID)
+	}
+	var node model.Node
+	if err := query.First(&node).Error; err != nil {
+		return nil, fmt.Errorf("节点 %s 不存在", key)
+	}
+	return &node, nil
+}
+
+func (b *TelegramBot) cmdStatus(user *model.User) string {
+	nodes, _ := b.svc.ListNodesByOwner(user.ID, isTelegramAdmin(user))
+	online, connections := 0, 0
+	var trafficIn, trafficOut int64
+	for _, node := range nodes {
+		if node.Status == "online" {
+			online++
+		}
+		connections += node.Connections
+		trafficIn += node.TrafficIn
+		trafficOut += node.TrafficOut
+	}
+
+	lines := []string{
+		fmt.Sprintf("节点: %d/%d 在线", online, len(nodes)),
+		fmt.Sprintf("连接数: %d", connections),
+		fmt.Sprintf("累计流量: ↓%s ↑%s", notify.FormatBytes(trafficIn), notify.FormatBytes(trafficOut)),
+	}
+	if isTelegramAdmin(user) {
+		var firing, acked int64
+		b.svc.db.Model(&model.AlertIncident{}).Where("status = ?", notify.IncidentFiring).Count(&firing)
+		b.svc.db.Model(&model.AlertIncident{}).Where("status = ?", notify.IncidentAcknowledged).Count(&acked)
+		lines = append(lines, fmt.Sprintf("告警事件: %d 触发中, %d 已确认", firing, acked))
+	}
+
+	b.logAction(user, "view", "telegram", 0, map[string]interface{}{"command": "status"}, "success")
+	return strings.Join(lines, "\n")
+}
+
+func (b *TelegramBot) cmdNodes(user *model.User) string {
+	nodes, _ := b.svc.ListNodesByOwner(user.ID, isTelegramAdmin(user))
+	if len(nodes) == 0 {
+		return "没有可访问的节点"
+	}
+
+	lines := make([]string, 0, len(nodes)+1)
+	for i, node := range nodes {
+		if i == telegramListLimit {
+			lines = append(lines, fmt.Sprintf("... 共 %d 个节点", len(nodes)))
+			break
+		}
+		mark := "🔴"
+		if node.Status == "online" {
+			mark = "🟢"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s) 连接 %d", mark, node.Name, node.Host, node.Connections))
+	}
+
+	b.logAction(user, "view", "node", 0, map[string]interface{}{"command": "nodes"}, "success")
+	return strings.Join(lines, "\n")
+}
+
+func (b *TelegramBot) cmdNode(user *model.User, args []string) string {
+	if len(args) != 1 {
+		return "用法: /node <名称>"
+	}
+	node, err := b.findNode(user, args[0])
+	if err != nil {
+		return err.Error()
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s (#%d)", node.Name, node.ID),
+		fmt.Sprintf("状态: %s", node.Status),
+		fmt.Sprintf("地址: %s:%d", node.Host, node.Port),
+		fmt.Sprintf("协议: %s/%s", node.Protocol, node.Transport),
+		fmt.Sprintf("连接数: %d", node.Connections),
+		fmt.Sprintf("流量: ↓%s ↑%s", notify.FormatBytes(node.TrafficIn), notify.FormatBytes(node.TrafficOut)),
+	}
+	if node.TrafficQuota > 0 {
+		lines = append(lines, fmt.Sprintf("配额: %s / %s", notify.FormatBytes(node.QuotaUsed), notify.FormatBytes(node.TrafficQuota)))
+	}
+	if !node.LastSeen.IsZero() {
+		lines = append(lines, "最后心跳: "+node.LastSeen.Format("2006-01-02 15:04:05"))
+	}
+
+	var incidents []model.AlertIncident
+	b.svc.db.Where("target_type = ? AND target_id = ? AND status IN ?", "node", node.ID,
+		[]string{notify.IncidentFiring, notify.IncidentAcknowledged}).Order("id desc").Limit(10).Find(&incidents)
+	for _, incident := range incidents {
+		lines = append(lines, fmt.Sprintf("⚠️ #%d %s [%s]", incident.ID, incident.RuleName, incident.Status))
+	}
+
+	b.logAction(user, "view", "node", node.ID, map[string]interface{}{"command": "node"}, "success")
+	return strings.Join(lines, "\n")
+}
+
+func (b *TelegramBot) cmdTraffic(user *model.User) string {
+	nodes, _ := b.svc.ListNodesByOwner(user.ID, isTelegramAdmin(user))
+	since := time.Now().Add(-24 * time.Hour)
+
+	type nodeTraffic struct {
+		name    string
+		in, out int64
+	}
+	var stats []nodeTraffic
+	var totalIn, totalOut int64
+	for _, node := range nodes {
+		// 流量为累计值，24 小时用量 = 当前值 - 24 小时内最早的记录
+		var first model.TrafficHistory
+		if err := b.svc.db.Where("node_id = ? AND recorded_at >= ?", node.ID, since).
+			Order("recorded_at asc").First(&first).Error; err != nil {
+			continue
+		}
+		in, out := node.TrafficIn-first.TrafficIn, node.TrafficOut-first.TrafficOut
+		if in < 0 || out < 0 { // 计数器被重置
+			in, out = node.TrafficIn, node.TrafficOut
+		}
+		stats = append(stats, nodeTraffic{node.Name, in, out})
+		totalIn += in
+		totalOut += out
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].in+stats[i].out > stats[j].in+stats[j].out })
+
+	lines := []string{fmt.Sprintf("最近 24 小时: ↓%s ↑%s", notify.FormatBytes(totalIn), notify.FormatBytes(totalOut))}
+	for i, st := range stats {
+		if i == 10 {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s ↓%s ↑%s", i+1, st.name, notify.FormatBytes(st.in), notify.FormatBytes(st.out)))
+	}
+
+	b.logAction(user, "view", "traffic", 0, map[string]interface{}{"command": "traffic"}, "success")
+	return strings.Join(lines, "\n")
+}
+
+func (b *TelegramBot) cmdAck(user *model.User, args []string) string {
+	if len(args) != 1 {
+		return "用法: /ack <事件ID>"
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 32)
+	if err != nil {
+		return "事件 ID 无效"
+	}
+	if !isTelegramAdmin(user) {
+		return b.denied(user, "ack", "alert_incident", uint(id))
+	}
+
+	detail := map[string]interface{}{"via": "telegram"}
+	if err := b.svc.alertService.AckIncident(uint(id), user.Username); err != nil {
+		detail["error"] = err.Error()
+		b.logAction(user, "ack", "alert_incident", uint(id), detail, "failed")
+		return "确认失败: " + err.Error()
+	}
+	b.logAction(user, "ack", "alert_incident", uint(id), detail, "success")
+	return fmt.Sprintf("告警事件 #%d 已确认", id)
+}
+
+func (b *TelegramBot) cmdSilence(user *model.User, args []string) string {
+	if len(args) < 1 || len(args) > 2 {
+		return "用法: /silence <节点> <时长>，例如 /silence hk-1 1h"
+	}
+	node, err := b.findNode(user, args[0])
+	if err != nil {
+		return err.Error()
+	}
+	if !isTelegramAdmin(user) {
+		return b.denied(user, "create", "alert_silence", node.ID)
+	}
+
+	duration := time.Hour
+	if len(args) == 2 {
+		if duration, err = parseSilenceDuration(args[1]); err != nil {
+			return err.Error()
+		}
+	}
+
+	now := time.Now()
+	endsAt := now.Add(duration)
+	silence := &model.AlertSilence{
+		Name:      "Telegram: " + node.Name,
+		Reason:    "通过 Telegram 机器人创建",
+		MatchType: notify.SilenceMatchNode,
+		MatchID:   node.ID,
+		StartsAt:  &now,
+		EndsAt:    &endsAt,
+		Enabled:   true,
+		CreatedBy: user.Username,
+	}
+	if err := b.svc.alertService.CreateSilence(silence); err != nil {
+		b.logAction(user, "create", "alert_silence", 0, map[string]interface{}{"node": node.Name, "error": err.Error()}, "failed")
+		return "静默失败: " + err.Error()
+	}
+
+	b.logAction(user, "create", "alert_silence", silence.ID, map[string]interface{}{
+		"via": "telegram", "node": node.Name, "duration": duration.String(),
+	}, "success")
+	return fmt.Sprintf("节点 %s 的告警已静默至 %s", node.Name, endsAt.Format("2006-01-02 15:04"))
+}
+
+// parseSilenceDuration 解析静默时长，支持 30m/1h/2d 等
+func parseSilenceDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if strings.HasSuffix(s, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(s, "d"))
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < time.Minute || d > telegramMaxSilence {
+		return 0, errors.New("时长无效，范围 1m - 7d，例如 30m、1h、2d")
+	}
+	return d, nil
+}
+
+func (b *TelegramBot) cmdSync(user *model.User, args []string) string {
+	if len(args) != 1 {
+		return "用法: /sync <节点>"
+	}
+	node, err := b.findNode(user, args[0])
+	if err != nil {
+		return err.Error()
+	}
+	// 只读用户不能同步；普通用户只能同步自己的节点
+	if user.Role == "viewer" || (!isTelegramAdmin(user) && (node.OwnerID == nil || *node.OwnerID != user.ID)) {
+		return b.denied(user, "sync", "node", node.ID)
+	}
+
+	if err := b.svc.TouchNode(node.ID); err != nil {
+		b.logAction(user, "sync", "node", node.ID, map[string]interface{}{"via": "telegram", "error": err.Error()}, "failed")
+		return "同步失败: " + err.Error()
+	}
+	b.logAction(user, "sync", "node", node.ID, map[string]interface{}{"via": "telegram"}, "success")
+
+	if node.Status != "online" {
+		return fmt.Sprintf("节点 %s 当前离线，Agent 上线后将自动加载最新配置", node.Name)
+	}
+	return fmt.Sprintf("节点 %s 配置已更新，Agent 将在下次心跳时自动同步（最多 30 秒）", node.Name)
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 隧道多出口 ====================
+
+// tunnelExitStrategies 出口列表支持的选择策略 (与节点组一致)
+var tunnelExitStrategies = map[string]bool{"fifo": true, "round": true, "random": true, "hash": true}
+
+// parseIDList 解析逗号分隔的 ID 列表 (去重并保持顺序)
+func parseIDList(raw string) []uint {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, idStr := range strings.Split(raw, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32)
+		if err != nil || id == 0 || seen[uint(id)] {
+			continue
+		}
+		seen[uint(id)] = true
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+func formatIDList(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// resolveTunnelExits 解析隧道的出口节点: 出口节点组 > 出口列表 > 单一出口节点
+// 在线节点排在前面，其次按优先级 (组成员优先级 / 列表顺序)，fifo 策略据此故障转移；路径探测无法到达目标的出口排在最后
+func (s *Service) resolveTunnelExits(tunnel *model.Tunnel) []model.TunnelNode {
+	var exits []model.TunnelNode
+	switch {
+	case tunnel.ExitGroupID != nil:
+		if tunnel.ExitGroup == nil {
+			if group, err := s.GetNodeGroup(*tunnel.ExitGroupID); err == nil {
+				tunnel.ExitGroup = group
+			}
+		}
+		exits = s.groupTunnelNodes(*tunnel.ExitGroupID)
+
+	case tunnel.ExitNodeIDs != "":
+		ids := parseIDList(tunnel.ExitNodeIDs)
+		var nodes []model.Node
+		s.db.Where("id IN ?", ids).Find(&nodes)
+		byID := make(map[uint]*model.Node, len(nodes))
+		for i := range nodes {
+			byID[nodes[i].ID] = &nodes[i]
+		}
+		for i, id := range ids {
+			if node, ok := byID[id]; ok {
+				exits = append(exits, model.TunnelNode{Node: node, Weight: 1, Priority: i + 1})
+			}
+		}
+
+	default:
+		if tunnel.ExitNode == nil && tunnel.ExitNodeID != 0 {
+			if node, err := s.GetNode(tunnel.ExitNodeID); err == nil {
+				tunnel.ExitNode = node
+			}
+		}
+		if tunnel.ExitNode != nil {
+			exits = append(exits, model.TunnelNode{Node: tunnel.ExitNode, Weight: 1, Priority: 1})
+		}
+	}
+
+	return s.applyProbeHealth(tunnel, sortTunnelNodes(exits))
+}
+
+// groupTunnelNodes 节点组中启用的成员 (不含被健康检查自动停用的成员，权重为有效权重)
+func (s *Service) groupTunnelNodes(groupID uint) []model.TunnelNode {
+	var nodes []model.TunnelNode
+	members, _ := s.GetNodeGroupMembersWithNodes(groupID)
+	for _, m := range members {
+		if !m.Member.Active() {
+			continue
+		}
+		nodes = append(nodes, model.TunnelNode{Node: m.Node, Weight: m.Member.CurrentWeight(), Priority: m.Member.Priority})
+	}
+	return nodes
+}
+
+// sortTunnelNodes 填充节点信息，在线节点排在前面，其次按优先级
+func sortTunnelNodes(nodes []model.TunnelNode) []model.TunnelNode {
+	for i := range nodes {
+		nodes[i].NodeID = nodes[i].Node.ID
+		nodes[i].Name = nodes[i].Node.Name
+		nodes[i].Status = nodes[i].Node.Status
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		onlineI, onlineJ := nodes[i].Status == "online", nodes[j].Status == "online"
+		if onlineI != onlineJ {
+			return onlineI
+		}
+		return nodes[i].Priority < nodes[j].Priority
+	})
+	return nodes
+}
+
+// prepareTunnels 解析出口节点与中继跳点，计算同步状态并填充探测状态
+func (s *Service) prepareTunnels(tunnels ...*model.Tunnel) {
+	for _, tunnel := range tunnels {
+		tunnel.Exits = s.resolveTunnelExits(tunnel)
+		tunnel.Hops = s.resolveTunnelHops(tunnel.ID)
+	}
+	fillTunnelSyncStatus(tunnels...)
+	s.fillTunnelProbes(tunnels...)
+}
+
+// NormalizeTunnelExits 校验并规范化隧道的出口设置，ExitNodeID 保持为首选出口
+func (s *Service) NormalizeTunnelExits(tunnel *model.Tunnel) error {
+	if tunnel.ExitGroupID != nil && *tunnel.ExitGroupID == 0 {
+		tunnel.ExitGroupID = nil
+	}
+	if tunnel.ExitStrategy == "" {
+		tunnel.ExitStrategy = "fifo"
+	}
+	if !tunnelExitStrategies[tunnel.ExitStrategy] {
+		return fmt.Errorf("不支持的出口选择策略: %s", tunnel.ExitStrategy)
+	}
+
+	if tunnel.ExitGroupID != nil {
+		if _, err := s.GetNodeGroup(*tunnel.ExitGroupID); err != nil {
+			return errors.New("出口节点组不存在")
+		}
+		tunnel.ExitGroup = nil
+		tunnel.ExitNodeIDs = ""
+		exits := s.resolveTunnelExits(tunnel)
+		if len(exits) == 0 {
+			return errors.New("出口节点组没有启用的成员")
+		}
+		tunnel.ExitNodeID = exits[0].NodeID
+		return nil
+	}
+
+	ids := parseIDList(tunnel.ExitNodeIDs)
+	if len(ids) == 0 && tunnel.ExitNodeID != 0 {
+		ids = []uint{tunnel.ExitNodeID}
+	}
+	if len(ids) == 0 {
+		return errors.New("请选择出口节点")
+	}
+	var count int64
+	s.db.Model(&model.Node{}).Where("id IN ?", ids).Count(&count)
+	if int(count) != len(ids) {
+		return errors.New("出口节点不存在")
+	}
+
+	tunnel.ExitNodeID = ids[0]
+	tunnel.ExitNode = nil
+	tunnel.ExitNodeIDs = ""
+	if len(ids) > 1 {
+		tunnel.ExitNodeIDs = formatIDList(ids)
+	}
+	return nil
+}
+
+// TunnelExitNodeIDs 隧道当前的全部出口节点 ID
+func (s *Service) TunnelExitNodeIDs(tunnel *model.Tunnel) []uint {
+	exits := tunnel.Exits
+	if exits == nil {
+		exits = s.resolveTunnelExits(tunnel)
+	}
+	ids := make([]uint, len(exits))
+	for i, exit := range exits {
+		ids[i] = exit.NodeID
+	}
+	return ids
+}
+
+// ParseTunnelClientID 从出口中继的客户端标识 (tunnel-{id}) 解析隧道 ID
+func ParseTunnelClientID(clientID string) uint {
+	var id uint
+	if n, _ := fmt.Sscanf(clientID, "tunnel-%d", &id); n == 1 {
+		return id
+	}
+	return 0
+}
+
+// RecordTunnelExitTraffic 记录出口中继上报的隧道流量 (增量)，有流量或活动连接的出口即为当前承载流量的出口
+// 只接受该隧道出口节点的上报
+func (s *Service) RecordTunnelExitTraffic(tunnelID, nodeID uint, trafficIn, trafficOut, currentConns int64) error {
+	if trafficIn <= 0 && trafficOut <= 0 && currentConns <= 0 {
+		return nil
+	}
+	var tunnel model.Tunnel
+	if err := s.db.First(&tunnel, tunnelID).Error; err != nil {
+		return err
+	}
+	if !s.isTunnelExit(&tunnel, nodeID) {
+		return errors.New("node is not an exit of this tunnel")
+	}
+	return s.db.Model(&model.Tunnel{}).Where("id = ?", tunnelID).UpdateColumns(map[string]interface{}{
+		"exit_traffic_in":  gorm.Expr("exit_traffic_in + ?", trafficIn),
+		"exit_traffic_out": gorm.Expr("exit_traffic_out + ?", trafficOut),
+		"active_exit_id":   nodeID,
+		"active_exit_at":   time.Now(),
+	}).Error
+}
+
+// isTunnelExit 节点是否为隧道的出口 (节点组出口包含被健康检查暂时停用的成员)
+func (s *Service) isTunnelExit(tunnel *model.Tunnel, nodeID uint) bool {
+	switch {
+	case tunnel.ExitGroupID != nil:
+		var count int64
+		s.db.Model(&model.NodeGroupMember{}).Where("group_id = ? AND node_id = ?", *tunnel.ExitGroupID, nodeID).Count(&count)
+		return count > 0
+	case tunnel.ExitNodeIDs != "":
+		for _, id := range parseIDList(tunnel.ExitNodeIDs) {
+			if id == nodeID {
+				return true
+			}
+		}
+		return false
+	default:
+		return tunnel.ExitNodeID == nodeID
+	}
+}
+
+// markGroupTunnelsPending 节点组成员或策略变更后，标记使用该组作为出口或中继的隧道所在节点待同步
+func (s *Service) markGroupTunnelsPending(groupID uint) {
+	var tunnels []model.Tunnel
+	s.db.Where("enabled = ?", true).
+		Where("exit_group_id = ? OR id IN (?)", groupID,
+			s.db.Model(&model.TunnelHop{}).Select("tunnel_id").Where("group_id = ?", groupID)).
+		Find(&tunnels)
+	for i := range tunnels {
+		s.markTunnelNodesPending(&tunnels[i])
+	}
+}
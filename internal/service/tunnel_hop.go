@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/AliceNetworks/gost-panel/internal/gost"
+	"github.com/AliceNetworks/gost-panel/internal/model"
+	"gorm.io/gorm"
+)
+
+// ==================== 隧道中继跳点 ====================
+
+// maxTunnelHops 单条隧道最多的中继跳点数
+const maxTunnelHops = 5
+
+// resolveTunnelHops 按顺序加载隧道的中继跳点并解析各跳的节点
+func (s *Service) resolveTunnelHops(tunnelID uint) []model.TunnelHop {
+	hops := []model.TunnelHop{}
+	if tunnelID == 0 {
+		return hops
+	}
+	s.db.Where("tunnel_id = ?", tunnelID).Order("hop_order ASC, id ASC").Find(&hops)
+	for i := range hops {
+		s.resolveHopNodes(&hops[i])
+	}
+	return hops
+}
+
+// resolveHopNodes 解析跳点的中继节点 (节点组取启用的成员)
+func (s *Service) resolveHopNodes(hop *model.TunnelHop) {
+	var nodes []model.TunnelNode
+	if hop.GroupID != nil {
+		hop.Group, _ = s.GetNodeGroup(*hop.GroupID)
+		nodes = s.groupTunnelNodes(*hop.GroupID)
+	} else if node, err := s.GetNode(hop.NodeID); err == nil {
+		nodes = append(nodes, model.TunnelNode{Node: node, Weight: 1, Priority: 1})
+	}
+	hop.Nodes = sortTunnelNodes(nodes)
+}
+
+// tunnelHopNodeIDs 隧道全部中继节点 ID (未加载跳点时从数据库加载)
+func (s *Service) tunnelHopNodeIDs(tunnel *model.Tunnel) []uint {
+	hops := tunnel.Hops
+	if hops == nil {
+		hops = s.resolveTunnelHops(tunnel.ID)
+	}
+	var ids []uint
+	for _, hop := range hops {
+		for _, node := range hop.Nodes {
+			ids = append(ids, node.NodeID)
+		}
+	}
+	return ids
+}
+
+// NormalizeTunnelHops 校验隧道的中继跳点: 每跳为单个节点或节点组，
+// 中继节点不能是入口节点，也不能在多个跳点中重复出现 (同一端口只能承载一跳)，跳点端口不能被节点上的其他资源占用
+func (s *Service) NormalizeTunnelHops(tunnel *model.Tunnel) error {
+	if len(tunnel.Hops) > maxTunnelHops {
+		return fmt.Errorf("中继跳点最多 %d 个", maxTunnelHops)
+	}
+
+	seen := map[uint]bool{tunnel.EntryNodeID: true}
+	for i := range tunnel.Hops {
+		hop := &tunnel.Hops[i]
+		hop.ID = 0
+		hop.TunnelID = tunnel.ID
+		hop.HopOrder = i
+		hop.Group = nil
+		if hop.GroupID != nil && *hop.GroupID == 0 {
+			hop.GroupID = nil
+		}
+		if hop.Port < 0 || hop.Port > 65535 {
+			return fmt.Errorf("第 %d 跳端口无效", i+1)
+		}
+
+		if hop.GroupID != nil {
+			hop.NodeID = 0
+			if _, err := s.GetNodeGroup(*hop.GroupID); err != nil {
+				return fmt.Errorf("第 %d 跳节点组不存在", i+1)
+			}
+		} else if hop.NodeID == 0 {
+			return fmt.Errorf("第 %d 跳未选择中继节点", i+1)
+		}
+
+		s.resolveHopNodes(hop)
+		if len(hop.Nodes) == 0 {
+			return fmt.Errorf("第 %d 跳没有可用的中继节点", i+1)
+		}
+		for _, node := range hop.Nodes {
+			if seen[node.NodeID] {
+				return fmt.Errorf("中继节点 %s 与入口或其他跳点重复", node.Name)
+			}
+			seen[node.NodeID] = true
+			if err := s.CheckNodePorts(node.NodeID, []int{gost.TunnelHopPort(tunnel, hop)}, portResourceTunnel, tunnel.ID, tunnel.OwnerID); err != nil {
+				return fmt.Errorf("中继节点 %s: %v", node.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// replaceTunnelHops 在事务中整体替换隧道的中继跳点
+func replaceTunnelHops(tx *gorm.DB, tunnelID uint, hops []model.TunnelHop) error {
+	if err := tx.Where("tunnel_id = ?", tunnelID).Delete(&model.TunnelHop{}).Error; err != nil {
+		return err
+	}
+	for i := range hops {
+		hops[i].ID = 0
+		hops[i].TunnelID = tunnelID
+		hops[i].HopOrder = i
+		if err := tx.Create(&hops[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveMissingHopNodes 解析尚未解析节点的跳点 (分配中继端口需要各跳的节点)
+func (s *Service) resolveMissingHopNodes(hops []model.TunnelHop) {
+	for i := range hops {
+		if hops[i].Nodes == nil {
+			s.resolveHopNodes(&hops[i])
+		}
+	}
+}
+
+// groupHopTunnels 以节点组作为中继跳点的隧道 (含解析后的跳点)，joining 为即将加入该组的节点
+func (s *Service) groupHopTunnels(groupID uint, joining uint) []model.Tunnel {
+	var tunnels []model.Tunnel
+	s.db.Where("id IN (?)", s.db.Model(&model.TunnelHop{}).Select("tunnel_id").Where("group_id = ?", groupID)).Find(&tunnels)
+	for i := range tunnels {
+		tunnels[i].Hops = s.resolveTunnelHops(tunnels[i].ID)
+		if joining == 0 {
+			continue
+		}
+		for j := range tunnels[i].Hops {
+			hop := &tunnels[i].Hops[j]
+			if hop.GroupID != nil && *hop.GroupID == groupID {
+				hop.Nodes = append(hop.Nodes, model.TunnelNode{NodeID: joining})
+			}
+		}
+	}
+	return tunnels
+}
+
+// allocateTunnelPorts 在事务中重新分配隧道的入口与中继端口
+func allocateTunnelPorts(tx *gorm.DB, tunnels []model.Tunnel) error {
+	for i := range tunnels {
+		tunnel := &tunnels[i]
+		if err := allocatePorts(tx, portResourceTunnel, tunnel.ID, tunnel.Name, tunnel.OwnerID, tunnelPortClaims(tunnel)); err != nil {
+			return fmt.Errorf("隧道「%s」的中继端口冲突: %v", tunnel.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetTunnelsByRelayNode 获取经过指定中继节点的所有隧道 (含解析后的出口与跳点)
+func (s *Service) GetTunnelsByRelayNode(nodeID uint) ([]model.Tunnel, error) {
+	var candidates []model.Tunnel
+	err := s.db.Preload("EntryNode").Preload("ExitNode").Preload("ExitGroup").
+		Where("enabled = ?", true).
+		Where("id IN (?)", s.db.Model(&model.TunnelHop{}).Select("tunnel_id").
+			Where("node_id = ? OR group_id IN (?)", nodeID,
+				s.db.Model(&model.NodeGroupMember{}).Select("group_id").Where("node_id = ?", nodeID))).
+		Order("id ASC").Find(&candidates).Error
+
+	var tunnels []model.Tunnel
+	for i := range candidates {
+		tunnel := candidates[i]
+		tunnel.Hops = s.resolveTunnelHops(tunnel.ID)
+		if tunnelHopIndex(&tunnel, nodeID) < 0 {
+			continue
+		}
+		tunnel.Exits = s.resolveTunnelExits(&tunnel)
+		tunnels = append(tunnels, tunnel)
+	}
+	return tunnels, err
+}
+
+// tunnelHopIndex 节点在隧道中所处的跳点序号，不是中继节点时返回 -1
+func tunnelHopIndex(tunnel *model.Tunnel, nodeID uint) int {
+	for i, hop := range tunnel.Hops {
+		for _, node := range hop.Nodes {
+			if node.NodeID == nodeID {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// tunnelRelayConfigs 生成本节点作为中继承载的隧道跳点配置
+func (s *Service) tunnelRelayConfigs(node *model.Node) []map[string]interface{} {
+	var configs []map[string]interface{}
+	tunnels, _ := s.GetTunnelsByRelayNode(node.ID)
+	generator := gost.NewConfigGenerator()
+	for i := range tunnels {
+		index := tunnelHopIndex(&tunnels[i], node.ID)
+		if config := generator.GenerateTunnelRelayConfig(&tunnels[i], index, node); config != nil {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
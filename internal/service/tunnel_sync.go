@@ -0,0 +1,118 @@
+package service
+
+import (
+	"time"
+
+	"github.com/AliceNetworks/gost-panel/internal/model"
+)
+
+// ==================== 隧道配置同步 ====================
+
+// NodeTunnelConfigs 获取需要编译进节点配置的隧道
+// entries 为以该节点为入口的隧道配置 (按隧道所有者的套餐生成) 与该节点承载的中继跳点配置，
+// exits 为以该节点为出口的隧道
+func (s *Service) NodeTunnelConfigs(node *model.Node) ([]map[string]interface{}, []model.Tunnel) {
+	var entries []map[string]interface{}
+	entryTunnels, _ := s.GetTunnelsByEntryNode(node.ID)
+	for i := range entryTunnels {
+		if config := s.TunnelEntryConfig(&entryTunnels[i]); config != nil {
+			entries = append(entries, config)
+		}
+	}
+	entries = append(entries, s.tunnelRelayConfigs(node)...)
+
+	exits, _ := s.GetTunnelsByExitNode(node.ID)
+	return entries, exits
+}
+
+// SetNodeConfigSynced 记录 Agent 心跳上报的配置是否与面板生成的配置一致
+// 只更新同步字段，不改变 updated_at
+func (s *Service) SetNodeConfigSynced(id uint, synced bool) error {
+	if !synced {
+		return s.MarkNodesConfigPending(id)
+	}
+	return s.db.Model(&model.Node{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"config_status":    model.ConfigSynced,
+		"config_synced_at": time.Now(),
+	}).Error
+}
+
+// MarkNodesConfigPending 标记节点配置已变更，等待 Agent 在下次心跳时重新加载
+func (s *Service) MarkNodesConfigPending(ids ...uint) error {
+	var nodeIDs []uint
+	for _, id := range ids {
+		if id != 0 {
+			nodeIDs = append(nodeIDs, id)
+		}
+	}
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	return s.db.Model(&model.Node{}).Where("id IN ? AND config_status <> ?", nodeIDs, model.ConfigPending).
+		UpdateColumn("config_status", model.ConfigPending).Error
+}
+
+// markTunnelNodesPending 隧道变更后标记入口、中继与全部出口节点待同步
+func (s *Service) markTunnelNodesPending(tunnels ...*model.Tunnel) {
+	var ids []uint
+	for _, tunnel := range tunnels {
+		ids = append(ids, tunnel.EntryNodeID, tunnel.ExitNodeID)
+		ids = append(ids, s.TunnelExitNodeIDs(tunnel)...)
+		ids = append(ids, s.tunnelHopNodeIDs(tunnel)...)
+	}
+	s.MarkNodesConfigPending(ids...)
+}
+
+// fillTunnelSyncStatus 根据入口与出口节点的配置同步状态计算隧道同步状态
+func fillTunnelSyncStatus(tunnels ...*model.Tunnel) {
+	for _, tunnel := range tunnels {
+		tunnel.SyncStatus, tunnel.SyncedAt = tunnelSyncStatus(tunnel)
+	}
+}
+
+// tunnelSyncStatus 入口节点与所有在线的中继、出口节点都已加载最新配置时为已同步
+// 入口离线、某一跳没有在线中继或没有在线出口时为离线 (离线节点上线后会自行同步)
+func tunnelSyncStatus(tunnel *model.Tunnel) (string, *time.Time) {
+	if !tunnel.Enabled {
+		return model.SyncDisabled, nil
+	}
+	entry := tunnel.EntryNode
+	if entry == nil || entry.Status != "online" {
+		return model.SyncOffline, nil
+	}
+
+	nodes := []*model.Node{entry}
+	for _, hop := range tunnel.Hops {
+		online := onlineTunnelNodes(hop.Nodes)
+		if len(online) == 0 {
+			return model.SyncOffline, nil
+		}
+		nodes = append(nodes, online...)
+	}
+	exits := onlineTunnelNodes(tunnel.Exits)
+	if len(exits) == 0 {
+		return model.SyncOffline, nil
+	}
+	nodes = append(nodes, exits...)
+
+	var syncedAt *time.Time
+	for _, node := range nodes {
+		if node.ConfigStatus != model.ConfigSynced {
+			return model.ConfigPending, nil
+		}
+		if node.ConfigSyncedAt != nil && (syncedAt == nil || node.ConfigSyncedAt.After(*syncedAt)) {
+			syncedAt = node.ConfigSyncedAt
+		}
+	}
+	return model.ConfigSynced, syncedAt
+}
+
+func onlineTunnelNodes(tunnelNodes []model.TunnelNode) []*model.Node {
+	var nodes []*model.Node
+	for _, n := range tunnelNodes {
+		if n.Node != nil && n.Node.Status == "online" {
+			nodes = append(nodes, n.Node)
+		}
+	}
+	return nodes
+}
@@ -105,6 +105,11 @@ export const getNodeHealthLogs = (nodeId: number, limit: number = 50) =>
   api.get(`/nodes/${nodeId}/health-logs`, { params: { limit } })
 export const getHealthSummary = () => api.get('/health-summary')
 
+// 节点端口分配
+export const getNodePorts = (id: number) => api.get(`/nodes/${id}/ports`)
+export const getNodeFreePorts = (id: number, count: number = 1) =>
+  api.get(`/nodes/${id}/free-ports`, { params: { count } })
+
 // 节点批量操作
 export const batchEnableNodes = (ids: number[]) => api.post('/nodes/batch-enable', { ids })
 export const batchDisableNodes = (ids: number[]) => api.post('/nodes/batch-disable', { ids })
@@ -121,6 +126,11 @@ export const getClientInstallScript = (id: number, os: string = 'linux') =>
   api.get(`/clients/${id}/install-script`, { params: { os } })
 export const getClientGostConfig = (id: number) => api.get(`/clients/${id}/gost-config`)
 export const getClientProxyURI = (id: number) => api.get(`/clients/${id}/proxy-uri`)
+export const getClientExposures = (id: number) => api.get(`/clients/${id}/exposures`)
+export const createClientExposure = (id: number, data: Record<string, unknown>) => api.post(`/clients/${id}/exposures`, data)
+export const updateClientExposure = (id: number, eid: number, data: Record<string, unknown>) =>
+  api.put(`/clients/${id}/exposures/${eid}`, data)
+export const deleteClientExposure = (id: number, eid: number) => api.delete(`/clients/${id}/exposures/${eid}`)
 
 // 客户端批量操作
 export const batchEnableClients = (ids: number[]) => api.post('/clients/batch-enable', { ids })
@@ -150,6 +160,22 @@ export const verify2FA = (code: string) => api.post('/profile/2fa/verify', { cod
 export const disable2FA = (password: string) => api.post('/profile/2fa/disable', { password })
 export const login2FA = (temp_token: string, code: string) => api.post('/login/2fa', { temp_token, code })
 
+// Telegram 机器人绑定
+export const getTelegramLink = () => api.get('/profile/telegram')
+export const createTelegramLinkCode = () => api.post('/profile/telegram/link')
+export const unlinkTelegram = () => api.delete('/profile/telegram')
+
+// 个人通知渠道与订阅
+export const getPersonalChannels = () => api.get('/profile/notify-channels')
+export const createPersonalChannel = (data: any) => api.post('/profile/notify-channels', data)
+export const updatePersonalChannel = (id: number, data: any) => api.put(`/profile/notify-channels/${id}`, data)
+export const deletePersonalChannel = (id: number) => api.delete(`/profile/notify-channels/${id}`)
+export const testPersonalChannel = (id: number) => api.post(`/profile/notify-channels/${id}/test`)
+export const getNotifyPreferences = () => api.get('/profile/notify-preferences')
+export const updateNotifyPreference = (event: string, data: { enabled: boolean; channel_ids: number[]; thresholds: string }) =>
+  api.put(`/profile/notify-preferences/${event}`, data)
+export const getMyNotifications = (params?: { limit?: number; offset?: number }) => api.get('/profile/notifications', { params })
+
 // 用户注册和验证 (公开接口)
 export const register = (username: string, email: string, password: string) =>
   api.post('/register', { username, email, password })
@@ -182,6 +208,7 @@ export const createNotifyChannel = (data: NotifyChannelCreateRequest) => api.pos
 export const updateNotifyChannel = (id: number, data: NotifyChannelUpdateRequest) => api.put(`/notify-channels/${id}`, data)
 export const deleteNotifyChannel = (id: number) => api.delete(`/notify-channels/${id}`)
 export const testNotifyChannel = (id: number) => api.post(`/notify-channels/${id}/test`)
+export const testNotifyChannelConfig = (data: { type: string; config: Record<string, unknown> }) => api.post('/notify-channels/test', data)
 
 // 告警规则
 export const getAlertRules = () => api.get('/alert-rules')
@@ -189,10 +216,35 @@ export const getAlertRule = (id: number) => api.get(`/alert-rules/${id}`)
 export const createAlertRule = (data: AlertRuleCreateRequest) => api.post('/alert-rules', data)
 export const updateAlertRule = (id: number, data: AlertRuleUpdateRequest) => api.put(`/alert-rules/${id}`, data)
 export const deleteAlertRule = (id: number) => api.delete(`/alert-rules/${id}`)
+export const previewAlertRule = (data: AlertRuleCreateRequest) => api.post('/alert-rules/preview', data)
 
 // 告警日志
-export const getAlertLogs = (params: { limit?: number, offset?: number } = {}) =>
+export const getAlertLogs = (params: { limit?: number, offset?: number, status?: string } = {}) =>
   api.get('/alert-logs', { params })
+export const resendAlertLog = (id: number) => api.post(`/alert-logs/${id}/resend`)
+
+// 告警升级策略 / 值班排班
+export const getEscalationPolicies = () => api.get('/escalation-policies')
+export const createEscalationPolicy = (data: Record<string, unknown>) => api.post('/escalation-policies', data)
+export const updateEscalationPolicy = (id: number, data: Record<string, unknown>) => api.put(`/escalation-policies/${id}`, data)
+export const deleteEscalationPolicy = (id: number) => api.delete(`/escalation-policies/${id}`)
+export const getOnCallSchedules = () => api.get('/oncall-schedules')
+export const createOnCallSchedule = (data: Record<string, unknown>) => api.post('/oncall-schedules', data)
+export const updateOnCallSchedule = (id: number, data: Record<string, unknown>) => api.put(`/oncall-schedules/${id}`, data)
+export const deleteOnCallSchedule = (id: number) => api.delete(`/oncall-schedules/${id}`)
+
+// 摘要报告
+export const getDigestSchedules = () => api.get('/digests')
+export const createDigestSchedule = (data: Record<string, unknown>) => api.post('/digests', data)
+export const updateDigestSchedule = (id: number, data: Record<string, unknown>) => api.put(`/digests/${id}`, data)
+export const deleteDigestSchedule = (id: number) => api.delete(`/digests/${id}`)
+export const sendDigestNow = (id: number) => api.post(`/digests/${id}/send`)
+export const previewDigest = (params: { frequency: string, top_n: number }) => api.get('/digests/preview', { params })
+
+// 告警确认链接 (公开接口，签名认证)
+export type AlertAckParams = { incident: number, expires: number, sig: string }
+export const getAlertAck = (params: AlertAckParams) => api.get('/alert-ack', { params })
+export const ackAlertByLink = (data: AlertAckParams) => api.post('/alert-ack', data)
 
 // 操作日志
 export const getOperationLogs = (params: { limit?: number, offset?: number, action?: string, resource?: string } = {}) =>
@@ -236,6 +288,15 @@ export const getNodeGroupMembers = (id: number) => api.get(`/node-groups/${id}/m
 export const addNodeGroupMember = (id: number, data: NodeGroupMemberRequest) => api.post(`/node-groups/${id}/members`, data)
 export const removeNodeGroupMember = (groupId: number, memberId: number) => api.delete(`/node-groups/${groupId}/members/${memberId}`)
 export const getNodeGroupConfig = (id: number) => api.get(`/node-groups/${id}/config`)
+export const getNodeGroupHealthEvents = (id: number, limit = 100) => api.get(`/node-groups/${id}/health-events`, { params: { limit } })
+export const getNodeGroupWeightHints = (id: number, from?: number) => api.get(`/node-groups/${id}/weight-hints`, { params: { from } })
+export const applyNodeGroupWeightHints = (id: number, from?: number) => api.post(`/node-groups/${id}/weight-hints/apply`, null, { params: { from } })
+
+// 节点间延迟矩阵
+export const getLatencyMatrix = () => api.get('/latency/matrix')
+export const setLatencyNodes = (nodeIds: number[]) => api.put('/latency/nodes', { node_ids: nodeIds })
+export const getLatencyHistory = (source: number, target: number, hours = 24) => api.get('/latency/history', { params: { source, target, hours } })
+export const getRelayPath = (entry: number, exit: number, maxRelays?: number) => api.get('/latency/path', { params: { entry, exit, max_relays: maxRelays } })
 
 // 代理链/隧道转发
 export const getProxyChains = () => api.get('/proxy-chains')
@@ -248,6 +309,7 @@ export const addProxyChainHop = (id: number, data: ProxyChainHopRequest) => api.
 export const updateProxyChainHop = (chainId: number, hopId: number, data: ProxyChainHopRequest) => api.put(`/proxy-chains/${chainId}/hops/${hopId}`, data)
 export const removeProxyChainHop = (chainId: number, hopId: number) => api.delete(`/proxy-chains/${chainId}/hops/${hopId}`)
 export const getProxyChainConfig = (id: number) => api.get(`/proxy-chains/${id}/config`)
+export const getProxyChainProbes = (id: number, hours = 24) => api.get(`/proxy-chains/${id}/probes`, { params: { hours } })
 
 // 隧道转发 (入口-出口模式)
 export const getTunnels = () => api.get('/tunnels')
@@ -258,6 +320,8 @@ export const deleteTunnel = (id: number) => api.delete(`/tunnels/${id}`)
 export const syncTunnel = (id: number) => api.post(`/tunnels/${id}/sync`)
 export const getTunnelEntryConfig = (id: number) => api.get(`/tunnels/${id}/entry-config`)
 export const getTunnelExitConfig = (id: number) => api.get(`/tunnels/${id}/exit-config`)
+export const getTunnelRelayConfig = (id: number) => api.get(`/tunnels/${id}/relay-config`)
+export const getTunnelProbes = (id: number, hours = 24) => api.get(`/tunnels/${id}/probes`, { params: { hours } })
 
 // 预配置模板
 export const getTemplates = (category?: string) => {
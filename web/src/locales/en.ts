@@ -49,9 +49,11 @@ export default {
     portForwards: 'Port Forwards',
     nodeGroups: 'Load Balancing',
     tunnels: 'Tunnels',
+    myNotify: 'My Notifications',
     rules: 'Rules',
     users: 'Users',
     notify: 'Alerts',
+    latency: 'Latency Matrix',
     operationLogs: 'Audit Logs',
     plans: 'Plans',
     settings: 'Settings',
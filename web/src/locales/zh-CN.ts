@@ -49,9 +49,11 @@ export default {
     portForwards: '端口转发',
     nodeGroups: '负载均衡',
     tunnels: '隧道转发',
+    myNotify: '我的通知',
     rules: '规则管理',
     users: '用户管理',
     notify: '告警通知',
+    latency: '延迟矩阵',
     operationLogs: '操作日志',
     plans: '套餐管理',
     settings: '网站设置',
@@ -27,6 +27,11 @@ const router = createRouter({
       name: 'verify-email',
       component: () => import('../views/VerifyEmail.vue'),
     },
+    {
+      path: '/alert-ack',
+      name: 'alert-ack',
+      component: () => import('../views/AlertAck.vue'),
+    },
     {
       path: '/forgot-password',
       name: 'forgot-password',
@@ -91,6 +96,11 @@ const router = createRouter({
           name: 'settings',
           component: () => import('../views/Settings.vue'),
         },
+        {
+          path: 'latency',
+          name: 'latency',
+          component: () => import('../views/LatencyMatrix.vue'),
+        },
         {
           path: 'operation-logs',
           name: 'operation-logs',
@@ -106,6 +116,11 @@ const router = createRouter({
           name: 'rules',
           component: () => import('../views/Rules.vue'),
         },
+        {
+          path: 'my-notify',
+          name: 'my-notify',
+          component: () => import('../views/MyNotify.vue'),
+        },
         {
           path: 'change-password',
           name: 'change-password',
@@ -117,10 +132,10 @@ const router = createRouter({
 })
 
 // 公开页面（不需要登录）
-const publicPages = ['login', 'register', 'verify-email', 'forgot-password', 'reset-password']
+const publicPages = ['login', 'register', 'verify-email', 'forgot-password', 'reset-password', 'alert-ack']
 
 // 管理员专用页面
-const adminOnlyPages = ['users', 'settings', 'notify', 'operation-logs', 'plans', 'rules']
+const adminOnlyPages = ['users', 'settings', 'notify', 'operation-logs', 'plans', 'rules', 'latency']
 
 // 路由守卫
 router.beforeEach((to, _from, next) => {
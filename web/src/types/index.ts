@@ -80,6 +80,7 @@ export interface Node extends BaseEntity {
   // 所有者
   owner_id?: number
   last_seen?: string
+  latency_probe?: boolean
   tags?: Tag[]
 }
 
@@ -192,9 +193,103 @@ export interface NodeGroupMember {
   weight: number
   priority?: number
   enabled?: boolean
+  effective_weight?: number
+  auto_disabled?: boolean
+  health_state?: '' | 'healthy' | 'degraded' | 'down'
+  health_fail_rate?: number
+  health_latency?: number
+  health_changed_at?: string
   node?: Node
 }
 
+// 节点组成员健康调整记录
+export interface NodeGroupHealthEvent {
+  id: number
+  group_id: number
+  member_id: number
+  node_id: number
+  node_name: string
+  from_state: string
+  to_state: string
+  from_weight: number
+  to_weight: number
+  fail_rate: number
+  latency: number
+  reason: string
+  created_at: string
+}
+
+// 路径探测状态
+export interface PathProbeSummary {
+  status: 'ok' | 'degraded' | 'failed'
+  latency: number
+  loss: number
+  error?: string
+  checked_at: string
+}
+
+export interface PathProbeResult {
+  id: number
+  resource_type: string
+  resource_id: number
+  node_id: number
+  scope: 'path' | 'target' | 'hop'
+  type: string
+  target: string
+  attempts: number
+  loss: number
+  latency: number
+  error_msg: string
+  checked_at: string
+}
+
+// 节点间延迟矩阵
+export interface LatencyCell {
+  source_id: number
+  target_id: number
+  latency: number
+  loss: number
+  measured_at: string
+}
+
+export interface LatencyMatrix {
+  interval: number
+  nodes: { id: number, name: string, status: string }[]
+  cells: LatencyCell[]
+}
+
+export interface NodeLatency {
+  id: number
+  source_id: number
+  target_id: number
+  latency: number
+  loss: number
+  measured_at: string
+}
+
+export interface RelayPath {
+  hops: { node_id: number, name: string, latency: number }[]
+  latency: number
+  loss: number
+}
+
+export interface RelayPathSuggestion {
+  direct: RelayPath | null
+  best: RelayPath | null
+  alternatives: RelayPath[]
+}
+
+export interface NodeWeightHint {
+  member_id: number
+  node_id: number
+  name: string
+  weight: number
+  latency: number
+  loss: number
+  samples: number
+  suggested_weight: number
+}
+
 // 代理链
 export interface ProxyChain extends BaseEntity {
   name: string
@@ -203,6 +298,10 @@ export interface ProxyChain extends BaseEntity {
   listen_type: string
   target_addr?: string
   enabled: boolean
+  probe_type?: string
+  probe_target?: string
+  probe_interval?: number
+  probe?: PathProbeSummary
   owner_id?: number
   hops?: ProxyChainHop[]
 }
@@ -231,6 +330,10 @@ export interface Tunnel extends BaseEntity {
   traffic_quota?: number
   quota_reset_day?: number
   speed_limit?: number
+  probe_type?: string
+  probe_target?: string
+  probe_interval?: number
+  probe?: PathProbeSummary
   owner_id?: number
   entry_node?: Node
   exit_node?: Node
@@ -316,6 +419,10 @@ export interface Plan extends BaseEntity {
   description?: string
   traffic_quota: number
   speed_limit: number
+  conn_rate_limit?: number
+  max_conns?: number
+  quota_action?: 'notify' | 'throttle' | 'block'
+  quota_throttle?: number
   duration: number
   max_nodes: number
   max_clients: number